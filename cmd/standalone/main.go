@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/controller/standalone"
+	"github.com/docker/stacks/pkg/types"
 )
 
 var cmdServer = cli.Command{
@@ -28,6 +29,51 @@ var cmdServer = cli.Command{
 			Usage: "Port on which to expose the stacks API (default: 2375)",
 			Value: 2375,
 		},
+		cli.StringFlag{
+			Name:  "admission-config",
+			Usage: "Path to a JSON file listing the admission webhooks of stacks",
+		},
+		cli.StringFlag{
+			Name:  "policy",
+			Usage: "Path to a YAML file of policy rules evaluated over stacks",
+		},
+		cli.BoolFlag{
+			Name:  "maintenance",
+			Usage: "Enable the stored maintenance switch, pausing reconciliation of all stacks",
+		},
+		cli.StringFlag{
+			Name:  "secret-key-file",
+			Usage: "Path to a file holding the base64 encoded AES key stored secrets are encrypted with",
+		},
+		cli.StringFlag{
+			Name:  "federation",
+			Usage: "Path to a JSON file listing the remote stacks controllers of federated clusters",
+		},
+		cli.StringFlag{
+			Name:  "default-orchestrator",
+			Usage: "Orchestrator of the stacks created without one (default: swarm)",
+			Value: types.OrchestratorSwarm,
+		},
+		cli.StringFlag{
+			Name:  "kubernetes",
+			Usage: "URL of the Kubernetes API server stacks of the kubernetes orchestrator are deployed on, or in-cluster",
+		},
+		cli.StringFlag{
+			Name:  "kubernetes-ca-file",
+			Usage: "Path to the CA certificate of the Kubernetes API server",
+		},
+		cli.StringFlag{
+			Name:  "kubernetes-token-file",
+			Usage: "Path to a file holding the bearer token of the Kubernetes API server",
+		},
+		cli.StringFlag{
+			Name:  "containers-data-dir",
+			Usage: "Directory of the secret and config files of the stacks of the none orchestrator, which it enables",
+		},
+		cli.StringFlag{
+			Name:  "migrations-file",
+			Usage: "Path to a JSON file keeping the migrations of stacks across restarts",
+		},
 	},
 }
 
@@ -38,6 +84,21 @@ func RunStandaloneServer(c *cli.Context) error {
 		Debug:            c.Bool("debug"),
 		DockerSocketPath: c.String("docker-socket"),
 		ServerPort:       c.Int("port"),
+
+		AdmissionConfigPath: c.String("admission-config"),
+		PolicyPath:          c.String("policy"),
+		Maintenance:         c.Bool("maintenance"),
+		SecretKeyPath:       c.String("secret-key-file"),
+
+		FederationConfigPath: c.String("federation"),
+		DefaultOrchestrator:  types.OrchestratorChoice(c.String("default-orchestrator")),
+
+		KubernetesAPI:       c.String("kubernetes"),
+		KubernetesCAPath:    c.String("kubernetes-ca-file"),
+		KubernetesTokenPath: c.String("kubernetes-token-file"),
+
+		ContainersDataDir: c.String("containers-data-dir"),
+		MigrationsPath:    c.String("migrations-file"),
 	})
 }
 
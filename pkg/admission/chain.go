@@ -0,0 +1,138 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// maxResponseSize bounds the size of the responses read from webhooks
+const maxResponseSize = 1 << 20
+
+// Chain submits StackSpecs to a sequence of admission webhooks.
+type Chain struct {
+	mutating   []*webhook
+	validating []*webhook
+}
+
+// NewChain creates a new Chain from the configuration of its webhooks.
+func NewChain(webhooks []Webhook) (*Chain, error) {
+	c := &Chain{}
+	names := map[string]bool{}
+	for _, config := range webhooks {
+		w, err := newWebhook(config)
+		if err != nil {
+			return nil, err
+		}
+		if names[w.Name] {
+			return nil, fmt.Errorf("duplicate admission webhook %s", w.Name)
+		}
+		names[w.Name] = true
+
+		if w.Type == WebhookMutating {
+			c.mutating = append(c.mutating, w)
+		} else {
+			c.validating = append(c.validating, w)
+		}
+	}
+	return c, nil
+}
+
+// Admit submits request to the webhooks of the chain, and returns the
+// StackSpec to store, as patched by the mutating webhooks. Requests denied
+// by a webhook fail with an errdefs.Forbidden error, requests rejected
+// because a webhook failed with an errdefs.Unavailable error.
+func (c *Chain) Admit(request types.StackAdmissionRequest) (types.StackSpec, error) {
+	for _, w := range c.mutating {
+		response, err := w.call(request)
+		if err == nil && response.Allowed && len(response.Patch) > 0 {
+			patchType := response.PatchType
+			if patchType == "" {
+				patchType = types.StackPatchJSON
+			}
+			var patched types.StackSpec
+			patched, err = patch.Apply(request.Spec, patchType, response.Patch)
+			if err == nil {
+				request.Spec = patched
+			} else {
+				err = fmt.Errorf("invalid patch: %s", err)
+			}
+		}
+		if err := w.check(response, err); err != nil {
+			return types.StackSpec{}, err
+		}
+	}
+
+	for _, w := range c.validating {
+		response, err := w.call(request)
+		if err := w.check(response, err); err != nil {
+			return types.StackSpec{}, err
+		}
+	}
+
+	return request.Spec, nil
+}
+
+// check turns the outcome of a call of the webhook into the error of the
+// admission, according to its failure policy. Failed calls never return a
+// denial.
+func (w *webhook) check(response types.StackAdmissionResponse, err error) error {
+	if err != nil {
+		if w.FailurePolicy == FailurePolicyIgnore {
+			logrus.Warnf("ignoring failure of admission webhook %s: %s", w.Name, err)
+			return nil
+		}
+		return errdefs.Unavailable(fmt.Errorf("admission webhook %s failed: %s", w.Name, err))
+	}
+	if !response.Allowed {
+		reason := response.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return errdefs.Forbidden(fmt.Errorf("admission webhook %s denied the request: %s", w.Name, reason))
+	}
+	return nil
+}
+
+// call posts request to the webhook and returns its response. The data of
+// secrets is not sent to webhooks.
+func (w *webhook) call(request types.StackAdmissionRequest) (types.StackAdmissionResponse, error) {
+	request.Spec = secrets.Redact(request.Spec)
+	if request.OldSpec != nil {
+		oldSpec := secrets.Redact(*request.OldSpec)
+		request.OldSpec = &oldSpec
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return types.StackAdmissionResponse{}, err
+	}
+
+	resp, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return types.StackAdmissionResponse{}, err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.StackAdmissionResponse{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var response types.StackAdmissionResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return types.StackAdmissionResponse{}, fmt.Errorf("invalid response: %s", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,200 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// newWebhookServer starts a webhook answering every request with the
+// response returned by handler. The handler runs outside of the goroutine
+// of the test, so failures are reported with assert rather than require.
+func newWebhookServer(t *testing.T, handler func(types.StackAdmissionRequest) types.StackAdmissionResponse) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request types.StackAdmissionRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&request)) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(handler(request)))
+	}))
+}
+
+func getTestRequest() types.StackAdmissionRequest {
+	return types.StackAdmissionRequest{
+		Operation: types.StackAdmissionCreate,
+		Spec: types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+		},
+	}
+}
+
+func TestValidatingWebhook(t *testing.T) {
+	require := require.New(t)
+	server := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		if _, ok := request.Spec.Annotations.Labels["team"]; !ok {
+			return types.StackAdmissionResponse{Reason: "stacks must have a team label"}
+		}
+		return types.StackAdmissionResponse{Allowed: true}
+	})
+	defer server.Close()
+
+	chain, err := NewChain([]Webhook{
+		{Name: "labels", Type: WebhookValidating, URL: server.URL},
+	})
+	require.NoError(err)
+
+	request := getTestRequest()
+	_, err = chain.Admit(request)
+	require.Error(err)
+	require.True(errdefs.IsForbidden(err))
+	require.Contains(err.Error(), "stacks must have a team label")
+
+	request.Spec.Annotations.Labels = map[string]string{"team": "platform"}
+	spec, err := chain.Admit(request)
+	require.NoError(err)
+	require.Equal(request.Spec, spec)
+}
+
+func TestMutatingWebhooks(t *testing.T) {
+	require := require.New(t)
+	jsonPatch := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		return types.StackAdmissionResponse{
+			Allowed: true,
+			Patch:   json.RawMessage(`[{"op": "add", "path": "/Annotations/Labels", "value": {"team": "platform"}}]`),
+		}
+	})
+	defer jsonPatch.Close()
+	mergePatch := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		return types.StackAdmissionResponse{
+			Allowed:   true,
+			PatchType: types.StackPatchMerge,
+			Patch:     json.RawMessage(`{"Collection": "` + request.Spec.Annotations.Labels["team"] + `"}`),
+		}
+	})
+	defer mergePatch.Close()
+	var validated types.StackSpec
+	validating := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		validated = request.Spec
+		return types.StackAdmissionResponse{Allowed: true}
+	})
+	defer validating.Close()
+
+	// Validating webhooks are called after the mutating ones, whatever
+	// their order in the configuration
+	chain, err := NewChain([]Webhook{
+		{Name: "validate", Type: WebhookValidating, URL: validating.URL},
+		{Name: "label", Type: WebhookMutating, URL: jsonPatch.URL},
+		{Name: "collection", Type: WebhookMutating, URL: mergePatch.URL},
+	})
+	require.NoError(err)
+
+	spec, err := chain.Admit(getTestRequest())
+	require.NoError(err)
+	require.Equal(map[string]string{"team": "platform"}, spec.Annotations.Labels)
+	require.Equal("platform", spec.Collection)
+	require.Equal(spec, validated)
+}
+
+func TestWebhookSecretsRedacted(t *testing.T) {
+	require := require.New(t)
+	var received types.StackSpec
+	server := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		received = request.Spec
+		return types.StackAdmissionResponse{
+			Allowed: true,
+			Patch:   json.RawMessage(`[{"op": "add", "path": "/Secrets/password/Labels", "value": {"team": "platform"}}]`),
+		}
+	})
+	defer server.Close()
+
+	chain, err := NewChain([]Webhook{
+		{Name: "label", Type: WebhookMutating, URL: server.URL},
+	})
+	require.NoError(err)
+
+	request := getTestRequest()
+	request.Spec.Secrets = []swarm.SecretSpec{{
+		Annotations: swarm.Annotations{Name: "password"},
+		Data:        []byte("hunter2"),
+	}}
+	spec, err := chain.Admit(request)
+	require.NoError(err)
+	require.Empty(received.Secrets[0].Data)
+	require.Contains(received.Secrets[0].Annotations.Labels, types.StackSecretHashLabel)
+
+	// The patches apply to the secrets with their data
+	require.Equal([]byte("hunter2"), spec.Secrets[0].Data)
+	require.Equal(map[string]string{"team": "platform"}, spec.Secrets[0].Annotations.Labels)
+}
+
+func TestWebhookFailurePolicy(t *testing.T) {
+	require := require.New(t)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	defer close(done)
+	invalidPatch := newWebhookServer(t, func(request types.StackAdmissionRequest) types.StackAdmissionResponse {
+		return types.StackAdmissionResponse{
+			Allowed: true,
+			Patch:   json.RawMessage(`[{"op": "remove", "path": "/NoSuchField"}]`),
+		}
+	})
+	defer invalidPatch.Close()
+
+	for _, tc := range []Webhook{
+		{Name: "broken", Type: WebhookValidating, URL: broken.URL},
+		{Name: "slow", Type: WebhookValidating, URL: slow.URL, TimeoutSeconds: 1},
+		{Name: "patch", Type: WebhookMutating, URL: invalidPatch.URL},
+	} {
+		chain, err := NewChain([]Webhook{tc})
+		require.NoError(err)
+		_, err = chain.Admit(getTestRequest())
+		require.Error(err, tc.Name)
+		require.True(errdefs.IsUnavailable(err), tc.Name)
+
+		tc.FailurePolicy = FailurePolicyIgnore
+		chain, err = NewChain([]Webhook{tc})
+		require.NoError(err)
+		spec, err := chain.Admit(getTestRequest())
+		require.NoError(err, tc.Name)
+		require.Equal(getTestRequest().Spec, spec)
+	}
+}
+
+func TestNewChainInvalid(t *testing.T) {
+	for _, webhooks := range [][]Webhook{
+		{{Type: WebhookValidating, URL: "http://localhost"}},
+		{{Name: "a", Type: "auditing", URL: "http://localhost"}},
+		{{Name: "a", Type: WebhookValidating, URL: "ftp://localhost"}},
+		{{Name: "a", Type: WebhookValidating, URL: "http://localhost", FailurePolicy: "Retry"}},
+		{{Name: "a", Type: WebhookValidating, URL: "https://localhost", CABundle: []byte("garbage")}},
+		{
+			{Name: "a", Type: WebhookValidating, URL: "http://localhost"},
+			{Name: "a", Type: WebhookMutating, URL: "http://localhost"},
+		},
+	} {
+		_, err := NewChain(webhooks)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,12 @@
+package admission
+
+// The `admission` package submits the StackSpecs of created and updated
+// stacks to external HTTP(S) webhooks, so that organization policies can be
+// enforced without changes to the controller.
+//
+// Mutating webhooks are called first, in the order they are configured, and
+// may return a patch of the StackSpec. Validating webhooks are then called
+// with the final StackSpec, and may deny the request with a reason. Each
+// webhook has its own timeout, and its own failure policy deciding whether
+// the request is rejected or the webhook skipped when it cannot be reached
+// or returns an invalid response.
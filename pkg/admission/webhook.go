@@ -0,0 +1,126 @@
+package admission
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WebhookType is the kind of an admission webhook
+type WebhookType string
+
+const (
+	// WebhookValidating allows or denies requests
+	WebhookValidating WebhookType = "validating"
+
+	// WebhookMutating allows requests, possibly patching their StackSpec,
+	// or denies them
+	WebhookMutating WebhookType = "mutating"
+)
+
+// FailurePolicy decides the outcome of a request when a webhook fails
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail rejects the request. This is the default.
+	FailurePolicyFail FailurePolicy = "Fail"
+
+	// FailurePolicyIgnore skips the webhook
+	FailurePolicyIgnore FailurePolicy = "Ignore"
+)
+
+// defaultTimeout is the timeout of webhooks which do not configure one
+const defaultTimeout = 10 * time.Second
+
+// Webhook is the configuration of an admission webhook
+type Webhook struct {
+	Name string
+	Type WebhookType
+	URL  string
+
+	// CABundle is a PEM encoded bundle of certificate authorities trusted
+	// to sign the certificate of an HTTPS webhook, in place of the system
+	// ones.
+	CABundle []byte `json:",omitempty"`
+
+	TimeoutSeconds int           `json:",omitempty"`
+	FailurePolicy  FailurePolicy `json:",omitempty"`
+}
+
+// LoadConfig reads the list of Webhooks from a JSON file.
+func LoadConfig(path string) ([]Webhook, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var webhooks []Webhook
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return nil, fmt.Errorf("invalid admission configuration %s: %s", path, err)
+	}
+	return webhooks, nil
+}
+
+// webhook is a Webhook ready to be called
+type webhook struct {
+	Webhook
+	client *http.Client
+}
+
+func newWebhook(config Webhook) (*webhook, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("admission webhook has no name")
+	}
+	switch config.Type {
+	case WebhookValidating, WebhookMutating:
+	default:
+		return nil, fmt.Errorf("admission webhook %s has unknown type '%s'", config.Name, config.Type)
+	}
+	switch config.FailurePolicy {
+	case "":
+		config.FailurePolicy = FailurePolicyFail
+	case FailurePolicyFail, FailurePolicyIgnore:
+	default:
+		return nil, fmt.Errorf("admission webhook %s has unknown failure policy '%s'", config.Name, config.FailurePolicy)
+	}
+	if config.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("admission webhook %s has a negative timeout", config.Name)
+	}
+
+	target, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("admission webhook %s has an invalid URL: %s", config.Name, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("admission webhook %s has an unsupported URL scheme '%s'", config.Name, target.Scheme)
+	}
+
+	timeout := defaultTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if len(config.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CABundle) {
+			return nil, fmt.Errorf("admission webhook %s has an invalid CA bundle", config.Name)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: pool,
+		}
+	}
+
+	return &webhook{
+		Webhook: config,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+	}, nil
+}
@@ -0,0 +1,162 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Export returns a StackBundle of stack, whose secrets are carried as
+// options tell. Secrets whose data is not known, as stacks read from a
+// remote Stacks API are redacted, can only be excluded.
+func Export(stack types.Stack, options types.StackExportOptions) (types.StackBundle, error) {
+	mode := options.Secrets
+	if mode == "" {
+		mode = types.StackBundleSecretsExcluded
+	}
+
+	spec := stack.Spec
+	switch mode {
+	case types.StackBundleSecretsExcluded:
+		spec = secrets.Redact(spec)
+	case types.StackBundleSecretsEncrypted:
+		for _, secret := range spec.Secrets {
+			if _, ok := secret.Annotations.Labels[types.StackSecretHashLabel]; ok && len(secret.Data) == 0 {
+				return types.StackBundle{}, errdefs.InvalidParameter(
+					fmt.Errorf("the data of secret %s is redacted, and can only be excluded from the bundle", secret.Annotations.Name))
+			}
+		}
+		cipher, err := newCipher(options.Key)
+		if err != nil {
+			return types.StackBundle{}, err
+		}
+		if spec, err = cipher.Encrypt(spec); err != nil {
+			return types.StackBundle{}, fmt.Errorf("unable to encrypt secrets: %s", err)
+		}
+	default:
+		return types.StackBundle{}, errdefs.InvalidParameter(fmt.Errorf("invalid secrets mode %s", mode))
+	}
+
+	return types.StackBundle{
+		BundleVersion: types.StackBundleVersion,
+		ExportedAt:    time.Now().UTC(),
+		Source: types.StackBundleSource{
+			ID:        stack.ID,
+			Cluster:   stack.Cluster,
+			Version:   stack.Version.Index,
+			CreatedAt: stack.CreatedAt,
+			UpdatedAt: stack.UpdatedAt,
+		},
+		Orchestrator: stack.Orchestrator,
+		Secrets:      mode,
+		Spec:         spec,
+	}, nil
+}
+
+// Import returns the StackSpec and the StackCreateOptions to recreate the
+// stack of bundle with, as options tell.
+func Import(bundle types.StackBundle, options types.StackImportOptions) (types.StackSpec, types.StackCreateOptions, error) {
+	if bundle.BundleVersion != types.StackBundleVersion {
+		return types.StackSpec{}, types.StackCreateOptions{}, errdefs.InvalidParameter(
+			fmt.Errorf("unsupported bundle version '%s', only version %s is supported", bundle.BundleVersion, types.StackBundleVersion))
+	}
+
+	spec := bundle.Spec
+	switch bundle.Secrets {
+	case types.StackBundleSecretsExcluded:
+	case types.StackBundleSecretsEncrypted:
+		cipher, err := newCipher(options.Key)
+		if err != nil {
+			return types.StackSpec{}, types.StackCreateOptions{}, err
+		}
+		if spec, err = cipher.Decrypt(spec); err != nil {
+			return types.StackSpec{}, types.StackCreateOptions{}, errdefs.InvalidParameter(fmt.Errorf("unable to decrypt secrets: %s", err))
+		}
+	default:
+		return types.StackSpec{}, types.StackCreateOptions{}, errdefs.InvalidParameter(fmt.Errorf("invalid secrets mode %s", bundle.Secrets))
+	}
+
+	spec, err := withSecretData(spec, options.Secrets)
+	if err != nil {
+		return types.StackSpec{}, types.StackCreateOptions{}, err
+	}
+	if options.Name != "" {
+		spec.Annotations.Name = options.Name
+	}
+
+	createOptions := types.StackCreateOptions{
+		Orchestrator: bundle.Orchestrator,
+		Cluster:      options.Cluster,
+	}
+	if options.Orchestrator != "" {
+		createOptions.Orchestrator = options.Orchestrator
+	}
+	return spec, createOptions, nil
+}
+
+// withSecretData returns a copy of spec whose secrets have the data given
+// by name in data. Secrets excluded from the bundle must be given.
+func withSecretData(spec types.StackSpec, data map[string][]byte) (types.StackSpec, error) {
+	unknown := make(map[string]bool, len(data))
+	for name := range data {
+		unknown[name] = true
+	}
+
+	secretSpecs := make([]swarm.SecretSpec, len(spec.Secrets))
+	for i, secret := range spec.Secrets {
+		given, ok := data[secret.Annotations.Name]
+		delete(unknown, secret.Annotations.Name)
+		_, excluded := secret.Annotations.Labels[types.StackSecretHashLabel]
+		switch {
+		case ok:
+			secret.Data = given
+		case excluded && len(secret.Data) == 0:
+			return types.StackSpec{}, errdefs.InvalidParameter(
+				fmt.Errorf("secret %s is excluded from the bundle, and its data is not given", secret.Annotations.Name))
+		}
+		if excluded {
+			labels := make(map[string]string, len(secret.Annotations.Labels))
+			for k, v := range secret.Annotations.Labels {
+				if k != types.StackSecretHashLabel {
+					labels[k] = v
+				}
+			}
+			if len(labels) == 0 {
+				labels = nil
+			}
+			secret.Annotations.Labels = labels
+		}
+		secretSpecs[i] = secret
+	}
+
+	if len(unknown) > 0 {
+		names := make([]string, 0, len(unknown))
+		for name := range unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("the bundle has no secrets %s", strings.Join(names, ", ")))
+	}
+	if len(spec.Secrets) > 0 {
+		spec.Secrets = secretSpecs
+	}
+	return spec, nil
+}
+
+func newCipher(key []byte) (*secrets.Cipher, error) {
+	if len(key) == 0 {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("no key is given to encrypt or decrypt the secrets of the bundle with"))
+	}
+	cipher, err := secrets.NewCipher(key)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid bundle key: %s", err))
+	}
+	return cipher, nil
+}
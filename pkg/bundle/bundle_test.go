@@ -0,0 +1,145 @@
+package bundle
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func testStack() types.Stack {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	return types.Stack{
+		ID: "stack1",
+		Meta: swarm.Meta{
+			Version:   swarm.Version{Index: 7},
+			CreatedAt: created,
+			UpdatedAt: created.Add(time.Hour),
+		},
+		Orchestrator: types.OrchestratorSwarm,
+		Spec: types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "web",
+			},
+			Secrets: []swarm.SecretSpec{{
+				Annotations: swarm.Annotations{
+					Name:   "password",
+					Labels: map[string]string{"tier": "front"},
+				},
+				Data: []byte("hunter2"),
+			}},
+		},
+	}
+}
+
+// roundTrip returns bundle as it is read back from its JSON document
+func roundTrip(t *testing.T, bundle types.StackBundle) types.StackBundle {
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	var read types.StackBundle
+	require.NoError(t, json.Unmarshal(data, &read))
+	return read
+}
+
+func TestExportImportExcluded(t *testing.T) {
+	require := require.New(t)
+	stack := testStack()
+
+	bundle, err := Export(stack, types.StackExportOptions{})
+	require.NoError(err)
+	require.Equal(types.StackBundleVersion, bundle.BundleVersion)
+	require.Equal(types.StackBundleSecretsExcluded, bundle.Secrets)
+	require.Equal(types.StackBundleSource{
+		ID:        "stack1",
+		Version:   7,
+		CreatedAt: stack.CreatedAt,
+		UpdatedAt: stack.UpdatedAt,
+	}, bundle.Source)
+	require.Empty(bundle.Spec.Secrets[0].Data)
+	require.Equal(secrets.Hash([]byte("hunter2")), bundle.Spec.Secrets[0].Annotations.Labels[types.StackSecretHashLabel])
+	// The stack is left untouched
+	require.Equal(testStack(), stack)
+
+	// Excluded secrets must be given again
+	bundle = roundTrip(t, bundle)
+	_, _, err = Import(bundle, types.StackImportOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, _, err = Import(bundle, types.StackImportOptions{
+		Secrets: map[string][]byte{"password": []byte("hunter3"), "token": []byte("abc")},
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	spec, options, err := Import(bundle, types.StackImportOptions{
+		Name:    "web-staging",
+		Secrets: map[string][]byte{"password": []byte("hunter3")},
+	})
+	require.NoError(err)
+	require.Equal("web-staging", spec.Annotations.Name)
+	require.Equal([]byte("hunter3"), spec.Secrets[0].Data)
+	require.Equal(map[string]string{"tier": "front"}, spec.Secrets[0].Annotations.Labels)
+	require.Equal(types.StackCreateOptions{Orchestrator: types.OrchestratorSwarm}, options)
+}
+
+func TestExportImportEncrypted(t *testing.T) {
+	require := require.New(t)
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	_, err := Export(testStack(), types.StackExportOptions{Secrets: types.StackBundleSecretsEncrypted})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	bundle, err := Export(testStack(), types.StackExportOptions{Secrets: types.StackBundleSecretsEncrypted, Key: key})
+	require.NoError(err)
+	require.NotContains(string(bundle.Spec.Secrets[0].Data), "hunter2")
+	bundle = roundTrip(t, bundle)
+
+	_, _, err = Import(bundle, types.StackImportOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, _, err = Import(bundle, types.StackImportOptions{Key: []byte("fedcba9876543210fedcba9876543210")})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	spec, options, err := Import(bundle, types.StackImportOptions{
+		Key:          key,
+		Orchestrator: types.OrchestratorKubernetes,
+		Cluster:      "east",
+	})
+	require.NoError(err)
+	require.Equal(testStack().Spec, spec)
+	require.Equal(types.StackCreateOptions{Orchestrator: types.OrchestratorKubernetes, Cluster: "east"}, options)
+
+	// Redacted secrets cannot be encrypted
+	redacted := testStack()
+	redacted.Spec = secrets.Redact(redacted.Spec)
+	_, err = Export(redacted, types.StackExportOptions{Secrets: types.StackBundleSecretsEncrypted, Key: key})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// Secrets in clear are not imported from a bundle marked encrypted
+	plain := roundTrip(t, bundle)
+	plain.Spec.Secrets[0].Data = []byte("hunter2")
+	_, _, err = Import(plain, types.StackImportOptions{Key: key})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// nor from a bundle of secrets in clear
+	plain.Secrets = "included"
+	_, _, err = Import(plain, types.StackImportOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
+func TestImportVersion(t *testing.T) {
+	require := require.New(t)
+
+	bundle, err := Export(testStack(), types.StackExportOptions{})
+	require.NoError(err)
+	bundle.BundleVersion = "2"
+	_, _, err = Import(bundle, types.StackImportOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "unsupported bundle version")
+
+	_, err = Export(testStack(), types.StackExportOptions{Secrets: "included"})
+	require.True(errdefs.IsInvalidParameter(err))
+}
@@ -0,0 +1,17 @@
+package bundle
+
+// The `bundle` package exports stacks as portable StackBundles, and
+// recreates stacks from them, for disaster recovery or to promote a stack
+// from a cluster to another.
+//
+// A StackBundle is a JSON document holding the StackSpec of a stack, the
+// orchestrator it was deployed on, and the ID, version and creation and
+// update times of the exported stack. Its BundleVersion is
+// types.StackBundleVersion: bundles of other versions are not imported.
+//
+// The data of secrets is excluded from bundles by default, and replaced by
+// a types.StackSecretHashLabel: it must then be given again on import. It
+// may also be encrypted with an AES key which is given again on import, but
+// is never carried in clear. Encrypted data is the prefix "stacks:aes-gcm:",
+// followed by a random 12 byte nonce and the AES-GCM sealed data, and the
+// import of an encrypted bundle whose secrets are not all encrypted fails.
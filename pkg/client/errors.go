@@ -7,7 +7,10 @@ import (
 	"net/http"
 
 	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/errdefs"
 	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/types"
 )
 
 // errConnectionFailed implements an error returned when connection failed.
@@ -42,6 +45,9 @@ type notFound interface {
 // IsErrNotFound returns true if the error is a NotFound error, which is returned
 // by the API when some object is not found.
 func IsErrNotFound(err error) bool {
+	if errdefs.IsNotFound(err) {
+		return true
+	}
 	te, ok := err.(notFound)
 	return ok && te.NotFound()
 }
@@ -59,14 +65,36 @@ func (e objectNotFoundError) Error() string {
 	return fmt.Sprintf("Error: No such %s: %s", e.object, e.id)
 }
 
+// wrapResponseError wraps the error of a response so that it may be checked
+// both with the IsErr functions of this package and with errdefs, like the
+// errors of the other implementations of StackAPIClient.
 func wrapResponseError(err error, resp serverResponse, object, id string) error {
 	switch {
 	case err == nil:
 		return nil
 	case resp.statusCode == http.StatusNotFound:
-		return objectNotFoundError{object: object, id: id}
+		if id == "" {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.NotFound(objectNotFoundError{object: object, id: id})
 	case resp.statusCode == http.StatusNotImplemented:
-		return notImplementedError{message: err.Error()}
+		return errdefs.NotImplemented(notImplementedError{message: err.Error()})
+	case resp.statusCode == http.StatusBadRequest:
+		return errdefs.InvalidParameter(err)
+	case resp.statusCode == http.StatusUnauthorized:
+		return errdefs.Unauthorized(err)
+	case resp.statusCode == http.StatusForbidden:
+		return errdefs.Forbidden(err)
+	case resp.statusCode == http.StatusServiceUnavailable:
+		return errdefs.Unavailable(err)
+	case resp.statusCode == http.StatusConflict, resp.statusCode == http.StatusPreconditionFailed:
+		conflict := conflictError{message: err.Error()}
+		if resp.header != nil {
+			if version, err := types.ParseStackETag(resp.header.Get("ETag")); err == nil {
+				conflict.current = &types.Version{Index: version}
+			}
+		}
+		return conflict
 	default:
 		return err
 	}
@@ -120,10 +148,43 @@ func (e notImplementedError) NotImplemented() bool {
 // This is returned by the API when a requested feature has not been
 // implemented.
 func IsErrNotImplemented(err error) bool {
+	if errdefs.IsNotImplemented(err) {
+		return true
+	}
 	te, ok := err.(notImplementedError)
 	return ok && te.NotImplemented()
 }
 
+type conflictError struct {
+	message string
+	current *types.Version
+}
+
+func (e conflictError) Error() string {
+	return e.message
+}
+
+func (e conflictError) Conflict() {}
+
+// IsErrConflict returns true if the error is a Conflict error, which is
+// returned by the API when a stack was changed since the version given in
+// the request.
+func IsErrConflict(err error) bool {
+	_, ok := errors.Cause(err).(conflictError)
+	return ok
+}
+
+// ConflictCurrentVersion returns the version of the stack at the time a
+// Conflict error was returned, if the API reported it. A request may be
+// retried against that version.
+func ConflictCurrentVersion(err error) (types.Version, bool) {
+	conflict, ok := errors.Cause(err).(conflictError)
+	if !ok || conflict.current == nil {
+		return types.Version{}, false
+	}
+	return *conflict.current, true
+}
+
 // NewVersionError returns an error if the APIVersion required
 // if less than the current supported version
 func (cli *Client) NewVersionError(APIrequired, feature string) error {
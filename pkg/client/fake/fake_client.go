@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
 
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/query"
 	"github.com/docker/stacks/pkg/types"
+	"github.com/docker/stacks/pkg/validation"
 )
 
 // StackClient is a fake implementation of the Stacks API.
@@ -44,12 +48,13 @@ func NewStackClient(optsFunc ...StackOptionFunc) *StackClient {
 }
 
 // StackCreate creates a new stack.
-func (c *StackClient) StackCreate(_ context.Context, spec types.StackSpec, _ types.StackCreateOptions) (types.StackCreateResponse, error) {
+func (c *StackClient) StackCreate(_ context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	newStack := types.Stack{
-		ID:   fmt.Sprintf("%d", c.idx),
-		Spec: spec,
+		ID:           fmt.Sprintf("%d", c.idx),
+		Spec:         spec,
+		Orchestrator: options.Orchestrator,
 	}
 	c.idx++
 	c.stacks[newStack.ID] = newStack
@@ -58,6 +63,12 @@ func (c *StackClient) StackCreate(_ context.Context, spec types.StackSpec, _ typ
 	}, nil
 }
 
+// StackValidate validates a StackSpec. References to resources outside
+// of the stack cannot be resolved, as there is no swarm to look them up in.
+func (c *StackClient) StackValidate(_ context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	return validation.NewValidator(nil).Validate(spec), nil
+}
+
 // StackInspect inspects an existing stack.
 func (c *StackClient) StackInspect(_ context.Context, id string) (types.Stack, error) {
 	c.mu.RLock()
@@ -70,8 +81,8 @@ func (c *StackClient) StackInspect(_ context.Context, id string) (types.Stack, e
 	return stack, nil
 }
 
-// StackList lists all stacks.
-func (c *StackClient) StackList(_ context.Context, _ types.StackListOptions) ([]types.Stack, error) {
+// StackList lists the stacks selected by options.
+func (c *StackClient) StackList(_ context.Context, options types.StackListOptions) ([]types.Stack, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -80,7 +91,7 @@ func (c *StackClient) StackList(_ context.Context, _ types.StackListOptions) ([]
 		allStacks = append(allStacks, stack)
 	}
 
-	return allStacks, nil
+	return query.Stacks(allStacks, options)
 }
 
 // StackUpdate updates a stack.
@@ -93,7 +104,93 @@ func (c *StackClient) StackUpdate(_ context.Context, id string, version types.Ve
 	}
 
 	if version.Index != stack.Version.Index {
-		return fmt.Errorf("update out of sequence")
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: stack.Version.Index},
+		}
+	}
+
+	stack.Spec = spec
+	stack.Version.Index++
+	c.stacks[id] = stack
+	return nil
+}
+
+// StackScale sets the number of replicas of services of a stack.
+func (c *StackClient) StackScale(_ context.Context, id string, replicas map[string]uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	spec := stack.Spec
+	spec.Services = append([]swarm.ServiceSpec{}, stack.Spec.Services...)
+	for name, count := range replicas {
+		found := false
+		for i, service := range spec.Services {
+			if service.Annotations.Name != name || service.Mode.Replicated == nil {
+				continue
+			}
+			replicated := *service.Mode.Replicated
+			count := count
+			replicated.Replicas = &count
+			spec.Services[i].Mode.Replicated = &replicated
+			found = true
+		}
+		if !found {
+			return errdefs.NotFound(fmt.Errorf("replicated service %s not found", name))
+		}
+	}
+
+	stack.Spec = spec
+	stack.Version.Index++
+	c.stacks[id] = stack
+	return nil
+}
+
+// StackPause pauses the reconciliation of a stack.
+func (c *StackClient) StackPause(_ context.Context, id string) error {
+	return c.setPaused(id, true)
+}
+
+// StackResume resumes the reconciliation of a stack.
+func (c *StackClient) StackResume(_ context.Context, id string) error {
+	return c.setPaused(id, false)
+}
+
+func (c *StackClient) setPaused(id string, paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+	stack.Paused = paused
+	c.stacks[id] = stack
+	return nil
+}
+
+// StackPatch applies a patch to the spec of a stack.
+func (c *StackClient) StackPatch(_ context.Context, id string, version types.Version, patchType types.StackPatchType, data []byte, _ types.StackUpdateOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stack, ok := c.stacks[id]
+	if !ok {
+		return errdefs.NotFound(fmt.Errorf("stack not found"))
+	}
+
+	if version.Index != 0 && version.Index != stack.Version.Index {
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: stack.Version.Index},
+		}
+	}
+
+	spec, err := patch.Apply(stack.Spec, patchType, data)
+	if err != nil {
+		return err
 	}
 
 	stack.Spec = spec
@@ -109,3 +206,8 @@ func (c *StackClient) StackDelete(_ context.Context, id string) error {
 	delete(c.stacks, id)
 	return nil
 }
+
+// StackMigrate is not implemented, as the StackClient is a single backend.
+func (c *StackClient) StackMigrate(_ context.Context, id string, _ types.StackMigrateOptions) (types.StackMigration, error) {
+	return types.StackMigration{}, types.ErrStackNotMigratable(id)
+}
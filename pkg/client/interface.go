@@ -9,8 +9,14 @@ import (
 // StackAPIClient defines the client interface for managing Stacks
 type StackAPIClient interface {
 	StackCreate(ctx context.Context, stack types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error)
+	StackValidate(ctx context.Context, spec types.StackSpec) (types.StackValidationResult, error)
 	StackInspect(ctx context.Context, id string) (types.Stack, error)
 	StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error)
 	StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error
+	StackScale(ctx context.Context, id string, replicas map[string]uint64) error
+	StackPause(ctx context.Context, id string) error
+	StackResume(ctx context.Context, id string) error
+	StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, patch []byte, options types.StackUpdateOptions) error
 	StackDelete(ctx context.Context, id string) error
+	StackMigrate(ctx context.Context, id string, options types.StackMigrateOptions) (types.StackMigration, error)
 }
@@ -42,6 +42,11 @@ func (cli *Client) post(ctx context.Context, path string, query url.Values, obj
 	return cli.sendRequest(ctx, "POST", path, query, body, headers)
 }
 
+// patch sends an http request to the docker API using the method PATCH with a specific Go context.
+func (cli *Client) patch(ctx context.Context, path string, query url.Values, body io.Reader, headers map[string][]string) (serverResponse, error) {
+	return cli.sendRequest(ctx, "PATCH", path, query, body, headers)
+}
+
 // delete sends an http request to the docker API using the method DELETE.
 func (cli *Client) delete(ctx context.Context, path string, query url.Values, headers map[string][]string) (serverResponse, error) {
 	return cli.sendRequest(ctx, "DELETE", path, query, nil, headers)
@@ -7,7 +7,8 @@ import (
 	"github.com/docker/stacks/pkg/types"
 )
 
-// StackCreate creates a new Stack
+// StackCreate creates a new Stack, on the orchestrator and the cluster of
+// options if it specifies them.
 func (cli *Client) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
 	headers := map[string][]string{
 		"version": {cli.settings.Version},
@@ -18,9 +19,14 @@ func (cli *Client) StackCreate(ctx context.Context, spec types.StackSpec, option
 	}
 
 	var response types.StackCreateResponse
-	resp, err := cli.post(ctx, "/stacks", nil, spec, headers)
+	request := types.StackCreateRequest{
+		StackSpec:    spec,
+		Orchestrator: options.Orchestrator,
+		Cluster:      options.Cluster,
+	}
+	resp, err := cli.post(ctx, "/stacks", nil, request, headers)
 	if err != nil {
-		return response, err
+		return response, wrapResponseError(err, resp, "stack", "")
 	}
 
 	err = json.NewDecoder(resp.body).Decode(&response)
@@ -3,10 +3,14 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"testing"
 
+	"github.com/docker/docker/api/types/swarm"
+
 	"github.com/docker/stacks/pkg/types"
 
 	"gotest.tools/assert"
@@ -38,3 +42,28 @@ func TestCreateStackEmpty(t *testing.T) {
 	_, err = cli.StackCreate(ctx, types.StackSpec{}, types.StackCreateOptions{})
 	assert.NilError(t, err)
 }
+
+func TestCreateStackOrchestrator(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			var request types.StackCreateRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			if request.Annotations.Name != "app" || request.Orchestrator != types.OrchestratorKubernetes {
+				return nil, fmt.Errorf("wrong request - found: %v", request)
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ID": "default.app"}`)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	spec := types.StackSpec{Annotations: swarm.Annotations{Name: "app"}}
+	resp, err := cli.StackCreate(ctx, spec, types.StackCreateOptions{Orchestrator: types.OrchestratorKubernetes})
+	assert.NilError(t, err)
+	assert.Equal(t, "default.app", resp.ID)
+}
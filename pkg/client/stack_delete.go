@@ -13,5 +13,5 @@ func (cli *Client) StackDelete(ctx context.Context, id string) error {
 
 	resp, err := cli.delete(ctx, "/stacks/"+id, nil, headers)
 	ensureReaderClosed(resp)
-	return err
+	return wrapResponseError(err, resp, "stack", id)
 }
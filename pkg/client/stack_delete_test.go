@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/docker/docker/errdefs"
 	"gotest.tools/assert"
 )
 
@@ -22,6 +23,36 @@ func TestStackDeleteServerError(t *testing.T) {
 	assert.ErrorContains(t, err, "Server error")
 }
 
+func TestStackDeleteErrorClasses(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		status int
+		is     func(error) bool
+	}{
+		{http.StatusNotFound, errdefs.IsNotFound},
+		{http.StatusBadRequest, errdefs.IsInvalidParameter},
+		{http.StatusConflict, errdefs.IsConflict},
+		{http.StatusForbidden, errdefs.IsForbidden},
+		{http.StatusNotImplemented, errdefs.IsNotImplemented},
+		{http.StatusServiceUnavailable, errdefs.IsUnavailable},
+	} {
+		s := Settings{
+			Client: newMockClient(errorMock(tc.status, "Error")),
+		}
+		cli, err := NewClientWithSettings(s)
+		assert.NilError(t, err)
+		err = cli.StackDelete(ctx, "dummy")
+		assert.Assert(t, tc.is(err), "status %d: %v", tc.status, err)
+	}
+
+	// Errors are still recognized by the functions of this package
+	cli, err := NewClientWithSettings(Settings{
+		Client: newMockClient(errorMock(http.StatusNotFound, "Not found")),
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, IsErrNotFound(cli.StackDelete(ctx, "dummy")))
+}
+
 func TestStackDeleteEmpty(t *testing.T) {
 	ctx := context.Background()
 	id := "dummy"
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackExport exports a Stack as a StackBundle, from which it can be
+// recreated by StackImport.
+func (cli *Client) StackExport(ctx context.Context, id string, options types.StackExportOptions) (types.StackBundle, error) {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+	if len(options.Key) > 0 {
+		headers[types.StackBundleKeyHeader] = []string{base64.StdEncoding.EncodeToString(options.Key)}
+	}
+
+	query := url.Values{}
+	if options.Secrets != "" {
+		query.Set("secrets", string(options.Secrets))
+	}
+
+	var response types.StackBundle
+	resp, err := cli.get(ctx, "/stacks/"+id+"/export", query, headers)
+	if err != nil {
+		return response, wrapResponseError(err, resp, "stack", id)
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&response)
+
+	ensureReaderClosed(resp)
+	return response, err
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackExportNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusNotFound, "Not found")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackExport(ctx, "dummy", types.StackExportOptions{})
+	assert.Assert(t, IsErrNotFound(err))
+}
+
+func TestStackExport(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/stacks/dummy/export") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			if req.URL.Query().Get("secrets") != "encrypted" {
+				return nil, fmt.Errorf("wrong query - found: %s", req.URL.RawQuery)
+			}
+			if key := req.Header.Get(types.StackBundleKeyHeader); key != "a2V5" {
+				return nil, fmt.Errorf("wrong key - found: %s", key)
+			}
+			b, err := json.Marshal(types.StackBundle{
+				BundleVersion: types.StackBundleVersion,
+				Source:        types.StackBundleSource{ID: "dummy"},
+				Secrets:       types.StackBundleSecretsEncrypted,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	bundle, err := cli.StackExport(ctx, "dummy", types.StackExportOptions{
+		Secrets: types.StackBundleSecretsEncrypted,
+		Key:     []byte("key"),
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, bundle.Source.ID, "dummy")
+	assert.Equal(t, bundle.Secrets, types.StackBundleSecretsEncrypted)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackImport creates a new Stack from a StackBundle.
+func (cli *Client) StackImport(ctx context.Context, bundle types.StackBundle, options types.StackImportOptions) (types.StackCreateResponse, error) {
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+	if len(options.Key) > 0 {
+		headers[types.StackBundleKeyHeader] = []string{base64.StdEncoding.EncodeToString(options.Key)}
+	}
+
+	var response types.StackCreateResponse
+	request := types.StackImportRequest{
+		Bundle:       bundle,
+		Name:         options.Name,
+		Orchestrator: options.Orchestrator,
+		Cluster:      options.Cluster,
+		Secrets:      options.Secrets,
+	}
+	resp, err := cli.post(ctx, "/stacks/import", nil, request, headers)
+	if err != nil {
+		return response, wrapResponseError(err, resp, "stack", "")
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&response)
+
+	ensureReaderClosed(resp)
+	return response, err
+}
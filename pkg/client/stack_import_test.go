@@ -0,0 +1,66 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackImportInvalid(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusBadRequest, "unsupported bundle version")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackImport(ctx, types.StackBundle{}, types.StackImportOptions{})
+	assert.Assert(t, errdefs.IsInvalidParameter(err))
+	assert.ErrorContains(t, err, "unsupported bundle version")
+}
+
+func TestStackImport(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/stacks/import") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			var request types.StackImportRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			if request.Name != "renamed" || request.Bundle.Source.ID != "dummy" {
+				return nil, fmt.Errorf("wrong request - found: %+v", request)
+			}
+			if string(request.Secrets["password"]) != "secret" {
+				return nil, fmt.Errorf("wrong secrets - found: %v", request.Secrets)
+			}
+			b, err := json.Marshal(types.StackCreateResponse{ID: "imported"})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	resp, err := cli.StackImport(ctx, types.StackBundle{Source: types.StackBundleSource{ID: "dummy"}}, types.StackImportOptions{
+		Name:    "renamed",
+		Secrets: map[string][]byte{"password": []byte("secret")},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.ID, "imported")
+}
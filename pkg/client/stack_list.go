@@ -1,9 +1,11 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/url"
+	"strconv"
 
 	"github.com/docker/stacks/pkg/types"
 
@@ -12,6 +14,14 @@ import (
 
 // StackList returns the list of Stacks on the server
 func (cli *Client) StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	list, err := cli.StackListPartial(ctx, options)
+	return list.Items, err
+}
+
+// StackListPartial returns the list of Stacks on the server, along with
+// the warnings of the server about the backends whose stacks could not be
+// listed.
+func (cli *Client) StackListPartial(ctx context.Context, options types.StackListOptions) (types.StackList, error) {
 
 	headers := map[string][]string{
 		"version": {cli.settings.Version},
@@ -21,20 +31,40 @@ func (cli *Client) StackList(ctx context.Context, options types.StackListOptions
 	if options.Filters.Len() > 0 {
 		filterJSON, err := filters.ToJSON(options.Filters)
 		if err != nil {
-			return nil, err
+			return types.StackList{}, err
 		}
 
 		query.Set("filters", filterJSON)
 	}
+	if options.SortBy != "" {
+		query.Set("sort", string(options.SortBy))
+	}
+	if options.Descending {
+		query.Set("order", "desc")
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Cursor != "" {
+		query.Set("cursor", options.Cursor)
+	}
 
-	var response []types.Stack
+	var response types.StackList
 	resp, err := cli.get(ctx, "/stacks", query, headers)
 	if err != nil {
-		return response, err
+		return response, wrapResponseError(err, resp, "stack", "")
 	}
+	defer ensureReaderClosed(resp)
 
-	err = json.NewDecoder(resp.body).Decode(&response)
-
-	ensureReaderClosed(resp)
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.body).Decode(&body); err != nil {
+		return response, err
+	}
+	// Servers without warnings reply with a bare list of stacks
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &response.Items)
+	} else {
+		err = json.Unmarshal(body, &response)
+	}
 	return response, err
 }
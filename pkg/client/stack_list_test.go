@@ -52,3 +52,66 @@ func TestStackListEmpty(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Assert(t, is.Len(res, 0))
 }
+
+func TestStackListOrderAndPage(t *testing.T) {
+	ctx := context.Background()
+	opts := types.StackListOptions{
+		SortBy:     types.StackSortCreated,
+		Descending: true,
+		Limit:      10,
+		Cursor:     "abc",
+	}
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			query := req.URL.Query()
+			expected := map[string]string{
+				"sort":   "created",
+				"order":  "desc",
+				"limit":  "10",
+				"cursor": "abc",
+			}
+			for key, value := range expected {
+				if actual := query.Get(key); actual != value {
+					return nil, fmt.Errorf("%s query parameter: expected %s, found %s", key, value, actual)
+				}
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("[]")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	res, err := cli.StackList(ctx, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(res, 0))
+}
+
+func TestStackListPartialWarnings(t *testing.T) {
+	ctx := context.Background()
+	body := `{"items": [{"ID": "1"}], "warnings": [{"orchestrator": "kubernetes", "message": "unable to list stacks"}]}`
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+
+	list, err := cli.StackListPartial(ctx, types.StackListOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(list.Items, 1))
+	assert.Equal(t, list.Items[0].ID, "1")
+	assert.DeepEqual(t, list.Warnings, []types.StackListWarning{{
+		Orchestrator: types.OrchestratorKubernetes,
+		Message:      "unable to list stacks",
+	}})
+
+	stacks, err := cli.StackList(ctx, types.StackListOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(stacks, 1))
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackMigrate starts the migration of a Stack to another orchestrator. The
+// progress of the migration is reported by the returned StackMigration, and
+// then by the Migration of the Stack.
+func (cli *Client) StackMigrate(ctx context.Context, id string, options types.StackMigrateOptions) (types.StackMigration, error) {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	query.Set("to", string(options.To))
+	if options.CutOver {
+		query.Set("cutover", "1")
+	}
+	if options.Timeout > 0 {
+		query.Set("timeout", options.Timeout.String())
+	}
+
+	var response types.StackMigration
+	resp, err := cli.post(ctx, "/stacks/"+id+"/migrate", query, nil, headers)
+	if err != nil {
+		return response, wrapResponseError(err, resp, "stack", id)
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&response)
+
+	ensureReaderClosed(resp)
+	return response, err
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackMigrateNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusNotFound, "Not found")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackMigrate(ctx, "dummy", types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	assert.Assert(t, IsErrNotFound(err))
+}
+
+func TestStackMigrate(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/stacks/dummy/migrate") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			query := req.URL.Query()
+			if query.Get("to") != "kubernetes" || query.Get("cutover") != "1" || query.Get("timeout") != "1m0s" {
+				return nil, fmt.Errorf("wrong query - found: %s", req.URL.RawQuery)
+			}
+			b, err := json.Marshal(types.StackMigration{
+				SourceID: "dummy",
+				From:     types.OrchestratorSwarm,
+				To:       types.OrchestratorKubernetes,
+				Phase:    types.StackMigrationCreating,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusAccepted,
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	migration, err := cli.StackMigrate(ctx, "dummy", types.StackMigrateOptions{
+		To:      types.OrchestratorKubernetes,
+		CutOver: true,
+		Timeout: time.Minute,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, migration.Phase, types.StackMigrationCreating)
+	assert.Equal(t, migration.From, types.OrchestratorChoice(types.OrchestratorSwarm))
+}
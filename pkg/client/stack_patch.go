@@ -0,0 +1,31 @@
+package client
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackPatch applies a patch to the spec of an existing Stack. The patch
+// is only applied if the Stack is still at version, unless version is the
+// zero Version, in which case it is applied to the current spec.
+func (cli *Client) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, patch []byte, options types.StackUpdateOptions) error {
+
+	headers := map[string][]string{
+		"version":      {cli.settings.Version},
+		"Content-Type": {string(patchType)},
+	}
+
+	if version.Index != 0 {
+		headers["If-Match"] = []string{types.StackETag(version.Index)}
+	}
+
+	if options.EncodedRegistryAuth != "" {
+		headers["X-Registry-Auth"] = []string{options.EncodedRegistryAuth}
+	}
+
+	resp, err := cli.patch(ctx, "/stacks/"+id, nil, bytes.NewReader(patch), headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
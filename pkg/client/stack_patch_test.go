@@ -0,0 +1,58 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackPatchServerError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPatch(ctx, "dummy", types.Version{}, types.StackPatchMerge, []byte("{}"), types.StackUpdateOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackPatch(t *testing.T) {
+	ctx := context.Background()
+	patch := `[{"op": "replace", "path": "/Services/web/TaskTemplate/ContainerSpec/Image", "value": "nginx:1.17"}]`
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "PATCH" {
+				return nil, fmt.Errorf("expected PATCH method - found: %s", req.Method)
+			}
+			if contentType := req.Header.Get("Content-Type"); contentType != string(types.StackPatchJSON) {
+				return nil, fmt.Errorf("wrong Content-Type - found: %s", contentType)
+			}
+			if etag := req.Header.Get("If-Match"); etag != `"7"` {
+				return nil, fmt.Errorf("missing If-Match header - found: %s", etag)
+			}
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			if string(body) != patch {
+				return nil, fmt.Errorf("wrong body - found: %s", body)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPatch(ctx, "dummy", types.Version{Index: 7}, types.StackPatchJSON, []byte(patch), types.StackUpdateOptions{})
+	assert.NilError(t, err)
+}
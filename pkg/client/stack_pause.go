@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+)
+
+// StackPause pauses the reconciliation of a Stack. The drift of a paused
+// Stack is still reported in its status.
+func (cli *Client) StackPause(ctx context.Context, id string) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/pause", nil, nil, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
+
+// StackResume resumes the reconciliation of a paused Stack.
+func (cli *Client) StackResume(ctx context.Context, id string) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/resume", nil, nil, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestStackPauseNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusNotFound, "Not found")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackPause(ctx, "dummy")
+	assert.Assert(t, IsErrNotFound(err))
+}
+
+func TestStackPauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	paths := []string{}
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodPost {
+				return nil, fmt.Errorf("wrong method - found: %s", req.Method)
+			}
+			if !strings.Contains(req.URL.Path, "/stacks/dummy/") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			paths = append(paths, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	assert.NilError(t, cli.StackPause(ctx, "dummy"))
+	assert.NilError(t, cli.StackResume(ctx, "dummy"))
+	assert.DeepEqual(t, paths, []string{"pause", "resume"})
+}
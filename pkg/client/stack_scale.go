@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackScale sets the number of replicas of services of a Stack, named as
+// in its spec. Other changes made to the Stack concurrently are preserved.
+func (cli *Client) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	request := types.StackScaleRequest{
+		Services: replicas,
+	}
+
+	resp, err := cli.post(ctx, "/stacks/"+id+"/scale", nil, request, headers)
+	ensureReaderClosed(resp)
+	return wrapResponseError(err, resp, "stack", id)
+}
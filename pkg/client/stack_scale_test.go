@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackScaleNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusNotFound, "Not found")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackScale(ctx, "dummy", map[string]uint64{"web": 3})
+	assert.Assert(t, IsErrNotFound(err))
+}
+
+func TestStackScale(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/stacks/dummy/scale") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			var request types.StackScaleRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+			if len(request.Services) != 1 || request.Services["web"] != 3 {
+				return nil, fmt.Errorf("wrong request - found: %v", request)
+			}
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackScale(ctx, "dummy", map[string]uint64{"web": 3})
+	assert.NilError(t, err)
+}
@@ -12,7 +12,8 @@ import (
 func (cli *Client) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
 
 	headers := map[string][]string{
-		"version": {cli.settings.Version},
+		"version":  {cli.settings.Version},
+		"If-Match": {types.StackETag(version.Index)},
 	}
 
 	if options.EncodedRegistryAuth != "" {
@@ -48,3 +48,32 @@ func TestStackUpdateEmpty(t *testing.T) {
 	err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
 	assert.NilError(t, err)
 }
+
+func TestStackUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	id := "dummy"
+	version := types.Version{Index: 123}
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if etag := req.Header.Get("If-Match"); etag != `"123"` {
+				return nil, fmt.Errorf("missing If-Match header - found: %s", etag)
+			}
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			header.Set("ETag", `"124"`)
+			return &http.Response{
+				StatusCode: http.StatusPreconditionFailed,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"message":"update out of sequence"}`)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	err = cli.StackUpdate(ctx, id, version, types.StackSpec{}, types.StackUpdateOptions{})
+	assert.ErrorContains(t, err, "update out of sequence")
+	assert.Assert(t, IsErrConflict(err))
+	current, ok := ConflictCurrentVersion(err)
+	assert.Assert(t, ok)
+	assert.Equal(t, current.Index, uint64(124))
+}
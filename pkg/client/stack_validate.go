@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackValidate validates a StackSpec without creating a Stack
+func (cli *Client) StackValidate(ctx context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	var result types.StackValidationResult
+	resp, err := cli.post(ctx, "/stacks/validate", nil, spec, headers)
+	if err != nil {
+		return result, wrapResponseError(err, resp, "stack", "")
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&result)
+
+	ensureReaderClosed(resp)
+	return result, err
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+)
+
+func TestStackValidateError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackValidate(ctx, types.StackSpec{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackValidate(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/stacks/validate") {
+				return nil, fmt.Errorf("wrong URL - found: %s", req.URL.Path)
+			}
+			body, err := json.Marshal(types.StackValidationResult{
+				Errors: []types.StackValidationIssue{
+					{Field: "Annotations.Name", Message: "StackSpec contains no name"},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	result, err := cli.StackValidate(ctx, types.StackSpec{})
+	assert.NilError(t, err)
+	assert.Assert(t, !result.Valid)
+	assert.Equal(t, len(result.Errors), 1)
+	assert.Equal(t, result.Errors[0].Field, "Annotations.Name")
+}
@@ -0,0 +1,406 @@
+package compose
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func uint64Ptr(i uint64) *uint64 {
+	return &i
+}
+
+// composeSpec is a StackSpec whose fields all have a Compose equivalent.
+func composeSpec() types.StackSpec {
+	init := true
+	return types.StackSpec{
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{
+					Name:   "web",
+					Labels: map[string]string{"com.example.tier": "front"},
+				},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image:           "nginx:1.17",
+						Labels:          map[string]string{"com.example.container": "web"},
+						Command:         []string{"nginx"},
+						Args:            []string{"-g", "daemon off;"},
+						Hostname:        "web",
+						Env:             []string{"B=2", "A=1", "EMPTY"},
+						Dir:             "/srv",
+						User:            "www-data",
+						Init:            &init,
+						StopSignal:      "SIGQUIT",
+						TTY:             true,
+						ReadOnly:        true,
+						StopGracePeriod: durationPtr(20 * time.Second),
+						Mounts: []mount.Mount{
+							{
+								Type:        mount.TypeBind,
+								Source:      "/var/log",
+								Target:      "/logs",
+								ReadOnly:    true,
+								BindOptions: &mount.BindOptions{Propagation: mount.PropagationRSlave},
+							},
+							{
+								Type:          mount.TypeVolume,
+								Source:        "data",
+								Target:        "/data",
+								VolumeOptions: &mount.VolumeOptions{NoCopy: true},
+							},
+							{
+								Type:         mount.TypeTmpfs,
+								Target:       "/tmp",
+								TmpfsOptions: &mount.TmpfsOptions{SizeBytes: 1 << 20},
+							},
+						},
+						Healthcheck: &container.HealthConfig{
+							Test:     []string{"CMD", "curl", "-f", "http://localhost"},
+							Interval: 30 * time.Second,
+							Timeout:  5 * time.Second,
+							Retries:  3,
+						},
+						Hosts: []string{"10.0.0.1 db"},
+						DNSConfig: &swarm.DNSConfig{
+							Nameservers: []string{"8.8.8.8"},
+							Search:      []string{"example.com"},
+						},
+						Secrets: []*swarm.SecretReference{{
+							SecretName: "password",
+							File:       &swarm.SecretReferenceFileTarget{Name: "db_password", UID: "33", GID: "33", Mode: 0400},
+						}},
+						Configs: []*swarm.ConfigReference{{
+							ConfigName: "nginx",
+							File:       &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx/nginx.conf", UID: "0", GID: "0", Mode: 0444},
+						}},
+						Sysctls: map[string]string{"net.core.somaxconn": "1024"},
+					},
+					Resources: &swarm.ResourceRequirements{
+						Limits: &swarm.Resources{NanoCPUs: 1500000000, MemoryBytes: 512 << 20},
+						Reservations: &swarm.Resources{
+							NanoCPUs:    250000000,
+							MemoryBytes: 1000,
+							GenericResources: []swarm.GenericResource{{
+								DiscreteResourceSpec: &swarm.DiscreteGenericResource{Kind: "gpu", Value: 2},
+							}},
+						},
+					},
+					RestartPolicy: &swarm.RestartPolicy{
+						Condition:   swarm.RestartPolicyConditionOnFailure,
+						Delay:       durationPtr(5 * time.Second),
+						MaxAttempts: uint64Ptr(3),
+					},
+					Placement: &swarm.Placement{
+						Constraints: []string{"node.role == worker"},
+						Preferences: []swarm.PlacementPreference{{
+							Spread: &swarm.SpreadOver{SpreadDescriptor: "node.labels.zone"},
+						}},
+						MaxReplicas: 2,
+					},
+					Networks: []swarm.NetworkAttachmentConfig{
+						{Target: "front", Aliases: []string{"www"}},
+						{Target: "back"},
+					},
+					LogDriver: &swarm.Driver{Name: "json-file", Options: map[string]string{"max-size": "10m"}},
+				},
+				Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: uint64Ptr(3)}},
+				UpdateConfig: &swarm.UpdateConfig{
+					Parallelism:     2,
+					Delay:           10 * time.Second,
+					FailureAction:   swarm.UpdateFailureActionRollback,
+					MaxFailureRatio: 0.25,
+					Order:           swarm.UpdateOrderStartFirst,
+				},
+				RollbackConfig: &swarm.UpdateConfig{Parallelism: 0},
+				EndpointSpec: &swarm.EndpointSpec{
+					Mode: swarm.ResolutionModeVIP,
+					Ports: []swarm.PortConfig{
+						{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+						{Protocol: swarm.PortConfigProtocolUDP, TargetPort: 53, PublishMode: swarm.PortConfigPublishModeHost},
+					},
+				},
+			},
+			{
+				Annotations: swarm.Annotations{Name: "agent"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image:       "agent",
+						Healthcheck: &container.HealthConfig{Test: []string{"NONE"}},
+					},
+				},
+				Mode: swarm.ServiceMode{Global: &swarm.GlobalService{}},
+			},
+		},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"front": {
+				Driver:     "overlay",
+				Attachable: true,
+				Labels:     map[string]string{"com.example.network": "front"},
+				IPAM: &network.IPAM{
+					Driver: "default",
+					Config: []network.IPAMConfig{{Subnet: "10.1.0.0/24"}},
+				},
+			},
+			"back": {Driver: "overlay", Internal: true, Options: map[string]string{"encrypted": ""}},
+		},
+		Secrets: []swarm.SecretSpec{
+			{Annotations: swarm.Annotations{Name: "password", Labels: map[string]string{"a": "b"}}},
+			{
+				Annotations: swarm.Annotations{Name: "vault"},
+				Driver:      &swarm.Driver{Name: "vault", Options: map[string]string{"path": "db"}},
+			},
+		},
+		Configs: []swarm.ConfigSpec{{
+			Annotations: swarm.Annotations{Name: "nginx"},
+			Templating:  &swarm.Driver{Name: "golang"},
+		}},
+	}
+}
+
+func render(t *testing.T, spec types.StackSpec) (string, Config) {
+	data, err := Render(spec)
+	require.NoError(t, err)
+	var config Config
+	require.NoError(t, yaml.UnmarshalStrict(data, &config))
+	return string(data), config
+}
+
+func TestRenderCompose(t *testing.T) {
+	require := require.New(t)
+	spec := composeSpec()
+
+	data, config := render(t, spec)
+	require.Equal(Version, config.Version)
+	require.Empty(config.Extensions)
+	require.Len(config.Services, 2)
+	require.Equal("web", config.Services[0].Name)
+	require.Equal("agent", config.Services[1].Name)
+	for _, service := range config.Services {
+		require.Empty(service.Extensions, service.Name)
+	}
+	for name, net := range config.Networks {
+		require.Empty(net.Extensions, name)
+	}
+	for _, secret := range config.Secrets {
+		require.Empty(secret.Extensions, secret.Name)
+	}
+	require.Empty(config.Configs[0].Extensions)
+
+	web := config.Services[0]
+	require.Equal(ShellCommand{"nginx"}, web.Entrypoint)
+	require.Equal(ShellCommand{"-g", "daemon off;"}, web.Command)
+	require.Equal(ExtraHosts{"db:10.0.0.1"}, web.ExtraHosts)
+	require.Equal("1.5", web.Deploy.Resources.Limits.NanoCPUs)
+	require.Equal("replicated", web.Deploy.Mode)
+	require.Equal(uint64(3), *web.Deploy.Replicas)
+	require.True(config.Services[1].Healthcheck.Disable)
+	require.Equal("global", config.Services[1].Deploy.Mode)
+
+	require.Contains(data, "memory: 512m")
+	require.Contains(data, "stop_grace_period: 20s")
+	require.Contains(data, "- www")
+
+	loaded, err := Load([]byte(data))
+	require.NoError(err)
+	require.True(equal(spec, loaded), "%#v", loaded)
+}
+
+func TestRenderExtensions(t *testing.T) {
+	require := require.New(t)
+	spec := composeSpec()
+	spec.Annotations = swarm.Annotations{Name: "shop", Labels: map[string]string{"team": "web"}}
+	spec.Collection = "team-web"
+	spec.ResourceNaming = types.ResourceNamingNamespaced
+	spec.UpdateConfig = &types.StackUpdateConfig{Parallelism: 1}
+
+	web := &spec.Services[0]
+	web.TaskTemplate.ContainerSpec.Groups = []string{"docker"}
+	web.TaskTemplate.ContainerSpec.Hosts = []string{"10.0.0.1 db database"}
+	web.TaskTemplate.ContainerSpec.Mounts[2].TmpfsOptions.Mode = 0700
+	web.TaskTemplate.ContainerSpec.DNSConfig.Options = []string{"ndots:2"}
+	web.TaskTemplate.ForceUpdate = 2
+	web.EndpointSpec.Ports[0].Name = "http"
+
+	front := spec.Networks["front"]
+	front.IPAM.Config[0].Gateway = "10.1.0.1"
+	front.Scope = "swarm"
+	spec.Networks["front"] = front
+	spec.Secrets[0].Data = []byte("hunter2")
+	spec.Configs[0].Data = []byte("events {}")
+	spec.Configs[0].Templating.Options = map[string]string{"a": "b"}
+
+	data, config := render(t, spec)
+
+	require.Contains(config.Extensions, StackExtension)
+	service := config.Services[0]
+	require.Contains(service.Extensions, SwarmExtension)
+	// Fields without Compose equivalent are kept in the extension, while
+	// the others are still rendered.
+	require.Nil(service.Ports)
+	require.Nil(service.Volumes)
+	require.Nil(service.ExtraHosts)
+	require.Nil(service.DNS)
+	require.Equal("nginx:1.17", service.Image)
+	require.NotNil(service.Healthcheck)
+	require.Empty(config.Services[1].Extensions)
+	require.Nil(config.Networks["front"].Ipam)
+	require.Contains(config.Networks["front"].Extensions, SwarmExtension)
+	require.Empty(config.Networks["back"].Extensions)
+	require.Contains(config.Secrets[0].Extensions, SwarmExtension)
+	require.Empty(config.Secrets[1].Extensions)
+	require.Equal("", config.Configs[0].TemplateDriver)
+
+	loaded, err := Load([]byte(data))
+	require.NoError(err)
+	require.True(equal(spec, loaded), "%#v", loaded)
+	require.Equal("shop", loaded.Annotations.Name)
+	require.Equal([]string{"docker"}, loaded.Services[0].TaskTemplate.ContainerSpec.Groups)
+	require.Equal([]byte("hunter2"), loaded.Secrets[0].Data)
+}
+
+func TestRenderPluginService(t *testing.T) {
+	require := require.New(t)
+	spec := types.StackSpec{
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "plugin"},
+			TaskTemplate: swarm.TaskSpec{
+				Runtime:   swarm.RuntimePlugin,
+				Placement: &swarm.Placement{Constraints: []string{"node.role == manager"}},
+			},
+		}},
+	}
+
+	data, config := render(t, spec)
+	require.Contains(config.Services[0].Extensions, SwarmExtension)
+	require.Equal([]string{"node.role == manager"}, config.Services[0].Deploy.Placement.Constraints)
+
+	loaded, err := Load([]byte(data))
+	require.NoError(err)
+	require.Nil(loaded.Services[0].TaskTemplate.ContainerSpec)
+	require.True(equal(spec, loaded), "%#v", loaded)
+}
+
+func TestRenderDuplicateService(t *testing.T) {
+	spec := types.StackSpec{
+		Services: []swarm.ServiceSpec{
+			{Annotations: swarm.Annotations{Name: "web"}},
+			{Annotations: swarm.Annotations{Name: "web"}},
+		},
+	}
+	_, err := Render(spec)
+	require.Error(t, err)
+}
+
+func TestLoadShortSyntax(t *testing.T) {
+	require := require.New(t)
+	spec, err := Load([]byte(`
+version: "3.7"
+services:
+  web:
+    image: nginx
+    command: nginx -g 'daemon off;'
+    environment:
+      B: "2"
+      A: "1"
+      EMPTY:
+    labels:
+      - com.example.a=b
+    ports:
+      - "8080:80"
+      - "9000-9001:9000-9001/udp"
+      - 443
+    volumes:
+      - data:/data:ro,nocopy
+      - /var/run/docker.sock:/var/run/docker.sock
+      - /cache
+    secrets:
+      - password
+    healthcheck:
+      test: curl -f http://localhost
+    deploy:
+      replicas: 2
+      update_config:
+        delay: 10s
+    networks:
+      - front
+    extra_hosts:
+      db: 10.0.0.1
+    dns: 8.8.8.8
+    x-team: web
+networks:
+  front:
+secrets:
+  password:
+    x-swarm:
+      Data: aHVudGVyMg==
+`))
+	require.NoError(err)
+	require.Len(spec.Services, 1)
+
+	service := spec.Services[0]
+	c := service.TaskTemplate.ContainerSpec
+	require.Equal("web", service.Annotations.Name)
+	require.Equal("nginx", c.Image)
+	require.Equal([]string{"nginx", "-g", "daemon off;"}, c.Args)
+	require.Equal([]string{"A=1", "B=2", "EMPTY"}, c.Env)
+	require.Equal(map[string]string{"com.example.a": "b"}, c.Labels)
+	require.Equal([]swarm.PortConfig{
+		{Protocol: "tcp", TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeIngress},
+		{Protocol: "udp", TargetPort: 9000, PublishedPort: 9000, PublishMode: swarm.PortConfigPublishModeIngress},
+		{Protocol: "udp", TargetPort: 9001, PublishedPort: 9001, PublishMode: swarm.PortConfigPublishModeIngress},
+		{Protocol: "tcp", TargetPort: 443, PublishMode: swarm.PortConfigPublishModeIngress},
+	}, service.EndpointSpec.Ports)
+	require.Equal([]mount.Mount{
+		{Type: mount.TypeVolume, Source: "data", Target: "/data", ReadOnly: true, VolumeOptions: &mount.VolumeOptions{NoCopy: true}},
+		{Type: mount.TypeBind, Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"},
+		{Type: mount.TypeVolume, Target: "/cache"},
+	}, c.Mounts)
+	require.Equal([]*swarm.SecretReference{{
+		SecretName: "password",
+		File:       &swarm.SecretReferenceFileTarget{Name: "password", UID: "0", GID: "0", Mode: os.FileMode(0444)},
+	}}, c.Secrets)
+	require.Equal([]string{"CMD-SHELL", "curl -f http://localhost"}, c.Healthcheck.Test)
+	require.Equal(uint64(2), *service.Mode.Replicated.Replicas)
+	require.Equal(&swarm.UpdateConfig{Parallelism: 1, Delay: 10 * time.Second}, service.UpdateConfig)
+	require.Equal([]swarm.NetworkAttachmentConfig{{Target: "front"}}, service.TaskTemplate.Networks)
+	require.Equal([]string{"10.0.0.1 db"}, c.Hosts)
+	require.Equal([]string{"8.8.8.8"}, c.DNSConfig.Nameservers)
+
+	require.Equal(map[string]dockerTypes.NetworkCreate{"front": {}}, spec.Networks)
+	require.Equal([]byte("hunter2"), spec.Secrets[0].Data)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"version":         "version: '2'\nservices:\n  web:\n    image: nginx\n",
+		"build":           "services:\n  web:\n    build: .\n",
+		"ulimits":         "services:\n  web:\n    ulimits:\n      nproc: 65535\n",
+		"secret file":     "secrets:\n  password:\n    file: ./password\n",
+		"top level":       "volumes:\n  data:\n",
+		"deploy option":   "services:\n  web:\n    deploy:\n      unknown: 1\n",
+		"relative volume": "services:\n  web:\n    volumes:\n      - ./data:/data\n",
+		"cpus":            "services:\n  web:\n    deploy:\n      resources:\n        limits:\n          cpus: lots\n",
+		"duplicate":       "services:\n  web:\n    image: a\n  web:\n    image: b\n",
+		"stack extension": "services:\n  web:\n    x-stacks: {}\n",
+	} {
+		_, err := Load([]byte(data))
+		require.Error(t, err, name)
+		require.True(t, errdefs.IsInvalidParameter(err), name)
+	}
+}
@@ -0,0 +1,26 @@
+package compose
+
+// The `compose` package renders StackSpecs as Compose files, and loads
+// StackSpecs from them.
+//
+// Render writes a version 3.8 Compose file, using the long syntax of
+// ports, volumes, secrets and configs so that nothing is lost in the
+// conversion. The fields of a service, network, secret or config which
+// have no Compose equivalent are kept in an `x-swarm` extension of the
+// object, holding the JSON fields of the Swarm spec as they are in the
+// Stacks API. The fields of the StackSpec itself, as its name, labels and
+// update configuration, are kept in a top level `x-stacks` extension.
+// Loading a rendered Compose file returns the StackSpec it was rendered
+// from.
+//
+// Load also accepts the short syntaxes of Compose, and fills in the
+// defaults of the docker CLI where the Compose file format documents one.
+// The options of Compose which cannot be honoured by a server, as `build`
+// or the `file` of secrets, are rejected, as are the Compose files of
+// versions other than 3. The ulimits of services have no counterpart in
+// the Swarm API version of this package, and are rejected too.
+//
+// LoadFiles loads a StackSpec from several Compose files, as
+// docker-compose.yml and docker-compose.prod.yml, merged in order with the
+// override semantics of Compose, after the extends option of their services
+// is resolved. See MergeFiles.
@@ -0,0 +1,275 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-connections/nat"
+	ghodssYAML "github.com/ghodss/yaml"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/opts"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Load returns the StackSpec of a Compose file. The errors of invalid
+// Compose files are errdefs.InvalidParameter errors.
+func Load(data []byte) (types.StackSpec, error) {
+	return LoadFiles([]File{{Data: data}}, nil)
+}
+
+// ToStackSpec returns the StackSpec of a Compose file.
+func ToStackSpec(config Config) (types.StackSpec, error) {
+	if v := config.Version; v != "" && v != "3" && !strings.HasPrefix(v, "3.") {
+		return types.StackSpec{}, fmt.Errorf("unsupported version %s: only version 3 is supported", v)
+	}
+	if err := checkExtensions("", config.Extensions); err != nil {
+		return types.StackSpec{}, err
+	}
+
+	var spec types.StackSpec
+	if extension, ok := config.Extensions[StackExtension]; ok {
+		if err := decodeExtension(extension, &spec); err != nil {
+			return types.StackSpec{}, fmt.Errorf("invalid %s: %s", StackExtension, err)
+		}
+		spec.Services, spec.Networks, spec.Secrets, spec.Configs = nil, nil, nil, nil
+	}
+
+	for _, service := range config.Services {
+		serviceSpec, err := loadService(service)
+		if err != nil {
+			return types.StackSpec{}, fmt.Errorf("service %s: %s", service.Name, err)
+		}
+		spec.Services = append(spec.Services, serviceSpec)
+	}
+
+	names := make([]string, 0, len(config.Networks))
+	for name := range config.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		create, err := loadNetwork(config.Networks[name])
+		if err != nil {
+			return types.StackSpec{}, fmt.Errorf("network %s: %s", name, err)
+		}
+		if spec.Networks == nil {
+			spec.Networks = map[string]dockerTypes.NetworkCreate{}
+		}
+		spec.Networks[name] = create
+	}
+
+	for _, secret := range config.Secrets {
+		secretSpec, err := loadSecret(secret)
+		if err != nil {
+			return types.StackSpec{}, fmt.Errorf("secret %s: %s", secret.Name, err)
+		}
+		spec.Secrets = append(spec.Secrets, secretSpec)
+	}
+
+	for _, config := range config.Configs {
+		configSpec, err := loadConfig(config)
+		if err != nil {
+			return types.StackSpec{}, fmt.Errorf("config %s: %s", config.Name, err)
+		}
+		spec.Configs = append(spec.Configs, configSpec)
+	}
+	return spec, nil
+}
+
+func loadService(service Service) (swarm.ServiceSpec, error) {
+	if service.Extends != nil {
+		return swarm.ServiceSpec{}, fmt.Errorf("extends is not resolved")
+	}
+	if err := checkExtensions(SwarmExtension, service.Extensions); err != nil {
+		return swarm.ServiceSpec{}, err
+	}
+
+	spec := baselineService()
+	if extension, ok := service.Extensions[SwarmExtension]; ok {
+		spec = swarm.ServiceSpec{}
+		if err := decodeExtension(extension, &spec); err != nil {
+			return swarm.ServiceSpec{}, fmt.Errorf("invalid %s: %s", SwarmExtension, err)
+		}
+	}
+	spec.Annotations.Name = service.Name
+
+	for _, field := range serviceFields {
+		if err := field.load(service, &spec); err != nil {
+			return swarm.ServiceSpec{}, err
+		}
+	}
+	return spec, nil
+}
+
+func loadNetwork(net Network) (dockerTypes.NetworkCreate, error) {
+	if err := checkExtensions(SwarmExtension, net.Extensions); err != nil {
+		return dockerTypes.NetworkCreate{}, err
+	}
+
+	var create dockerTypes.NetworkCreate
+	if extension, ok := net.Extensions[SwarmExtension]; ok {
+		if err := decodeExtension(extension, &create); err != nil {
+			return dockerTypes.NetworkCreate{}, fmt.Errorf("invalid %s: %s", SwarmExtension, err)
+		}
+	}
+	create.Driver = net.Driver
+	create.Options = net.DriverOpts
+	create.Internal = net.Internal
+	create.Attachable = net.Attachable
+	create.Labels = net.Labels
+	if net.Ipam != nil {
+		create.IPAM = loadIPAM(net.Ipam)
+	}
+	return create, nil
+}
+
+func loadSecret(secret Secret) (swarm.SecretSpec, error) {
+	if err := checkExtensions(SwarmExtension, secret.Extensions); err != nil {
+		return swarm.SecretSpec{}, err
+	}
+
+	var spec swarm.SecretSpec
+	if extension, ok := secret.Extensions[SwarmExtension]; ok {
+		if err := decodeExtension(extension, &spec); err != nil {
+			return swarm.SecretSpec{}, fmt.Errorf("invalid %s: %s", SwarmExtension, err)
+		}
+	}
+	spec.Annotations = swarm.Annotations{Name: secret.Name, Labels: secret.Labels}
+	if secret.Driver != "" {
+		spec.Driver = &swarm.Driver{Name: secret.Driver, Options: secret.DriverOpts}
+	} else if secret.DriverOpts != nil {
+		return swarm.SecretSpec{}, fmt.Errorf("driver_opts cannot be set without a driver")
+	}
+	if secret.TemplateDriver != "" {
+		spec.Templating = &swarm.Driver{Name: secret.TemplateDriver}
+	}
+	return spec, nil
+}
+
+func loadConfig(config ConfigObj) (swarm.ConfigSpec, error) {
+	if err := checkExtensions(SwarmExtension, config.Extensions); err != nil {
+		return swarm.ConfigSpec{}, err
+	}
+
+	var spec swarm.ConfigSpec
+	if extension, ok := config.Extensions[SwarmExtension]; ok {
+		if err := decodeExtension(extension, &spec); err != nil {
+			return swarm.ConfigSpec{}, fmt.Errorf("invalid %s: %s", SwarmExtension, err)
+		}
+	}
+	spec.Annotations = swarm.Annotations{Name: config.Name, Labels: config.Labels}
+	if config.TemplateDriver != "" {
+		spec.Templating = &swarm.Driver{Name: config.TemplateDriver}
+	}
+	return spec, nil
+}
+
+// checkExtensions returns an error naming the options of extensions which
+// are not extensions, and thus are options this package does not support.
+// The x-stacks extension is only valid at the top level of a Compose file,
+// where extension is "".
+func checkExtensions(extension string, extensions map[string]interface{}) error {
+	var unsupported []string
+	for key := range extensions {
+		if !strings.HasPrefix(key, "x-") || (extension != "" && key == StackExtension) {
+			unsupported = append(unsupported, key)
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	sort.Strings(unsupported)
+	return fmt.Errorf("unsupported options: %s", strings.Join(unsupported, ", "))
+}
+
+// decodeExtension decodes the JSON fields held by a YAML extension into
+// value.
+func decodeExtension(extension interface{}, value interface{}) error {
+	data, err := yaml.Marshal(extension)
+	if err != nil {
+		return err
+	}
+	data, err = ghodssYAML.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// parsePorts parses a port in the short syntax, as the docker CLI does.
+// A range of ports expands to several ports.
+func parsePorts(spec string) ([]Port, error) {
+	exposed, bindings, err := nat.ParsePortSpecs([]string{spec})
+	if err != nil {
+		return nil, err
+	}
+	var ports []Port
+	for port := range exposed {
+		configs, err := opts.ConvertPortToPortConfig(port, bindings)
+		if err != nil {
+			return nil, err
+		}
+		for _, config := range configs {
+			ports = append(ports, Port{
+				Mode:      string(config.PublishMode),
+				Target:    config.TargetPort,
+				Published: config.PublishedPort,
+				Protocol:  string(config.Protocol),
+			})
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Target != ports[j].Target {
+			return ports[i].Target < ports[j].Target
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports, nil
+}
+
+// parseVolume parses a volume in the short syntax, [source:]target[:mode].
+// A source which is an absolute path is bind mounted, and other sources
+// are the names of volumes. Paths relative to the Compose file have no
+// meaning on the server, and are rejected.
+func parseVolume(spec string) (Volume, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) == 1 {
+		return Volume{Type: "volume", Target: parts[0]}, nil
+	}
+	if len(parts) > 3 {
+		return Volume{}, fmt.Errorf("invalid volume %s", spec)
+	}
+
+	volume := Volume{Type: "volume", Source: parts[0], Target: parts[1]}
+	switch {
+	case strings.HasPrefix(volume.Source, "/"):
+		volume.Type = "bind"
+	case strings.HasPrefix(volume.Source, ".") || strings.HasPrefix(volume.Source, "~"):
+		return Volume{}, fmt.Errorf("invalid volume %s: relative paths are not supported", spec)
+	}
+	if len(parts) == 2 {
+		return volume, nil
+	}
+
+	for _, option := range strings.Split(parts[2], ",") {
+		switch option {
+		case "ro":
+			volume.ReadOnly = true
+		case "rw":
+		case "nocopy":
+			volume.Volume = &VolumeVolume{NoCopy: true}
+		case "consistent", "cached", "delegated":
+			volume.Consistency = option
+		case "rprivate", "private", "rshared", "shared", "rslave", "slave":
+			volume.Bind = &VolumeBind{Propagation: option}
+		default:
+			return Volume{}, fmt.Errorf("invalid volume %s: unknown mode %s", spec, option)
+		}
+	}
+	return volume, nil
+}
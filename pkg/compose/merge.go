@@ -0,0 +1,344 @@
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/imdario/mergo"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// File is a Compose file loaded along with others.
+type File struct {
+	// Name is the name of the file, which the extends option of services
+	// refers to.
+	Name string
+	Data []byte
+}
+
+// SplitDocuments returns the documents of a YAML stream as unnamed Files.
+func SplitDocuments(data []byte) ([]File, error) {
+	var files []File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document yaml.MapSlice
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("invalid Compose file: %s", err))
+		}
+		if document == nil {
+			continue
+		}
+		data, err := yaml.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Data: data})
+	}
+	return files, nil
+}
+
+// LoadFiles returns the StackSpec of files, merged in order as MergeFiles
+// does. The names of the files are recorded in a
+// types.StackComposeFilesLabel on the StackSpec when there are several.
+// The errors of invalid Compose files are errdefs.InvalidParameter errors.
+func LoadFiles(files []File, extended []File) (types.StackSpec, error) {
+	config, err := MergeFiles(files, extended)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+	spec, err := ToStackSpec(config)
+	if err != nil {
+		return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("invalid Compose file: %s", err))
+	}
+
+	if len(files) > 1 {
+		names := make([]string, 0, len(files))
+		for _, file := range files {
+			if file.Name == "" {
+				names = append(names, "-")
+			} else {
+				names = append(names, file.Name)
+			}
+		}
+		if spec.Annotations.Labels == nil {
+			spec.Annotations.Labels = map[string]string{}
+		}
+		spec.Annotations.Labels[types.StackComposeFilesLabel] = strings.Join(names, ",")
+	}
+	return spec, nil
+}
+
+// MergeFiles returns the Compose file of files merged in order, each file
+// overriding the ones before it as `docker-compose -f` does:
+//
+//   - scalars are replaced,
+//   - mappings, as labels and environment variables, are merged,
+//   - the ports, volumes, secrets, configs and networks of services are
+//     merged by their target, or name for networks,
+//   - the other lists, as commands, are replaced,
+//   - the services, networks, secrets and configs of the files are merged
+//     by name, and the extensions of objects by key.
+//
+// As with Compose, options cannot be reset to their empty value by an
+// overriding file.
+//
+// The extends option of the services of each file is resolved before the
+// files are merged. A service extends a service of the same file, or one
+// of a file of files or extended, which are only looked up by name and not
+// merged.
+func MergeFiles(files []File, extended []File) (Config, error) {
+	if len(files) == 0 {
+		return Config{}, errdefs.InvalidParameter(fmt.Errorf("no Compose file"))
+	}
+
+	configs := make([]Config, 0, len(files)+len(extended))
+	names := map[string]int{}
+	for i, file := range append(append([]File{}, files...), extended...) {
+		var config Config
+		if err := yaml.UnmarshalStrict(file.Data, &config); err != nil {
+			return Config{}, errdefs.InvalidParameter(fmt.Errorf("invalid Compose file %s: %s", fileName(file), err))
+		}
+		if file.Name != "" {
+			if _, ok := names[file.Name]; ok {
+				return Config{}, errdefs.InvalidParameter(fmt.Errorf("duplicate Compose file %s", file.Name))
+			}
+			names[file.Name] = i
+		}
+		configs = append(configs, config)
+	}
+
+	resolver := extendsResolver{configs: configs, names: names}
+	for i := range files {
+		for j, service := range configs[i].Services {
+			if service.Extends == nil {
+				continue
+			}
+			resolved, err := resolver.resolve(i, service.Name, nil)
+			if err != nil {
+				return Config{}, errdefs.InvalidParameter(fmt.Errorf("invalid Compose file %s: %s", fileName(files[i]), err))
+			}
+			configs[i].Services[j] = resolved
+		}
+	}
+
+	var merged Config
+	for i := range files {
+		if err := mergeConfig(&merged, configs[i]); err != nil {
+			return Config{}, errdefs.InvalidParameter(fmt.Errorf("unable to merge Compose file %s: %s", fileName(files[i]), err))
+		}
+	}
+	return merged, nil
+}
+
+func fileName(file File) string {
+	if file.Name == "" {
+		return "-"
+	}
+	return file.Name
+}
+
+// extendsResolver resolves the extends option of services.
+type extendsResolver struct {
+	configs []Config
+	// names are the indexes in configs of the named files.
+	names map[string]int
+}
+
+// resolve returns the service called name of the file at index, with the
+// services it extends merged into it. chain lists the services extending
+// it, to tell cycles.
+func (r extendsResolver) resolve(index int, name string, chain []string) (Service, error) {
+	key := fmt.Sprintf("%d/%s", index, name)
+	for _, link := range chain {
+		if link == key {
+			return Service{}, fmt.Errorf("service %s extends itself", name)
+		}
+	}
+
+	var service *Service
+	for i := range r.configs[index].Services {
+		if r.configs[index].Services[i].Name == name {
+			service = &r.configs[index].Services[i]
+		}
+	}
+	if service == nil {
+		return Service{}, fmt.Errorf("extended service %s not found", name)
+	}
+	if service.Extends == nil {
+		return copyService(*service)
+	}
+
+	baseIndex := index
+	if file := service.Extends.File; file != "" {
+		i, ok := r.names[file]
+		if !ok {
+			return Service{}, fmt.Errorf("extended file %s of service %s not found", file, name)
+		}
+		baseIndex = i
+	}
+	base, err := r.resolve(baseIndex, service.Extends.Service, append(chain, key))
+	if err != nil {
+		return Service{}, err
+	}
+
+	extending, err := copyService(*service)
+	if err != nil {
+		return Service{}, err
+	}
+	extending.Extends = nil
+	if err := mergo.Merge(&base, extending, mergeOptions...); err != nil {
+		return Service{}, err
+	}
+	base.Name = name
+	return base, nil
+}
+
+// copyService returns a deep copy of service.
+func copyService(service Service) (Service, error) {
+	data, err := yaml.Marshal(service)
+	if err != nil {
+		return Service{}, err
+	}
+	var copied Service
+	if err := yaml.UnmarshalStrict(data, &copied); err != nil {
+		return Service{}, err
+	}
+	copied.Name = service.Name
+	return copied, nil
+}
+
+func mergeConfig(dst *Config, src Config) error {
+	return mergo.Merge(dst, src, mergeOptions...)
+}
+
+var mergeOptions []func(*mergo.Config)
+
+func init() {
+	mergeOptions = []func(*mergo.Config){
+		mergo.WithOverride,
+		mergo.WithTransformers(transformers{}),
+	}
+}
+
+// transformers implements the Compose override semantics that mergo does
+// not: mergo replaces pointers and lists, and the values of maps.
+type transformers struct{}
+
+// Transformer implements mergo.Transformers.
+func (transformers) Transformer(t reflect.Type) func(dst, src reflect.Value) error {
+	switch t {
+	case reflect.TypeOf(Services{}):
+		return mergeByKey(func(v reflect.Value) string { return v.Interface().(Service).Name })
+	case reflect.TypeOf(Secrets{}):
+		return mergeByKey(func(v reflect.Value) string { return v.Interface().(Secret).Name })
+	case reflect.TypeOf(Configs{}):
+		return mergeByKey(func(v reflect.Value) string { return v.Interface().(ConfigObj).Name })
+	case reflect.TypeOf(ServiceNetworks{}):
+		return mergeByKey(func(v reflect.Value) string { return v.Interface().(ServiceNetwork).Name })
+	case reflect.TypeOf(Ports{}):
+		return mergeByKey(func(v reflect.Value) string {
+			port := v.Interface().(Port)
+			return fmt.Sprintf("%d:%d/%s", port.Published, port.Target, port.Protocol)
+		})
+	case reflect.TypeOf(Volumes{}):
+		return mergeByKey(func(v reflect.Value) string { return v.Interface().(Volume).Target })
+	case reflect.TypeOf(FileReferences{}):
+		return mergeByKey(func(v reflect.Value) string {
+			reference := v.Interface().(FileReference)
+			if reference.Target == "" {
+				return reference.Source
+			}
+			return reference.Target
+		})
+	case reflect.TypeOf(Environment{}):
+		return mergeByKey(func(v reflect.Value) string {
+			return strings.SplitN(v.Interface().(string), "=", 2)[0]
+		})
+	case reflect.TypeOf(map[string]Network{}):
+		return mergeNetworks
+	}
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		return mergePointer
+	}
+	return nil
+}
+
+// mergeByKey returns a function merging lists whose items are identified
+// by key: an item of src is merged into the item of dst with the same key,
+// or otherwise appended to dst.
+func mergeByKey(key func(reflect.Value) string) func(dst, src reflect.Value) error {
+	return func(dst, src reflect.Value) error {
+		merged := reflect.MakeSlice(dst.Type(), 0, dst.Len()+src.Len())
+		merged = reflect.AppendSlice(merged, dst)
+		indexes := map[string]int{}
+		for i := 0; i < merged.Len(); i++ {
+			indexes[key(merged.Index(i))] = i
+		}
+		for i := 0; i < src.Len(); i++ {
+			item := src.Index(i)
+			index, ok := indexes[key(item)]
+			if !ok {
+				indexes[key(item)] = merged.Len()
+				merged = reflect.Append(merged, item)
+				continue
+			}
+			if item.Kind() != reflect.Struct {
+				merged.Index(index).Set(item)
+				continue
+			}
+			if err := mergo.Merge(merged.Index(index).Addr().Interface(), item.Interface(), mergeOptions...); err != nil {
+				return err
+			}
+		}
+		dst.Set(merged)
+		return nil
+	}
+}
+
+// mergePointer merges the structs pointed to by src into the ones pointed
+// to by dst.
+func mergePointer(dst, src reflect.Value) error {
+	if src.IsNil() {
+		return nil
+	}
+	merged := reflect.New(dst.Type().Elem())
+	merged.Elem().Set(dst.Elem())
+	if err := mergo.Merge(merged.Interface(), src.Interface(), mergeOptions...); err != nil {
+		return err
+	}
+	dst.Set(merged)
+	return nil
+}
+
+// mergeNetworks merges the networks of src into the ones of dst with the
+// same name.
+func mergeNetworks(dst, src reflect.Value) error {
+	networks := dst.Interface().(map[string]Network)
+	merged := make(map[string]Network, len(networks))
+	for name, network := range networks {
+		merged[name] = network
+	}
+	for name, network := range src.Interface().(map[string]Network) {
+		current, ok := merged[name]
+		if !ok {
+			merged[name] = network
+			continue
+		}
+		if err := mergo.Merge(&current, network, mergeOptions...); err != nil {
+			return err
+		}
+		merged[name] = current
+	}
+	dst.Set(reflect.ValueOf(merged))
+	return nil
+}
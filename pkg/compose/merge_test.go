@@ -0,0 +1,247 @@
+package compose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const baseFile = `
+version: "3.7"
+services:
+  web:
+    image: nginx:1.16
+    command: ["nginx", "-g", "daemon off;"]
+    environment:
+      LOG_LEVEL: debug
+      REGION: eu
+    labels:
+      tier: front
+    ports:
+      - "80:80"
+      - "443:443"
+    volumes:
+      - data:/data
+      - logs:/logs
+    secrets:
+      - password
+    deploy:
+      replicas: 1
+      resources:
+        limits:
+          memory: 256m
+      placement:
+        constraints: [node.role == worker]
+  db:
+    image: postgres
+networks:
+  front:
+    driver: overlay
+    labels:
+      tier: front
+secrets:
+  password:
+    labels:
+      tier: front
+`
+
+const prodFile = `
+version: "3.8"
+services:
+  web:
+    image: nginx:1.17
+    command: ["nginx"]
+    environment:
+      - LOG_LEVEL=warn
+    labels:
+      env: prod
+    ports:
+      - published: 443
+        target: 443
+        protocol: tcp
+        mode: host
+      - "8080:8080"
+    volumes:
+      - type: volume
+        source: fast
+        target: /data
+    secrets:
+      - source: password
+        mode: 0400
+    deploy:
+      replicas: 5
+      resources:
+        limits:
+          cpus: "2"
+  cache:
+    image: redis
+networks:
+  front:
+    attachable: true
+`
+
+func TestMergeFiles(t *testing.T) {
+	require := require.New(t)
+
+	config, err := MergeFiles([]File{
+		{Name: "docker-compose.yml", Data: []byte(baseFile)},
+		{Name: "docker-compose.prod.yml", Data: []byte(prodFile)},
+	}, nil)
+	require.NoError(err)
+	require.Equal("3.8", config.Version)
+
+	// Services are merged by name, new ones are appended
+	require.Len(config.Services, 3)
+	require.Equal("web", config.Services[0].Name)
+	require.Equal("db", config.Services[1].Name)
+	require.Equal("cache", config.Services[2].Name)
+
+	web := config.Services[0]
+	// Scalars and lists are replaced, mappings are merged
+	require.Equal("nginx:1.17", web.Image)
+	require.Equal(ShellCommand{"nginx"}, web.Command)
+	require.Equal(Environment{"LOG_LEVEL=warn", "REGION=eu"}, web.Environment)
+	require.Equal(Labels{"tier": "front", "env": "prod"}, web.Labels)
+
+	// Ports, volumes and secrets are merged by key
+	require.Equal(Ports{
+		{Mode: "ingress", Target: 80, Published: 80, Protocol: "tcp"},
+		{Mode: "host", Target: 443, Published: 443, Protocol: "tcp"},
+		{Mode: "ingress", Target: 8080, Published: 8080, Protocol: "tcp"},
+	}, web.Ports)
+	require.Equal(Volumes{
+		{Type: "volume", Source: "fast", Target: "/data"},
+		{Type: "volume", Source: "logs", Target: "/logs"},
+	}, web.Volumes)
+	require.Len(web.Secrets, 1)
+	require.Equal("password", web.Secrets[0].Source)
+	require.Equal(uint32(0400), *web.Secrets[0].Mode)
+
+	// Nested sections are merged rather than replaced
+	require.Equal(uint64(5), *web.Deploy.Replicas)
+	require.Equal("2", web.Deploy.Resources.Limits.NanoCPUs)
+	require.Equal(UnitBytes(256<<20), web.Deploy.Resources.Limits.MemoryBytes)
+	require.Equal([]string{"node.role == worker"}, web.Deploy.Placement.Constraints)
+
+	require.Equal(Network{Driver: "overlay", Attachable: true, Labels: Labels{"tier": "front"}}, config.Networks["front"])
+	require.Equal(Labels{"tier": "front"}, config.Secrets[0].Labels)
+}
+
+func TestLoadFiles(t *testing.T) {
+	require := require.New(t)
+
+	files, err := SplitDocuments([]byte(baseFile + "---\n" + prodFile))
+	require.NoError(err)
+	require.Len(files, 2)
+
+	spec, err := LoadFiles(files, nil)
+	require.NoError(err)
+	require.Equal("-,-", spec.Annotations.Labels[types.StackComposeFilesLabel])
+	require.Len(spec.Services, 3)
+
+	web := spec.Services[0]
+	require.Equal("nginx:1.17", web.TaskTemplate.ContainerSpec.Image)
+	require.Equal(uint64(5), *web.Mode.Replicated.Replicas)
+	require.Equal(int64(2e9), web.TaskTemplate.Resources.Limits.NanoCPUs)
+	require.Equal(int64(256<<20), web.TaskTemplate.Resources.Limits.MemoryBytes)
+	require.Equal([]mount.Mount{
+		{Type: mount.TypeVolume, Source: "fast", Target: "/data"},
+		{Type: mount.TypeVolume, Source: "logs", Target: "/logs"},
+	}, web.TaskTemplate.ContainerSpec.Mounts)
+
+	// The merged StackSpec is rendered as it was applied
+	data, err := Render(spec)
+	require.NoError(err)
+	loaded, err := Load(data)
+	require.NoError(err)
+	require.True(equal(spec, loaded))
+}
+
+func TestMergeFilesExtends(t *testing.T) {
+	require := require.New(t)
+
+	common := `
+services:
+  app:
+    image: app
+    environment:
+      - MODE=common
+    deploy:
+      update_config:
+        delay: 10s
+`
+	config, err := MergeFiles([]File{
+		{Name: "docker-compose.yml", Data: []byte(`
+services:
+  base:
+    image: base
+    labels:
+      a: "1"
+  web:
+    extends: base
+    labels:
+      b: "2"
+  worker:
+    extends:
+      service: app
+      file: common.yml
+    environment:
+      - ROLE=worker
+`)},
+		{Name: "docker-compose.prod.yml", Data: []byte(`
+services:
+  worker:
+    image: app:prod
+`)},
+	}, []File{{Name: "common.yml", Data: []byte(common)}})
+	require.NoError(err)
+
+	// The services of extended files are not merged
+	require.Len(config.Services, 3)
+	web := config.Services[1]
+	require.Equal("web", web.Name)
+	require.Nil(web.Extends)
+	require.Equal("base", web.Image)
+	require.Equal(Labels{"a": "1", "b": "2"}, web.Labels)
+
+	worker := config.Services[2]
+	require.Equal("app:prod", worker.Image)
+	require.Equal(Environment{"MODE=common", "ROLE=worker"}, worker.Environment)
+	require.Equal(Duration(10*time.Second), *worker.Deploy.UpdateConfig.Delay)
+
+	spec, err := LoadFiles([]File{{Data: []byte(`
+services:
+  web:
+    extends: base
+  base:
+    image: base
+    deploy:
+      mode: global
+`)}}, nil)
+	require.NoError(err)
+	require.Equal("web", spec.Services[0].Annotations.Name)
+	require.Equal(swarm.ServiceMode{Global: &swarm.GlobalService{}}, spec.Services[0].Mode)
+	require.NotContains(spec.Annotations.Labels, types.StackComposeFilesLabel)
+}
+
+func TestMergeFilesInvalid(t *testing.T) {
+	for name, files := range map[string][]File{
+		"none":       nil,
+		"cycle":      {{Data: []byte("services:\n  a:\n    extends: b\n  b:\n    extends: a\n")}},
+		"missing":    {{Data: []byte("services:\n  a:\n    extends: b\n")}},
+		"file":       {{Data: []byte("services:\n  a:\n    extends:\n      service: b\n      file: other.yml\n")}},
+		"duplicate":  {{Name: "a.yml", Data: []byte("services: {}\n")}, {Name: "a.yml", Data: []byte("services: {}\n")}},
+		"invalid":    {{Data: []byte("services:\n  a:\n    unknown: 1\n")}},
+		"not a file": {{Data: []byte("- a\n")}},
+	} {
+		_, err := LoadFiles(files, nil)
+		require.Error(t, err, name)
+		require.True(t, errdefs.IsInvalidParameter(err), name)
+	}
+}
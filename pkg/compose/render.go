@@ -0,0 +1,388 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// Version is the version of the Compose files rendered by Render.
+	Version = "3.8"
+
+	// StackExtension is the top level extension of a Compose file holding
+	// the fields of its StackSpec which have no Compose equivalent.
+	StackExtension = "x-stacks"
+
+	// SwarmExtension is the extension of a service, network, secret or
+	// config holding the fields of its Swarm spec which have no Compose
+	// equivalent.
+	SwarmExtension = "x-swarm"
+)
+
+// Render returns the Compose file of spec.
+func Render(spec types.StackSpec) ([]byte, error) {
+	config, err := FromStackSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(config)
+}
+
+// FromStackSpec returns the Compose file of spec.
+func FromStackSpec(spec types.StackSpec) (Config, error) {
+	config := Config{Version: Version}
+
+	seen := map[string]bool{}
+	for _, serviceSpec := range spec.Services {
+		if seen[serviceSpec.Annotations.Name] {
+			return Config{}, fmt.Errorf("duplicate service %s", serviceSpec.Annotations.Name)
+		}
+		seen[serviceSpec.Annotations.Name] = true
+		service, err := renderService(serviceSpec)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Services = append(config.Services, service)
+	}
+
+	for name, create := range spec.Networks {
+		net, err := renderNetwork(create)
+		if err != nil {
+			return Config{}, err
+		}
+		if config.Networks == nil {
+			config.Networks = map[string]Network{}
+		}
+		config.Networks[name] = net
+	}
+
+	seen = map[string]bool{}
+	for _, secretSpec := range spec.Secrets {
+		if seen[secretSpec.Annotations.Name] {
+			return Config{}, fmt.Errorf("duplicate secret %s", secretSpec.Annotations.Name)
+		}
+		seen[secretSpec.Annotations.Name] = true
+		secret, err := renderSecret(secretSpec)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Secrets = append(config.Secrets, secret)
+	}
+
+	seen = map[string]bool{}
+	for _, configSpec := range spec.Configs {
+		if seen[configSpec.Annotations.Name] {
+			return Config{}, fmt.Errorf("duplicate config %s", configSpec.Annotations.Name)
+		}
+		seen[configSpec.Annotations.Name] = true
+		obj, err := renderConfig(configSpec)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Configs = append(config.Configs, obj)
+	}
+
+	residual := types.StackSpec{
+		Annotations:      spec.Annotations,
+		Collection:       spec.Collection,
+		ResourceNaming:   spec.ResourceNaming,
+		UpdateConfig:     spec.UpdateConfig,
+		DeployStrategies: spec.DeployStrategies,
+	}
+	if !equal(residual, types.StackSpec{}) {
+		extension, err := encodeExtension(residual)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Extensions = map[string]interface{}{StackExtension: extension}
+	}
+	return config, nil
+}
+
+// baselineService is the ServiceSpec loaded from a Compose service
+// without options.
+func baselineService() swarm.ServiceSpec {
+	return swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{ContainerSpec: &swarm.ContainerSpec{}},
+	}
+}
+
+func renderService(spec swarm.ServiceSpec) (Service, error) {
+	service := Service{Name: spec.Annotations.Name}
+
+	var residual swarm.ServiceSpec
+	if err := copyJSON(spec, &residual); err != nil {
+		return Service{}, err
+	}
+	residual.Annotations.Name = ""
+
+	for _, field := range serviceFields {
+		var candidate Service
+		field.render(spec, &candidate)
+		if !roundTrips(field, candidate, spec) {
+			continue
+		}
+		field.render(spec, &service)
+		field.clear(&residual)
+	}
+
+	if !equal(residual, baselineService()) {
+		extension, err := encodeExtension(residual)
+		if err != nil {
+			return Service{}, err
+		}
+		service.Extensions = map[string]interface{}{SwarmExtension: extension}
+	}
+	return service, nil
+}
+
+// roundTrips returns whether the Compose options rendered by field from
+// spec are loaded back as they were.
+func roundTrips(field serviceField, candidate Service, spec swarm.ServiceSpec) bool {
+	data, err := yaml.Marshal(candidate)
+	if err != nil {
+		return false
+	}
+	var service Service
+	if err := yaml.UnmarshalStrict(data, &service); err != nil {
+		return false
+	}
+
+	var loaded swarm.ServiceSpec
+	if err := copyJSON(spec, &loaded); err != nil {
+		return false
+	}
+	field.clear(&loaded)
+	if err := field.load(service, &loaded); err != nil {
+		return false
+	}
+	return equal(loaded, spec)
+}
+
+func renderNetwork(create dockerTypes.NetworkCreate) (Network, error) {
+	net := Network{
+		Driver:     create.Driver,
+		DriverOpts: create.Options,
+		Internal:   create.Internal,
+		Attachable: create.Attachable,
+		Labels:     create.Labels,
+	}
+	residual := dockerTypes.NetworkCreate{
+		CheckDuplicate: create.CheckDuplicate,
+		Scope:          create.Scope,
+		EnableIPv6:     create.EnableIPv6,
+		Ingress:        create.Ingress,
+		ConfigOnly:     create.ConfigOnly,
+		ConfigFrom:     create.ConfigFrom,
+	}
+	if create.IPAM != nil {
+		ipam := &IPAM{Driver: create.IPAM.Driver}
+		for _, pool := range create.IPAM.Config {
+			ipam.Config = append(ipam.Config, IPAMPool{Subnet: pool.Subnet})
+		}
+		if equal(loadIPAM(ipam), create.IPAM) {
+			net.Ipam = ipam
+		} else {
+			residual.IPAM = create.IPAM
+		}
+	}
+	if !equal(residual, dockerTypes.NetworkCreate{}) {
+		extension, err := encodeExtension(residual)
+		if err != nil {
+			return Network{}, err
+		}
+		net.Extensions = map[string]interface{}{SwarmExtension: extension}
+	}
+	return net, nil
+}
+
+func loadIPAM(ipam *IPAM) *network.IPAM {
+	if ipam == nil {
+		return nil
+	}
+	loaded := &network.IPAM{Driver: ipam.Driver}
+	for _, pool := range ipam.Config {
+		loaded.Config = append(loaded.Config, network.IPAMConfig{Subnet: pool.Subnet})
+	}
+	return loaded
+}
+
+func renderSecret(spec swarm.SecretSpec) (Secret, error) {
+	secret := Secret{
+		Name:   spec.Annotations.Name,
+		Labels: spec.Annotations.Labels,
+	}
+	residual := swarm.SecretSpec{Data: spec.Data}
+	if spec.Driver != nil {
+		if spec.Driver.Name != "" {
+			secret.Driver = spec.Driver.Name
+			secret.DriverOpts = spec.Driver.Options
+		} else {
+			residual.Driver = spec.Driver
+		}
+	}
+	if spec.Templating != nil {
+		if spec.Templating.Name != "" && len(spec.Templating.Options) == 0 {
+			secret.TemplateDriver = spec.Templating.Name
+		} else {
+			residual.Templating = spec.Templating
+		}
+	}
+	if !equal(residual, swarm.SecretSpec{}) {
+		extension, err := encodeExtension(residual)
+		if err != nil {
+			return Secret{}, err
+		}
+		secret.Extensions = map[string]interface{}{SwarmExtension: extension}
+	}
+	return secret, nil
+}
+
+func renderConfig(spec swarm.ConfigSpec) (ConfigObj, error) {
+	config := ConfigObj{
+		Name:   spec.Annotations.Name,
+		Labels: spec.Annotations.Labels,
+	}
+	residual := swarm.ConfigSpec{Data: spec.Data}
+	if spec.Templating != nil {
+		if spec.Templating.Name != "" && len(spec.Templating.Options) == 0 {
+			config.TemplateDriver = spec.Templating.Name
+		} else {
+			residual.Templating = spec.Templating
+		}
+	}
+	if !equal(residual, swarm.ConfigSpec{}) {
+		extension, err := encodeExtension(residual)
+		if err != nil {
+			return ConfigObj{}, err
+		}
+		config.Extensions = map[string]interface{}{SwarmExtension: extension}
+	}
+	return config, nil
+}
+
+// encodeExtension returns the JSON fields of value, as they are in the
+// Stacks API, in a form rendered as YAML. The fields which are decoded
+// back as they are when they are left out, as the zero values of structs
+// and numbers, are dropped.
+func encodeExtension(value interface{}) (interface{}, error) {
+	fields, err := jsonFields(value)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := jsonFields(reflect.Zero(reflect.TypeOf(value)).Interface())
+	if err != nil {
+		return nil, err
+	}
+	return extensionValue(fields, zero), nil
+}
+
+func jsonFields(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// extensionValue drops the null fields of a decoded JSON value, and those
+// equal to the ones of zero, and turns its numbers into the integers or
+// floats the YAML encoder renders.
+func extensionValue(value, zero interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		zeroFields, _ := zero.(map[string]interface{})
+		for key, field := range value {
+			if field == nil || reflect.DeepEqual(field, zeroFields[key]) {
+				delete(value, key)
+				continue
+			}
+			value[key] = extensionValue(field, zeroFields[key])
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = extensionValue(item, nil)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return value
+}
+
+// copyJSON copies from into to through their JSON encoding, as the Stacks
+// API does.
+func copyJSON(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// equal returns whether a and b are equal, once copied through their JSON
+// encoding, and without telling empty slices and maps from nil ones.
+func equal(a, b interface{}) bool {
+	ca := reflect.New(reflect.TypeOf(a))
+	cb := reflect.New(reflect.TypeOf(b))
+	if copyJSON(a, ca.Interface()) != nil || copyJSON(b, cb.Interface()) != nil {
+		return false
+	}
+	normalize(ca.Elem())
+	normalize(cb.Elem())
+	return reflect.DeepEqual(ca.Elem().Interface(), cb.Elem().Interface())
+}
+
+// normalize sets the empty slices and maps reachable from the settable
+// value v to nil.
+func normalize(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalize(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				normalize(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			normalize(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			normalize(value)
+			v.SetMapIndex(key, value)
+		}
+	}
+}
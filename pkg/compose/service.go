@@ -0,0 +1,880 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/opts"
+)
+
+// serviceField converts a group of fields of a ServiceSpec to and from
+// the Compose service options representing them together.
+type serviceField struct {
+	// render sets the options of service from spec.
+	render func(spec swarm.ServiceSpec, service *Service)
+	// load sets the fields of spec from the options of service, leaving
+	// spec untouched when the options are not given.
+	load func(service Service, spec *swarm.ServiceSpec) error
+	// clear resets the fields of spec.
+	clear func(spec *swarm.ServiceSpec)
+}
+
+// containerField returns a serviceField converting fields of the
+// ContainerSpec of services, which plugin services do not have.
+func containerField(
+	render func(c swarm.ContainerSpec, service *Service),
+	load func(service Service, spec *swarm.ServiceSpec) error,
+	clear func(c *swarm.ContainerSpec),
+) serviceField {
+	return serviceField{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if c := spec.TaskTemplate.ContainerSpec; c != nil {
+				render(*c, service)
+			}
+		},
+		load: load,
+		clear: func(spec *swarm.ServiceSpec) {
+			if c := spec.TaskTemplate.ContainerSpec; c != nil {
+				clear(c)
+			}
+		},
+	}
+}
+
+// containerSpec returns the ContainerSpec of spec, setting an empty one if
+// it has none.
+func containerSpec(spec *swarm.ServiceSpec) *swarm.ContainerSpec {
+	if spec.TaskTemplate.ContainerSpec == nil {
+		spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{}
+	}
+	return spec.TaskTemplate.ContainerSpec
+}
+
+// deploy returns the deploy section of service, setting an empty one if it
+// has none.
+func deploy(service *Service) *Deploy {
+	if service.Deploy == nil {
+		service.Deploy = &Deploy{}
+	}
+	return service.Deploy
+}
+
+// serviceFields are the fields of a ServiceSpec which have a Compose
+// equivalent. Each of them is rendered only if it is loaded back as it
+// was, and is otherwise kept in the x-swarm extension of the service.
+var serviceFields = []serviceField{
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Image = c.Image },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Image != "" {
+				containerSpec(spec).Image = service.Image
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Image = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Entrypoint = c.Command },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Entrypoint != nil {
+				containerSpec(spec).Command = service.Entrypoint
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Command = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Command = c.Args },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Command != nil {
+				containerSpec(spec).Args = service.Command
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Args = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Environment = c.Env },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Environment != nil {
+				containerSpec(spec).Env = service.Environment
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Env = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Labels = c.Labels },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Labels != nil {
+				containerSpec(spec).Labels = service.Labels
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Labels = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Hostname = c.Hostname },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Hostname != "" {
+				containerSpec(spec).Hostname = service.Hostname
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Hostname = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.WorkingDir = c.Dir },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.WorkingDir != "" {
+				containerSpec(spec).Dir = service.WorkingDir
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Dir = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.User = c.User },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.User != "" {
+				containerSpec(spec).User = service.User
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.User = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.StopSignal = c.StopSignal },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.StopSignal != "" {
+				containerSpec(spec).StopSignal = service.StopSignal
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.StopSignal = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			service.StopGracePeriod = renderDuration(c.StopGracePeriod)
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.StopGracePeriod != nil {
+				containerSpec(spec).StopGracePeriod = loadDuration(service.StopGracePeriod)
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.StopGracePeriod = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Tty = c.TTY },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Tty {
+				containerSpec(spec).TTY = true
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.TTY = false },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.StdinOpen = c.OpenStdin },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.StdinOpen {
+				containerSpec(spec).OpenStdin = true
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.OpenStdin = false },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.ReadOnly = c.ReadOnly },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.ReadOnly {
+				containerSpec(spec).ReadOnly = true
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.ReadOnly = false },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Init = c.Init },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Init != nil {
+				containerSpec(spec).Init = service.Init
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Init = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Isolation = string(c.Isolation) },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Isolation != "" {
+				containerSpec(spec).Isolation = container.Isolation(service.Isolation)
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Isolation = "" },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Sysctls = c.Sysctls },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Sysctls != nil {
+				containerSpec(spec).Sysctls = service.Sysctls
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Sysctls = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) { service.Healthcheck = renderHealthcheck(c.Healthcheck) },
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Healthcheck == nil {
+				return nil
+			}
+			healthcheck, err := loadHealthcheck(*service.Healthcheck)
+			if err != nil {
+				return err
+			}
+			containerSpec(spec).Healthcheck = healthcheck
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Healthcheck = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			for _, m := range c.Mounts {
+				service.Volumes = append(service.Volumes, renderMount(m))
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Volumes == nil {
+				return nil
+			}
+			mounts := []mount.Mount{}
+			for _, volume := range service.Volumes {
+				m, err := loadMount(volume)
+				if err != nil {
+					return err
+				}
+				mounts = append(mounts, m)
+			}
+			containerSpec(spec).Mounts = mounts
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Mounts = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			for _, secret := range c.Secrets {
+				reference := FileReference{Source: secret.SecretName}
+				if secret.File != nil {
+					reference = renderFileReference(secret.SecretName, secret.File.Name, secret.File.UID, secret.File.GID, uint32(secret.File.Mode))
+				}
+				service.Secrets = append(service.Secrets, reference)
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Secrets == nil {
+				return nil
+			}
+			references := []*swarm.SecretReference{}
+			for _, reference := range service.Secrets {
+				reference = loadFileReference(reference)
+				references = append(references, &swarm.SecretReference{
+					SecretName: reference.Source,
+					File: &swarm.SecretReferenceFileTarget{
+						Name: reference.Target,
+						UID:  reference.UID,
+						GID:  reference.GID,
+						Mode: os.FileMode(*reference.Mode),
+					},
+				})
+			}
+			containerSpec(spec).Secrets = references
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Secrets = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			for _, config := range c.Configs {
+				reference := FileReference{Source: config.ConfigName}
+				if config.File != nil {
+					reference = renderFileReference(config.ConfigName, config.File.Name, config.File.UID, config.File.GID, uint32(config.File.Mode))
+				}
+				service.Configs = append(service.Configs, reference)
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Configs == nil {
+				return nil
+			}
+			references := []*swarm.ConfigReference{}
+			for _, reference := range service.Configs {
+				reference = loadFileReference(reference)
+				references = append(references, &swarm.ConfigReference{
+					ConfigName: reference.Source,
+					File: &swarm.ConfigReferenceFileTarget{
+						Name: reference.Target,
+						UID:  reference.UID,
+						GID:  reference.GID,
+						Mode: os.FileMode(*reference.Mode),
+					},
+				})
+			}
+			containerSpec(spec).Configs = references
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Configs = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			for _, host := range c.Hosts {
+				// The hosts of swarmkit are "IP_address canonical_hostname
+				// [aliases...]", which Compose writes "hostname:IP_address".
+				if fields := strings.Fields(host); len(fields) == 2 {
+					host = fields[1] + ":" + fields[0]
+				}
+				service.ExtraHosts = append(service.ExtraHosts, host)
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.ExtraHosts == nil {
+				return nil
+			}
+			hosts := []string{}
+			for _, host := range service.ExtraHosts {
+				parts := strings.SplitN(host, ":", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("invalid extra host %s: expected hostname:ip", host)
+				}
+				hosts = append(hosts, parts[1]+" "+parts[0])
+			}
+			containerSpec(spec).Hosts = hosts
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.Hosts = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			if c.DNSConfig != nil {
+				service.DNS = c.DNSConfig.Nameservers
+				service.DNSSearch = c.DNSConfig.Search
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.DNS != nil || service.DNSSearch != nil {
+				containerSpec(spec).DNSConfig = &swarm.DNSConfig{
+					Nameservers: service.DNS,
+					Search:      service.DNSSearch,
+				}
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) { c.DNSConfig = nil },
+	),
+	containerField(
+		func(c swarm.ContainerSpec, service *Service) {
+			if c.Privileges != nil && c.Privileges.CredentialSpec != nil {
+				service.CredentialSpec = &CredentialSpec{
+					Config:   c.Privileges.CredentialSpec.Config,
+					File:     c.Privileges.CredentialSpec.File,
+					Registry: c.Privileges.CredentialSpec.Registry,
+				}
+			}
+		},
+		func(service Service, spec *swarm.ServiceSpec) error {
+			if service.CredentialSpec == nil {
+				return nil
+			}
+			c := containerSpec(spec)
+			if c.Privileges == nil {
+				c.Privileges = &swarm.Privileges{}
+			}
+			c.Privileges.CredentialSpec = &swarm.CredentialSpec{
+				Config:   service.CredentialSpec.Config,
+				File:     service.CredentialSpec.File,
+				Registry: service.CredentialSpec.Registry,
+			}
+			return nil
+		},
+		func(c *swarm.ContainerSpec) {
+			if c.Privileges == nil {
+				return
+			}
+			c.Privileges.CredentialSpec = nil
+			if c.Privileges.SELinuxContext == nil {
+				c.Privileges = nil
+			}
+		},
+	),
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if spec.Annotations.Labels != nil {
+				deploy(service).Labels = spec.Annotations.Labels
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy != nil && service.Deploy.Labels != nil {
+				spec.Annotations.Labels = service.Deploy.Labels
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.Annotations.Labels = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			switch {
+			case spec.Mode.Global != nil:
+				deploy(service).Mode = "global"
+			case spec.Mode.Replicated != nil:
+				deploy(service).Mode = "replicated"
+				deploy(service).Replicas = spec.Mode.Replicated.Replicas
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy == nil {
+				return nil
+			}
+			switch service.Deploy.Mode {
+			case "global":
+				if service.Deploy.Replicas != nil {
+					return fmt.Errorf("replicas cannot be set on a global service")
+				}
+				spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+			case "replicated", "":
+				if service.Deploy.Mode != "" || service.Deploy.Replicas != nil {
+					spec.Mode = swarm.ServiceMode{
+						Replicated: &swarm.ReplicatedService{Replicas: service.Deploy.Replicas},
+					}
+				}
+			default:
+				return fmt.Errorf("invalid deploy mode %s", service.Deploy.Mode)
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.Mode = swarm.ServiceMode{} },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if spec.UpdateConfig != nil {
+				deploy(service).UpdateConfig = renderUpdateConfig(*spec.UpdateConfig)
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy != nil && service.Deploy.UpdateConfig != nil {
+				spec.UpdateConfig = loadUpdateConfig(*service.Deploy.UpdateConfig)
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.UpdateConfig = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if spec.RollbackConfig != nil {
+				deploy(service).RollbackConfig = renderUpdateConfig(*spec.RollbackConfig)
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy != nil && service.Deploy.RollbackConfig != nil {
+				spec.RollbackConfig = loadUpdateConfig(*service.Deploy.RollbackConfig)
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.RollbackConfig = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if r := spec.TaskTemplate.Resources; r != nil {
+				deploy(service).Resources = &Resources{
+					Limits:       renderResource(r.Limits),
+					Reservations: renderResource(r.Reservations),
+				}
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy == nil || service.Deploy.Resources == nil {
+				return nil
+			}
+			limits, err := loadResource(service.Deploy.Resources.Limits)
+			if err != nil {
+				return err
+			}
+			reservations, err := loadResource(service.Deploy.Resources.Reservations)
+			if err != nil {
+				return err
+			}
+			spec.TaskTemplate.Resources = &swarm.ResourceRequirements{
+				Limits:       limits,
+				Reservations: reservations,
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.TaskTemplate.Resources = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if p := spec.TaskTemplate.RestartPolicy; p != nil {
+				deploy(service).RestartPolicy = &RestartPolicy{
+					Condition:   string(p.Condition),
+					Delay:       renderDuration(p.Delay),
+					MaxAttempts: p.MaxAttempts,
+					Window:      renderDuration(p.Window),
+				}
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy == nil || service.Deploy.RestartPolicy == nil {
+				return nil
+			}
+			p := service.Deploy.RestartPolicy
+			spec.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{
+				Condition:   swarm.RestartPolicyCondition(p.Condition),
+				Delay:       loadDuration(p.Delay),
+				MaxAttempts: p.MaxAttempts,
+				Window:      loadDuration(p.Window),
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.TaskTemplate.RestartPolicy = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			p := spec.TaskTemplate.Placement
+			if p == nil {
+				return
+			}
+			placement := &Placement{Constraints: p.Constraints, MaxReplicas: p.MaxReplicas}
+			for _, preference := range p.Preferences {
+				if preference.Spread != nil {
+					placement.Preferences = append(placement.Preferences, PlacementPreference{
+						Spread: preference.Spread.SpreadDescriptor,
+					})
+				}
+			}
+			deploy(service).Placement = placement
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Deploy == nil || service.Deploy.Placement == nil {
+				return nil
+			}
+			p := service.Deploy.Placement
+			placement := &swarm.Placement{Constraints: p.Constraints, MaxReplicas: p.MaxReplicas}
+			for _, preference := range p.Preferences {
+				placement.Preferences = append(placement.Preferences, swarm.PlacementPreference{
+					Spread: &swarm.SpreadOver{SpreadDescriptor: preference.Spread},
+				})
+			}
+			spec.TaskTemplate.Placement = placement
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.TaskTemplate.Placement = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			e := spec.EndpointSpec
+			if e == nil {
+				return
+			}
+			if e.Mode != "" {
+				deploy(service).EndpointMode = string(e.Mode)
+			}
+			for _, port := range e.Ports {
+				service.Ports = append(service.Ports, Port{
+					Mode:      string(port.PublishMode),
+					Target:    port.TargetPort,
+					Published: port.PublishedPort,
+					Protocol:  string(port.Protocol),
+				})
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			var mode string
+			if service.Deploy != nil {
+				mode = service.Deploy.EndpointMode
+			}
+			if mode == "" && service.Ports == nil {
+				return nil
+			}
+			endpoint := &swarm.EndpointSpec{Mode: swarm.ResolutionMode(mode)}
+			for _, port := range service.Ports {
+				endpoint.Ports = append(endpoint.Ports, swarm.PortConfig{
+					Protocol:      swarm.PortConfigProtocol(port.Protocol),
+					TargetPort:    port.Target,
+					PublishedPort: port.Published,
+					PublishMode:   swarm.PortConfigPublishMode(port.Mode),
+				})
+			}
+			spec.EndpointSpec = endpoint
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.EndpointSpec = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			for _, network := range spec.TaskTemplate.Networks {
+				service.Networks = append(service.Networks, ServiceNetwork{
+					Name:    network.Target,
+					Aliases: network.Aliases,
+				})
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Networks == nil {
+				return nil
+			}
+			networks := []swarm.NetworkAttachmentConfig{}
+			for _, network := range service.Networks {
+				networks = append(networks, swarm.NetworkAttachmentConfig{
+					Target:  network.Name,
+					Aliases: network.Aliases,
+				})
+			}
+			spec.TaskTemplate.Networks = networks
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.TaskTemplate.Networks = nil },
+	},
+	{
+		render: func(spec swarm.ServiceSpec, service *Service) {
+			if d := spec.TaskTemplate.LogDriver; d != nil {
+				service.Logging = &Logging{Driver: d.Name, Options: d.Options}
+			}
+		},
+		load: func(service Service, spec *swarm.ServiceSpec) error {
+			if service.Logging != nil {
+				spec.TaskTemplate.LogDriver = &swarm.Driver{
+					Name:    service.Logging.Driver,
+					Options: service.Logging.Options,
+				}
+			}
+			return nil
+		},
+		clear: func(spec *swarm.ServiceSpec) { spec.TaskTemplate.LogDriver = nil },
+	},
+}
+
+func renderDuration(d *time.Duration) *Duration {
+	if d == nil {
+		return nil
+	}
+	duration := Duration(*d)
+	return &duration
+}
+
+func loadDuration(d *Duration) *time.Duration {
+	if d == nil {
+		return nil
+	}
+	duration := time.Duration(*d)
+	return &duration
+}
+
+// renderOptionalDuration renders durations whose zero value means unset.
+func renderOptionalDuration(d time.Duration) *Duration {
+	if d == 0 {
+		return nil
+	}
+	return renderDuration(&d)
+}
+
+// loadOptionalDuration loads durations whose zero value means unset.
+func loadOptionalDuration(d *Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return time.Duration(*d)
+}
+
+func renderHealthcheck(h *container.HealthConfig) *Healthcheck {
+	if h == nil {
+		return nil
+	}
+	if len(h.Test) == 1 && h.Test[0] == "NONE" {
+		return &Healthcheck{Disable: true}
+	}
+	healthcheck := &Healthcheck{
+		Test:        h.Test,
+		Interval:    renderOptionalDuration(h.Interval),
+		Timeout:     renderOptionalDuration(h.Timeout),
+		StartPeriod: renderOptionalDuration(h.StartPeriod),
+	}
+	if h.Retries > 0 {
+		retries := uint64(h.Retries)
+		healthcheck.Retries = &retries
+	}
+	return healthcheck
+}
+
+func loadHealthcheck(h Healthcheck) (*container.HealthConfig, error) {
+	if h.Disable {
+		if len(h.Test) > 0 {
+			return nil, fmt.Errorf("a healthcheck cannot have a test and be disabled")
+		}
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	healthcheck := &container.HealthConfig{
+		Test:        h.Test,
+		Interval:    loadOptionalDuration(h.Interval),
+		Timeout:     loadOptionalDuration(h.Timeout),
+		StartPeriod: loadOptionalDuration(h.StartPeriod),
+	}
+	if h.Retries != nil {
+		healthcheck.Retries = int(*h.Retries)
+	}
+	return healthcheck, nil
+}
+
+func renderMount(m mount.Mount) Volume {
+	volume := Volume{
+		Type:        string(m.Type),
+		Source:      m.Source,
+		Target:      m.Target,
+		ReadOnly:    m.ReadOnly,
+		Consistency: string(m.Consistency),
+	}
+	if m.BindOptions != nil {
+		volume.Bind = &VolumeBind{Propagation: string(m.BindOptions.Propagation)}
+	}
+	if m.VolumeOptions != nil {
+		volume.Volume = &VolumeVolume{NoCopy: m.VolumeOptions.NoCopy}
+	}
+	if m.TmpfsOptions != nil {
+		volume.Tmpfs = &VolumeTmpfs{Size: m.TmpfsOptions.SizeBytes}
+	}
+	return volume
+}
+
+func loadMount(volume Volume) (mount.Mount, error) {
+	if volume.Target == "" {
+		return mount.Mount{}, fmt.Errorf("invalid volume %s: no target", volume.Source)
+	}
+	m := mount.Mount{
+		Type:        mount.Type(volume.Type),
+		Source:      volume.Source,
+		Target:      volume.Target,
+		ReadOnly:    volume.ReadOnly,
+		Consistency: mount.Consistency(volume.Consistency),
+	}
+	if volume.Bind != nil {
+		m.BindOptions = &mount.BindOptions{Propagation: mount.Propagation(volume.Bind.Propagation)}
+	}
+	if volume.Volume != nil {
+		m.VolumeOptions = &mount.VolumeOptions{NoCopy: volume.Volume.NoCopy}
+	}
+	if volume.Tmpfs != nil {
+		m.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: volume.Tmpfs.Size}
+	}
+	return m, nil
+}
+
+// renderFileReference renders a secret or config reference with all its
+// options, so that none of them takes a default when it is loaded.
+func renderFileReference(source, target, uid, gid string, mode uint32) FileReference {
+	return FileReference{Source: source, Target: target, UID: uid, GID: gid, Mode: &mode}
+}
+
+// loadFileReference sets the defaults of the docker CLI on the options of
+// a secret or config reference which are not given.
+func loadFileReference(reference FileReference) FileReference {
+	if reference.Target == "" {
+		reference.Target = reference.Source
+	}
+	if reference.UID == "" {
+		reference.UID = "0"
+	}
+	if reference.GID == "" {
+		reference.GID = "0"
+	}
+	if reference.Mode == nil {
+		mode := uint32(0444)
+		reference.Mode = &mode
+	}
+	return reference
+}
+
+func renderUpdateConfig(u swarm.UpdateConfig) *UpdateConfig {
+	parallelism := u.Parallelism
+	return &UpdateConfig{
+		Parallelism:     &parallelism,
+		Delay:           renderOptionalDuration(u.Delay),
+		FailureAction:   u.FailureAction,
+		Monitor:         renderOptionalDuration(u.Monitor),
+		MaxFailureRatio: u.MaxFailureRatio,
+		Order:           u.Order,
+	}
+}
+
+// loadUpdateConfig loads an update or rollback configuration. As with the
+// docker CLI, a single task is updated at a time unless the parallelism
+// is given.
+func loadUpdateConfig(u UpdateConfig) *swarm.UpdateConfig {
+	parallelism := uint64(1)
+	if u.Parallelism != nil {
+		parallelism = *u.Parallelism
+	}
+	return &swarm.UpdateConfig{
+		Parallelism:     parallelism,
+		Delay:           loadOptionalDuration(u.Delay),
+		FailureAction:   u.FailureAction,
+		Monitor:         loadOptionalDuration(u.Monitor),
+		MaxFailureRatio: u.MaxFailureRatio,
+		Order:           u.Order,
+	}
+}
+
+func renderResource(r *swarm.Resources) *Resource {
+	if r == nil {
+		return nil
+	}
+	resource := &Resource{MemoryBytes: UnitBytes(r.MemoryBytes)}
+	if r.NanoCPUs != 0 {
+		resource.NanoCPUs = strconv.FormatFloat(float64(r.NanoCPUs)/1e9, 'f', -1, 64)
+	}
+	for _, generic := range r.GenericResources {
+		var discrete *DiscreteGenericResource
+		if generic.DiscreteResourceSpec != nil {
+			discrete = &DiscreteGenericResource{
+				Kind:  generic.DiscreteResourceSpec.Kind,
+				Value: generic.DiscreteResourceSpec.Value,
+			}
+		}
+		resource.GenericResources = append(resource.GenericResources, GenericResource{
+			DiscreteResourceSpec: discrete,
+		})
+	}
+	return resource
+}
+
+func loadResource(r *Resource) (*swarm.Resources, error) {
+	if r == nil {
+		return nil, nil
+	}
+	resources := &swarm.Resources{MemoryBytes: int64(r.MemoryBytes)}
+	if r.NanoCPUs != "" {
+		nanoCPUs, err := opts.ParseCPUs(r.NanoCPUs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpus %s: %s", r.NanoCPUs, err)
+		}
+		resources.NanoCPUs = nanoCPUs
+	}
+	for _, generic := range r.GenericResources {
+		if generic.DiscreteResourceSpec == nil {
+			return nil, fmt.Errorf("generic resources must be discrete_resource_spec")
+		}
+		resources.GenericResources = append(resources.GenericResources, swarm.GenericResource{
+			DiscreteResourceSpec: &swarm.DiscreteGenericResource{
+				Kind:  generic.DiscreteResourceSpec.Kind,
+				Value: generic.DiscreteResourceSpec.Value,
+			},
+		})
+	}
+	return resources, nil
+}
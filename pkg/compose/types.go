@@ -0,0 +1,725 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	units "github.com/docker/go-units"
+	shellwords "github.com/mattn/go-shellwords"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is a Compose file.
+type Config struct {
+	Version    string                 `yaml:"version,omitempty"`
+	Services   Services               `yaml:"services,omitempty"`
+	Networks   map[string]Network     `yaml:"networks,omitempty"`
+	Secrets    Secrets                `yaml:"secrets,omitempty"`
+	Configs    Configs                `yaml:"configs,omitempty"`
+	Extensions map[string]interface{} `yaml:",inline"`
+}
+
+// Service is a service of a Compose file.
+type Service struct {
+	Name string `yaml:"-"`
+
+	Extends         *Extends               `yaml:"extends,omitempty"`
+	Image           string                 `yaml:"image,omitempty"`
+	Entrypoint      ShellCommand           `yaml:"entrypoint,omitempty"`
+	Command         ShellCommand           `yaml:"command,omitempty"`
+	Environment     Environment            `yaml:"environment,omitempty"`
+	Labels          Labels                 `yaml:"labels,omitempty"`
+	Hostname        string                 `yaml:"hostname,omitempty"`
+	WorkingDir      string                 `yaml:"working_dir,omitempty"`
+	User            string                 `yaml:"user,omitempty"`
+	StopSignal      string                 `yaml:"stop_signal,omitempty"`
+	StopGracePeriod *Duration              `yaml:"stop_grace_period,omitempty"`
+	Tty             bool                   `yaml:"tty,omitempty"`
+	StdinOpen       bool                   `yaml:"stdin_open,omitempty"`
+	ReadOnly        bool                   `yaml:"read_only,omitempty"`
+	Init            *bool                  `yaml:"init,omitempty"`
+	Isolation       string                 `yaml:"isolation,omitempty"`
+	Sysctls         Labels                 `yaml:"sysctls,omitempty"`
+	Ports           Ports                  `yaml:"ports,omitempty"`
+	Volumes         Volumes                `yaml:"volumes,omitempty"`
+	Secrets         FileReferences         `yaml:"secrets,omitempty"`
+	Configs         FileReferences         `yaml:"configs,omitempty"`
+	Healthcheck     *Healthcheck           `yaml:"healthcheck,omitempty"`
+	Networks        ServiceNetworks        `yaml:"networks,omitempty"`
+	Logging         *Logging               `yaml:"logging,omitempty"`
+	DNS             StringList             `yaml:"dns,omitempty"`
+	DNSSearch       StringList             `yaml:"dns_search,omitempty"`
+	ExtraHosts      ExtraHosts             `yaml:"extra_hosts,omitempty"`
+	CredentialSpec  *CredentialSpec        `yaml:"credential_spec,omitempty"`
+	Deploy          *Deploy                `yaml:"deploy,omitempty"`
+	Extensions      map[string]interface{} `yaml:",inline"`
+}
+
+// Extends names the service a service extends, in the same Compose file
+// unless File is given.
+type Extends struct {
+	Service string `yaml:"service"`
+	File    string `yaml:"file,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *Extends) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var service string
+	if err := unmarshal(&service); err == nil {
+		*e = Extends{Service: service}
+		return nil
+	}
+	type extends Extends
+	return unmarshal((*extends)(e))
+}
+
+// Deploy is the deploy section of a service.
+type Deploy struct {
+	Mode           string         `yaml:"mode,omitempty"`
+	Replicas       *uint64        `yaml:"replicas,omitempty"`
+	Labels         Labels         `yaml:"labels,omitempty"`
+	UpdateConfig   *UpdateConfig  `yaml:"update_config,omitempty"`
+	RollbackConfig *UpdateConfig  `yaml:"rollback_config,omitempty"`
+	Resources      *Resources     `yaml:"resources,omitempty"`
+	RestartPolicy  *RestartPolicy `yaml:"restart_policy,omitempty"`
+	Placement      *Placement     `yaml:"placement,omitempty"`
+	EndpointMode   string         `yaml:"endpoint_mode,omitempty"`
+}
+
+// UpdateConfig is the update or rollback configuration of a service.
+type UpdateConfig struct {
+	Parallelism     *uint64   `yaml:"parallelism,omitempty"`
+	Delay           *Duration `yaml:"delay,omitempty"`
+	FailureAction   string    `yaml:"failure_action,omitempty"`
+	Monitor         *Duration `yaml:"monitor,omitempty"`
+	MaxFailureRatio float32   `yaml:"max_failure_ratio,omitempty"`
+	Order           string    `yaml:"order,omitempty"`
+}
+
+// Resources are the resource limits and reservations of a service.
+type Resources struct {
+	Limits       *Resource `yaml:"limits,omitempty"`
+	Reservations *Resource `yaml:"reservations,omitempty"`
+}
+
+// Resource is a resource limit or reservation.
+type Resource struct {
+	NanoCPUs         string            `yaml:"cpus,omitempty"`
+	MemoryBytes      UnitBytes         `yaml:"memory,omitempty"`
+	GenericResources []GenericResource `yaml:"generic_resources,omitempty"`
+}
+
+// GenericResource is a generic resource of a resource reservation.
+type GenericResource struct {
+	DiscreteResourceSpec *DiscreteGenericResource `yaml:"discrete_resource_spec,omitempty"`
+}
+
+// DiscreteGenericResource is a discrete generic resource.
+type DiscreteGenericResource struct {
+	Kind  string `yaml:"kind"`
+	Value int64  `yaml:"value"`
+}
+
+// RestartPolicy is the restart policy of a service.
+type RestartPolicy struct {
+	Condition   string    `yaml:"condition,omitempty"`
+	Delay       *Duration `yaml:"delay,omitempty"`
+	MaxAttempts *uint64   `yaml:"max_attempts,omitempty"`
+	Window      *Duration `yaml:"window,omitempty"`
+}
+
+// Placement are the placement constraints and preferences of a service.
+type Placement struct {
+	Constraints []string              `yaml:"constraints,omitempty"`
+	Preferences []PlacementPreference `yaml:"preferences,omitempty"`
+	MaxReplicas uint64                `yaml:"max_replicas_per_node,omitempty"`
+}
+
+// PlacementPreference is a placement preference of a service.
+type PlacementPreference struct {
+	Spread string `yaml:"spread"`
+}
+
+// Healthcheck is the healthcheck of a service.
+type Healthcheck struct {
+	Test        HealthcheckTest `yaml:"test,omitempty"`
+	Interval    *Duration       `yaml:"interval,omitempty"`
+	Timeout     *Duration       `yaml:"timeout,omitempty"`
+	StartPeriod *Duration       `yaml:"start_period,omitempty"`
+	Retries     *uint64         `yaml:"retries,omitempty"`
+	Disable     bool            `yaml:"disable,omitempty"`
+}
+
+// Logging is the logging configuration of a service.
+type Logging struct {
+	Driver  string            `yaml:"driver,omitempty"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// CredentialSpec is the credential spec of the service of a Windows
+// container.
+type CredentialSpec struct {
+	Config   string `yaml:"config,omitempty"`
+	File     string `yaml:"file,omitempty"`
+	Registry string `yaml:"registry,omitempty"`
+}
+
+// Port is a port published by a service.
+type Port struct {
+	Mode      string `yaml:"mode,omitempty"`
+	Target    uint32 `yaml:"target,omitempty"`
+	Published uint32 `yaml:"published,omitempty"`
+	Protocol  string `yaml:"protocol,omitempty"`
+}
+
+// Volume is a volume mounted by a service.
+type Volume struct {
+	Type        string        `yaml:"type,omitempty"`
+	Source      string        `yaml:"source,omitempty"`
+	Target      string        `yaml:"target,omitempty"`
+	ReadOnly    bool          `yaml:"read_only,omitempty"`
+	Consistency string        `yaml:"consistency,omitempty"`
+	Bind        *VolumeBind   `yaml:"bind,omitempty"`
+	Volume      *VolumeVolume `yaml:"volume,omitempty"`
+	Tmpfs       *VolumeTmpfs  `yaml:"tmpfs,omitempty"`
+}
+
+// VolumeBind are the options of a bind mount.
+type VolumeBind struct {
+	Propagation string `yaml:"propagation,omitempty"`
+}
+
+// VolumeVolume are the options of a volume mount.
+type VolumeVolume struct {
+	NoCopy bool `yaml:"nocopy,omitempty"`
+}
+
+// VolumeTmpfs are the options of a tmpfs mount.
+type VolumeTmpfs struct {
+	Size int64 `yaml:"size,omitempty"`
+}
+
+// FileReference is a secret or config given to a service.
+type FileReference struct {
+	Source string  `yaml:"source"`
+	Target string  `yaml:"target,omitempty"`
+	UID    string  `yaml:"uid,omitempty"`
+	GID    string  `yaml:"gid,omitempty"`
+	Mode   *uint32 `yaml:"mode,omitempty"`
+}
+
+// ServiceNetwork is a network a service is attached to.
+type ServiceNetwork struct {
+	Name    string   `yaml:"-"`
+	Aliases []string `yaml:"aliases,omitempty"`
+}
+
+// Network is a network of a Compose file.
+type Network struct {
+	Driver     string                 `yaml:"driver,omitempty"`
+	DriverOpts map[string]string      `yaml:"driver_opts,omitempty"`
+	Ipam       *IPAM                  `yaml:"ipam,omitempty"`
+	Internal   bool                   `yaml:"internal,omitempty"`
+	Attachable bool                   `yaml:"attachable,omitempty"`
+	Labels     Labels                 `yaml:"labels,omitempty"`
+	Extensions map[string]interface{} `yaml:",inline"`
+}
+
+// IPAM is the IP address management of a network.
+type IPAM struct {
+	Driver string     `yaml:"driver,omitempty"`
+	Config []IPAMPool `yaml:"config,omitempty"`
+}
+
+// IPAMPool is an address pool of a network.
+type IPAMPool struct {
+	Subnet string `yaml:"subnet"`
+}
+
+// Secret is a secret of a Compose file.
+type Secret struct {
+	Name           string                 `yaml:"-"`
+	Labels         Labels                 `yaml:"labels,omitempty"`
+	Driver         string                 `yaml:"driver,omitempty"`
+	DriverOpts     map[string]string      `yaml:"driver_opts,omitempty"`
+	TemplateDriver string                 `yaml:"template_driver,omitempty"`
+	Extensions     map[string]interface{} `yaml:",inline"`
+}
+
+// ConfigObj is a config of a Compose file.
+type ConfigObj struct {
+	Name           string                 `yaml:"-"`
+	Labels         Labels                 `yaml:"labels,omitempty"`
+	TemplateDriver string                 `yaml:"template_driver,omitempty"`
+	Extensions     map[string]interface{} `yaml:",inline"`
+}
+
+// Services are the services of a Compose file, in the order of the file.
+type Services []Service
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Services) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, 0, len(s))
+	for _, service := range s {
+		items = append(items, yaml.MapItem{Key: service.Name, Value: service})
+	}
+	return items, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *Services) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names, err := mappingKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	var byName map[string]Service
+	if err := unmarshal(&byName); err != nil {
+		return err
+	}
+	*s = make(Services, 0, len(names))
+	for _, name := range names {
+		service := byName[name]
+		service.Name = name
+		*s = append(*s, service)
+	}
+	return nil
+}
+
+// Secrets are the secrets of a Compose file, in the order of the file.
+type Secrets []Secret
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Secrets) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, 0, len(s))
+	for _, secret := range s {
+		items = append(items, yaml.MapItem{Key: secret.Name, Value: secret})
+	}
+	return items, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *Secrets) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names, err := mappingKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	var byName map[string]Secret
+	if err := unmarshal(&byName); err != nil {
+		return err
+	}
+	*s = make(Secrets, 0, len(names))
+	for _, name := range names {
+		secret := byName[name]
+		secret.Name = name
+		*s = append(*s, secret)
+	}
+	return nil
+}
+
+// Configs are the configs of a Compose file, in the order of the file.
+type Configs []ConfigObj
+
+// MarshalYAML implements yaml.Marshaler.
+func (c Configs) MarshalYAML() (interface{}, error) {
+	items := make(yaml.MapSlice, 0, len(c))
+	for _, config := range c {
+		items = append(items, yaml.MapItem{Key: config.Name, Value: config})
+	}
+	return items, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Configs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names, err := mappingKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	var byName map[string]ConfigObj
+	if err := unmarshal(&byName); err != nil {
+		return err
+	}
+	*c = make(Configs, 0, len(names))
+	for _, name := range names {
+		config := byName[name]
+		config.Name = name
+		*c = append(*c, config)
+	}
+	return nil
+}
+
+// ServiceNetworks are the networks of a service, given either as a list
+// of names or as a mapping of names to their aliases.
+type ServiceNetworks []ServiceNetwork
+
+// MarshalYAML implements yaml.Marshaler.
+func (n ServiceNetworks) MarshalYAML() (interface{}, error) {
+	names := make([]string, 0, len(n))
+	items := make(yaml.MapSlice, 0, len(n))
+	for _, network := range n {
+		names = append(names, network.Name)
+		items = append(items, yaml.MapItem{Key: network.Name, Value: network})
+	}
+	for _, network := range n {
+		if len(network.Aliases) > 0 {
+			return items, nil
+		}
+	}
+	return names, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (n *ServiceNetworks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		*n = make(ServiceNetworks, 0, len(names))
+		for _, name := range names {
+			*n = append(*n, ServiceNetwork{Name: name})
+		}
+		return nil
+	}
+
+	names, err := mappingKeys(unmarshal)
+	if err != nil {
+		return err
+	}
+	var byName map[string]*ServiceNetwork
+	if err := unmarshal(&byName); err != nil {
+		return err
+	}
+	*n = make(ServiceNetworks, 0, len(names))
+	for _, name := range names {
+		network := ServiceNetwork{Name: name}
+		if byName[name] != nil {
+			network.Aliases = byName[name].Aliases
+		}
+		*n = append(*n, network)
+	}
+	return nil
+}
+
+// mappingKeys returns the keys of a YAML mapping, in the order of the
+// document.
+func mappingKeys(unmarshal func(interface{}) error) ([]string, error) {
+	var items yaml.MapSlice
+	if err := unmarshal(&items); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		key, ok := item.Key.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key %v: keys must be strings", item.Key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %s", key)
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Ports are the ports of a service, in their short or long syntax.
+type Ports []Port
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *Ports) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []portItem
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	*p = Ports{}
+	for _, item := range items {
+		*p = append(*p, item...)
+	}
+	return nil
+}
+
+// portItem is an item of a list of ports, which expands to several ports
+// when it is a range in the short syntax.
+type portItem []Port
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *portItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var spec string
+	if err := unmarshal(&spec); err == nil {
+		ports, err := parsePorts(spec)
+		if err != nil {
+			return err
+		}
+		*p = ports
+		return nil
+	}
+	var port Port
+	if err := unmarshal(&port); err != nil {
+		return err
+	}
+	*p = portItem{port}
+	return nil
+}
+
+// Volumes are the volumes of a service, in their short or long syntax.
+type Volumes []Volume
+
+// volumeItem is an item of a list of volumes.
+type volumeItem Volume
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *Volumes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []volumeItem
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	*v = make(Volumes, 0, len(items))
+	for _, item := range items {
+		*v = append(*v, Volume(item))
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *volumeItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var spec string
+	if err := unmarshal(&spec); err == nil {
+		volume, err := parseVolume(spec)
+		if err != nil {
+			return err
+		}
+		*v = volumeItem(volume)
+		return nil
+	}
+	var volume Volume
+	if err := unmarshal(&volume); err != nil {
+		return err
+	}
+	*v = volumeItem(volume)
+	return nil
+}
+
+// FileReferences are the secrets or configs of a service, in their short
+// or long syntax.
+type FileReferences []FileReference
+
+// fileReferenceItem is an item of a list of secrets or configs.
+type fileReferenceItem FileReference
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (f *FileReferences) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []fileReferenceItem
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	*f = make(FileReferences, 0, len(items))
+	for _, item := range items {
+		*f = append(*f, FileReference(item))
+	}
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (f *fileReferenceItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var source string
+	if err := unmarshal(&source); err == nil {
+		*f = fileReferenceItem{Source: source}
+		return nil
+	}
+	var reference FileReference
+	if err := unmarshal(&reference); err != nil {
+		return err
+	}
+	*f = fileReferenceItem(reference)
+	return nil
+}
+
+// Duration is a duration, written as a Go duration string.
+type Duration time.Duration
+
+// MarshalYAML implements yaml.Marshaler.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// UnitBytes is a number of bytes, written with a binary unit suffix.
+type UnitBytes int64
+
+// MarshalYAML implements yaml.Marshaler.
+func (u UnitBytes) MarshalYAML() (interface{}, error) {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"g", units.GiB}, {"m", units.MiB}, {"k", units.KiB}} {
+		if u != 0 && int64(u)%unit.size == 0 {
+			return strconv.FormatInt(int64(u)/unit.size, 10) + unit.suffix, nil
+		}
+	}
+	return int64(u), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (u *UnitBytes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	bytes, err := units.RAMInBytes(value)
+	if err != nil {
+		return err
+	}
+	*u = UnitBytes(bytes)
+	return nil
+}
+
+// StringList is a list of strings, which may be given as a single string.
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*s = StringList{value}
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// ShellCommand is a command, which may be given as a string split like a
+// shell does.
+type ShellCommand []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *ShellCommand) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		words, err := shellwords.Parse(value)
+		if err != nil {
+			return err
+		}
+		*s = words
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// HealthcheckTest is the test of a healthcheck. A string is a command run
+// by the shell of the container.
+type HealthcheckTest []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (h *HealthcheckTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*h = HealthcheckTest{"CMD-SHELL", value}
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*h = values
+	return nil
+}
+
+// Environment are the environment variables of a service, given either
+// as a list of NAME=value or as a mapping. A variable without a value is
+// written without an equal sign.
+type Environment []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *Environment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values []string
+	if err := unmarshal(&values); err == nil {
+		*e = values
+		return nil
+	}
+	var mapping map[string]*string
+	if err := unmarshal(&mapping); err != nil {
+		return err
+	}
+	*e = make(Environment, 0, len(mapping))
+	for name, value := range mapping {
+		if value == nil {
+			*e = append(*e, name)
+		} else {
+			*e = append(*e, name+"="+*value)
+		}
+	}
+	sort.Strings(*e)
+	return nil
+}
+
+// Labels are labels, given either as a list of name=value or as a mapping.
+type Labels map[string]string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *Labels) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values []string
+	if err := unmarshal(&values); err == nil {
+		*l = make(Labels, len(values))
+		for _, value := range values {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) == 1 {
+				(*l)[parts[0]] = ""
+			} else {
+				(*l)[parts[0]] = parts[1]
+			}
+		}
+		return nil
+	}
+	var mapping map[string]string
+	if err := unmarshal(&mapping); err != nil {
+		return err
+	}
+	*l = mapping
+	return nil
+}
+
+// ExtraHosts are hosts added to /etc/hosts, given either as a list of
+// host:ip or as a mapping of hosts to their IP address.
+type ExtraHosts []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *ExtraHosts) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values []string
+	if err := unmarshal(&values); err == nil {
+		*e = values
+		return nil
+	}
+	var mapping map[string]string
+	if err := unmarshal(&mapping); err != nil {
+		return err
+	}
+	*e = make(ExtraHosts, 0, len(mapping))
+	for host, ip := range mapping {
+		*e = append(*e, host+":"+ip)
+	}
+	sort.Strings(*e)
+	return nil
+}
@@ -0,0 +1,290 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/policy"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+	"github.com/docker/stacks/pkg/validation"
+)
+
+// Client implements the client.StackAPIClient interface for the "none"
+// orchestrator, running stacks as plain containers.
+//
+// Stacks are reconciled as soon as they change, and the errors reconciling
+// them are reported in their status. When a router.StacksRouter serves the
+// Client next to other backends, its StackStore must not give stacks the
+// IDs of the stacks of the other backends.
+type Client struct {
+	store      interfaces.StackStore
+	reconciler *Reconciler
+	admission  *admission.Chain
+	policy     *policy.Engine
+}
+
+// ClientOptionFunc is the type used for functional arguments of the Client
+// during its creation.
+type ClientOptionFunc func(*Client)
+
+// WithAdmissionChain is a ClientOptionFunc which submits the specs of
+// created and updated stacks to the webhooks of chain.
+func WithAdmissionChain(chain *admission.Chain) ClientOptionFunc {
+	return func(c *Client) {
+		c.admission = chain
+	}
+}
+
+// WithPolicy is a ClientOptionFunc which evaluates the rules of engine over
+// the specs of created and updated stacks.
+func WithPolicy(engine *policy.Engine) ClientOptionFunc {
+	return func(c *Client) {
+		c.policy = engine
+	}
+}
+
+// NewClient creates a new Client storing stacks in store, which reconciler
+// runs.
+func NewClient(store interfaces.StackStore, reconciler *Reconciler, optsFunc ...ClientOptionFunc) *Client {
+	c := &Client{
+		store:      store,
+		reconciler: reconciler,
+	}
+	for _, f := range optsFunc {
+		f(c)
+	}
+	return c
+}
+
+// StackCreate creates a stack and runs its containers.
+func (c *Client) StackCreate(ctx context.Context, spec types.StackSpec, _ types.StackCreateOptions) (types.StackCreateResponse, error) {
+	spec, err := secrets.RestoreNew(spec)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	spec, err = c.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionCreate,
+		Spec:      spec,
+	})
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	if err := c.validator().Error(spec); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	id, err := c.store.AddStackWithOrchestrator(spec, types.OrchestratorNone)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	c.reconcile(ctx, id)
+	return types.StackCreateResponse{ID: id}, nil
+}
+
+// StackValidate validates a StackSpec, including the parts of it which
+// cannot run as plain containers.
+func (c *Client) StackValidate(_ context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	return c.validator().Validate(spec), nil
+}
+
+// StackInspect returns a stack by its ID.
+func (c *Client) StackInspect(_ context.Context, id string) (types.Stack, error) {
+	return c.store.GetStack(id)
+}
+
+// StackList lists the stacks selected by options.
+func (c *Client) StackList(_ context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	return c.store.ListStacks(options)
+}
+
+// StackUpdate updates a stack at version, and its containers. The name of
+// a stack cannot change, as its containers and networks are named after it.
+func (c *Client) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, _ types.StackUpdateOptions) error {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if spec.Annotations.Name != stack.Spec.Annotations.Name {
+		return errdefs.InvalidParameter(fmt.Errorf("the name of stack %s cannot change", id))
+	}
+	if spec, err = secrets.Restore(spec, stack.Spec); err != nil {
+		return err
+	}
+	spec, err = c.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionUpdate,
+		ID:        stack.ID,
+		Spec:      spec,
+		OldSpec:   &stack.Spec,
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.validator().Error(spec); err != nil {
+		return err
+	}
+
+	if err := c.store.UpdateStack(stack.ID, spec, version.Index); err != nil {
+		return err
+	}
+	c.reconcile(ctx, stack.ID)
+	return nil
+}
+
+// StackScale sets the number of replicas of services of a stack, that is
+// their number of containers. The scaled spec is admitted and validated as
+// any other update.
+func (c *Client) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return err
+	}
+	return interfaces.ScaleStackServices(&stackUpdater{ctx: ctx, client: c}, stack.ID, replicas)
+}
+
+// StackPause stops the reconciliation of a stack. Its containers keep
+// running, and the stack is reconciled once more to report its drift.
+func (c *Client) StackPause(ctx context.Context, id string) error {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if err := interfaces.SetStackPaused(c.store, stack.ID, true); err != nil {
+		return err
+	}
+	c.reconcile(ctx, stack.ID)
+	return nil
+}
+
+// StackResume resumes the reconciliation of a stack, which reverts its
+// drift.
+func (c *Client) StackResume(ctx context.Context, id string) error {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if err := interfaces.SetStackPaused(c.store, stack.ID, false); err != nil {
+		return err
+	}
+	c.reconcile(ctx, stack.ID)
+	return nil
+}
+
+// StackPatch applies a patch to the spec of a stack, at version unless
+// version is the zero Version.
+func (c *Client) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, data []byte, options types.StackUpdateOptions) error {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if version.Index != 0 && version.Index != stack.Version.Index {
+		return types.StackVersionConflict{
+			ID:      stack.ID,
+			Current: types.Version{Index: stack.Version.Index},
+		}
+	}
+
+	spec, err := patch.Apply(stack.Spec, patchType, data)
+	if err != nil {
+		return err
+	}
+	return c.StackUpdate(ctx, stack.ID, types.Version{Index: stack.Version.Index}, spec, options)
+}
+
+// StackDelete deletes a stack, along with its containers, networks and
+// files. Deleting a stack which does not exist succeeds.
+func (c *Client) StackDelete(ctx context.Context, id string) error {
+	stack, err := c.store.GetStack(id)
+	if errdefs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.store.DeleteStack(stack.ID); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return c.reconciler.Reconcile(ctx, stack.ID)
+}
+
+// StackMigrate is not implemented, see types.ErrStackNotMigratable.
+func (c *Client) StackMigrate(_ context.Context, id string, _ types.StackMigrateOptions) (types.StackMigration, error) {
+	return types.StackMigration{}, types.ErrStackNotMigratable(id)
+}
+
+// StackTasks returns the tasks of a stack, which are the containers of its
+// services.
+func (c *Client) StackTasks(ctx context.Context, id string) ([]types.StackTask, error) {
+	stack, err := c.store.GetStack(id)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := c.reconciler.engine.ContainerList(ctx, dockerTypes.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(interfaces.StackLabelArg(stack.ID)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]types.StackTask, 0, len(containers))
+	for _, container := range containers {
+		tasks = append(tasks, types.StackTask{
+			ID:           container.ID,
+			Name:         strings.TrimPrefix(container.Names[0], "/"),
+			Image:        container.Image,
+			DesiredState: "running",
+			CurrentState: container.State,
+		})
+	}
+	return tasks, nil
+}
+
+// reconcile reconciles the stack id after a change. The errors are reported
+// in the status of the stack by the Reconciler, and the stack is
+// reconciled again by the next change or removal of its containers.
+func (c *Client) reconcile(ctx context.Context, id string) {
+	if err := c.reconciler.Reconcile(ctx, id); err != nil {
+		logrus.Errorf("unable to reconcile stack %s: %s", id, err)
+	}
+}
+
+// admit submits request to the admission webhooks of the client, if any.
+func (c *Client) admit(request types.StackAdmissionRequest) (types.StackSpec, error) {
+	if c.admission == nil {
+		return request.Spec, nil
+	}
+	return c.admission.Admit(request)
+}
+
+// validator checks StackSpecs against what can run as plain containers and
+// the policy rules of the client, if any.
+func (c *Client) validator() *validation.Validator {
+	if c.policy == nil {
+		return validation.NewValidator(nil, validation.WithChecker(checker{}))
+	}
+	return validation.NewValidator(nil, validation.WithChecker(checker{}), validation.WithChecker(c.policy))
+}
+
+// stackUpdater is the interfaces.StackUpdater updating stacks through the
+// Client, so that their specs are checked and reconciled
+type stackUpdater struct {
+	ctx    context.Context
+	client *Client
+}
+
+func (u *stackUpdater) GetStack(id string) (types.Stack, error) {
+	return u.client.store.GetStack(id)
+}
+
+func (u *stackUpdater) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	return u.client.StackUpdate(u.ctx, id, types.Version{Index: version}, spec, types.StackUpdateOptions{})
+}
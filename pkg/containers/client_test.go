@@ -0,0 +1,264 @@
+package containers_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/containers"
+	"github.com/docker/stacks/pkg/containers/fake"
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestSpec() types.StackSpec {
+	replicas := uint64(2)
+	return types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "web"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image: "nginx:1.16",
+					Secrets: []*swarm.SecretReference{{
+						SecretName: "password",
+					}},
+				},
+				Networks: []swarm.NetworkAttachmentConfig{{Target: "front"}},
+			},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		}},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"front": {Driver: "overlay"},
+		},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "password"},
+			Data:        []byte("hunter2"),
+		}},
+	}
+}
+
+type testClient struct {
+	*containers.Client
+	reconciler *containers.Reconciler
+	engine     *fake.Engine
+	dir        string
+}
+
+func newTestClient(t *testing.T) testClient {
+	dir, err := ioutil.TempDir("", "containers")
+	require.NoError(t, err)
+	store := fakes.NewFakeStackStore()
+	engine := fake.NewEngine()
+	reconciler := containers.NewReconciler(store, engine, dir)
+	return testClient{
+		Client:     containers.NewClient(store, reconciler),
+		reconciler: reconciler,
+		engine:     engine,
+		dir:        dir,
+	}
+}
+
+func TestStackLifecycle(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	cli := newTestClient(t)
+	defer os.RemoveAll(cli.dir)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorNone), stack.Orchestrator)
+	require.Equal(types.StackPhaseUpdated, stack.Status.Phase)
+	require.Empty(stack.Status.Message)
+
+	network, ok := cli.engine.Network("app_front")
+	require.True(ok)
+	require.Equal("bridge", network.Driver)
+
+	secretPath := filepath.Join(cli.dir, resp.ID, "secrets", "password")
+	data, err := ioutil.ReadFile(secretPath)
+	require.NoError(err)
+	require.Equal("hunter2", string(data))
+	info, err := os.Stat(secretPath)
+	require.NoError(err)
+	require.Equal(os.FileMode(0444), info.Mode())
+
+	first, ok := cli.engine.Container("app_web.1")
+	require.True(ok)
+	require.Equal("running", first.State)
+	require.Equal(map[string][]string{"app_front": {"web"}}, first.Networks)
+	require.Equal(secretPath, first.HostConfig.Mounts[0].Source)
+	require.Equal("/run/secrets/password", first.HostConfig.Mounts[0].Target)
+
+	tasks, err := cli.StackTasks(ctx, resp.ID)
+	require.NoError(err)
+	require.Len(tasks, 2)
+	require.Equal("app_web.1", tasks[0].Name)
+	require.Equal("running", tasks[0].CurrentState)
+
+	// Scaling down removes the containers of the extra replicas
+	require.NoError(cli.StackScale(ctx, resp.ID, map[string]uint64{"web": 1}))
+	_, ok = cli.engine.Container("app_web.2")
+	require.False(ok)
+	unchanged, ok := cli.engine.Container("app_web.1")
+	require.True(ok)
+	require.Equal(first.ID, unchanged.ID)
+
+	// A change of the secret recreates the containers using it
+	stack, err = cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	spec := stack.Spec
+	spec.Secrets[0].Data = []byte("correct horse")
+	require.NoError(cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{}))
+	recreated, ok := cli.engine.Container("app_web.1")
+	require.True(ok)
+	require.NotEqual(first.ID, recreated.ID)
+	data, err = ioutil.ReadFile(secretPath)
+	require.NoError(err)
+	require.Equal("correct horse", string(data))
+
+	require.NoError(cli.StackDelete(ctx, resp.ID))
+	_, ok = cli.engine.Container("app_web.1")
+	require.False(ok)
+	_, ok = cli.engine.Network("app_front")
+	require.False(ok)
+	_, err = os.Stat(filepath.Join(cli.dir, resp.ID))
+	require.True(os.IsNotExist(err))
+
+	// Deleting a stack which does not exist succeeds
+	require.NoError(cli.StackDelete(ctx, resp.ID))
+}
+
+func TestStackInvalid(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	cli := newTestClient(t)
+	defer os.RemoveAll(cli.dir)
+
+	spec := getTestSpec()
+	spec.Services[0].TaskTemplate.Networks[0].Target = "back"
+	_, err := cli.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	spec = stack.Spec
+	spec.Annotations.Name = "renamed"
+	err = cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
+func TestReconcilerRun(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cli := newTestClient(t)
+	defer os.RemoveAll(cli.dir)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+
+	done := make(chan error)
+	go func() {
+		done <- cli.reconciler.Run(ctx)
+	}()
+
+	// A container removed behind the back of the reconciler is recreated
+	removed, ok := cli.engine.Container("app_web.2")
+	require.True(ok)
+	require.NoError(cli.engine.ContainerRemove(ctx, removed.ID, dockerTypes.ContainerRemoveOptions{Force: true}))
+	require.True(poll(func() bool {
+		recreated, ok := cli.engine.Container("app_web.2")
+		return ok && recreated.ID != removed.ID && recreated.State == "running"
+	}))
+
+	// as is a container updated behind its back
+	updated, ok := cli.engine.Container("app_web.1")
+	require.True(ok)
+	require.NoError(cli.engine.Update("app_web.1"))
+	require.True(poll(func() bool {
+		recreated, ok := cli.engine.Container("app_web.1")
+		return ok && recreated.ID != updated.ID && recreated.State == "running"
+	}))
+
+	// but not when the stack is paused, which reports the drift instead
+	require.NoError(cli.StackPause(ctx, resp.ID))
+	removed, ok = cli.engine.Container("app_web.2")
+	require.True(ok)
+	require.NoError(cli.engine.ContainerRemove(ctx, removed.ID, dockerTypes.ContainerRemoveOptions{Force: true}))
+	require.False(poll(func() bool {
+		_, ok := cli.engine.Container("app_web.2")
+		return ok
+	}))
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal([]types.StackDrift{{Kind: "container", Name: "app_web.2", Action: types.StackDriftCreate}}, stack.Status.Drift)
+
+	require.NoError(cli.StackResume(ctx, resp.ID))
+	_, ok = cli.engine.Container("app_web.2")
+	require.True(ok)
+	stack, err = cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Empty(stack.Status.Drift)
+
+	cancel()
+	require.Equal(context.Canceled, <-done)
+}
+
+func TestReconcilerMaintenance(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "containers")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	store := fakes.NewFakeStackStore()
+	engine := fake.NewEngine()
+	maintenance := types.StackMaintenance{Enabled: true}
+	reconciler := containers.NewReconciler(store, engine, dir, containers.WithMaintenance(func() (types.StackMaintenance, error) {
+		return maintenance, nil
+	}))
+	cli := containers.NewClient(store, reconciler)
+
+	// No stack is reconciled during a maintenance
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	_, ok := engine.Container("app_web.1")
+	require.False(ok)
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Len(stack.Status.Drift, 3)
+	require.Equal(types.StackDrift{Kind: "network", Name: "app_front", Action: types.StackDriftCreate}, stack.Status.Drift[0])
+
+	maintenance.Enabled = false
+	require.NoError(reconciler.ReconcileAll(ctx))
+	_, ok = engine.Container("app_web.1")
+	require.True(ok)
+	stack, err = cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Empty(stack.Status.Drift)
+}
+
+// poll returns whether condition becomes true within a second
+func poll(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
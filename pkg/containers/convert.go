@@ -0,0 +1,429 @@
+package containers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// serviceLabel and slotLabel are the labels of containers holding the
+	// name of their service and their replica slot
+	serviceLabel = "com.docker.stacks.service"
+	slotLabel    = "com.docker.stacks.slot"
+
+	// configHashLabel is the label of containers and networks holding the
+	// hash of their configuration
+	configHashLabel = "com.docker.stacks.config_hash"
+
+	// defaultNetwork is the network of the services which are not attached
+	// to any network
+	defaultNetwork = "default"
+
+	// secretsPath is the directory relative secret targets are mounted in
+	secretsPath = "/run/secrets"
+)
+
+// containerName returns the name of the container of the replica slot of
+// service in the stack called stack.
+func containerName(stack, service string, slot uint64) string {
+	return fmt.Sprintf("%s_%s.%d", stack, service, slot)
+}
+
+// networkName returns the name of the network called network in the stack
+// called stack.
+func networkName(stack, network string) string {
+	return stack + "_" + network
+}
+
+// replicas returns the number of containers running service
+func replicas(service swarm.ServiceSpec) uint64 {
+	if service.Mode.Replicated == nil || service.Mode.Replicated.Replicas == nil {
+		return 1
+	}
+	return *service.Mode.Replicated.Replicas
+}
+
+// configHash returns a hash of the parts of the configuration of a
+// container or network
+func configHash(parts ...interface{}) string {
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// stackState is the desired state of the containers, networks and files of
+// a stack.
+type stackState struct {
+	// containers and networks are indexed by their names
+	containers map[string]containerState
+	networks   map[string]networkState
+	// files maps the paths of the secret and config files to their data
+	files map[string][]byte
+}
+
+// containerState is the desired state of a container.
+type containerState struct {
+	config     container.Config
+	hostConfig container.HostConfig
+	// networks are the networks the container is attached to. It is
+	// created on the first one.
+	networks []string
+	aliases  map[string][]string
+	hash     string
+}
+
+// networkState is the desired state of a network.
+type networkState struct {
+	options dockerTypes.NetworkCreate
+	hash    string
+}
+
+// checker reports the parts of StackSpecs which cannot run as plain
+// containers. It implements validation.Checker.
+type checker struct{}
+
+// Check returns the issues converting spec.
+func (checker) Check(spec types.StackSpec) types.StackValidationResult {
+	c := &converter{spec: spec}
+	c.stack("", "")
+	return types.StackValidationResult{
+		Valid:  len(c.issues) == 0,
+		Errors: c.issues,
+	}
+}
+
+// converter converts a StackSpec into the desired state of its containers,
+// networks and files, and collects the issues found on the way.
+type converter struct {
+	spec   types.StackSpec
+	issues []types.StackValidationIssue
+}
+
+func (c *converter) errorf(field, format string, args ...interface{}) {
+	c.issues = append(c.issues, types.StackValidationIssue{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// stack returns the desired state of the stack id, whose secret and config
+// files are kept in dir.
+func (c *converter) stack(id, dir string) stackState {
+	state := stackState{
+		containers: map[string]containerState{},
+		networks:   map[string]networkState{},
+		files:      map[string][]byte{},
+	}
+	for i, secret := range c.spec.Secrets {
+		if !validFileName(secret.Annotations.Name) {
+			c.errorf(fmt.Sprintf("Secrets[%d].Annotations.Name", i), "invalid secret name '%s'", secret.Annotations.Name)
+		}
+		if secret.Driver != nil {
+			c.errorf(fmt.Sprintf("Secrets[%d].Driver", i), "secret drivers are not supported without an orchestrator")
+		}
+		if secret.Templating != nil {
+			c.errorf(fmt.Sprintf("Secrets[%d].Templating", i), "templating is not supported without an orchestrator")
+		}
+	}
+	for i, config := range c.spec.Configs {
+		if !validFileName(config.Annotations.Name) {
+			c.errorf(fmt.Sprintf("Configs[%d].Annotations.Name", i), "invalid config name '%s'", config.Annotations.Name)
+		}
+		if config.Templating != nil {
+			c.errorf(fmt.Sprintf("Configs[%d].Templating", i), "templating is not supported without an orchestrator")
+		}
+	}
+	for name, network := range c.spec.Networks {
+		c.network(id, name, network, state)
+	}
+	for i, service := range c.spec.Services {
+		c.service(fmt.Sprintf("Services[%d]", i), id, dir, service, state)
+	}
+	return state
+}
+
+// network adds the local network replacing the network name of the stack
+// to state. Overlay networks are replaced by bridge networks, without the
+// options of the overlay driver.
+func (c *converter) network(id, name string, network dockerTypes.NetworkCreate, state stackState) {
+	options := dockerTypes.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         network.Driver,
+		EnableIPv6:     network.EnableIPv6,
+		IPAM:           network.IPAM,
+		Internal:       network.Internal,
+		Options:        network.Options,
+		Labels:         map[string]string{},
+	}
+	if options.Driver == "" || options.Driver == "overlay" {
+		options.Driver = "bridge"
+		options.Options = nil
+	}
+	for key, value := range network.Labels {
+		options.Labels[key] = value
+	}
+	options.Labels[types.StackLabel] = id
+
+	hash := configHash(options)
+	options.Labels[configHashLabel] = hash
+	state.networks[networkName(c.spec.Annotations.Name, name)] = networkState{
+		options: options,
+		hash:    hash,
+	}
+}
+
+// service adds the containers of the replicas of a service to state
+// nolint: gocyclo
+func (c *converter) service(field, id, dir string, service swarm.ServiceSpec, state stackState) {
+	spec := service.TaskTemplate.ContainerSpec
+	if spec == nil {
+		c.errorf(field+".TaskTemplate.ContainerSpec", "only container services can run without an orchestrator")
+		return
+	}
+	stackName := c.spec.Annotations.Name
+	name := service.Annotations.Name
+
+	config := container.Config{
+		Image:        spec.Image,
+		Entrypoint:   spec.Command,
+		Cmd:          spec.Args,
+		Hostname:     spec.Hostname,
+		Env:          spec.Env,
+		WorkingDir:   spec.Dir,
+		User:         spec.User,
+		Tty:          spec.TTY,
+		OpenStdin:    spec.OpenStdin,
+		StopSignal:   spec.StopSignal,
+		Healthcheck:  spec.Healthcheck,
+		ExposedPorts: nat.PortSet{},
+	}
+	if spec.StopGracePeriod != nil {
+		seconds := int(*spec.StopGracePeriod / time.Second)
+		config.StopTimeout = &seconds
+	}
+	hostConfig := container.HostConfig{
+		RestartPolicy:  restartPolicy(service.TaskTemplate.RestartPolicy),
+		GroupAdd:       spec.Groups,
+		Init:           spec.Init,
+		ReadonlyRootfs: spec.ReadOnly,
+		Sysctls:        spec.Sysctls,
+		Isolation:      spec.Isolation,
+		Mounts:         append([]mount.Mount{}, spec.Mounts...),
+	}
+	if dns := spec.DNSConfig; dns != nil {
+		hostConfig.DNS = dns.Nameservers
+		hostConfig.DNSSearch = dns.Search
+		hostConfig.DNSOptions = dns.Options
+	}
+	if resources := service.TaskTemplate.Resources; resources != nil && resources.Limits != nil {
+		hostConfig.NanoCPUs = resources.Limits.NanoCPUs
+		hostConfig.Memory = resources.Limits.MemoryBytes
+	}
+	for i, host := range spec.Hosts {
+		// Swarm hosts are "IP hostname [aliases...]", docker ones
+		// "hostname:IP"
+		parts := strings.Fields(host)
+		if len(parts) < 2 {
+			c.errorf(fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Hosts[%d]", field, i), "invalid host entry '%s'", host)
+			continue
+		}
+		for _, hostname := range parts[1:] {
+			hostConfig.ExtraHosts = append(hostConfig.ExtraHosts, hostname+":"+parts[0])
+		}
+	}
+
+	// The data of the files is part of the configuration of the container,
+	// which is recreated when it changes
+	fileHashes := []string{}
+	for i, reference := range spec.Secrets {
+		secret := c.secret(reference.SecretName)
+		if secret == nil {
+			c.errorf(fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Secrets[%d]", field, i), "secret %s is not defined in the stack", reference.SecretName)
+			continue
+		}
+		target := reference.SecretName
+		if reference.File != nil && reference.File.Name != "" {
+			target = reference.File.Name
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(secretsPath, target)
+		}
+		source := filepath.Join(dir, "secrets", secret.Annotations.Name)
+		state.files[source] = secret.Data
+		fileHashes = append(fileHashes, configHash(secret.Data))
+		hostConfig.Mounts = append(hostConfig.Mounts, fileMount(source, target))
+	}
+	for i, reference := range spec.Configs {
+		stackConfig := c.config(reference.ConfigName)
+		if stackConfig == nil {
+			c.errorf(fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Configs[%d]", field, i), "config %s is not defined in the stack", reference.ConfigName)
+			continue
+		}
+		target := reference.ConfigName
+		if reference.File != nil && reference.File.Name != "" {
+			target = reference.File.Name
+		}
+		if !path.IsAbs(target) {
+			target = path.Join("/", target)
+		}
+		source := filepath.Join(dir, "configs", stackConfig.Annotations.Name)
+		state.files[source] = stackConfig.Data
+		fileHashes = append(fileHashes, configHash(stackConfig.Data))
+		hostConfig.Mounts = append(hostConfig.Mounts, fileMount(source, target))
+	}
+
+	// Published ports can only be bound once on the engine, by the first
+	// replica. Ports without a published port are bound to random ports.
+	var publishedPorts, randomPorts nat.PortMap
+	if endpoint := service.EndpointSpec; endpoint != nil {
+		publishedPorts, randomPorts = nat.PortMap{}, nat.PortMap{}
+		for _, port := range endpoint.Ports {
+			protocol := string(port.Protocol)
+			if protocol == "" {
+				protocol = string(swarm.PortConfigProtocolTCP)
+			}
+			containerPort := nat.Port(fmt.Sprintf("%d/%s", port.TargetPort, protocol))
+			config.ExposedPorts[containerPort] = struct{}{}
+			if port.PublishedPort == 0 {
+				randomPorts[containerPort] = append(randomPorts[containerPort], nat.PortBinding{})
+				continue
+			}
+			publishedPorts[containerPort] = append(publishedPorts[containerPort], nat.PortBinding{
+				HostPort: strconv.FormatUint(uint64(port.PublishedPort), 10),
+			})
+		}
+	}
+
+	networks := []string{}
+	aliases := map[string][]string{}
+	attachments := service.TaskTemplate.Networks
+	if len(attachments) == 0 {
+		attachments = []swarm.NetworkAttachmentConfig{{Target: defaultNetwork}}
+		if _, ok := state.networks[networkName(stackName, defaultNetwork)]; !ok {
+			c.network(id, defaultNetwork, dockerTypes.NetworkCreate{}, state)
+		}
+	}
+	for i, attachment := range attachments {
+		if _, ok := c.spec.Networks[attachment.Target]; !ok && attachment.Target != defaultNetwork {
+			c.errorf(fmt.Sprintf("%s.TaskTemplate.Networks[%d]", field, i), "network %s is not defined in the stack", attachment.Target)
+			continue
+		}
+		network := networkName(stackName, attachment.Target)
+		networks = append(networks, network)
+		aliases[network] = append([]string{name}, attachment.Aliases...)
+	}
+	if len(networks) > 0 {
+		hostConfig.NetworkMode = container.NetworkMode(networks[0])
+	}
+	networkHashes := []string{}
+	for _, network := range networks {
+		networkHashes = append(networkHashes, state.networks[network].hash)
+	}
+
+	for slot := uint64(1); slot <= replicas(service); slot++ {
+		replicaConfig := config
+		replicaConfig.Labels = map[string]string{}
+		for key, value := range spec.Labels {
+			replicaConfig.Labels[key] = value
+		}
+		replicaConfig.Labels[types.StackLabel] = id
+		replicaConfig.Labels[serviceLabel] = name
+		replicaConfig.Labels[slotLabel] = strconv.FormatUint(slot, 10)
+
+		replicaHostConfig := hostConfig
+		replicaHostConfig.PortBindings = randomPorts
+		if slot == 1 && len(publishedPorts) > 0 {
+			replicaHostConfig.PortBindings = nat.PortMap{}
+			for port, bindings := range randomPorts {
+				replicaHostConfig.PortBindings[port] = bindings
+			}
+			for port, bindings := range publishedPorts {
+				replicaHostConfig.PortBindings[port] = bindings
+			}
+		}
+
+		hash := configHash(replicaConfig, replicaHostConfig, networks, aliases, networkHashes, fileHashes)
+		replicaConfig.Labels[configHashLabel] = hash
+		state.containers[containerName(stackName, name, slot)] = containerState{
+			config:     replicaConfig,
+			hostConfig: replicaHostConfig,
+			networks:   networks,
+			aliases:    aliases,
+			hash:       hash,
+		}
+	}
+}
+
+// secret returns the secret of the stack called name
+func (c *converter) secret(name string) *swarm.SecretSpec {
+	for i := range c.spec.Secrets {
+		if c.spec.Secrets[i].Annotations.Name == name {
+			return &c.spec.Secrets[i]
+		}
+	}
+	return nil
+}
+
+// config returns the config of the stack called name
+func (c *converter) config(name string) *swarm.ConfigSpec {
+	for i := range c.spec.Configs {
+		if c.spec.Configs[i].Annotations.Name == name {
+			return &c.spec.Configs[i]
+		}
+	}
+	return nil
+}
+
+// validFileName returns whether the secret or config called name can be
+// stored in a file of that name
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// fileMount returns the read-only bind mount of a secret or config file
+func fileMount(source, target string) mount.Mount {
+	return mount.Mount{
+		Type:     mount.TypeBind,
+		Source:   source,
+		Target:   target,
+		ReadOnly: true,
+	}
+}
+
+// restartPolicy returns the restart policy of containers emulating the
+// restart policy of the tasks of a service. Tasks are restarted on any
+// condition by default.
+func restartPolicy(policy *swarm.RestartPolicy) container.RestartPolicy {
+	if policy == nil {
+		return container.RestartPolicy{Name: "always"}
+	}
+	switch policy.Condition {
+	case swarm.RestartPolicyConditionNone:
+		return container.RestartPolicy{Name: "no"}
+	case swarm.RestartPolicyConditionOnFailure:
+		restart := container.RestartPolicy{Name: "on-failure"}
+		if policy.MaxAttempts != nil {
+			restart.MaximumRetryCount = int(*policy.MaxAttempts)
+		}
+		return restart
+	}
+	return container.RestartPolicy{Name: "always"}
+}
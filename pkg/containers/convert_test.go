@@ -0,0 +1,203 @@
+package containers
+
+import (
+	"testing"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestConvertService(t *testing.T) {
+	require := require.New(t)
+	replicas := uint64(2)
+	attempts := uint64(3)
+	grace := 10 * time.Second
+	spec := types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "web"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image:           "nginx:1.16",
+					Labels:          map[string]string{"tier": "front"},
+					Command:         []string{"nginx"},
+					Args:            []string{"-g", "daemon off;"},
+					Env:             []string{"A=1"},
+					Hosts:           []string{"10.0.0.1 db database"},
+					StopGracePeriod: &grace,
+					Secrets: []*swarm.SecretReference{{
+						File:       &swarm.SecretReferenceFileTarget{Name: "db_password"},
+						SecretName: "password",
+					}},
+					Configs: []*swarm.ConfigReference{{
+						File:       &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx/nginx.conf"},
+						ConfigName: "nginx.conf",
+					}},
+				},
+				RestartPolicy: &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionOnFailure, MaxAttempts: &attempts},
+				Resources: &swarm.ResourceRequirements{
+					Limits: &swarm.Resources{NanoCPUs: 1500000000, MemoryBytes: 1 << 20},
+				},
+				Networks: []swarm.NetworkAttachmentConfig{{Target: "front", Aliases: []string{"www"}}},
+			},
+			Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+			EndpointSpec: &swarm.EndpointSpec{
+				Ports: []swarm.PortConfig{
+					{TargetPort: 80, PublishedPort: 8080},
+					{TargetPort: 53, Protocol: swarm.PortConfigProtocolUDP},
+				},
+			},
+		}},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"front": {Driver: "overlay", Options: map[string]string{"encrypted": ""}},
+		},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "password"},
+			Data:        []byte("hunter2"),
+		}},
+		Configs: []swarm.ConfigSpec{{
+			Annotations: swarm.Annotations{Name: "nginx.conf"},
+			Data:        []byte("worker_processes 1;"),
+		}},
+	}
+
+	c := &converter{spec: spec}
+	state := c.stack("STK_1", "/var/lib/stacks/STK_1")
+	require.Empty(c.issues)
+
+	require.Len(state.networks, 1)
+	network := state.networks["app_front"]
+	require.Equal("bridge", network.options.Driver)
+	require.Nil(network.options.Options)
+	require.Equal("STK_1", network.options.Labels[types.StackLabel])
+	require.Equal(network.hash, network.options.Labels[configHashLabel])
+
+	require.Equal(map[string][]byte{
+		"/var/lib/stacks/STK_1/secrets/password":   []byte("hunter2"),
+		"/var/lib/stacks/STK_1/configs/nginx.conf": []byte("worker_processes 1;"),
+	}, state.files)
+
+	require.Len(state.containers, 2)
+	first, second := state.containers["app_web.1"], state.containers["app_web.2"]
+	require.Equal("nginx:1.16", first.config.Image)
+	require.Equal([]string{"nginx"}, []string(first.config.Entrypoint))
+	require.Equal([]string{"-g", "daemon off;"}, []string(first.config.Cmd))
+	require.Equal(10, *first.config.StopTimeout)
+	require.Equal(map[string]string{
+		"tier":           "front",
+		types.StackLabel: "STK_1",
+		serviceLabel:     "web",
+		slotLabel:        "1",
+		configHashLabel:  first.hash,
+	}, first.config.Labels)
+	require.Equal("2", second.config.Labels[slotLabel])
+	require.NotEqual(first.hash, second.hash)
+
+	require.Equal(container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}, first.hostConfig.RestartPolicy)
+	require.Equal([]string{"db:10.0.0.1", "database:10.0.0.1"}, first.hostConfig.ExtraHosts)
+	require.Equal(int64(1500000000), first.hostConfig.NanoCPUs)
+	require.Equal(int64(1<<20), first.hostConfig.Memory)
+	require.Equal([]mount.Mount{
+		{Type: mount.TypeBind, Source: "/var/lib/stacks/STK_1/secrets/password", Target: "/run/secrets/db_password", ReadOnly: true},
+		{Type: mount.TypeBind, Source: "/var/lib/stacks/STK_1/configs/nginx.conf", Target: "/etc/nginx/nginx.conf", ReadOnly: true},
+	}, first.hostConfig.Mounts)
+
+	// Only the first replica binds the published port
+	require.Equal(nat.PortSet{"80/tcp": {}, "53/udp": {}}, first.config.ExposedPorts)
+	require.Equal(nat.PortMap{
+		"80/tcp": {{HostPort: "8080"}},
+		"53/udp": {{}},
+	}, first.hostConfig.PortBindings)
+	require.Equal(nat.PortMap{"53/udp": {{}}}, second.hostConfig.PortBindings)
+
+	require.Equal([]string{"app_front"}, first.networks)
+	require.Equal(container.NetworkMode("app_front"), first.hostConfig.NetworkMode)
+	require.Equal([]string{"web", "www"}, first.aliases["app_front"])
+}
+
+func TestConvertDefaultNetwork(t *testing.T) {
+	require := require.New(t)
+	spec := types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "agent"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image: "agent",
+					Secrets: []*swarm.SecretReference{{
+						SecretName: "token",
+					}},
+				},
+			},
+			Mode: swarm.ServiceMode{Global: &swarm.GlobalService{}},
+		}},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "token"},
+			Data:        []byte("t0k3n"),
+		}},
+	}
+
+	c := &converter{spec: spec}
+	state := c.stack("STK_1", "/data")
+	require.Empty(c.issues)
+	require.Len(state.networks, 1)
+	require.Equal("bridge", state.networks["app_default"].options.Driver)
+
+	require.Len(state.containers, 1)
+	agent := state.containers["app_agent.1"]
+	require.Equal([]string{"app_default"}, agent.networks)
+	require.Equal([]string{"agent"}, agent.aliases["app_default"])
+	require.Equal(container.RestartPolicy{Name: "always"}, agent.hostConfig.RestartPolicy)
+	require.Equal("/run/secrets/token", agent.hostConfig.Mounts[0].Target)
+}
+
+func TestCheck(t *testing.T) {
+	spec := types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "web"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image: "nginx",
+					Hosts: []string{"db"},
+					Secrets: []*swarm.SecretReference{{
+						SecretName: "external",
+					}},
+				},
+				Networks: []swarm.NetworkAttachmentConfig{{Target: "missing"}},
+			},
+		}, {
+			Annotations:  swarm.Annotations{Name: "plugin"},
+			TaskTemplate: swarm.TaskSpec{Runtime: swarm.RuntimePlugin},
+		}},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "vault"},
+			Driver:      &swarm.Driver{Name: "vault"},
+		}},
+		Configs: []swarm.ConfigSpec{{
+			Annotations: swarm.Annotations{Name: "../passwd"},
+		}},
+	}
+
+	result := checker{}.Check(spec)
+	require.False(t, result.Valid)
+	fields := []string{}
+	for _, issue := range result.Errors {
+		fields = append(fields, issue.Field)
+	}
+	require.Equal(t, []string{
+		"Secrets[0].Driver",
+		"Configs[0].Annotations.Name",
+		"Services[0].TaskTemplate.ContainerSpec.Hosts[0]",
+		"Services[0].TaskTemplate.ContainerSpec.Secrets[0]",
+		"Services[0].TaskTemplate.Networks[0]",
+		"Services[1].TaskTemplate.ContainerSpec",
+	}, fields)
+}
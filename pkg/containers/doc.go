@@ -0,0 +1,34 @@
+package containers
+
+// The `containers` package implements the Stacks API for the "none"
+// orchestrator, on a single Docker engine without Swarm. A router.StacksRouter
+// can serve such stacks next to those deployed on Swarm and on Kubernetes.
+//
+// A Client stores stacks in an interfaces.StackStore, and a Reconciler turns
+// each stored stack into plain containers:
+//
+//  - every replica of a service is a container named
+//    <stack>_<service>.<slot>. Global services run a single container, and
+//    the published ports of a service are only bound by its first replica;
+//  - the networks of the stack are local bridge networks named
+//    <stack>_<network>, and services without networks are attached to a
+//    <stack>_default network. Containers are reachable by the name of
+//    their service on the networks of the stack;
+//  - secrets and configs are files in the data directory of the
+//    Reconciler, bind mounted read-only into the containers referencing
+//    them. Secrets are mounted in /run/secrets unless their target is an
+//    absolute path.
+//
+// The containers and networks are labelled with the ID of their stack and a
+// hash of their configuration. Those whose configuration changed are
+// recreated, and those which are no longer part of their stack are removed.
+// Reconciler.Run reconciles the stacks again whenever one of their
+// containers changes, and recreates the containers updated behind its back.
+// Paused stacks, and every stack during a maintenance, are not reconciled:
+// the changes reconciling them would make are reported as the drift of their
+// status instead. Placement constraints, update configs and deploy
+// strategies are ignored, as there is a single engine.
+//
+// The Client and the Reconciler only depend on the Engine interface, which
+// the Docker API client implements and the `fake` package implements in
+// memory.
@@ -0,0 +1,26 @@
+package containers
+
+import (
+	"context"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Engine is the part of the Docker API the containers of stacks are run
+// with. It is implemented by the Docker API client.
+type Engine interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerStart(ctx context.Context, container string, options dockerTypes.ContainerStartOptions) error
+	ContainerRemove(ctx context.Context, container string, options dockerTypes.ContainerRemoveOptions) error
+	ContainerList(ctx context.Context, options dockerTypes.ContainerListOptions) ([]dockerTypes.Container, error)
+
+	NetworkCreate(ctx context.Context, name string, options dockerTypes.NetworkCreate) (dockerTypes.NetworkCreateResponse, error)
+	NetworkConnect(ctx context.Context, network, container string, config *network.EndpointSettings) error
+	NetworkRemove(ctx context.Context, network string) error
+	NetworkList(ctx context.Context, options dockerTypes.NetworkListOptions) ([]dockerTypes.NetworkResource, error)
+
+	Events(ctx context.Context, options dockerTypes.EventsOptions) (<-chan events.Message, <-chan error)
+}
@@ -0,0 +1,343 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// eventBuffer is the number of events buffered for each subscriber
+const eventBuffer = 1024
+
+// Container is a container of an Engine, along with the configuration it
+// was created with.
+type Container struct {
+	dockerTypes.Container
+	Config     container.Config
+	HostConfig container.HostConfig
+	// Networks maps the names of the networks the container is attached
+	// to to its aliases on them
+	Networks map[string][]string
+}
+
+// Engine is an in-memory containers.Engine. Containers do not run: they
+// are running once started, until they are removed.
+type Engine struct {
+	mu          sync.Mutex
+	curID       int
+	containers  map[string]*Container
+	networks    map[string]*dockerTypes.NetworkResource
+	subscribers map[chan events.Message]filters.Args
+}
+
+// NewEngine creates a new, empty Engine.
+func NewEngine() *Engine {
+	return &Engine{
+		curID:       1,
+		containers:  map[string]*Container{},
+		networks:    map[string]*dockerTypes.NetworkResource{},
+		subscribers: map[chan events.Message]filters.Args{},
+	}
+}
+
+func (e *Engine) newID(kind string) string {
+	id := fmt.Sprintf("%s_%d", kind, e.curID)
+	e.curID++
+	return id
+}
+
+// ContainerCreate creates a container, attached to the network of its
+// NetworkMode.
+func (e *Engine) ContainerCreate(_ context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.findContainer(containerName) != nil {
+		return container.ContainerCreateCreatedBody{}, errdefs.Conflict(fmt.Errorf("container name %s is already in use", containerName))
+	}
+	created := &Container{
+		Container: dockerTypes.Container{
+			ID:     e.newID("CTR"),
+			Names:  []string{"/" + containerName},
+			Image:  config.Image,
+			Labels: config.Labels,
+			State:  "created",
+		},
+		Config:     *config,
+		HostConfig: *hostConfig,
+		Networks:   map[string][]string{},
+	}
+	if mode := hostConfig.NetworkMode; mode != "" {
+		if e.findNetwork(string(mode)) == nil {
+			return container.ContainerCreateCreatedBody{}, errdefs.NotFound(fmt.Errorf("network %s not found", mode))
+		}
+		created.Networks[string(mode)] = nil
+		if networkingConfig != nil && networkingConfig.EndpointsConfig[string(mode)] != nil {
+			created.Networks[string(mode)] = networkingConfig.EndpointsConfig[string(mode)].Aliases
+		}
+	}
+	e.containers[created.ID] = created
+	e.publish(created, "create")
+	return container.ContainerCreateCreatedBody{ID: created.ID}, nil
+}
+
+// ContainerStart starts a container.
+func (e *Engine) ContainerStart(_ context.Context, containerID string, _ dockerTypes.ContainerStartOptions) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findContainer(containerID)
+	if found == nil {
+		return errdefs.NotFound(fmt.Errorf("no such container: %s", containerID))
+	}
+	found.State = "running"
+	e.publish(found, "start")
+	return nil
+}
+
+// ContainerRemove removes a container. Running containers are only removed
+// by force.
+func (e *Engine) ContainerRemove(_ context.Context, containerID string, options dockerTypes.ContainerRemoveOptions) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findContainer(containerID)
+	if found == nil {
+		return errdefs.NotFound(fmt.Errorf("no such container: %s", containerID))
+	}
+	if found.State == "running" && !options.Force {
+		return errdefs.Conflict(fmt.Errorf("container %s is running", containerID))
+	}
+	delete(e.containers, found.ID)
+	e.publish(found, "destroy")
+	return nil
+}
+
+// ContainerList lists the containers selected by the label filters of
+// options, ordered by name. Only running containers are listed unless
+// options.All is set.
+func (e *Engine) ContainerList(_ context.Context, options dockerTypes.ContainerListOptions) ([]dockerTypes.Container, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	containers := []dockerTypes.Container{}
+	for _, c := range e.containers {
+		if (options.All || c.State == "running") && matchLabels(options.Filters, c.Labels) {
+			containers = append(containers, c.Container)
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Names[0] < containers[j].Names[0]
+	})
+	return containers, nil
+}
+
+// NetworkCreate creates a network.
+func (e *Engine) NetworkCreate(_ context.Context, name string, options dockerTypes.NetworkCreate) (dockerTypes.NetworkCreateResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if options.CheckDuplicate && e.findNetwork(name) != nil {
+		return dockerTypes.NetworkCreateResponse{}, errdefs.Conflict(fmt.Errorf("network with name %s already exists", name))
+	}
+	created := &dockerTypes.NetworkResource{
+		ID:         e.newID("NET"),
+		Name:       name,
+		Driver:     options.Driver,
+		EnableIPv6: options.EnableIPv6,
+		Internal:   options.Internal,
+		Options:    options.Options,
+		Labels:     options.Labels,
+	}
+	if options.IPAM != nil {
+		created.IPAM = *options.IPAM
+	}
+	e.networks[created.ID] = created
+	return dockerTypes.NetworkCreateResponse{ID: created.ID}, nil
+}
+
+// NetworkConnect attaches a container to a network.
+func (e *Engine) NetworkConnect(_ context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	foundNetwork := e.findNetwork(networkID)
+	if foundNetwork == nil {
+		return errdefs.NotFound(fmt.Errorf("network %s not found", networkID))
+	}
+	foundContainer := e.findContainer(containerID)
+	if foundContainer == nil {
+		return errdefs.NotFound(fmt.Errorf("no such container: %s", containerID))
+	}
+	var aliases []string
+	if config != nil {
+		aliases = config.Aliases
+	}
+	foundContainer.Networks[foundNetwork.Name] = aliases
+	return nil
+}
+
+// NetworkRemove removes a network. Networks cannot be removed while
+// containers are attached to them.
+func (e *Engine) NetworkRemove(_ context.Context, networkID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findNetwork(networkID)
+	if found == nil {
+		return errdefs.NotFound(fmt.Errorf("network %s not found", networkID))
+	}
+	for _, c := range e.containers {
+		if _, ok := c.Networks[found.Name]; ok {
+			return errdefs.Forbidden(fmt.Errorf("network %s has active endpoints", found.Name))
+		}
+	}
+	delete(e.networks, found.ID)
+	return nil
+}
+
+// NetworkList lists the networks selected by the label filters of options,
+// ordered by name.
+func (e *Engine) NetworkList(_ context.Context, options dockerTypes.NetworkListOptions) ([]dockerTypes.NetworkResource, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	networks := []dockerTypes.NetworkResource{}
+	for _, n := range e.networks {
+		if matchLabels(options.Filters, n.Labels) {
+			networks = append(networks, *n)
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Name < networks[j].Name
+	})
+	return networks, nil
+}
+
+// Events returns the container events selected by the type, event and
+// label filters of options, until ctx is done.
+func (e *Engine) Events(ctx context.Context, options dockerTypes.EventsOptions) (<-chan events.Message, <-chan error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	messages := make(chan events.Message, eventBuffer)
+	errs := make(chan error, 1)
+	e.subscribers[messages] = options.Filters
+	go func() {
+		<-ctx.Done()
+		e.mu.Lock()
+		delete(e.subscribers, messages)
+		e.mu.Unlock()
+		errs <- ctx.Err()
+	}()
+	return messages, errs
+}
+
+// Update changes the container called name behind the back of its stack,
+// as `docker update` does.
+func (e *Engine) Update(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findContainer(name)
+	if found == nil {
+		return errdefs.NotFound(fmt.Errorf("no such container: %s", name))
+	}
+	e.publish(found, "update")
+	return nil
+}
+
+// Container returns the container called name, if any.
+func (e *Engine) Container(name string) (Container, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findContainer(name)
+	if found == nil {
+		return Container{}, false
+	}
+	return *found, true
+}
+
+// Network returns the network called name, if any.
+func (e *Engine) Network(name string) (dockerTypes.NetworkResource, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := e.findNetwork(name)
+	if found == nil {
+		return dockerTypes.NetworkResource{}, false
+	}
+	return *found, true
+}
+
+// findContainer returns the container whose ID or name is key
+func (e *Engine) findContainer(key string) *Container {
+	if c, ok := e.containers[key]; ok {
+		return c
+	}
+	for _, c := range e.containers {
+		if c.Names[0] == "/"+strings.TrimPrefix(key, "/") {
+			return c
+		}
+	}
+	return nil
+}
+
+// findNetwork returns the network whose ID or name is key
+func (e *Engine) findNetwork(key string) *dockerTypes.NetworkResource {
+	if n, ok := e.networks[key]; ok {
+		return n
+	}
+	for _, n := range e.networks {
+		if n.Name == key {
+			return n
+		}
+	}
+	return nil
+}
+
+// publish sends the event action of a container to the subscribers whose
+// filters select it
+func (e *Engine) publish(c *Container, action string) {
+	attributes := map[string]string{"name": strings.TrimPrefix(c.Names[0], "/")}
+	for key, value := range c.Labels {
+		attributes[key] = value
+	}
+	message := events.Message{
+		Type:   events.ContainerEventType,
+		Action: action,
+		Actor:  events.Actor{ID: c.ID, Attributes: attributes},
+	}
+	for subscriber, args := range e.subscribers {
+		if !args.ExactMatch("type", message.Type) || !args.ExactMatch("event", action) || !matchLabels(args, c.Labels) {
+			continue
+		}
+		select {
+		case subscriber <- message:
+		default:
+		}
+	}
+}
+
+// matchLabels returns whether labels have the labels of the label filters
+// of args, given as key or key=value
+func matchLabels(args filters.Args, labels map[string]string) bool {
+	for _, filter := range args.Get("label") {
+		parts := strings.SplitN(filter, "=", 2)
+		value, ok := labels[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package containers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// dirMode is the mode of the directories of the secret and config
+	// files, which only the engine reads when it mounts them
+	dirMode = 0700
+
+	// fileMode is the mode of the secret and config files, which are
+	// mounted read-only into containers running as any user
+	fileMode = 0444
+)
+
+// writeFiles writes the secret and config files of a stack
+func writeFiles(files map[string][]byte) error {
+	for _, path := range sortedPaths(files) {
+		if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+			return err
+		}
+		current, err := ioutil.ReadFile(path)
+		if err == nil && string(current) == string(files[path]) {
+			continue
+		}
+		// The file is read-only, and cannot be written in place
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := ioutil.WriteFile(path, files[path], fileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStaleFiles removes the files in dir which are not among files, and
+// the directories left empty, including dir itself
+func removeStaleFiles(dir string, files map[string][]byte) error {
+	dirs := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir():
+			dirs = append(dirs, path)
+			return nil
+		}
+		if _, ok := files[path]; ok {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the deepest directories first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := ioutil.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedPaths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
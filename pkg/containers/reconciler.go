@@ -0,0 +1,413 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// containerEvents are the events of the containers of stacks which cause
+// their stack to be reconciled
+var containerEvents = []string{"create", "start", "die", "stop", "kill", "pause", "unpause", "update", "rename", "destroy"}
+
+// Reconciler runs the stacks of a StackStore as plain containers of an
+// Engine.
+type Reconciler struct {
+	store   interfaces.StackStore
+	engine  Engine
+	dataDir string
+
+	// maintenance returns the cluster-wide maintenance switch
+	maintenance func() (types.StackMaintenance, error)
+
+	// mu serializes reconciliations, which are triggered both by the
+	// Client and by the events of the Engine. It guards updated, the IDs
+	// of the containers updated behind the back of the Reconciler.
+	mu      sync.Mutex
+	updated map[string]bool
+}
+
+// ReconcilerOptionFunc is the type used for functional arguments of the
+// Reconciler during its creation.
+type ReconcilerOptionFunc func(*Reconciler)
+
+// WithMaintenance is a ReconcilerOptionFunc which reads the cluster-wide
+// maintenance switch with maintenance, rather than from the StackStore of
+// the Reconciler.
+func WithMaintenance(maintenance func() (types.StackMaintenance, error)) ReconcilerOptionFunc {
+	return func(r *Reconciler) {
+		r.maintenance = maintenance
+	}
+}
+
+// NewReconciler creates a new Reconciler running the stacks of store on
+// engine. The secret and config files of the stacks are kept in dataDir.
+func NewReconciler(store interfaces.StackStore, engine Engine, dataDir string, optsFunc ...ReconcilerOptionFunc) *Reconciler {
+	r := &Reconciler{
+		store:       store,
+		engine:      engine,
+		dataDir:     dataDir,
+		maintenance: store.GetMaintenance,
+		updated:     map[string]bool{},
+	}
+
+	for _, f := range optsFunc {
+		f(r)
+	}
+
+	return r
+}
+
+// Run reconciles every stack, and then the stacks whose containers change,
+// until ctx is done or the events of the Engine fail.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribe before reconciling, so that no change is missed
+	filter := filters.NewArgs(
+		filters.Arg("type", events.ContainerEventType),
+		filters.Arg("label", types.StackLabel),
+	)
+	for _, event := range containerEvents {
+		filter.Add("event", event)
+	}
+	messages, errs := r.engine.Events(ctx, dockerTypes.EventsOptions{Filters: filter})
+
+	if err := r.ReconcileAll(ctx); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case message := <-messages:
+			id := message.Actor.Attributes[types.StackLabel]
+			if id == "" {
+				continue
+			}
+			r.witness(message)
+			if err := r.Reconcile(ctx, id); err != nil {
+				logrus.Errorf("unable to reconcile stack %s: %s", id, err)
+			}
+		}
+	}
+}
+
+// ReconcileAll reconciles every stack, such as once a maintenance is over.
+// The errors reconciling the stacks are reported in their status.
+func (r *Reconciler) ReconcileAll(ctx context.Context) error {
+	stacks, err := r.store.ListStacks(types.StackListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, stack := range stacks {
+		if err := r.Reconcile(ctx, stack.ID); err != nil {
+			logrus.Errorf("unable to reconcile stack %s: %s", stack.ID, err)
+		}
+	}
+	return nil
+}
+
+// witness records the containers updated behind the back of the
+// Reconciler, which are recreated from their spec as their configuration
+// hash no longer tells whether they match it.
+func (r *Reconciler) witness(message events.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch message.Action {
+	case "update":
+		r.updated[message.Actor.ID] = true
+	case "destroy":
+		delete(r.updated, message.Actor.ID)
+	}
+}
+
+// Reconcile converges the containers, networks and files of the stack id
+// to its spec, and reports the outcome in the status of the stack. Those of
+// a stack which no longer exists are removed. Paused stacks, and every
+// stack during a maintenance, are left alone: the changes reconciling them
+// would make are reported as their drift instead.
+func (r *Reconciler) Reconcile(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot, err := r.store.GetSnapshotStack(id)
+	if errdefs.IsNotFound(err) {
+		return r.converge(ctx, id, stackState{})
+	}
+	if err != nil {
+		return err
+	}
+
+	c := &converter{spec: snapshot.CurrentSpec}
+	state := c.stack(id, r.stackDir(id))
+	if len(c.issues) > 0 {
+		return r.reportStatus(id, types.StackValidationError{Result: types.StackValidationResult{Errors: c.issues}})
+	}
+
+	maintenance, err := r.maintenance()
+	if err != nil {
+		return err
+	}
+	if snapshot.Paused || maintenance.Enabled {
+		return r.reportDrift(ctx, snapshot, state)
+	}
+	return r.reportStatus(id, r.converge(ctx, id, state))
+}
+
+// stackDir returns the directory of the secret and config files of the
+// stack id
+func (r *Reconciler) stackDir(id string) string {
+	return filepath.Join(r.dataDir, id)
+}
+
+// converge creates, recreates and removes the containers, networks and
+// files of the stack id to reach state. Containers are removed before the
+// networks they are attached to, and created after them.
+// nolint: gocyclo
+func (r *Reconciler) converge(ctx context.Context, id string, state stackState) error {
+	filter := filters.NewArgs(interfaces.StackLabelArg(id))
+
+	if err := writeFiles(state.files); err != nil {
+		return err
+	}
+
+	existing, err := r.engine.ContainerList(ctx, dockerTypes.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return err
+	}
+	current := map[string]dockerTypes.Container{}
+	for _, container := range existing {
+		name := strings.TrimPrefix(container.Names[0], "/")
+		if r.isCurrent(container, state) {
+			current[name] = container
+			continue
+		}
+		err := r.engine.ContainerRemove(ctx, container.ID, dockerTypes.ContainerRemoveOptions{Force: true})
+		if err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+		delete(r.updated, container.ID)
+	}
+
+	networks, err := r.engine.NetworkList(ctx, dockerTypes.NetworkListOptions{Filters: filter})
+	if err != nil {
+		return err
+	}
+	currentNetworks := map[string]bool{}
+	for _, existing := range networks {
+		if isCurrentNetwork(existing, state) {
+			currentNetworks[existing.Name] = true
+			continue
+		}
+		if err := r.engine.NetworkRemove(ctx, existing.ID); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+	for _, name := range sortedNetworkNames(state.networks) {
+		if currentNetworks[name] {
+			continue
+		}
+		if _, err := r.engine.NetworkCreate(ctx, name, state.networks[name].options); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedContainerNames(state.containers) {
+		if container, ok := current[name]; ok {
+			// Containers which failed to start are started again, while
+			// the restart policy of the others is left to the engine
+			if container.State == "created" {
+				if err := r.engine.ContainerStart(ctx, container.ID, dockerTypes.ContainerStartOptions{}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := r.createContainer(ctx, name, state.containers[name]); err != nil {
+			return err
+		}
+	}
+
+	return removeStaleFiles(r.stackDir(id), state.files)
+}
+
+// isCurrent returns whether container is a container of state, with the
+// configuration of its spec
+func (r *Reconciler) isCurrent(container dockerTypes.Container, state stackState) bool {
+	desired, ok := state.containers[strings.TrimPrefix(container.Names[0], "/")]
+	return ok && container.Labels[configHashLabel] == desired.hash && !r.updated[container.ID]
+}
+
+// isCurrentNetwork returns whether network is a network of state, with the
+// configuration of its spec
+func isCurrentNetwork(network dockerTypes.NetworkResource, state stackState) bool {
+	desired, ok := state.networks[network.Name]
+	return ok && network.Labels[configHashLabel] == desired.hash
+}
+
+// drift returns the changes converging the containers and networks of the
+// stack id to state would make
+func (r *Reconciler) drift(ctx context.Context, id string, state stackState) ([]types.StackDrift, error) {
+	filter := filters.NewArgs(interfaces.StackLabelArg(id))
+	drift := []types.StackDrift{}
+
+	existing, err := r.engine.ContainerList(ctx, dockerTypes.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, container := range existing {
+		name := strings.TrimPrefix(container.Names[0], "/")
+		seen[name] = true
+		switch _, desired := state.containers[name]; {
+		case !desired:
+			drift = append(drift, types.StackDrift{Kind: events.ContainerEventType, Name: name, Action: types.StackDriftDelete})
+		case !r.isCurrent(container, state):
+			drift = append(drift, types.StackDrift{Kind: events.ContainerEventType, Name: name, Action: types.StackDriftUpdate})
+		}
+	}
+	for _, name := range sortedContainerNames(state.containers) {
+		if !seen[name] {
+			drift = append(drift, types.StackDrift{Kind: events.ContainerEventType, Name: name, Action: types.StackDriftCreate})
+		}
+	}
+
+	networks, err := r.engine.NetworkList(ctx, dockerTypes.NetworkListOptions{Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	seen = map[string]bool{}
+	for _, network := range networks {
+		seen[network.Name] = true
+		switch _, desired := state.networks[network.Name]; {
+		case !desired:
+			drift = append(drift, types.StackDrift{Kind: events.NetworkEventType, Name: network.Name, Action: types.StackDriftDelete})
+		case !isCurrentNetwork(network, state):
+			drift = append(drift, types.StackDrift{Kind: events.NetworkEventType, Name: network.Name, Action: types.StackDriftUpdate})
+		}
+	}
+	for _, name := range sortedNetworkNames(state.networks) {
+		if !seen[name] {
+			drift = append(drift, types.StackDrift{Kind: events.NetworkEventType, Name: name, Action: types.StackDriftCreate})
+		}
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		return drift[i].Name < drift[j].Name
+	})
+	return drift, nil
+}
+
+// reportDrift records the drift of the stack of snapshot from state in its
+// status
+func (r *Reconciler) reportDrift(ctx context.Context, snapshot interfaces.SnapshotStack, state stackState) error {
+	drift, err := r.drift(ctx, snapshot.ID, state)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 && len(snapshot.Status.Drift) == 0 || reflect.DeepEqual(drift, snapshot.Status.Drift) {
+		return nil
+	}
+	snapshot.Status.Drift = drift
+	_, err = r.store.UpdateSnapshotStack(snapshot.ID, snapshot, snapshot.Version.Index)
+	if errdefs.IsConflict(err) {
+		// A concurrent update of the stack is reconciled again
+		return nil
+	}
+	return err
+}
+
+// createContainer creates and starts the container name, attached to its
+// networks
+func (r *Reconciler) createContainer(ctx context.Context, name string, desired containerState) error {
+	networking := &network.NetworkingConfig{}
+	if len(desired.networks) > 0 {
+		networking.EndpointsConfig = map[string]*network.EndpointSettings{
+			desired.networks[0]: {Aliases: desired.aliases[desired.networks[0]]},
+		}
+	}
+	config, hostConfig := desired.config, desired.hostConfig
+	created, err := r.engine.ContainerCreate(ctx, &config, &hostConfig, networking, name)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(desired.networks); i++ {
+		attached := desired.networks[i]
+		if err := r.engine.NetworkConnect(ctx, attached, created.ID, &network.EndpointSettings{Aliases: desired.aliases[attached]}); err != nil {
+			return err
+		}
+	}
+	return r.engine.ContainerStart(ctx, created.ID, dockerTypes.ContainerStartOptions{})
+}
+
+// reportStatus records the outcome of the reconciliation of the stack id
+// in its status, and returns reconcileErr
+func (r *Reconciler) reportStatus(id string, reconcileErr error) error {
+	snapshot, err := r.store.GetSnapshotStack(id)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return reconcileErr
+		}
+		return err
+	}
+
+	status := types.StackStatus{Phase: types.StackPhaseUpdated}
+	if reconcileErr != nil {
+		status = types.StackStatus{
+			Phase:   snapshot.Status.Phase,
+			Message: fmt.Sprintf("unable to run the containers of the stack: %s", reconcileErr),
+		}
+	}
+	if reflect.DeepEqual(status, snapshot.Status) {
+		return reconcileErr
+	}
+	snapshot.Status = status
+	// A concurrent update of the stack is reconciled again, and reports
+	// its own status
+	_, err = r.store.UpdateSnapshotStack(id, snapshot, snapshot.Version.Index)
+	if reconcileErr != nil {
+		return reconcileErr
+	}
+	if err != nil && !errdefs.IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+func sortedContainerNames(containers map[string]containerState) []string {
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedNetworkNames(networks map[string]networkState) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -10,8 +10,12 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 
+	"github.com/docker/stacks/pkg/admission"
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/policy"
+	"github.com/docker/stacks/pkg/secrets"
 	"github.com/docker/stacks/pkg/types"
+	"github.com/docker/stacks/pkg/validation"
 )
 
 // DefaultStacksBackend implements the interfaces.StacksBackend
@@ -27,6 +31,14 @@ type DefaultStacksBackend struct {
 	interfaces.SwarmServiceBackend
 	interfaces.SwarmNetworkBackend
 	interfaces.SwarmSecretBackend
+
+	// admission is the chain of admission webhooks the specs of created
+	// and updated stacks are submitted to, if any.
+	admission *admission.Chain
+
+	// policy holds the rules evaluated over the specs of created and
+	// updated stacks, if any.
+	policy *policy.Engine
 }
 
 /*
@@ -41,21 +53,60 @@ type DefaultStacksBackend struct {
  *  triggers could be shared between the shim and fake calls.
  */
 
+// BackendOptionFunc is the type used for functional arguments of the
+// DefaultStacksBackend during its creation.
+type BackendOptionFunc func(*DefaultStacksBackend)
+
+// WithAdmissionChain is a BackendOptionFunc which submits the specs of
+// created and updated stacks to the webhooks of chain.
+func WithAdmissionChain(chain *admission.Chain) BackendOptionFunc {
+	return func(b *DefaultStacksBackend) {
+		b.admission = chain
+	}
+}
+
+// WithPolicy is a BackendOptionFunc which evaluates the rules of engine
+// over the specs of created and updated stacks.
+func WithPolicy(engine *policy.Engine) BackendOptionFunc {
+	return func(b *DefaultStacksBackend) {
+		b.policy = engine
+	}
+}
+
 // NewDefaultStacksBackend creates a new DefaultStacksBackend.
-func NewDefaultStacksBackend(stackStore interfaces.StackStore, swarmBackend interfaces.SwarmResourceBackend) *DefaultStacksBackend {
-	return &DefaultStacksBackend{
+func NewDefaultStacksBackend(stackStore interfaces.StackStore, swarmBackend interfaces.SwarmResourceBackend, optsFunc ...BackendOptionFunc) *DefaultStacksBackend {
+	b := &DefaultStacksBackend{
 		StackStore:           stackStore,
 		SwarmResourceBackend: swarmBackend,
 	}
+
+	for _, f := range optsFunc {
+		f(b)
+	}
+
+	return b
 }
 
-// CreateStack creates a new stack if the stack is valid.
+// CreateStack creates a new stack if the stack is valid and admitted.
 func (b *DefaultStacksBackend) CreateStack(stackSpec types.StackSpec) (types.StackCreateResponse, error) {
-	if stackSpec.Annotations.Name == "" {
-		return types.StackCreateResponse{}, fmt.Errorf("StackSpec contains no name")
+	stackSpec, err := secrets.RestoreNew(stackSpec)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	stackSpec, err = b.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionCreate,
+		Spec:      stackSpec,
+	})
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	if err := b.validator().Error(stackSpec); err != nil {
+		return types.StackCreateResponse{}, err
 	}
 
-	id, err := b.StackStore.AddStack(stackSpec)
+	id, err := b.StackStore.AddStackWithOrchestrator(stackSpec, types.OrchestratorSwarm)
 	if err != nil {
 		return types.StackCreateResponse{}, fmt.Errorf("unable to store stack: %s", err)
 	}
@@ -70,19 +121,90 @@ func (b *DefaultStacksBackend) GetSnapshotStack(id string) (interfaces.SnapshotS
 	return b.StackStore.GetSnapshotStack(id)
 }
 
-// GetStack retrieves a stack by its ID.
-func (b *DefaultStacksBackend) GetStack(id string) (types.Stack, error) {
-	return b.StackStore.GetStack(id)
+// UpdateStack updates a stack if the new spec is valid and admitted. The
+// secrets of spec redacted by the Stacks API keep their stored data.
+func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	stack, err := b.StackStore.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if spec, err = secrets.Restore(spec, stack.Spec); err != nil {
+		return err
+	}
+
+	spec, err = b.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionUpdate,
+		ID:        id,
+		Spec:      spec,
+		OldSpec:   &stack.Spec,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := b.validator().Error(spec); err != nil {
+		return err
+	}
+	return b.StackStore.UpdateStack(id, spec, version)
+}
+
+// ValidateStack validates a StackSpec without storing it.
+func (b *DefaultStacksBackend) ValidateStack(spec types.StackSpec) types.StackValidationResult {
+	return b.validator().Validate(spec)
 }
 
-// ListStacks lists all stacks.
-func (b *DefaultStacksBackend) ListStacks() ([]types.Stack, error) {
-	return b.StackStore.ListStacks()
+// PolicyReport evaluates the policy rules of the backend over the existing
+// stacks.
+func (b *DefaultStacksBackend) PolicyReport() (types.StackPolicyReport, error) {
+	stacks, err := b.StackStore.ListStacks(types.StackListOptions{})
+	if err != nil {
+		return types.StackPolicyReport{}, err
+	}
+	return policy.Report(b.policy, stacks), nil
 }
 
-// UpdateStack updates a stack.
-func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, version uint64) error {
-	return b.StackStore.UpdateStack(id, spec, version)
+// admit submits request to the admission webhooks of the backend, if any.
+func (b *DefaultStacksBackend) admit(request types.StackAdmissionRequest) (types.StackSpec, error) {
+	if b.admission == nil {
+		return request.Spec, nil
+	}
+	return b.admission.Admit(request)
+}
+
+// validator checks StackSpecs against the swarm and the policy rules of the
+// backend, if any.
+func (b *DefaultStacksBackend) validator() *validation.Validator {
+	if b.policy == nil {
+		return validation.NewValidator(b.SwarmResourceBackend)
+	}
+	return validation.NewValidator(b.SwarmResourceBackend, validation.WithChecker(b.policy))
+}
+
+// ScaleStack sets the number of replicas of services of a stack. The scaled
+// spec is admitted and validated as any other update.
+func (b *DefaultStacksBackend) ScaleStack(id string, replicas map[string]uint64) error {
+	return interfaces.ScaleStackServices(b, id, replicas)
+}
+
+// PauseStack stops the reconciliation of a stack.
+func (b *DefaultStacksBackend) PauseStack(id string) error {
+	return interfaces.SetStackPaused(b.StackStore, id, true)
+}
+
+// ResumeStack resumes the reconciliation of a stack.
+func (b *DefaultStacksBackend) ResumeStack(id string) error {
+	return interfaces.SetStackPaused(b.StackStore, id, false)
+}
+
+// Maintenance returns the cluster-wide maintenance switch, as stored.
+func (b *DefaultStacksBackend) Maintenance() (types.StackMaintenance, error) {
+	return b.StackStore.GetMaintenance()
+}
+
+// SetMaintenance stores the cluster-wide maintenance switch, so that it
+// outlives restarts of the server.
+func (b *DefaultStacksBackend) SetMaintenance(maintenance types.StackMaintenance) error {
+	return b.StackStore.UpdateMaintenance(maintenance)
 }
 
 // DeleteStack deletes a stack.
@@ -1,13 +1,19 @@
 package backend
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/stacks/pkg/admission"
 	"github.com/docker/stacks/pkg/fakes"
 	"github.com/docker/stacks/pkg/mocks"
 	"github.com/docker/stacks/pkg/types"
@@ -59,7 +65,7 @@ func TestStacksBackendInvalidCreate(t *testing.T) {
 	require.Contains(err.Error(), "contains no name")
 
 	// Ensure no stacks were created
-	stacks, err := b.ListStacks()
+	stacks, err := b.ListStacks(types.StackListOptions{})
 	require.NoError(err)
 	require.Empty(stacks)
 }
@@ -132,7 +138,7 @@ func TestStacksBackendCRUD(t *testing.T) {
 	require.Equal("STK_2", response.ID)
 
 	// List both stacks
-	stacks, err := b.ListStacks()
+	stacks, err := b.ListStacks(types.StackListOptions{})
 	require.NoError(err)
 	require.Len(stacks, 2)
 
@@ -156,7 +162,7 @@ func TestStacksBackendCRUD(t *testing.T) {
 	require.NoError(err)
 	require.True(reflect.DeepEqual(stack.Spec, stack1Spec))
 	require.Equal(stack.ID, "STK_1")
-	// TODO: require.Equal(stack.Orchestrator, types.OrchestratorSwarm)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
 
 	// Update a stack
 	stack3Spec := types.StackSpec{
@@ -185,3 +191,183 @@ func TestStacksBackendCRUD(t *testing.T) {
 	require.Error(err)
 	require.Contains(err.Error(), "stack STK_2 not found")
 }
+
+func TestStacksBackendScale(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(fakes.NewFakeStackStore(), backendClient)
+
+	replicas := uint64(1)
+	response, err := b.CreateStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{
+					Name: "web",
+				},
+				Mode: swarm.ServiceMode{
+					Replicated: &swarm.ReplicatedService{
+						Replicas: &replicas,
+					},
+				},
+			},
+			{
+				Annotations: swarm.Annotations{
+					Name: "agent",
+				},
+				Mode: swarm.ServiceMode{
+					Global: &swarm.GlobalService{},
+				},
+			},
+		},
+	})
+	require.NoError(err)
+
+	before, err := b.GetStack(response.ID)
+	require.NoError(err)
+
+	require.NoError(b.ScaleStack(response.ID, map[string]uint64{"web": 5}))
+
+	stack, err := b.GetStack(response.ID)
+	require.NoError(err)
+	require.Equal(uint64(5), *stack.Spec.Services[0].Mode.Replicated.Replicas)
+	require.Equal(before.Version.Index+1, stack.Version.Index)
+	require.Equal(uint64(1), replicas)
+
+	err = b.ScaleStack(response.ID, map[string]uint64{"agent": 5})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	err = b.ScaleStack(response.ID, map[string]uint64{"nosuchservice": 5})
+	require.Error(err)
+	require.True(errdefs.IsNotFound(err))
+
+	// Nothing changed on failure
+	stack, err = b.GetStack(response.ID)
+	require.NoError(err)
+	require.Equal(before.Version.Index+1, stack.Version.Index)
+}
+
+func TestStacksBackendAdmission(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request types.StackAdmissionRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&request)) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		response := types.StackAdmissionResponse{Allowed: true}
+		if request.Operation == types.StackAdmissionUpdate {
+			if !assert.NotNil(t, request.OldSpec) {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if request.Spec.Annotations.Name != request.OldSpec.Annotations.Name {
+				response = types.StackAdmissionResponse{Reason: "stacks cannot be renamed"}
+			}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	defer server.Close()
+
+	chain, err := admission.NewChain([]admission.Webhook{
+		{Name: "rename", Type: admission.WebhookValidating, URL: server.URL},
+	})
+	require.NoError(err)
+	b := NewDefaultStacksBackend(fakes.NewFakeStackStore(), nil, WithAdmissionChain(chain))
+
+	spec := types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+	}
+	response, err := b.CreateStack(spec)
+	require.NoError(err)
+
+	stack, err := b.GetStack(response.ID)
+	require.NoError(err)
+	spec.Annotations.Name = "renamed"
+	err = b.UpdateStack(response.ID, spec, stack.Version.Index)
+	require.Error(err)
+	require.True(errdefs.IsForbidden(err))
+
+	spec.Annotations.Labels = map[string]string{"key": "value"}
+	spec.Annotations.Name = "teststack"
+	require.NoError(b.UpdateStack(response.ID, spec, stack.Version.Index))
+}
+
+func TestStacksBackendScaleAdmission(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request types.StackAdmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		response := types.StackAdmissionResponse{Allowed: true}
+		for _, service := range request.Spec.Services {
+			if *service.Mode.Replicated.Replicas > 3 {
+				response = types.StackAdmissionResponse{Reason: "too many replicas"}
+			}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	defer server.Close()
+
+	chain, err := admission.NewChain([]admission.Webhook{
+		{Name: "replicas", Type: admission.WebhookValidating, URL: server.URL},
+	})
+	require.NoError(err)
+	b := NewDefaultStacksBackend(fakes.NewFakeStackStore(), nil, WithAdmissionChain(chain))
+
+	replicas := uint64(1)
+	response, err := b.CreateStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{
+					Name: "web",
+				},
+				Mode: swarm.ServiceMode{
+					Replicated: &swarm.ReplicatedService{
+						Replicas: &replicas,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(err)
+
+	require.NoError(b.ScaleStack(response.ID, map[string]uint64{"web": 3}))
+
+	// Scaling is an update, which admission may reject
+	err = b.ScaleStack(response.ID, map[string]uint64{"web": 5})
+	require.Error(err)
+	require.True(errdefs.IsForbidden(err))
+
+	stack, err := b.GetStack(response.ID)
+	require.NoError(err)
+	require.Equal(uint64(3), *stack.Spec.Services[0].Mode.Replicated.Replicas)
+}
+
+func TestStacksBackendMaintenance(t *testing.T) {
+	require := require.New(t)
+	store := fakes.NewFakeStackStore()
+	b := NewDefaultStacksBackend(store, nil)
+
+	maintenance, err := b.Maintenance()
+	require.NoError(err)
+	require.False(maintenance.Enabled)
+
+	require.NoError(b.SetMaintenance(types.StackMaintenance{Enabled: true}))
+
+	// The switch is stored, and outlives the backend
+	maintenance, err = NewDefaultStacksBackend(store, nil).Maintenance()
+	require.NoError(err)
+	require.True(maintenance.Enabled)
+}
@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// ClientBackend serves the Stacks API over a client.StackAPIClient, such as
+// a router.StacksRouter routing stacks to orchestrators or clusters, rather
+// than over a store of stacks. The stacks of its client are reconciled
+// elsewhere: it neither evaluates policies nor has a maintenance switch.
+type ClientBackend struct {
+	client client.StackAPIClient
+}
+
+// NewClientBackend creates a ClientBackend serving the stacks of
+// stackClient.
+func NewClientBackend(stackClient client.StackAPIClient) *ClientBackend {
+	return &ClientBackend{
+		client: stackClient,
+	}
+}
+
+// CreateStack creates a stack on the default orchestrator and cluster of
+// the client.
+func (b *ClientBackend) CreateStack(spec types.StackSpec) (types.StackCreateResponse, error) {
+	return b.CreateStackWithOptions(spec, types.StackCreateOptions{})
+}
+
+// CreateStackWithOptions creates a stack on the orchestrator and the
+// cluster of options.
+func (b *ClientBackend) CreateStackWithOptions(spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	return b.client.StackCreate(context.Background(), spec, options)
+}
+
+// GetStack retrieves a stack by its ID.
+func (b *ClientBackend) GetStack(id string) (types.Stack, error) {
+	return b.client.StackInspect(context.Background(), id)
+}
+
+// ListStacks lists the stacks selected by options.
+func (b *ClientBackend) ListStacks(options types.StackListOptions) ([]types.Stack, error) {
+	return b.client.StackList(context.Background(), options)
+}
+
+// ListStacksPartial lists the stacks selected by options, along with the
+// warnings of the client if it lists stacks partially, as a
+// router.StacksRouter does.
+func (b *ClientBackend) ListStacksPartial(options types.StackListOptions) (types.StackList, error) {
+	if lister, ok := b.client.(partialLister); ok {
+		return lister.StackListPartial(context.Background(), options)
+	}
+	stacks, err := b.client.StackList(context.Background(), options)
+	if err != nil {
+		return types.StackList{}, err
+	}
+	return types.StackList{Items: stacks}, nil
+}
+
+// partialLister is implemented by the clients which list the stacks of the
+// backends they can reach, with warnings for the others.
+type partialLister interface {
+	StackListPartial(ctx context.Context, options types.StackListOptions) (types.StackList, error)
+}
+
+// UpdateStack updates a stack, provided it is still at version.
+func (b *ClientBackend) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	return b.client.StackUpdate(context.Background(), id, types.Version{Index: version}, spec, types.StackUpdateOptions{})
+}
+
+// ScaleStack sets the replicas of services of a stack.
+func (b *ClientBackend) ScaleStack(id string, replicas map[string]uint64) error {
+	return b.client.StackScale(context.Background(), id, replicas)
+}
+
+// ValidateStack validates a StackSpec. A spec which cannot be validated is
+// reported as invalid, with the reason why.
+func (b *ClientBackend) ValidateStack(spec types.StackSpec) types.StackValidationResult {
+	result, err := b.client.StackValidate(context.Background(), spec)
+	if err != nil {
+		return types.StackValidationResult{
+			Errors: []types.StackValidationIssue{{Message: err.Error()}},
+		}
+	}
+	return result
+}
+
+// PolicyReport is not implemented: policies are evaluated by the
+// controllers of the stacks.
+func (b *ClientBackend) PolicyReport() (types.StackPolicyReport, error) {
+	return types.StackPolicyReport{}, errdefs.NotImplemented(errors.New("policies are evaluated by the controllers of the stacks"))
+}
+
+// PauseStack pauses a stack.
+func (b *ClientBackend) PauseStack(id string) error {
+	return b.client.StackPause(context.Background(), id)
+}
+
+// ResumeStack resumes a stack.
+func (b *ClientBackend) ResumeStack(id string) error {
+	return b.client.StackResume(context.Background(), id)
+}
+
+// Maintenance always reports the maintenance switch as off: maintenance is
+// switched on the controllers of the stacks.
+func (b *ClientBackend) Maintenance() (types.StackMaintenance, error) {
+	return types.StackMaintenance{}, nil
+}
+
+// SetMaintenance is not implemented: maintenance is switched on the
+// controllers of the stacks.
+func (b *ClientBackend) SetMaintenance(types.StackMaintenance) error {
+	return errdefs.NotImplemented(errors.New("maintenance is switched on the controllers of the stacks"))
+}
+
+// DeleteStack deletes a stack.
+func (b *ClientBackend) DeleteStack(id string) error {
+	return b.client.StackDelete(context.Background(), id)
+}
+
+// IndexStats returns the statistics of the index of the client, if it
+// indexes the backend of its stacks as a router.StacksRouter does.
+func (b *ClientBackend) IndexStats() (types.StackIndexStats, error) {
+	reporter, ok := b.client.(indexReporter)
+	if !ok {
+		return types.StackIndexStats{}, errdefs.NotImplemented(errors.New("the client does not index stacks"))
+	}
+	return reporter.IndexStats(), nil
+}
+
+// indexReporter is implemented by the clients which index the backend of
+// their stacks.
+type indexReporter interface {
+	IndexStats() types.StackIndexStats
+}
+
+// MigrateStack migrates a stack to another orchestrator, if the client
+// serves several.
+func (b *ClientBackend) MigrateStack(id string, options types.StackMigrateOptions) (types.StackMigration, error) {
+	return b.client.StackMigrate(context.Background(), id, options)
+}
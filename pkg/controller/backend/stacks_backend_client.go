@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StacksBackendClient implements the client.StackAPIClient interface over
+// an interfaces.StacksBackend, so that a router.StacksRouter serves the
+// stacks the backend deploys on Swarm next to those of other orchestrators.
+type StacksBackendClient struct {
+	backend interfaces.StacksBackend
+}
+
+var _ client.StackAPIClient = &StacksBackendClient{}
+
+// NewStacksBackendClient creates a StacksBackendClient serving the stacks
+// of backend.
+func NewStacksBackendClient(backend interfaces.StacksBackend) *StacksBackendClient {
+	return &StacksBackendClient{
+		backend: backend,
+	}
+}
+
+// StackCreate creates a stack. The backend is not federated, so that
+// options cannot choose a cluster.
+func (c *StacksBackendClient) StackCreate(_ context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	if options.Cluster != "" {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("invalid cluster %s: the server is not federated", options.Cluster))
+	}
+	return c.backend.CreateStack(spec)
+}
+
+// StackValidate validates a StackSpec.
+func (c *StacksBackendClient) StackValidate(_ context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	return c.backend.ValidateStack(spec), nil
+}
+
+// StackInspect returns a stack by its ID.
+func (c *StacksBackendClient) StackInspect(_ context.Context, id string) (types.Stack, error) {
+	return c.backend.GetStack(id)
+}
+
+// StackList lists the stacks selected by options.
+func (c *StacksBackendClient) StackList(_ context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	return c.backend.ListStacks(options)
+}
+
+// StackUpdate updates a stack, provided it is still at version.
+func (c *StacksBackendClient) StackUpdate(_ context.Context, id string, version types.Version, spec types.StackSpec, _ types.StackUpdateOptions) error {
+	return c.backend.UpdateStack(id, spec, version.Index)
+}
+
+// StackScale sets the replicas of services of a stack.
+func (c *StacksBackendClient) StackScale(_ context.Context, id string, replicas map[string]uint64) error {
+	return c.backend.ScaleStack(id, replicas)
+}
+
+// StackPause pauses a stack.
+func (c *StacksBackendClient) StackPause(_ context.Context, id string) error {
+	return c.backend.PauseStack(id)
+}
+
+// StackResume resumes a stack.
+func (c *StacksBackendClient) StackResume(_ context.Context, id string) error {
+	return c.backend.ResumeStack(id)
+}
+
+// StackPatch applies a patch to the spec of a stack, at version unless
+// version is the zero Version.
+func (c *StacksBackendClient) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, data []byte, options types.StackUpdateOptions) error {
+	stack, err := c.backend.GetStack(id)
+	if err != nil {
+		return err
+	}
+	if version.Index != 0 && version.Index != stack.Version.Index {
+		return types.StackVersionConflict{
+			ID:      stack.ID,
+			Current: types.Version{Index: stack.Version.Index},
+		}
+	}
+
+	spec, err := patch.Apply(stack.Spec, patchType, data)
+	if err != nil {
+		return err
+	}
+	return c.StackUpdate(ctx, stack.ID, types.Version{Index: stack.Version.Index}, spec, options)
+}
+
+// StackDelete deletes a stack.
+func (c *StacksBackendClient) StackDelete(_ context.Context, id string) error {
+	return c.backend.DeleteStack(id)
+}
+
+// StackMigrate is not implemented, see types.ErrStackNotMigratable.
+func (c *StacksBackendClient) StackMigrate(_ context.Context, id string, _ types.StackMigrateOptions) (types.StackMigration, error) {
+	return types.StackMigration{}, types.ErrStackNotMigratable(id)
+}
@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/router"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendClientRouted(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(fakes.NewFakeStackStore(), backendClient)
+
+	stacks := router.NewStacksRouter()
+	stacks.RegisterBackend(types.OrchestratorSwarm, NewStacksBackendClient(b))
+	ctx := context.Background()
+
+	spec := types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+	}
+	resp, err := stacks.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.NoError(err)
+
+	// The orchestrator is stored along with the stack
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+	listed, err := b.ListStacks(types.StackListOptions{})
+	require.NoError(err)
+	require.Len(listed, 1)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), listed[0].Orchestrator)
+
+	_, err = stacks.StackCreate(ctx, spec, types.StackCreateOptions{Orchestrator: types.OrchestratorKubernetes})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = stacks.StackCreate(ctx, spec, types.StackCreateOptions{Cluster: "east"})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	require.NoError(stacks.StackPatch(ctx, resp.ID, types.Version{}, types.StackPatchMerge, []byte(`{"Annotations":{"Labels":{"env":"prod"}}}`), types.StackUpdateOptions{}))
+	stack, err = stacks.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal("prod", stack.Spec.Annotations.Labels["env"])
+
+	require.NoError(stacks.StackDelete(ctx, resp.ID))
+	_, err = b.GetStack(resp.ID)
+	require.True(errdefs.IsNotFound(err))
+}
@@ -8,7 +8,40 @@ import (
 type Backend interface {
 	CreateStack(types.StackSpec) (types.StackCreateResponse, error)
 	GetStack(id string) (types.Stack, error)
-	ListStacks() ([]types.Stack, error)
+	ListStacks(options types.StackListOptions) ([]types.Stack, error)
 	UpdateStack(id string, spec types.StackSpec, version uint64) error
+	ScaleStack(id string, replicas map[string]uint64) error
+	ValidateStack(spec types.StackSpec) types.StackValidationResult
+	PolicyReport() (types.StackPolicyReport, error)
+	PauseStack(id string) error
+	ResumeStack(id string) error
+	Maintenance() (types.StackMaintenance, error)
+	SetMaintenance(maintenance types.StackMaintenance) error
 	DeleteStack(id string) error
 }
+
+// Migrator is implemented by the Backends which serve several orchestrators
+// and can migrate stacks between them.
+type Migrator interface {
+	MigrateStack(id string, options types.StackMigrateOptions) (types.StackMigration, error)
+}
+
+// Creator is implemented by the Backends which serve several orchestrators
+// or clusters, and create stacks on the orchestrator and the cluster of
+// options.
+type Creator interface {
+	CreateStackWithOptions(spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error)
+}
+
+// PartialLister is implemented by the Backends which serve several
+// orchestrators or clusters, and list the stacks of those which can be
+// reached along with warnings for the others.
+type PartialLister interface {
+	ListStacksPartial(options types.StackListOptions) (types.StackList, error)
+}
+
+// IndexReporter is implemented by the Backends which index the backend of
+// their stacks, as a router.StacksRouter does.
+type IndexReporter interface {
+	IndexStats() (types.StackIndexStats, error)
+}
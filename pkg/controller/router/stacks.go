@@ -25,8 +25,22 @@ func (sr *stacksRouter) initRoutes() {
 	sr.routes = []router.Route{
 		router.NewGetRoute("/stacks", sr.getStacks),
 		router.NewPostRoute("/stacks", sr.createStack),
+		router.NewPostRoute("/stacks/validate", sr.validateStack),
+		router.NewPostRoute("/stacks/import", sr.importStack),
+		router.NewGetRoute("/stacks/policy/report", sr.getPolicyReport),
+		router.NewGetRoute("/stacks/maintenance", sr.getMaintenance),
+		router.NewPostRoute("/stacks/maintenance", sr.setMaintenance),
+		router.NewGetRoute("/stacks/router/index", sr.getIndexStats),
 		router.NewGetRoute("/stacks/{id}", sr.getStack),
+		router.NewGetRoute("/stacks/{id}/export", sr.exportStack),
 		router.NewDeleteRoute("/stacks/{id}", sr.removeStack),
 		router.NewPostRoute("/stacks/{id}", sr.updateStack),
+		router.NewPutRoute("/stacks/{id}", sr.updateStack),
+		router.NewRoute("PATCH", "/stacks/{id}", sr.patchStack),
+		router.NewPostRoute("/stacks/{id}/scale", sr.scaleStack),
+		router.NewPostRoute("/stacks/{id}/pause", sr.pauseStack),
+		router.NewPostRoute("/stacks/{id}/resume", sr.resumeStack),
+		router.NewPostRoute("/stacks/{id}/migrate", sr.migrateStack),
+		router.NewPostRoute("/stacks/{id}/services/{name}/scale", sr.scaleStackService),
 	}
 }
@@ -2,31 +2,217 @@ package router
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/stacks/pkg/bundle"
+	"github.com/docker/stacks/pkg/compose"
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/query"
+	"github.com/docker/stacks/pkg/secrets"
 	"github.com/docker/stacks/pkg/types"
 )
 
-func (sr *stacksRouter) getStacks(_ context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
-	stacks, err := sr.backend.ListStacks()
+func (sr *stacksRouter) getStacks(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	options, err := parseStackListOptions(r)
+	if err != nil {
+		return err
+	}
+
+	list, err := sr.listStacks(options)
 	if err != nil {
 		logrus.Errorf("error getting stacks: %s", err)
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, stacks)
+	if cursor := query.NextCursor(list.Items, options); cursor != "" {
+		w.Header().Set("X-Next-Cursor", cursor)
+	}
+	list.Items = secrets.RedactStacks(list.Items)
+	return httputils.WriteJSON(w, http.StatusOK, list)
+}
+
+// listStacks lists the stacks selected by options, with the warnings of
+// the PartialLister backends.
+func (sr *stacksRouter) listStacks(options types.StackListOptions) (types.StackList, error) {
+	if lister, ok := sr.backend.(PartialLister); ok {
+		return lister.ListStacksPartial(options)
+	}
+	stacks, err := sr.backend.ListStacks(options)
+	if err != nil {
+		return types.StackList{}, err
+	}
+	return types.StackList{Items: stacks}, nil
+}
+
+// parseStackListOptions reads the filters, sort, order, limit and cursor
+// query parameters of a stack listing.
+func parseStackListOptions(r *http.Request) (types.StackListOptions, error) {
+	if err := httputils.ParseForm(r); err != nil {
+		return types.StackListOptions{}, err
+	}
+
+	filter, err := filters.FromJSON(r.Form.Get("filters"))
+	if err != nil {
+		return types.StackListOptions{}, errdefs.InvalidParameter(err)
+	}
+
+	options := types.StackListOptions{
+		Filters: filter,
+		SortBy:  types.StackSortField(r.Form.Get("sort")),
+		Cursor:  r.Form.Get("cursor"),
+	}
+
+	switch order := r.Form.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		options.Descending = true
+	default:
+		return types.StackListOptions{}, errdefs.InvalidParameter(fmt.Errorf("invalid order '%s'", order))
+	}
+
+	if rawLimit := r.Form.Get("limit"); rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit < 0 {
+			return types.StackListOptions{}, errdefs.InvalidParameter(fmt.Errorf("invalid limit '%s'", rawLimit))
+		}
+		options.Limit = limit
+	}
+
+	return options, nil
 }
 
 func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	var request types.StackCreateRequest
+	spec, isCompose, err := readComposeSpec(r)
+	if err != nil {
+		return err
+	}
+	if isCompose {
+		request = types.StackCreateRequest{
+			StackSpec:    spec,
+			Orchestrator: types.OrchestratorChoice(r.URL.Query().Get("orchestrator")),
+			Cluster:      r.URL.Query().Get("cluster"),
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		if err == io.EOF {
+			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+		}
+		return errdefs.InvalidParameter(err)
+	}
+	id, err := sr.create(request.StackSpec, types.StackCreateOptions{
+		Orchestrator: request.Orchestrator,
+		Cluster:      request.Cluster,
+	})
+	if err != nil {
+		if invalid, ok := err.(types.StackValidationError); ok {
+			return writeValidationError(w, invalid)
+		}
+		logrus.Errorf("Error creating stack: %s", err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusCreated, id)
+}
+
+// readComposeSpec reads the StackSpec of a request whose body holds Compose
+// files, either as a stream of YAML documents or as the "file" parts of a
+// multipart form, which are merged in order. The "extends" parts of a form
+// are files which services may extend without them being merged. The name
+// query parameter names the stack. isCompose is false for the requests
+// whose body is a JSON document.
+func readComposeSpec(r *http.Request) (spec types.StackSpec, isCompose bool, err error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return types.StackSpec{}, false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return types.StackSpec{}, false, errdefs.InvalidParameter(fmt.Errorf("invalid content type: %s", err))
+	}
+
+	var files, extended []compose.File
+	switch mediaType {
+	case types.StackComposeMediaType:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return types.StackSpec{}, true, errdefs.InvalidParameter(err)
+		}
+		if files, err = compose.SplitDocuments(data); err != nil {
+			return types.StackSpec{}, true, err
+		}
+	case "multipart/form-data":
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return types.StackSpec{}, true, errdefs.InvalidParameter(err)
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return types.StackSpec{}, true, errdefs.InvalidParameter(err)
+			}
+			data, err := ioutil.ReadAll(part)
+			if err != nil {
+				return types.StackSpec{}, true, errdefs.InvalidParameter(err)
+			}
+			file := compose.File{Name: part.FileName(), Data: data}
+			switch part.FormName() {
+			case "file":
+				files = append(files, file)
+			case "extends":
+				extended = append(extended, file)
+			default:
+				return types.StackSpec{}, true, errdefs.InvalidParameter(fmt.Errorf("invalid form field %s", part.FormName()))
+			}
+		}
+	default:
+		return types.StackSpec{}, false, nil
+	}
+
+	if spec, err = compose.LoadFiles(files, extended); err != nil {
+		return types.StackSpec{}, true, err
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		spec.Annotations.Name = name
+	}
+	return spec, true, nil
+}
+
+// create creates a stack on the orchestrator and the cluster of options,
+// which only Creator backends choose among.
+func (sr *stacksRouter) create(spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	if creator, ok := sr.backend.(Creator); ok {
+		return creator.CreateStackWithOptions(spec, options)
+	}
+
+	// The backend deploys stacks on Swarm only
+	if options.Orchestrator != "" && options.Orchestrator != types.OrchestratorSwarm {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("invalid orchestrator choice %s", options.Orchestrator))
+	}
+	if options.Cluster != "" {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("invalid cluster %s: the server is not federated", options.Cluster))
+	}
+	return sr.backend.CreateStack(spec)
+}
+
+func (sr *stacksRouter) validateStack(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
 	var stackSpec types.StackSpec
 	if err := json.NewDecoder(r.Body).Decode(&stackSpec); err != nil {
 		if err == io.EOF {
@@ -35,28 +221,103 @@ func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r
 		return errdefs.InvalidParameter(err)
 	}
 
-	id, err := sr.backend.CreateStack(stackSpec)
+	return httputils.WriteJSON(w, http.StatusOK, sr.backend.ValidateStack(stackSpec))
+}
+
+func (sr *stacksRouter) getPolicyReport(_ context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
+	report, err := sr.backend.PolicyReport()
 	if err != nil {
-		logrus.Errorf("Error creating stack: %s", err)
+		logrus.Errorf("Error evaluating policy: %s", err)
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusCreated, id)
+	return httputils.WriteJSON(w, http.StatusOK, report)
+}
+
+func (sr *stacksRouter) getMaintenance(_ context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
+	maintenance, err := sr.backend.Maintenance()
+	if err != nil {
+		logrus.Errorf("Error getting maintenance switch: %s", err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, maintenance)
 }
 
-func (sr *stacksRouter) getStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
+func (sr *stacksRouter) getIndexStats(_ context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
+	reporter, ok := sr.backend.(IndexReporter)
+	if !ok {
+		return errdefs.NotImplemented(errors.New("the backend does not index stacks"))
+	}
+
+	stats, err := reporter.IndexStats()
+	if err != nil {
+		logrus.Errorf("Error getting index statistics: %s", err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, stats)
+}
+
+func (sr *stacksRouter) setMaintenance(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	var maintenance types.StackMaintenance
+	if err := json.NewDecoder(r.Body).Decode(&maintenance); err != nil {
+		if err == io.EOF {
+			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+		}
+		return errdefs.InvalidParameter(err)
+	}
+
+	if err := sr.backend.SetMaintenance(maintenance); err != nil {
+		logrus.Errorf("Error setting maintenance switch: %s", err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, maintenance)
+}
+
+func (sr *stacksRouter) getStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "compose" {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid format %s", format))
+	}
+
 	stack, err := sr.backend.GetStack(vars["id"])
 	if err != nil {
 		logrus.Errorf("Error getting stack %s: %s", vars["id"], err)
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, stack)
+	w.Header().Set("ETag", types.StackETag(stack.Version.Index))
+	if format != "compose" {
+		return httputils.WriteJSON(w, http.StatusOK, secrets.RedactStack(stack))
+	}
+
+	data, err := compose.Render(secrets.Redact(stack.Spec))
+	if err != nil {
+		return fmt.Errorf("unable to render stack %s as a Compose file: %s", vars["id"], err)
+	}
+	w.Header().Set("Content-Type", types.StackComposeMediaType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	return err
 }
 
-func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
-	err := sr.backend.DeleteStack(vars["id"])
+func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var err error
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		// The store cannot delete conditionally, which leaves a short
+		// window for an update to slip in between the check and the
+		// deletion.
+		_, err = sr.matchStack(vars["id"], ifMatch)
+	}
+	if err == nil {
+		err = sr.backend.DeleteStack(vars["id"])
+	}
 	if err != nil {
+		if conflict, ok := err.(types.StackVersionConflict); ok {
+			return writeConflict(w, http.StatusPreconditionFailed, conflict)
+		}
 		logrus.Errorf("Error removing stack %s: %s", vars["id"], err)
 		return err
 	}
@@ -65,27 +326,343 @@ func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, _
 	return nil
 }
 
-func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	var stackSpec types.StackSpec
-	if err := json.NewDecoder(r.Body).Decode(&stackSpec); err != nil {
+func (sr *stacksRouter) updateStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	stackSpec, isCompose, err := readComposeSpec(r)
+	if err != nil {
+		return err
+	}
+	if !isCompose {
+		if err := json.NewDecoder(r.Body).Decode(&stackSpec); err != nil {
+			if err == io.EOF {
+				return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+			}
+			return errdefs.InvalidParameter(err)
+		}
+	} else if stackSpec.Annotations.Name == "" {
+		// Compose files updating a stack keep its name unless they set one
+		current, err := sr.backend.GetStack(vars["id"])
+		if err != nil {
+			return err
+		}
+		stackSpec.Annotations.Name = current.Spec.Annotations.Name
+	}
+
+	// A conditional request is answered with 412 Precondition Failed when
+	// the stack changed, a request for an explicit version with 409
+	// Conflict.
+	var version uint64
+	conflictStatus := http.StatusConflict
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		conflictStatus = http.StatusPreconditionFailed
+		var stack types.Stack
+		stack, err = sr.matchStack(vars["id"], ifMatch)
+		version = stack.Version.Index
+	} else {
+		rawVersion := r.URL.Query().Get("version")
+		version, err = strconv.ParseUint(rawVersion, 10, 64)
+		if err != nil {
+			err := fmt.Errorf("invalid stack version '%s': %v", rawVersion, err)
+			return errdefs.InvalidParameter(err)
+		}
+	}
+
+	if err == nil {
+		err = sr.backend.UpdateStack(vars["id"], stackSpec, version)
+	}
+	if err != nil {
+		if conflict, ok := err.(types.StackVersionConflict); ok {
+			return writeConflict(w, conflictStatus, conflict)
+		}
+		if invalid, ok := err.(types.StackValidationError); ok {
+			return writeValidationError(w, invalid)
+		}
+		logrus.Errorf("Error updating stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	return nil
+}
+
+func (sr *stacksRouter) patchStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid patch content type: %s", err))
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	// The patch applies to the version of the stack named by the request,
+	// if any, and otherwise to the current one. Either way the update is
+	// rejected if the stack changes while the patch is applied.
+	var stack types.Stack
+	conflictStatus := http.StatusConflict
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		conflictStatus = http.StatusPreconditionFailed
+		stack, err = sr.matchStack(vars["id"], ifMatch)
+	} else {
+		stack, err = sr.backend.GetStack(vars["id"])
+		if rawVersion := r.URL.Query().Get("version"); err == nil && rawVersion != "" {
+			version, parseErr := strconv.ParseUint(rawVersion, 10, 64)
+			if parseErr != nil {
+				return errdefs.InvalidParameter(fmt.Errorf("invalid stack version '%s': %v", rawVersion, parseErr))
+			}
+			if version != stack.Version.Index {
+				err = types.StackVersionConflict{
+					ID:      stack.ID,
+					Current: types.Version{Index: stack.Version.Index},
+				}
+			}
+		}
+	}
+
+	if err == nil {
+		var spec types.StackSpec
+		spec, err = patch.Apply(stack.Spec, types.StackPatchType(mediaType), data)
+		if err == nil {
+			err = sr.backend.UpdateStack(vars["id"], spec, stack.Version.Index)
+		}
+	}
+	if err != nil {
+		if conflict, ok := err.(types.StackVersionConflict); ok {
+			return writeConflict(w, conflictStatus, conflict)
+		}
+		if invalid, ok := err.(types.StackValidationError); ok {
+			return writeValidationError(w, invalid)
+		}
+		logrus.Errorf("Error patching stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	return nil
+}
+
+func (sr *stacksRouter) scaleStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var scale types.StackScaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&scale); err != nil {
 		if err == io.EOF {
 			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
 		}
 		return errdefs.InvalidParameter(err)
 	}
+	if len(scale.Services) == 0 {
+		return errdefs.InvalidParameter(errors.New("no services to scale"))
+	}
 
-	rawVersion := r.URL.Query().Get("version")
-	version, err := strconv.ParseUint(rawVersion, 10, 64)
-	if err != nil {
-		err := fmt.Errorf("invalid stack version '%s': %v", rawVersion, err)
+	return sr.scale(w, vars["id"], scale.Services)
+}
+
+func (sr *stacksRouter) scaleStackService(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var scale types.StackServiceScaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&scale); err != nil {
+		if err == io.EOF {
+			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+		}
 		return errdefs.InvalidParameter(err)
 	}
+	if scale.Replicas == nil {
+		return errdefs.InvalidParameter(errors.New("missing number of replicas"))
+	}
+
+	return sr.scale(w, vars["id"], map[string]uint64{vars["name"]: *scale.Replicas})
+}
 
-	err = sr.backend.UpdateStack(vars["id"], stackSpec, version)
+func (sr *stacksRouter) scale(w http.ResponseWriter, id string, replicas map[string]uint64) error {
+	err := sr.backend.ScaleStack(id, replicas)
 	if err != nil {
-		logrus.Errorf("Error updating stack %s: %s", vars["id"], err)
+		logrus.Errorf("Error scaling stack %s: %s", id, err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) pauseStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
+	if err := sr.backend.PauseStack(vars["id"]); err != nil {
+		logrus.Errorf("Error pausing stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (sr *stacksRouter) resumeStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
+	if err := sr.backend.ResumeStack(vars["id"]); err != nil {
+		logrus.Errorf("Error resuming stack %s: %s", vars["id"], err)
 		return err
 	}
 
+	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+func (sr *stacksRouter) migrateStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	migrator, ok := sr.backend.(Migrator)
+	if !ok {
+		return errdefs.NotImplemented(errors.New("the backend serves a single orchestrator, and cannot migrate stacks"))
+	}
+
+	options, err := parseStackMigrateOptions(r)
+	if err != nil {
+		return err
+	}
+
+	migration, err := migrator.MigrateStack(vars["id"], options)
+	if err != nil {
+		if invalid, ok := err.(types.StackValidationError); ok {
+			return writeValidationError(w, invalid)
+		}
+		logrus.Errorf("Error migrating stack %s: %s", vars["id"], err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusAccepted, migration)
+}
+
+// parseStackMigrateOptions reads the to, cutover and timeout query
+// parameters of a stack migration.
+func parseStackMigrateOptions(r *http.Request) (types.StackMigrateOptions, error) {
+	if err := httputils.ParseForm(r); err != nil {
+		return types.StackMigrateOptions{}, err
+	}
+
+	options := types.StackMigrateOptions{
+		To:      types.OrchestratorChoice(r.Form.Get("to")),
+		CutOver: httputils.BoolValue(r, "cutover"),
+	}
+	if options.To == "" {
+		return types.StackMigrateOptions{}, errdefs.InvalidParameter(errors.New("missing orchestrator to migrate the stack to"))
+	}
+
+	if rawTimeout := r.Form.Get("timeout"); rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout < 0 {
+			return types.StackMigrateOptions{}, errdefs.InvalidParameter(fmt.Errorf("invalid timeout '%s'", rawTimeout))
+		}
+		options.Timeout = timeout
+	}
+
+	return options, nil
+}
+
+func (sr *stacksRouter) exportStack(_ context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	key, err := parseBundleKey(r)
+	if err != nil {
+		return err
+	}
+
+	stack, err := sr.backend.GetStack(vars["id"])
+	if err != nil {
+		return err
+	}
+
+	stackBundle, err := bundle.Export(stack, types.StackExportOptions{
+		Secrets: types.StackBundleSecrets(r.Form.Get("secrets")),
+		Key:     key,
+	})
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, stackBundle)
+}
+
+func (sr *stacksRouter) importStack(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	var request types.StackImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		if err == io.EOF {
+			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+		}
+		return errdefs.InvalidParameter(err)
+	}
+	key, err := parseBundleKey(r)
+	if err != nil {
+		return err
+	}
+
+	spec, options, err := bundle.Import(request.Bundle, types.StackImportOptions{
+		Name:         request.Name,
+		Orchestrator: request.Orchestrator,
+		Cluster:      request.Cluster,
+		Secrets:      request.Secrets,
+		Key:          key,
+	})
+	if err != nil {
+		return err
+	}
+
+	id, err := sr.create(spec, options)
+	if err != nil {
+		if invalid, ok := err.(types.StackValidationError); ok {
+			return writeValidationError(w, invalid)
+		}
+		logrus.Errorf("Error importing stack: %s", err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusCreated, id)
+}
+
+// parseBundleKey reads the AES key of the secrets of a StackBundle from the
+// types.StackBundleKeyHeader, if any.
+func parseBundleKey(r *http.Request) ([]byte, error) {
+	encoded := r.Header.Get(types.StackBundleKeyHeader)
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid %s header: %s", types.StackBundleKeyHeader, err))
+	}
+	return key, nil
+}
+
+// matchStack returns the stack if its current version matches one of the
+// entity tags of an If-Match header, and a types.StackVersionConflict
+// otherwise.
+func (sr *stacksRouter) matchStack(id, ifMatch string) (types.Stack, error) {
+	stack, err := sr.backend.GetStack(id)
+	if err != nil {
+		return types.Stack{}, err
+	}
+
+	for _, etag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(etag) == "*" {
+			return stack, nil
+		}
+		if version, err := types.ParseStackETag(etag); err == nil && version == stack.Version.Index {
+			return stack, nil
+		}
+	}
+
+	return types.Stack{}, types.StackVersionConflict{
+		ID:      stack.ID,
+		Current: types.Version{Index: stack.Version.Index},
+	}
+}
+
+// writeConflict responds to a request which conflicted with a change to the
+// stack, with the information needed to retry it.
+func writeConflict(w http.ResponseWriter, status int, conflict types.StackVersionConflict) error {
+	w.Header().Set("ETag", types.StackETag(conflict.Current.Index))
+	return httputils.WriteJSON(w, status, types.StackConflictResponse{
+		Message:        conflict.Error(),
+		ID:             conflict.ID,
+		CurrentVersion: conflict.Current,
+	})
+}
+
+// writeValidationError responds to a request which was rejected because of
+// an invalid StackSpec, with the issues found in it.
+func writeValidationError(w http.ResponseWriter, invalid types.StackValidationError) error {
+	return httputils.WriteJSON(w, http.StatusBadRequest, types.StackValidationErrorResponse{
+		Message:               invalid.Error(),
+		StackValidationResult: invalid.Result,
+	})
+}
@@ -0,0 +1,650 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/client/fake"
+	"github.com/docker/stacks/pkg/compose"
+	"github.com/docker/stacks/pkg/controller/backend"
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/policy"
+	stacksRouting "github.com/docker/stacks/pkg/router"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func newTestRouter(t *testing.T) (*stacksRouter, string) {
+	b := backend.NewDefaultStacksBackend(fakes.NewFakeStackStore(), nil)
+	resp, err := b.CreateStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+	})
+	require.NoError(t, err)
+	return &stacksRouter{backend: b}, resp.ID
+}
+
+// serve calls handler as the API server would, turning errors into
+// responses.
+func serve(handler httputils.APIFunc, r *http.Request, vars map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	if err := handler(context.Background(), w, r, vars); err != nil {
+		httputils.MakeErrorHandler(err)(w, r)
+	}
+	return w
+}
+
+func updateRequest(t *testing.T, method, target string, spec types.StackSpec) *http.Request {
+	body, err := json.Marshal(spec)
+	require.NoError(t, err)
+	return httptest.NewRequest(method, target, bytes.NewReader(body))
+}
+
+func TestGetStackETag(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+
+	w := serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+id, nil), map[string]string{"id": id})
+	require.Equal(http.StatusOK, w.Code)
+
+	var stack types.Stack
+	require.NoError(json.NewDecoder(w.Body).Decode(&stack))
+	require.Equal(types.StackETag(stack.Version.Index), w.Header().Get("ETag"))
+}
+
+func TestGetStackCompose(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+	vars := map[string]string{"id": id}
+
+	w := serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+id+"?format=compose", nil), vars)
+	require.Equal(http.StatusOK, w.Code)
+	require.Equal("application/x-yaml", w.Header().Get("Content-Type"))
+	require.NotEmpty(w.Header().Get("ETag"))
+
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	spec, err := compose.Load(w.Body.Bytes())
+	require.NoError(err)
+	require.Equal(stack.Spec.Annotations.Name, spec.Annotations.Name)
+
+	w = serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+id+"?format=xml", nil), vars)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestCreateStackCompose(t *testing.T) {
+	require := require.New(t)
+	sr, _ := newTestRouter(t)
+
+	base := "version: '3.8'\nservices:\n  web:\n    image: nginx:1.16\n    deploy:\n      replicas: 1\n"
+	prod := "services:\n  web:\n    image: nginx:1.17\n"
+
+	// Compose files are sent as the parts of a form
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for _, file := range []struct{ name, data string }{
+		{"docker-compose.yml", base},
+		{"docker-compose.prod.yml", prod},
+	} {
+		part, err := form.CreateFormFile("file", file.name)
+		require.NoError(err)
+		_, err = part.Write([]byte(file.data))
+		require.NoError(err)
+	}
+	require.NoError(form.Close())
+	r := httptest.NewRequest("POST", "/stacks?name=web", body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := serve(sr.createStack, r, nil)
+	require.Equal(http.StatusCreated, w.Code)
+	var resp types.StackCreateResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+	stack, err := sr.backend.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal("web", stack.Spec.Annotations.Name)
+	require.Equal("docker-compose.yml,docker-compose.prod.yml", stack.Spec.Annotations.Labels[types.StackComposeFilesLabel])
+	require.Equal("nginx:1.17", stack.Spec.Services[0].TaskTemplate.ContainerSpec.Image)
+	require.Equal(uint64(1), *stack.Spec.Services[0].Mode.Replicated.Replicas)
+
+	// or as a stream of YAML documents
+	r = httptest.NewRequest("POST", "/stacks/"+resp.ID+"?version="+strconv.FormatUint(stack.Version.Index, 10),
+		strings.NewReader(base+"---\n"+prod+"    deploy:\n      replicas: 3\n"))
+	r.Header.Set("Content-Type", types.StackComposeMediaType)
+	w = serve(sr.updateStack, r, map[string]string{"id": resp.ID})
+	require.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	stack, err = sr.backend.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(3), *stack.Spec.Services[0].Mode.Replicated.Replicas)
+	require.Equal("-,-", stack.Spec.Annotations.Labels[types.StackComposeFilesLabel])
+
+	r = httptest.NewRequest("POST", "/stacks?name=web", strings.NewReader("services:\n  web:\n    build: .\n"))
+	r.Header.Set("Content-Type", types.StackComposeMediaType)
+	w = serve(sr.createStack, r, nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateStackIfMatch(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+	vars := map[string]string{"id": id}
+
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	etag := types.StackETag(stack.Version.Index)
+
+	r := updateRequest(t, "PUT", "/stacks/"+id, stack.Spec)
+	r.Header.Set("If-Match", etag)
+	w := serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusOK, w.Code)
+
+	// The same entity tag is now stale
+	r = updateRequest(t, "PUT", "/stacks/"+id, stack.Spec)
+	r.Header.Set("If-Match", etag)
+	w = serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusPreconditionFailed, w.Code)
+
+	var conflict types.StackConflictResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&conflict))
+	require.Equal(id, conflict.ID)
+	require.Equal(stack.Version.Index+1, conflict.CurrentVersion.Index)
+	require.Contains(conflict.Message, "update out of sequence")
+	require.Equal(types.StackETag(conflict.CurrentVersion.Index), w.Header().Get("ETag"))
+
+	// Weak entity tags never match
+	r = updateRequest(t, "PUT", "/stacks/"+id, stack.Spec)
+	r.Header.Set("If-Match", "W/"+types.StackETag(stack.Version.Index+1))
+	w = serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusPreconditionFailed, w.Code)
+
+	// An unconditional update still fails on an explicit, stale version
+	r = updateRequest(t, "POST", "/stacks/"+id+"?version=1", stack.Spec)
+	w = serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusConflict, w.Code)
+
+	r = updateRequest(t, "PUT", "/stacks/"+id, stack.Spec)
+	r.Header.Set("If-Match", "*")
+	w = serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusOK, w.Code)
+}
+
+func TestRemoveStackIfMatch(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+	vars := map[string]string{"id": id}
+
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+
+	r := httptest.NewRequest("DELETE", "/stacks/"+id, nil)
+	r.Header.Set("If-Match", types.StackETag(stack.Version.Index+1))
+	w := serve(sr.removeStack, r, vars)
+	require.Equal(http.StatusPreconditionFailed, w.Code)
+
+	_, err = sr.backend.GetStack(id)
+	require.NoError(err)
+
+	r = httptest.NewRequest("DELETE", "/stacks/"+id, nil)
+	r.Header.Set("If-Match", types.StackETag(stack.Version.Index))
+	w = serve(sr.removeStack, r, vars)
+	require.Equal(http.StatusNoContent, w.Code)
+}
+
+func TestPatchStack(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+	vars := map[string]string{"id": id}
+
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+
+	r := httptest.NewRequest("PATCH", "/stacks/"+id, bytes.NewBufferString(`{"Annotations": {"Labels": {"team": "ci"}}}`))
+	r.Header.Set("Content-Type", string(types.StackPatchMerge))
+	r.Header.Set("If-Match", types.StackETag(stack.Version.Index))
+	w := serve(sr.patchStack, r, vars)
+	require.Equal(http.StatusOK, w.Code)
+
+	patched, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	require.Equal("teststack", patched.Spec.Annotations.Name)
+	require.Equal(map[string]string{"team": "ci"}, patched.Spec.Annotations.Labels)
+
+	// The patch does not apply to a stale version
+	r = httptest.NewRequest("PATCH", "/stacks/"+id, bytes.NewBufferString(`[{"op": "remove", "path": "/Annotations/Labels/team"}]`))
+	r.Header.Set("Content-Type", string(types.StackPatchJSON))
+	r.Header.Set("If-Match", types.StackETag(stack.Version.Index))
+	w = serve(sr.patchStack, r, vars)
+	require.Equal(http.StatusPreconditionFailed, w.Code)
+
+	r = httptest.NewRequest("PATCH", "/stacks/"+id, bytes.NewBufferString(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = serve(sr.patchStack, r, vars)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+// unreachableClient is a stacks client whose listings fail
+type unreachableClient struct {
+	*fake.StackClient
+}
+
+func (c unreachableClient) StackList(context.Context, types.StackListOptions) ([]types.Stack, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestGetStacksWarnings(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+
+	w := serve(sr.getStacks, httptest.NewRequest("GET", "/stacks", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	var list types.StackList
+	require.NoError(json.NewDecoder(w.Body).Decode(&list))
+	require.Len(list.Items, 1)
+	require.Equal(id, list.Items[0].ID)
+	require.Empty(list.Warnings)
+
+	// The stacks of the backends which cannot be reached are reported as
+	// warnings
+	stacks := stacksRouting.NewStacksRouter()
+	swarmClient := fake.NewStackClient()
+	_, err := swarmClient.StackCreate(context.Background(), types.StackSpec{}, types.StackCreateOptions{})
+	require.NoError(err)
+	stacks.RegisterBackend(types.OrchestratorSwarm, swarmClient)
+	stacks.RegisterBackend(types.OrchestratorKubernetes, unreachableClient{fake.NewStackClient()})
+	sr = &stacksRouter{backend: backend.NewClientBackend(stacks)}
+
+	w = serve(sr.getStacks, httptest.NewRequest("GET", "/stacks", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	list = types.StackList{}
+	require.NoError(json.NewDecoder(w.Body).Decode(&list))
+	require.Len(list.Items, 1)
+	require.Len(list.Warnings, 1)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), list.Warnings[0].Orchestrator)
+	require.Contains(list.Warnings[0].Message, "connection refused")
+}
+
+func TestIndexStats(t *testing.T) {
+	require := require.New(t)
+	sr, _ := newTestRouter(t)
+
+	// The default backend serves Swarm only, and has no index
+	w := serve(sr.getIndexStats, httptest.NewRequest("GET", "/stacks/router/index", nil), nil)
+	require.Equal(http.StatusNotImplemented, w.Code)
+
+	stacks := stacksRouting.NewStacksRouter()
+	stacks.RegisterBackend(types.OrchestratorSwarm, fake.NewStackClient())
+	sr = &stacksRouter{backend: backend.NewClientBackend(stacks)}
+	resp, err := stacks.StackCreate(context.Background(), types.StackSpec{
+		Annotations: swarm.Annotations{Name: "indexed"},
+	}, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = stacks.StackInspect(context.Background(), resp.ID)
+	require.NoError(err)
+
+	w = serve(sr.getIndexStats, httptest.NewRequest("GET", "/stacks/router/index", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	var stats types.StackIndexStats
+	require.NoError(json.NewDecoder(w.Body).Decode(&stats))
+	require.Equal(types.StackIndexStats{Hits: 1, Size: 1}, stats)
+}
+
+func TestSecretsRedacted(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+	vars := map[string]string{"id": id}
+
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	stack.Spec.Secrets = []swarm.SecretSpec{{
+		Annotations: swarm.Annotations{Name: "password"},
+		Data:        []byte("hunter2"),
+	}}
+	require.NoError(sr.backend.UpdateStack(id, stack.Spec, stack.Version.Index))
+
+	w := serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+id, nil), vars)
+	require.Equal(http.StatusOK, w.Code)
+	require.NotContains(w.Body.String(), "aHVudGVyMg==")
+	var redacted types.Stack
+	require.NoError(json.NewDecoder(w.Body).Decode(&redacted))
+	require.Empty(redacted.Spec.Secrets[0].Data)
+	require.NotEmpty(redacted.Spec.Secrets[0].Annotations.Labels[types.StackSecretHashLabel])
+
+	w = serve(sr.getStacks, httptest.NewRequest("GET", "/stacks", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	require.NotContains(w.Body.String(), "aHVudGVyMg==")
+
+	// The redacted spec can be sent back unchanged
+	r := updateRequest(t, "PUT", "/stacks/"+id, redacted.Spec)
+	r.Header.Set("If-Match", types.StackETag(redacted.Version.Index))
+	w = serve(sr.updateStack, r, vars)
+	require.Equal(http.StatusOK, w.Code)
+
+	stored, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	require.Equal([]byte("hunter2"), stored.Spec.Secrets[0].Data)
+	require.Empty(stored.Spec.Secrets[0].Annotations.Labels)
+}
+
+func TestScaleStack(t *testing.T) {
+	require := require.New(t)
+	b := backend.NewDefaultStacksBackend(fakes.NewFakeStackStore(), nil)
+	replicas := uint64(1)
+	resp, err := b.CreateStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{Name: "web"},
+				Mode:        swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+			},
+			{
+				Annotations: swarm.Annotations{Name: "db"},
+				Mode:        swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &replicas}},
+			},
+		},
+	})
+	require.NoError(err)
+	sr := &stacksRouter{backend: b}
+
+	r := httptest.NewRequest("POST", "/stacks/"+resp.ID+"/services/web/scale", bytes.NewBufferString(`{"replicas": 3}`))
+	w := serve(sr.scaleStackService, r, map[string]string{"id": resp.ID, "name": "web"})
+	require.Equal(http.StatusNoContent, w.Code)
+
+	r = httptest.NewRequest("POST", "/stacks/"+resp.ID+"/scale", bytes.NewBufferString(`{"Services": {"web": 0, "db": 2}}`))
+	w = serve(sr.scaleStack, r, map[string]string{"id": resp.ID})
+	require.Equal(http.StatusNoContent, w.Code)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(uint64(0), *stack.Spec.Services[0].Mode.Replicated.Replicas)
+	require.Equal(uint64(2), *stack.Spec.Services[1].Mode.Replicated.Replicas)
+
+	r = httptest.NewRequest("POST", "/stacks/"+resp.ID+"/services/web/scale", bytes.NewBufferString(`{}`))
+	w = serve(sr.scaleStackService, r, map[string]string{"id": resp.ID, "name": "web"})
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest("POST", "/stacks/"+resp.ID+"/services/cache/scale", bytes.NewBufferString(`{"replicas": 3}`))
+	w = serve(sr.scaleStackService, r, map[string]string{"id": resp.ID, "name": "cache"})
+	require.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestValidateStack(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+
+	spec := `{"Annotations": {"Name": "teststack"}, "Services": [{"Name": "web", "TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.17", "Secrets": [{"SecretName": "password"}]}}}]}`
+
+	r := httptest.NewRequest("POST", "/stacks/validate", bytes.NewBufferString(spec))
+	w := serve(sr.validateStack, r, nil)
+	require.Equal(http.StatusOK, w.Code)
+	var result types.StackValidationResult
+	require.NoError(json.NewDecoder(w.Body).Decode(&result))
+	require.False(result.Valid)
+	require.Len(result.Errors, 1)
+	require.Equal("Services[0].TaskTemplate.ContainerSpec.Secrets[0].SecretName", result.Errors[0].Field)
+
+	// Invalid specs are rejected on create and update, with the same issues
+	r = httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(spec))
+	w = serve(sr.createStack, r, nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+	var response types.StackValidationErrorResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(result.Errors, response.Errors)
+	require.Contains(response.Message, "password")
+
+	r = httptest.NewRequest("POST", "/stacks/"+id+"?version=1", bytes.NewBufferString(spec))
+	w = serve(sr.updateStack, r, map[string]string{"id": id})
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestCreateStackOrchestrator(t *testing.T) {
+	require := require.New(t)
+	sr, _ := newTestRouter(t)
+
+	r := httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(`{"Annotations": {"Name": "swarmstack"}, "Orchestrator": "swarm"}`))
+	w := serve(sr.createStack, r, nil)
+	require.Equal(http.StatusCreated, w.Code)
+	var resp types.StackCreateResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+	w = serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+resp.ID, nil), map[string]string{"id": resp.ID})
+	require.Equal(http.StatusOK, w.Code)
+	var stack types.Stack
+	require.NoError(json.NewDecoder(w.Body).Decode(&stack))
+	require.Equal("swarmstack", stack.Spec.Annotations.Name)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+
+	r = httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(`{"Annotations": {"Name": "kubestack"}, "Orchestrator": "kubernetes"}`))
+	w = serve(sr.createStack, r, nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestCreateStackCluster(t *testing.T) {
+	require := require.New(t)
+	sr, _ := newTestRouter(t)
+
+	body := `{"Annotations": {"Name": "webstack"}, "Cluster": "west"}`
+	w := serve(sr.createStack, httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(body)), nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	// Federations create stacks on the cluster of the request
+	fed := stacksRouting.NewStacksRouter()
+	west := fake.NewStackClient()
+	fed.RegisterCluster("east", fake.NewStackClient())
+	fed.RegisterCluster("west", west)
+	sr = &stacksRouter{backend: backend.NewClientBackend(fed)}
+
+	w = serve(sr.createStack, httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(body)), nil)
+	require.Equal(http.StatusCreated, w.Code)
+	var resp types.StackCreateResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal("west:1", resp.ID)
+	_, err := west.StackInspect(context.Background(), "1")
+	require.NoError(err)
+
+	w = serve(sr.getStack, httptest.NewRequest("GET", "/stacks/"+resp.ID, nil), map[string]string{"id": resp.ID})
+	require.Equal(http.StatusOK, w.Code)
+	var stack types.Stack
+	require.NoError(json.NewDecoder(w.Body).Decode(&stack))
+	require.Equal("west", stack.Cluster)
+
+	w = serve(sr.createStack, httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(`{"Annotations": {"Name": "webstack"}}`)), nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestExportImportStack(t *testing.T) {
+	require := require.New(t)
+	b := backend.NewDefaultStacksBackend(fakes.NewFakeStackStore(), nil)
+	sr := &stacksRouter{backend: b}
+	resp, err := b.CreateStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "teststack",
+		},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "password"},
+			Data:        []byte("hunter2"),
+		}},
+	})
+	require.NoError(err)
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+
+	r := httptest.NewRequest("GET", "/stacks/"+resp.ID+"/export?secrets=encrypted", nil)
+	w := serve(sr.exportStack, r, map[string]string{"id": resp.ID})
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest("GET", "/stacks/"+resp.ID+"/export?secrets=encrypted", nil)
+	r.Header.Set(types.StackBundleKeyHeader, key)
+	w = serve(sr.exportStack, r, map[string]string{"id": resp.ID})
+	require.Equal(http.StatusOK, w.Code)
+	var bundle types.StackBundle
+	require.NoError(json.NewDecoder(w.Body).Decode(&bundle))
+	require.Equal(resp.ID, bundle.Source.ID)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), bundle.Orchestrator)
+	require.NotContains(string(bundle.Spec.Secrets[0].Data), "hunter2")
+
+	// The stack is recreated under another name, with the same secrets
+	body, err := json.Marshal(types.StackImportRequest{Bundle: bundle, Name: "copy"})
+	require.NoError(err)
+	r = httptest.NewRequest("POST", "/stacks/import", bytes.NewReader(body))
+	r.Header.Set(types.StackBundleKeyHeader, key)
+	w = serve(sr.importStack, r, nil)
+	require.Equal(http.StatusCreated, w.Code)
+	var imported types.StackCreateResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&imported))
+	require.NotEqual(resp.ID, imported.ID)
+
+	stack, err := b.GetStack(imported.ID)
+	require.NoError(err)
+	require.Equal("copy", stack.Spec.Annotations.Name)
+	require.Equal([]byte("hunter2"), stack.Spec.Secrets[0].Data)
+
+	// Non-federated servers only import on Swarm
+	body, err = json.Marshal(types.StackImportRequest{Bundle: bundle, Orchestrator: types.OrchestratorKubernetes})
+	require.NoError(err)
+	r = httptest.NewRequest("POST", "/stacks/import", bytes.NewReader(body))
+	r.Header.Set(types.StackBundleKeyHeader, key)
+	w = serve(sr.importStack, r, nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestPolicyReport(t *testing.T) {
+	require := require.New(t)
+	engine, err := policy.NewEngine([]policy.Rule{
+		{Name: "team-label", Path: "Annotations.Labels[team]", Required: true},
+	})
+	require.NoError(err)
+	store := fakes.NewFakeStackStore()
+	_, err = store.AddStack(types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "legacy",
+		},
+	})
+	require.NoError(err)
+	sr := &stacksRouter{backend: backend.NewDefaultStacksBackend(store, nil, backend.WithPolicy(engine))}
+
+	// New stacks must comply with the policy
+	r := httptest.NewRequest("POST", "/stacks", bytes.NewBufferString(`{"Annotations": {"Name": "teststack"}}`))
+	w := serve(sr.createStack, r, nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+	var response types.StackValidationErrorResponse
+	require.NoError(json.NewDecoder(w.Body).Decode(&response))
+	require.Len(response.Errors, 1)
+	require.Equal("Annotations.Labels[team]", response.Errors[0].Field)
+
+	// Existing ones are reported
+	w = serve(sr.getPolicyReport, httptest.NewRequest("GET", "/stacks/policy/report", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	var report types.StackPolicyReport
+	require.NoError(json.NewDecoder(w.Body).Decode(&report))
+	require.Len(report.Stacks, 1)
+	require.Equal("legacy", report.Stacks[0].Name)
+	require.False(report.Stacks[0].Compliant)
+}
+
+func TestPauseStack(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+
+	w := serve(sr.pauseStack, httptest.NewRequest("POST", "/stacks/"+id+"/pause", nil), map[string]string{"id": id})
+	require.Equal(http.StatusNoContent, w.Code)
+	stack, err := sr.backend.GetStack(id)
+	require.NoError(err)
+	require.True(stack.Paused)
+
+	w = serve(sr.resumeStack, httptest.NewRequest("POST", "/stacks/"+id+"/resume", nil), map[string]string{"id": id})
+	require.Equal(http.StatusNoContent, w.Code)
+	stack, err = sr.backend.GetStack(id)
+	require.NoError(err)
+	require.False(stack.Paused)
+
+	w = serve(sr.pauseStack, httptest.NewRequest("POST", "/stacks/nosuchstack/pause", nil), map[string]string{"id": "nosuchstack"})
+	require.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestMaintenance(t *testing.T) {
+	require := require.New(t)
+	sr, _ := newTestRouter(t)
+
+	r := httptest.NewRequest("POST", "/stacks/maintenance", bytes.NewBufferString(`{"Enabled": true}`))
+	w := serve(sr.setMaintenance, r, nil)
+	require.Equal(http.StatusOK, w.Code)
+
+	w = serve(sr.getMaintenance, httptest.NewRequest("GET", "/stacks/maintenance", nil), nil)
+	require.Equal(http.StatusOK, w.Code)
+	var maintenance types.StackMaintenance
+	require.NoError(json.NewDecoder(w.Body).Decode(&maintenance))
+	require.True(maintenance.Enabled)
+
+	w = serve(sr.setMaintenance, httptest.NewRequest("POST", "/stacks/maintenance", nil), nil)
+	require.Equal(http.StatusBadRequest, w.Code)
+}
+
+// migratingBackend is a Backend which records the migrations of stacks
+type migratingBackend struct {
+	Backend
+	options types.StackMigrateOptions
+}
+
+func (b *migratingBackend) MigrateStack(id string, options types.StackMigrateOptions) (types.StackMigration, error) {
+	if _, err := b.GetStack(id); err != nil {
+		return types.StackMigration{}, err
+	}
+	b.options = options
+	return types.StackMigration{
+		SourceID: id,
+		From:     types.OrchestratorSwarm,
+		To:       options.To,
+		Phase:    types.StackMigrationCreating,
+	}, nil
+}
+
+func TestMigrateStack(t *testing.T) {
+	require := require.New(t)
+	sr, id := newTestRouter(t)
+
+	// The default backend serves Swarm only
+	r := httptest.NewRequest("POST", "/stacks/"+id+"/migrate?to=kubernetes", nil)
+	w := serve(sr.migrateStack, r, map[string]string{"id": id})
+	require.Equal(http.StatusNotImplemented, w.Code)
+
+	b := &migratingBackend{Backend: sr.backend}
+	sr.backend = b
+	r = httptest.NewRequest("POST", "/stacks/"+id+"/migrate?to=kubernetes&cutover=1&timeout=90s", nil)
+	w = serve(sr.migrateStack, r, map[string]string{"id": id})
+	require.Equal(http.StatusAccepted, w.Code)
+	var migration types.StackMigration
+	require.NoError(json.NewDecoder(w.Body).Decode(&migration))
+	require.Equal(types.StackMigrationCreating, migration.Phase)
+	require.Equal(types.StackMigrateOptions{
+		To:      types.OrchestratorKubernetes,
+		CutOver: true,
+		Timeout: 90 * time.Second,
+	}, b.options)
+
+	r = httptest.NewRequest("POST", "/stacks/"+id+"/migrate", nil)
+	w = serve(sr.migrateStack, r, map[string]string{"id": id})
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest("POST", "/stacks/"+id+"/migrate?to=kubernetes&timeout=soon", nil)
+	w = serve(sr.migrateStack, r, map[string]string{"id": id})
+	require.Equal(http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest("POST", "/stacks/nosuchstack/migrate?to=kubernetes", nil)
+	w = serve(sr.migrateStack, r, map[string]string{"id": "nosuchstack"})
+	require.Equal(http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,32 @@
+package standalone
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/controller/backend"
+	stacksRouter "github.com/docker/stacks/pkg/controller/router"
+	"github.com/docker/stacks/pkg/federation"
+)
+
+// federationServer runs a standalone http Server that serves the Stacks API
+// over the stacks controllers of the clusters listed in the
+// FederationConfigPath option. Stacks are reconciled by these controllers.
+func federationServer(opts ServerOptions) error {
+	stacks, err := federation.Load(opts.FederationConfigPath)
+	if err != nil {
+		return fmt.Errorf("unable to load federation: %s", err)
+	}
+
+	r := stacksRouter.NewRouter(backend.NewClientBackend(stacks))
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("0.0.0.0:%d", opts.ServerPort),
+		Handler: registerRoutes(r),
+	}
+
+	logrus.Infof("Running standalone Stacks API server federating clusters %v", stacks.Clusters())
+	return server.ListenAndServe()
+}
@@ -0,0 +1,149 @@
+package standalone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/containers"
+	"github.com/docker/stacks/pkg/kubernetes"
+	"github.com/docker/stacks/pkg/policy"
+	stacksRouting "github.com/docker/stacks/pkg/router"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// inCluster is the KubernetesAPI of the servers running in a pod of the
+// Kubernetes cluster stacks are deployed on.
+const inCluster = "in-cluster"
+
+// orchestrators are the orchestrators other than Swarm a server serves
+type orchestrators struct {
+	served []types.OrchestratorChoice
+
+	// containers reconciles the stacks of the none orchestrator, if it is
+	// served
+	containers *containers.Reconciler
+}
+
+// stackChecks are the admission webhooks and the policy rules of a server, if
+// any, which the stacks of every orchestrator are checked against
+type stackChecks struct {
+	admission *admission.Chain
+	policy    *policy.Engine
+}
+
+// loadChecks loads the admission webhooks and the policy rules configured
+// by opts.
+func loadChecks(opts ServerOptions) (stackChecks, error) {
+	var c stackChecks
+	if opts.AdmissionConfigPath != "" {
+		webhooks, err := admission.LoadConfig(opts.AdmissionConfigPath)
+		if err != nil {
+			return stackChecks{}, fmt.Errorf("unable to load admission webhooks: %s", err)
+		}
+		if c.admission, err = admission.NewChain(webhooks); err != nil {
+			return stackChecks{}, fmt.Errorf("unable to configure admission webhooks: %s", err)
+		}
+	}
+	if opts.PolicyPath != "" {
+		engine, err := policy.Load(opts.PolicyPath)
+		if err != nil {
+			return stackChecks{}, fmt.Errorf("unable to load policy rules: %s", err)
+		}
+		c.policy = engine
+	}
+	return c, nil
+}
+
+// registerOrchestrators registers the backends of the orchestrators other
+// than Swarm configured by opts with stacks, which check stacks as the
+// Swarm backend does. The stacks of the none orchestrator run on engine,
+// and are not reconciled while maintenance reports a maintenance.
+func registerOrchestrators(stacks *stacksRouting.StacksRouter, opts ServerOptions, c stackChecks, engine containers.Engine, maintenance func() (types.StackMaintenance, error)) (*orchestrators, error) {
+	o := &orchestrators{}
+
+	if opts.KubernetesAPI != "" {
+		config, err := kubernetesConfig(opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure kubernetes client: %s", err)
+		}
+		clientset, err := kubernetes.NewClientset(config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create kubernetes client: %s", err)
+		}
+		var clientOpts []kubernetes.ClientOptionFunc
+		if c.admission != nil {
+			clientOpts = append(clientOpts, kubernetes.WithAdmissionChain(c.admission))
+		}
+		if c.policy != nil {
+			clientOpts = append(clientOpts, kubernetes.WithPolicy(c.policy))
+		}
+		stacks.RegisterBackend(types.OrchestratorKubernetes, kubernetes.NewClient(clientset, clientOpts...))
+		o.served = append(o.served, types.OrchestratorKubernetes)
+	}
+
+	if opts.ContainersDataDir != "" {
+		// The stacks of the store are named apart from those of Swarm
+		store, err := newStackStore(opts, types.OrchestratorNone)
+		if err != nil {
+			return nil, err
+		}
+		o.containers = containers.NewReconciler(store, engine, opts.ContainersDataDir, containers.WithMaintenance(maintenance))
+		var clientOpts []containers.ClientOptionFunc
+		if c.admission != nil {
+			clientOpts = append(clientOpts, containers.WithAdmissionChain(c.admission))
+		}
+		if c.policy != nil {
+			clientOpts = append(clientOpts, containers.WithPolicy(c.policy))
+		}
+		stacks.RegisterBackend(types.OrchestratorNone, containers.NewClient(store, o.containers, clientOpts...))
+		o.served = append(o.served, types.OrchestratorNone)
+	}
+
+	return o, nil
+}
+
+// kubernetesConfig returns the configuration of the client of the
+// Kubernetes API server of opts.
+func kubernetesConfig(opts ServerOptions) (*rest.Config, error) {
+	if opts.KubernetesAPI == inCluster {
+		return rest.InClusterConfig()
+	}
+
+	config := &rest.Config{
+		Host: opts.KubernetesAPI,
+	}
+	config.CAFile = opts.KubernetesCAPath
+	if opts.KubernetesTokenPath != "" {
+		token, err := ioutil.ReadFile(opts.KubernetesTokenPath)
+		if err != nil {
+			return nil, err
+		}
+		config.BearerToken = strings.TrimSpace(string(token))
+	}
+	return config, nil
+}
+
+// defaultOrchestrator returns the orchestrator of the stacks created
+// without one.
+func defaultOrchestrator(opts ServerOptions) types.OrchestratorChoice {
+	if opts.DefaultOrchestrator == "" {
+		return types.OrchestratorSwarm
+	}
+	return opts.DefaultOrchestrator
+}
+
+// checkDefaultOrchestrator fails unless the default orchestrator of opts is
+// one of the served orchestrators.
+func checkDefaultOrchestrator(opts ServerOptions, served ...types.OrchestratorChoice) error {
+	orchestrator := defaultOrchestrator(opts)
+	for _, o := range served {
+		if o == orchestrator {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid default orchestrator %s: the server only serves %v", orchestrator, served)
+}
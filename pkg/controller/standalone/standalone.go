@@ -1,6 +1,7 @@
 package standalone
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -10,11 +11,16 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/stacks/pkg/containers"
 	"github.com/docker/stacks/pkg/controller/backend"
 	stacksRouter "github.com/docker/stacks/pkg/controller/router"
 	"github.com/docker/stacks/pkg/fakes"
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/policy"
 	"github.com/docker/stacks/pkg/reconciler"
+	stacksRouting "github.com/docker/stacks/pkg/router"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
 )
 
 // ServerOptions is the set of options required for the creation of a
@@ -23,6 +29,53 @@ type ServerOptions struct {
 	Debug            bool
 	DockerSocketPath string
 	ServerPort       int
+
+	// AdmissionConfigPath is the path of a JSON file listing the
+	// admission webhooks of the server, if any.
+	AdmissionConfigPath string
+
+	// PolicyPath is the path of a YAML file of policy rules evaluated over
+	// stacks, if any.
+	PolicyPath string
+
+	// Maintenance enables the stored cluster-wide maintenance switch when
+	// the server starts, so that no stack is reconciled until it is
+	// disabled. Without it, the stored switch is left as it was.
+	Maintenance bool
+
+	// SecretKeyPath is the path of a file holding the base64 encoded AES
+	// key the data of the secrets of stored stacks is encrypted with, if
+	// any.
+	SecretKeyPath string
+
+	// FederationConfigPath is the path of a JSON file listing the remote
+	// stacks controllers of the clusters the server federates, if any. A
+	// federating server neither stores nor reconciles stacks itself.
+	FederationConfigPath string
+
+	// DefaultOrchestrator is the orchestrator of the stacks created
+	// without one. It defaults to Swarm.
+	DefaultOrchestrator types.OrchestratorChoice
+
+	// KubernetesAPI is the URL of the API server of the Kubernetes cluster
+	// the stacks of the kubernetes orchestrator are deployed on, or
+	// "in-cluster" for the cluster the server runs in, if any. The API
+	// server is authenticated with the CA certificate of KubernetesCAPath,
+	// and the server with the bearer token of KubernetesTokenPath.
+	KubernetesAPI       string
+	KubernetesCAPath    string
+	KubernetesTokenPath string
+
+	// ContainersDataDir is the directory of the secret and config files of
+	// the stacks of the none orchestrator, which run as plain containers
+	// of the Docker engine of DockerSocketPath. The server only serves the
+	// none orchestrator if it is set.
+	ContainersDataDir string
+
+	// MigrationsPath is the path of a JSON file keeping the migrations of
+	// stacks between orchestrators, which are resumed when the server
+	// restarts. Without it, the running migrations are lost on restart.
+	MigrationsPath string
 }
 
 // Server initializes and runs a standalone http Server that serves the Stacks
@@ -34,6 +87,10 @@ func Server(opts ServerOptions) error {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
+	if opts.FederationConfigPath != "" {
+		return federationServer(opts)
+	}
+
 	// Create an unauthenticated docker client
 	dclient, err := client.NewClient(fmt.Sprintf("unix://%s", opts.DockerSocketPath), "", nil, nil)
 	if err != nil {
@@ -47,10 +104,32 @@ func Server(opts ServerOptions) error {
 
 	// Create the underlying storage for stacks and swarmstacks as an
 	// in-memory store.
-	stackStore := fakes.NewFakeStackStore()
+	stackStore, err := newStackStore(opts, "")
+	if err != nil {
+		return err
+	}
+
+	// Load the admission webhooks and the policy rules, which the stacks of
+	// every orchestrator are checked against before being stored.
+	checks, err := loadChecks(opts)
+	if err != nil {
+		return err
+	}
+	var backendOpts []backend.BackendOptionFunc
+	if checks.admission != nil {
+		backendOpts = append(backendOpts, backend.WithAdmissionChain(checks.admission))
+	}
+	if checks.policy != nil {
+		backendOpts = append(backendOpts, backend.WithPolicy(checks.policy))
+	}
 
 	// Create a Stacks API Backend, which includes the API handling logic.
-	stacksBackend := backend.NewDefaultStacksBackend(stackStore, swarmResourceBackend)
+	stacksBackend := backend.NewDefaultStacksBackend(stackStore, swarmResourceBackend, backendOpts...)
+	if opts.Maintenance {
+		if err := stacksBackend.SetMaintenance(types.StackMaintenance{Enabled: true}); err != nil {
+			return fmt.Errorf("unable to enable maintenance: %s", err)
+		}
+	}
 
 	// Create a BackendClient shim for the reconciler
 	backendClient := interfaces.NewBackendAPIClientShim(dclient, stacksBackend)
@@ -58,13 +137,45 @@ func Server(opts ServerOptions) error {
 	// Create the reconciler manager
 	reconcilerManager := reconciler.New(backendClient)
 
+	// Route the stacks to the backends of their orchestrators. The Swarm
+	// backend is wired up against the backendClient so that the API can
+	// trigger stack events.
+	routerOpts := []stacksRouting.StacksRouterOptionFunc{stacksRouting.WithDefaultOrchestrator(defaultOrchestrator(opts))}
+	if opts.MigrationsPath != "" {
+		routerOpts = append(routerOpts, stacksRouting.WithMigrationStore(stacksRouting.NewFileMigrationStore(opts.MigrationsPath)))
+	}
+	stacks := stacksRouting.NewStacksRouter(routerOpts...)
+	stacks.RegisterBackend(types.OrchestratorSwarm, backend.NewStacksBackendClient(backendClient))
+	orchestrators, err := registerOrchestrators(stacks, opts, checks, dclient, stacksBackend.Maintenance)
+	if err != nil {
+		return err
+	}
+	if err := checkDefaultOrchestrator(opts, append(orchestrators.served, types.OrchestratorSwarm)...); err != nil {
+		return err
+	}
+	if err := stacks.ResumeMigrations(); err != nil {
+		return err
+	}
+
 	// Create a Stacks API Router, which includes basic HTTP handlers
-	// for the Stacks APIs. This is wired up against the backendClient
-	// so that the API can trigger stack events.
-	r := stacksRouter.NewRouter(backendClient)
+	// for the Stacks APIs.
+	r := stacksRouter.NewRouter(&routedBackend{
+		ClientBackend: backend.NewClientBackend(stacks),
+		swarm:         backendClient,
+		policy:        checks.policy,
+		containers:    orchestrators.containers,
+	})
 
 	errChan := make(chan error)
 
+	// Launch the reconciler of the containers of the none orchestrator
+	if orchestrators.containers != nil {
+		go func() {
+			logrus.Infof("Starting containers Stacks reconciler")
+			errChan <- orchestrators.containers.Run(context.Background())
+		}()
+	}
+
 	server := &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%d", opts.ServerPort),
 		Handler: registerRoutes(r),
@@ -85,6 +196,75 @@ func Server(opts ServerOptions) error {
 	return <-errChan
 }
 
+// newStackStore creates an in-memory store of stacks whose IDs start with
+// keyPrefix, if any. The data of the secrets of the stacks is encrypted
+// with the secret key of opts, if any.
+func newStackStore(opts ServerOptions, keyPrefix string) (interfaces.StackStore, error) {
+	store := fakes.NewFakeStackStore()
+	if keyPrefix != "" {
+		store.SpecifyKeyPrefix(keyPrefix)
+	}
+	if opts.SecretKeyPath == "" {
+		return store, nil
+	}
+
+	key, err := secrets.LoadKey(opts.SecretKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load secret key: %s", err)
+	}
+	cipher, err := secrets.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %s", err)
+	}
+	return secrets.NewEncryptedStackStore(store, cipher), nil
+}
+
+// routedBackend serves the Stacks API over a router.StacksRouter, along
+// with the maintenance switch of the Swarm backend, which is that of the
+// server.
+type routedBackend struct {
+	*backend.ClientBackend
+	swarm interfaces.StacksBackend
+
+	// policy holds the policy rules of the server, if any
+	policy *policy.Engine
+
+	// containers reconciles the stacks of the none orchestrator, if the
+	// server serves it
+	containers *containers.Reconciler
+}
+
+// PolicyReport evaluates the policy rules of the server over the stacks of
+// every orchestrator.
+func (b *routedBackend) PolicyReport() (types.StackPolicyReport, error) {
+	stacks, err := b.ListStacks(types.StackListOptions{})
+	if err != nil {
+		return types.StackPolicyReport{}, err
+	}
+	return policy.Report(b.policy, stacks), nil
+}
+
+// Maintenance returns the maintenance switch of the server.
+func (b *routedBackend) Maintenance() (types.StackMaintenance, error) {
+	return b.swarm.Maintenance()
+}
+
+// SetMaintenance switches the maintenance of the server. Every stack is
+// reconciled once the maintenance is over.
+func (b *routedBackend) SetMaintenance(maintenance types.StackMaintenance) error {
+	if err := b.swarm.SetMaintenance(maintenance); err != nil {
+		return err
+	}
+	if b.containers != nil && !maintenance.Enabled {
+		go func() {
+			if err := b.containers.ReconcileAll(context.Background()); err != nil {
+				logrus.Errorf("unable to reconcile containers stacks after maintenance: %s", err)
+			}
+		}()
+	}
+	return nil
+}
+
 // versionMatcher defines a variable matcher to be parsed by the router
 // when a request is about to be served.
 const versionMatcher = "/v{version:[0-9.]+}"
@@ -10,7 +10,10 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/policy"
 	"github.com/docker/stacks/pkg/types"
+	"github.com/docker/stacks/pkg/validation"
 )
 
 // FakeReconcilerClient is a fake implementing the BackendClient interface,
@@ -33,11 +36,6 @@ func (*FakeReconcilerClient) GetNode(id string) (swarm.Node, error) {
 	return swarm.Node{}, FakeUnimplemented
 }
 
-// GetTasks calls of the SwarmResourceBackend - unused
-func (*FakeReconcilerClient) GetTasks(dockerTypes.TaskListOptions) ([]swarm.Task, error) {
-	return []swarm.Task{}, FakeUnimplemented
-}
-
 // GetTask calls of the SwarmResourceBackend - unused
 func (*FakeReconcilerClient) GetTask(string) (swarm.Task, error) {
 	return swarm.Task{}, FakeUnimplemented
@@ -82,6 +80,46 @@ func (f *FakeReconcilerClient) CreateStack(stackSpec types.StackSpec) (types.Sta
 	}, err
 }
 
+// ScaleStack sets the number of replicas of services of a stack
+func (f *FakeReconcilerClient) ScaleStack(id string, replicas map[string]uint64) error {
+	return interfaces.ScaleStackServices(&f.FakeStackStore, id, replicas)
+}
+
+// ValidateStack validates a StackSpec against the fake swarm resources
+func (f *FakeReconcilerClient) ValidateStack(spec types.StackSpec) types.StackValidationResult {
+	return validation.NewValidator(f).Validate(spec)
+}
+
+// PolicyReport reports every stack as compliant, as there are no policy
+// rules
+func (f *FakeReconcilerClient) PolicyReport() (types.StackPolicyReport, error) {
+	stacks, err := f.FakeStackStore.ListStacks(types.StackListOptions{})
+	if err != nil {
+		return types.StackPolicyReport{}, err
+	}
+	return policy.Report(nil, stacks), nil
+}
+
+// PauseStack stops the reconciliation of a stack
+func (f *FakeReconcilerClient) PauseStack(id string) error {
+	return interfaces.SetStackPaused(&f.FakeStackStore, id, true)
+}
+
+// ResumeStack resumes the reconciliation of a stack
+func (f *FakeReconcilerClient) ResumeStack(id string) error {
+	return interfaces.SetStackPaused(&f.FakeStackStore, id, false)
+}
+
+// Maintenance returns the cluster-wide maintenance switch
+func (f *FakeReconcilerClient) Maintenance() (types.StackMaintenance, error) {
+	return f.FakeStackStore.GetMaintenance()
+}
+
+// SetMaintenance sets the cluster-wide maintenance switch
+func (f *FakeReconcilerClient) SetMaintenance(maintenance types.StackMaintenance) error {
+	return f.FakeStackStore.UpdateMaintenance(maintenance)
+}
+
 // GenerateStackDependencies creates a new stack if the stack is valid.
 // nolint: gocyclo
 func (f *FakeReconcilerClient) GenerateStackDependencies(stackID string) error {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/containerd/typeurl"
 
@@ -33,6 +34,7 @@ import (
  *   MarkServiceSpecForError(errorKey string, *swarm.ServiceSpec, ops ...string)
  *   SpecifyKeyPrefix(keyPrefix string)
  *   SpecifyErrorTrigger(errorKey string, err error)
+ *   SpecifyTaskState(image string, state swarm.TaskState)
  */
 
 // FakeServiceStore contains the subset of Backend APIs SwarmServiceBackend
@@ -44,6 +46,7 @@ type FakeServiceStore struct {
 
 	services       map[string]*swarm.Service
 	servicesByName map[string]string
+	taskStates     map[string]swarm.TaskState
 }
 
 func init() {
@@ -72,6 +75,7 @@ func NewFakeServiceStore() *FakeServiceStore {
 		curID:          1,
 		services:       map[string]*swarm.Service{},
 		servicesByName: map[string]string{},
+		taskStates:     map[string]swarm.TaskState{},
 		labelErrors:    map[string]error{},
 	}
 }
@@ -223,6 +227,53 @@ func (f *FakeServiceStore) UpdateService(
 	return &dockerTypes.ServiceUpdateResponse{}, nil
 }
 
+// GetTasks returns a task for each replica of the services selected by the
+// service filter, or of every service without one. The tasks are created
+// at the time of the call and are running, unless SpecifyTaskState directed
+// otherwise for the image of their service.
+func (f *FakeServiceStore) GetTasks(opts dockerTypes.TaskListOptions) ([]swarm.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := []swarm.Task{}
+	now := time.Now()
+	for _, id := range f.SortedIDs() {
+		service := f.services[id]
+		if opts.Filters.Len() != 0 && !opts.Filters.ExactMatch("service", service.ID) && !opts.Filters.ExactMatch("service", service.Spec.Annotations.Name) {
+			continue
+		}
+
+		replicas := uint64(1)
+		if mode := service.Spec.Mode.Replicated; mode != nil && mode.Replicas != nil {
+			replicas = *mode.Replicas
+		}
+
+		state := swarm.TaskStateRunning
+		if container := service.Spec.TaskTemplate.ContainerSpec; container != nil {
+			if specified, ok := f.taskStates[container.Image]; ok {
+				state = specified
+			}
+		}
+
+		for slot := uint64(1); slot <= replicas; slot++ {
+			result = append(result, swarm.Task{
+				ID: fmt.Sprintf("%s_TASK_%v", service.ID, slot),
+				Meta: swarm.Meta{
+					CreatedAt: now,
+				},
+				Spec:         service.Spec.TaskTemplate,
+				ServiceID:    service.ID,
+				Slot:         int(slot),
+				DesiredState: swarm.TaskStateRunning,
+				Status: swarm.TaskStatus{
+					State: state,
+				},
+			})
+		}
+	}
+	return result, nil
+}
+
 // RemoveService deletes the service
 func (f *FakeServiceStore) RemoveService(idOrName string) error {
 	f.mu.Lock()
@@ -264,6 +315,14 @@ func (f *FakeServiceStore) SpecifyErrorTrigger(errorKey string, err error) {
 	f.labelErrors[errorKey] = err
 }
 
+// SpecifyTaskState sets the state of the tasks of the services running
+// image
+func (f *FakeServiceStore) SpecifyTaskState(image string, state swarm.TaskState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.taskStates[image] = state
+}
+
 // SpecifyKeyPrefix provides prefix to generated ID's
 func (f *FakeServiceStore) SpecifyKeyPrefix(keyPrefix string) {
 	f.keyPrefix = keyPrefix
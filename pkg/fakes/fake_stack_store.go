@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/query"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -44,6 +45,8 @@ type FakeStackStore struct {
 
 	stacks       map[string]*interfaces.SnapshotStack
 	stacksByName map[string]string
+
+	maintenance types.StackMaintenance
 }
 
 // These type registrations are for TESTING in order to create deep copies
@@ -71,9 +74,12 @@ func fakeConstructStack(snapshotStack *interfaces.SnapshotStack) types.Stack {
 	stackSpec := CopyStackSpec(snapshotStack.CurrentSpec)
 
 	stack := types.Stack{
-		ID:   snapshotStack.ID,
-		Meta: snapshotStack.Meta,
-		Spec: *stackSpec,
+		ID:           snapshotStack.ID,
+		Meta:         snapshotStack.Meta,
+		Spec:         *stackSpec,
+		Orchestrator: snapshotStack.Orchestrator,
+		Status:       snapshotStack.Status,
+		Paused:       snapshotStack.Paused,
 	}
 	return stack
 }
@@ -110,6 +116,12 @@ func (s *FakeStackStore) newID() string {
 
 // AddStack adds a stack to the store.
 func (s *FakeStackStore) AddStack(spec types.StackSpec) (string, error) {
+	return s.AddStackWithOrchestrator(spec, "")
+}
+
+// AddStackWithOrchestrator adds a stack deployed on orchestrator to the
+// store.
+func (s *FakeStackStore) AddStackWithOrchestrator(spec types.StackSpec, orchestrator types.OrchestratorChoice) (string, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -141,6 +153,8 @@ func (s *FakeStackStore) AddStack(spec types.StackSpec) (string, error) {
 		Networks:    []interfaces.SnapshotResource{},
 		Secrets:     []interfaces.SnapshotResource{},
 		Configs:     []interfaces.SnapshotResource{},
+
+		Orchestrator: orchestrator,
 	}
 
 	s.InternalAddStack(snapshot.ID, snapshot)
@@ -161,7 +175,10 @@ func (s *FakeStackStore) UpdateStack(idOrName string, stackSpec types.StackSpec,
 	}
 
 	if existing.Version.Index != version {
-		return fmt.Errorf("update out of sequence")
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: existing.Version.Index},
+		}
 	}
 
 	if err := s.maybeTriggerAnError("UpdateStack", existing.CurrentSpec); err != nil {
@@ -197,7 +214,10 @@ func (s *FakeStackStore) UpdateSnapshotStack(idOrName string, snapshot interface
 	}
 
 	if existing.Version.Index != version {
-		return interfaces.SnapshotStack{}, fmt.Errorf("update out of sequence")
+		return interfaces.SnapshotStack{}, types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: existing.Version.Index},
+		}
 	}
 
 	if err := s.maybeTriggerAnError("UpdateSnapshotStack", existing.CurrentSpec); err != nil {
@@ -215,6 +235,12 @@ func (s *FakeStackStore) UpdateSnapshotStack(idOrName string, snapshot interface
 	existing.Configs = copied.Configs
 	existing.Secrets = copied.Secrets
 	existing.Networks = copied.Networks
+	existing.Status = copied.Status
+	existing.RolledBack = copied.RolledBack
+	existing.Rollout = copied.Rollout
+	existing.Deployments = copied.Deployments
+	existing.Paused = copied.Paused
+	existing.Versions = copied.Versions
 
 	s.stacks[id] = existing
 	return *existing, nil
@@ -262,8 +288,8 @@ func (s *FakeStackStore) GetSnapshotStack(idOrName string) (interfaces.SnapshotS
 	return *stack, s.maybeTriggerAnError("GetSnapshotStack", stack.CurrentSpec)
 }
 
-// ListStacks returns all known stacks from the store.
-func (s *FakeStackStore) ListStacks() ([]types.Stack, error) {
+// ListStacks returns the known stacks selected by options.
+func (s *FakeStackStore) ListStacks(options types.StackListOptions) ([]types.Stack, error) {
 	s.RLock()
 	defer s.RUnlock()
 	stacks := []types.Stack{}
@@ -274,7 +300,22 @@ func (s *FakeStackStore) ListStacks() ([]types.Stack, error) {
 		}
 		stacks = append(stacks, fakeConstructStack(snapshot))
 	}
-	return stacks, nil
+	return query.Stacks(stacks, options)
+}
+
+// GetMaintenance returns the cluster-wide maintenance switch.
+func (s *FakeStackStore) GetMaintenance() (types.StackMaintenance, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.maintenance, nil
+}
+
+// UpdateMaintenance sets the cluster-wide maintenance switch.
+func (s *FakeStackStore) UpdateMaintenance(maintenance types.StackMaintenance) error {
+	s.Lock()
+	defer s.Unlock()
+	s.maintenance = maintenance
+	return nil
 }
 
 func (s *FakeStackStore) maybeTriggerAnError(operation string, spec types.StackSpec) error {
@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,6 +37,7 @@ func TestUpdateFakeStackStore(t *testing.T) {
 	updateErr =
 		store.UpdateStack(id1, stack2.Spec, astack.Version.Index)
 	require.Error(updateErr)
+	require.True(errdefs.IsConflict(updateErr))
 
 	// id missing
 	updateErr =
@@ -176,7 +178,7 @@ func TestSpecifiedErrorsFakeStackStore(t *testing.T) {
 	_, err = store.AddStack(fixtures[7].Spec)
 	require.NoError(err)
 
-	_, err = store.ListStacks()
+	_, err = store.ListStacks(types.StackListOptions{})
 	require.Error(err)
 	require.True(err == FakeUnimplemented)
 
@@ -229,7 +231,7 @@ func TestCRDFakeStackStore(t *testing.T) {
 	}
 
 	// Assert we can list the three items and fetch them individually
-	stacks, stacksErr := store.ListStacks()
+	stacks, stacksErr := store.ListStacks(types.StackListOptions{})
 	require.NoError(stacksErr)
 	require.NotNil(stacks)
 	require.Len(stacks, 3)
@@ -266,7 +268,7 @@ func TestCRDFakeStackStore(t *testing.T) {
 	require.NotEmpty(stacksPointers)
 
 	// Assert we can list the two items and fetch them individually
-	stacks2, err2 := store.ListStacks()
+	stacks2, err2 := store.ListStacks(types.StackListOptions{})
 	require.NoError(err2)
 	require.NotNil(stacks2)
 	require.Len(stacks2, 2)
@@ -289,7 +291,7 @@ func TestCRDFakeStackStore(t *testing.T) {
 	require.True(errdefs.IsNotFound(err))
 
 	// Ensure the expected list of stacks is present
-	stacks, err = store.ListStacks()
+	stacks, err = store.ListStacks(types.StackListOptions{})
 	require.NoError(err)
 	require.NotNil(stacks)
 	require.Len(stacks, 3)
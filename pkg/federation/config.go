@@ -0,0 +1,169 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/tlsconfig"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/router"
+)
+
+// Cluster is the configuration of a cluster of a federation, served by the
+// stacks controller at URL
+type Cluster struct {
+	Name string
+	URL  string
+
+	// TLS configures the connections to an HTTPS stacks controller. The
+	// system certificate authorities are trusted when it is omitted.
+	TLS *TLS `json:",omitempty"`
+
+	// TimeoutSeconds bounds each call to the controller, instead of
+	// router.DefaultBackendTimeout.
+	TimeoutSeconds int `json:",omitempty"`
+
+	// Default marks the cluster the stacks which do not name one are
+	// created on. At most one cluster is the default.
+	Default bool `json:",omitempty"`
+}
+
+// TLS is the TLS configuration of the connections to a stacks controller
+type TLS struct {
+	// CAFile is the certificate authorities trusted to sign the certificate
+	// of the controller, in place of the system ones.
+	CAFile string `json:",omitempty"`
+
+	// CertFile and KeyFile are the certificate and the key the federation
+	// authenticates with, if the controller requires clients to.
+	CertFile string `json:",omitempty"`
+	KeyFile  string `json:",omitempty"`
+
+	InsecureSkipVerify bool `json:",omitempty"`
+}
+
+// LoadConfig reads the list of Clusters from a JSON file, and checks that
+// their names are valid and unique.
+func LoadConfig(path string) ([]Cluster, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var clusters []Cluster
+	if err := json.Unmarshal(data, &clusters); err != nil {
+		return nil, fmt.Errorf("invalid federation configuration %s: %s", path, err)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("invalid federation configuration %s: no clusters", path)
+	}
+
+	names := make(map[string]bool, len(clusters))
+	defaultCluster := ""
+	for _, cluster := range clusters {
+		if err := validateName(cluster.Name); err != nil {
+			return nil, fmt.Errorf("invalid federation configuration %s: %s", path, err)
+		}
+		if cluster.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("invalid federation configuration %s: cluster %s has a negative timeout", path, cluster.Name)
+		}
+		if names[cluster.Name] {
+			return nil, fmt.Errorf("invalid federation configuration %s: duplicate cluster %s", path, cluster.Name)
+		}
+		names[cluster.Name] = true
+		if cluster.Default {
+			if defaultCluster != "" {
+				return nil, fmt.Errorf("invalid federation configuration %s: both clusters %s and %s are the default", path, defaultCluster, cluster.Name)
+			}
+			defaultCluster = cluster.Name
+		}
+	}
+	return clusters, nil
+}
+
+func validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("cluster has no name")
+	}
+	if strings.Contains(name, router.ClusterSeparator) {
+		return fmt.Errorf("cluster name %s contains '%s'", name, router.ClusterSeparator)
+	}
+	return nil
+}
+
+// NewClient returns a client of the stacks controller of cluster. Its
+// calls are not bounded, which is left to the router the cluster is
+// registered with.
+func NewClient(cluster Cluster) (*client.Client, error) {
+	target, err := url.Parse(cluster.URL)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s has an invalid URL: %s", cluster.Name, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("cluster %s has an unsupported URL scheme '%s'", cluster.Name, target.Scheme)
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if cluster.TLS != nil {
+		if target.Scheme != "https" {
+			return nil, fmt.Errorf("cluster %s configures TLS for a %s URL", cluster.Name, target.Scheme)
+		}
+		transport.TLSClientConfig, err = tlsconfig.Client(tlsconfig.Options{
+			CAFile:             cluster.TLS.CAFile,
+			CertFile:           cluster.TLS.CertFile,
+			KeyFile:            cluster.TLS.KeyFile,
+			InsecureSkipVerify: cluster.TLS.InsecureSkipVerify,
+			ExclusiveRootPools: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s has an invalid TLS configuration: %s", cluster.Name, err)
+		}
+	}
+
+	return client.NewClientWithSettings(client.Settings{
+		Scheme:   target.Scheme,
+		Host:     cluster.URL,
+		Proto:    "tcp",
+		Addr:     target.Host,
+		BasePath: target.Path,
+		Client: &http.Client{
+			Transport: transport,
+		},
+	})
+}
+
+// Load reads the configuration of a federation from a JSON file, and
+// returns a router.StacksRouter with the stacks controllers of its clusters
+// registered.
+func Load(path string) (*router.StacksRouter, error) {
+	clusters, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var optsFunc []router.StacksRouterOptionFunc
+	for _, cluster := range clusters {
+		if cluster.Default {
+			optsFunc = append(optsFunc, router.WithDefaultCluster(cluster.Name))
+		}
+	}
+	stacks := router.NewStacksRouter(optsFunc...)
+	for _, cluster := range clusters {
+		stackClient, err := NewClient(cluster)
+		if err != nil {
+			return nil, err
+		}
+		var backendOpts []router.BackendOptionFunc
+		if cluster.TimeoutSeconds > 0 {
+			backendOpts = append(backendOpts, router.WithBackendTimeout(time.Duration(cluster.TimeoutSeconds)*time.Second))
+		}
+		stacks.RegisterCluster(cluster.Name, stackClient, backendOpts...)
+	}
+	return stacks, nil
+}
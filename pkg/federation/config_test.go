@@ -0,0 +1,108 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/router"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func writeConfig(t *testing.T, dir, config string) string {
+	path := filepath.Join(dir, "federation.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(config), 0600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "federation")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	clusters, err := LoadConfig(writeConfig(t, dir, `[
+		{"Name": "east", "URL": "https://east.example.com:2376", "TLS": {"CAFile": "/etc/stacks/ca.pem"}, "Default": true},
+		{"Name": "west", "URL": "http://west.example.com:2375", "TimeoutSeconds": 5}
+	]`))
+	require.NoError(err)
+	require.Len(clusters, 2)
+	require.Equal("/etc/stacks/ca.pem", clusters[0].TLS.CAFile)
+	require.True(clusters[0].Default)
+	require.Equal(5, clusters[1].TimeoutSeconds)
+
+	for _, config := range []string{
+		`{}`,
+		`[]`,
+		`[{"URL": "http://east.example.com"}]`,
+		`[{"Name": "us:east", "URL": "http://east.example.com"}]`,
+		`[{"Name": "east", "URL": "http://east.example.com", "TimeoutSeconds": -1}]`,
+		`[{"Name": "east", "URL": "http://east.example.com"}, {"Name": "east", "URL": "http://west.example.com"}]`,
+		`[{"Name": "east", "URL": "http://east.example.com", "Default": true}, {"Name": "west", "URL": "http://west.example.com", "Default": true}]`,
+	} {
+		_, err := LoadConfig(writeConfig(t, dir, config))
+		require.Error(err, config)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	require := require.New(t)
+
+	for _, cluster := range []Cluster{
+		{Name: "east", URL: "unix:///var/run/stacks.sock"},
+		{Name: "east", URL: "http://east.example.com", TLS: &TLS{InsecureSkipVerify: true}},
+		{Name: "east", URL: "https://east.example.com", TLS: &TLS{CAFile: "/nonexistent/ca.pem"}},
+	} {
+		_, err := NewClient(cluster)
+		require.Error(err, cluster.URL)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/east/stacks":
+			assert.NoError(t, json.NewEncoder(w).Encode(types.StackList{Items: []types.Stack{{ID: "1"}}}))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			assert.NoError(t, json.NewEncoder(w).Encode(dockerTypes.ErrorResponse{Message: "stack not found"}))
+		}
+	}))
+	defer server.Close()
+
+	stackClient, err := NewClient(Cluster{Name: "east", URL: server.URL + "/east"})
+	require.NoError(err)
+	stacks := router.NewStacksRouter()
+	stacks.RegisterCluster("east", stackClient)
+
+	list, err := stacks.StackList(context.Background(), types.StackListOptions{})
+	require.NoError(err)
+	require.Len(list, 1)
+	require.Equal("east:1", list[0].ID)
+
+	_, err = stacks.StackInspect(context.Background(), "east:2")
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "federation")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	stacks, err := Load(writeConfig(t, dir, `[
+		{"Name": "east", "URL": "https://east.example.com:2376"},
+		{"Name": "west", "URL": "http://west.example.com:2375", "TimeoutSeconds": 5, "Default": true}
+	]`))
+	require.NoError(err)
+	require.Equal([]string{"east", "west"}, stacks.Clusters())
+}
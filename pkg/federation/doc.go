@@ -0,0 +1,12 @@
+package federation
+
+// The `federation` package serves the stacks of several clusters, each with
+// its own stacks controller, behind a single front door.
+//
+// Clusters are named in a configuration file, along with the URL of their
+// stacks controller and the TLS settings to reach it. The clients of these
+// controllers are registered as the backends of a router.StacksRouter: the
+// IDs of its stacks are prefixed with the name of their cluster, which is
+// also reported in the Cluster field of stacks, so that each request is
+// routed to the right controller. Listings fan out to every cluster in
+// parallel, and leave out the clusters which cannot be reached.
@@ -132,6 +132,99 @@ func (c *BackendAPIClientShim) UpdateStack(id string, spec types.StackSpec, vers
 	return err
 }
 
+// ScaleStack scales services of a stack. Only the scaled services are
+// reconciled, as nothing else in the stack changed.
+func (c *BackendAPIClientShim) ScaleStack(id string, replicas map[string]uint64) error {
+	err := c.StacksBackend.ScaleStack(id, replicas)
+	if err != nil {
+		return err
+	}
+
+	stack, err := c.StacksBackend.GetStack(id)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for name := range replicas {
+			logrus.Debugf("writing service update event")
+			serviceName := stack.Spec.ResourceName(name)
+			c.stackEvents <- events.Message{
+				Type:   events.ServiceEventType,
+				Action: "update",
+				Actor: events.Actor{
+					ID: serviceName,
+					Attributes: map[string]string{
+						"name": serviceName,
+					},
+				},
+			}
+			logrus.Debugf("wrote service update event")
+		}
+	}()
+
+	return nil
+}
+
+// PauseStack stops the reconciliation of a stack. The stack is reconciled
+// once more to report its drift.
+func (c *BackendAPIClientShim) PauseStack(id string) error {
+	if err := c.StacksBackend.PauseStack(id); err != nil {
+		return err
+	}
+	c.stackUpdated(id)
+	return nil
+}
+
+// ResumeStack resumes the reconciliation of a stack, which reverts its
+// drift.
+func (c *BackendAPIClientShim) ResumeStack(id string) error {
+	if err := c.StacksBackend.ResumeStack(id); err != nil {
+		return err
+	}
+	c.stackUpdated(id)
+	return nil
+}
+
+// SetMaintenance sets the cluster-wide maintenance switch. Every stack is
+// reconciled once the maintenance is over.
+func (c *BackendAPIClientShim) SetMaintenance(maintenance types.StackMaintenance) error {
+	previous, err := c.StacksBackend.Maintenance()
+	if err != nil {
+		return err
+	}
+	if err := c.StacksBackend.SetMaintenance(maintenance); err != nil {
+		return err
+	}
+	if !previous.Enabled || maintenance.Enabled {
+		return nil
+	}
+
+	stacks, err := c.StacksBackend.ListStacks(types.StackListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list stacks after maintenance: %s", err)
+	}
+	for _, stack := range stacks {
+		c.stackUpdated(stack.ID)
+	}
+	return nil
+}
+
+// stackUpdated writes a stack update event, which causes the stack to be
+// reconciled
+func (c *BackendAPIClientShim) stackUpdated(id string) {
+	go func() {
+		logrus.Debugf("writing stack update event")
+		c.stackEvents <- events.Message{
+			Type:   "stack",
+			Action: "update",
+			Actor: events.Actor{
+				ID: id,
+			},
+		}
+		logrus.Debugf("wrote stack update event")
+	}()
+}
+
 // DeleteStack deletes a stack.
 func (c *BackendAPIClientShim) DeleteStack(id string) error {
 	err := c.StacksBackend.DeleteStack(id)
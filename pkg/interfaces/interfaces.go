@@ -19,9 +19,16 @@ type StacksBackend interface {
 	CreateStack(spec types.StackSpec) (types.StackCreateResponse, error)
 	GetStack(id string) (types.Stack, error)
 	GetSnapshotStack(id string) (SnapshotStack, error)
-	ListStacks() ([]types.Stack, error)
+	ListStacks(options types.StackListOptions) ([]types.Stack, error)
 	UpdateStack(id string, spec types.StackSpec, version uint64) error
 	UpdateSnapshotStack(id string, spec SnapshotStack, version uint64) (SnapshotStack, error)
+	ScaleStack(id string, replicas map[string]uint64) error
+	ValidateStack(spec types.StackSpec) types.StackValidationResult
+	PolicyReport() (types.StackPolicyReport, error)
+	PauseStack(id string) error
+	ResumeStack(id string) error
+	Maintenance() (types.StackMaintenance, error)
+	SetMaintenance(maintenance types.StackMaintenance) error
 	DeleteStack(id string) error
 }
 
@@ -104,6 +111,9 @@ type BackendClient interface {
 // Controller.
 type StackStore interface {
 	AddStack(types.StackSpec) (string, error)
+	// AddStackWithOrchestrator stores a new stack along with the
+	// orchestrator it is deployed on, which is reported by the stack.
+	AddStackWithOrchestrator(types.StackSpec, types.OrchestratorChoice) (string, error)
 	UpdateStack(string, types.StackSpec, uint64) error
 	UpdateSnapshotStack(string, SnapshotStack, uint64) (SnapshotStack, error)
 
@@ -112,7 +122,12 @@ type StackStore interface {
 	GetStack(id string) (types.Stack, error)
 	GetSnapshotStack(id string) (SnapshotStack, error)
 
-	ListStacks() ([]types.Stack, error)
+	ListStacks(options types.StackListOptions) ([]types.Stack, error)
+
+	// GetMaintenance and UpdateMaintenance store the cluster-wide
+	// maintenance switch, which is disabled until it is first set.
+	GetMaintenance() (types.StackMaintenance, error)
+	UpdateMaintenance(types.StackMaintenance) error
 }
 
 // SnapshotStack - a stored version of a stack with types.StackSpec and ID's of created Resources
@@ -123,13 +138,104 @@ type SnapshotStack struct {
 	Networks    []SnapshotResource
 	Secrets     []SnapshotResource
 	Configs     []SnapshotResource
+
+	// Status is reported as the types.StackStatus of the stack.
+	Status types.StackStatus
+	// RolledBack is the types.StackSpec whose rolling update was rolled
+	// back. It is not rolled out again until the stack is updated.
+	RolledBack *types.StackSpec `json:",omitempty"`
+	// Rollout is the rolling update in progress.
+	Rollout *SnapshotRollout `json:",omitempty"`
+	// Deployments are the canary and blue/green deployments in progress.
+	Deployments []SnapshotDeployment `json:",omitempty"`
+	// Paused stacks are not reconciled.
+	Paused bool `json:",omitempty"`
+	// Versions are the versions of the secrets and configs of the stack.
+	Versions []SnapshotVersion `json:",omitempty"`
+	// Orchestrator is the orchestrator the stack is deployed on, as
+	// recorded when it was created.
+	Orchestrator types.OrchestratorChoice `json:",omitempty"`
+}
+
+// SnapshotVersion - the versions of a secret or config of a stack. Secrets
+// and configs cannot be updated, so a change of their data is rolled out as
+// a new version named after the resource and a hash of its data, which the
+// services reference in place of the previous version. Previous versions
+// are removed once no task uses them.
+type SnapshotVersion struct {
+	Kind ReconcileKind
+	// Name is the name of the resource, as specified
+	Name string
+	// Hash is the hash of the data of the current version
+	Hash string
+	// Current is the name of the current version, and Previous the names
+	// of the previous versions which are still in use
+	Current  string
+	Previous []string `json:",omitempty"`
+}
+
+// SnapshotRollout - the state of the rolling update of the services of a
+// stack, which is rolled out one batch of services at a time
+type SnapshotRollout struct {
+	// Batch is the number of batches rolled out so far
+	Batch int
+	// Monitored are the IDs of the services of the batch being monitored,
+	// whose tasks must become healthy between Started and Deadline
+	Monitored []string `json:",omitempty"`
+	Started   time.Time
+	Deadline  time.Time
+	// Updated are the services updated so far, which are reverted if the
+	// rollout is rolled back
+	Updated []SnapshotRolledOut `json:",omitempty"`
+}
+
+// SnapshotRolledOut - a service updated by a rollout, and the spec it is
+// reverted to if the rollout is rolled back
+type SnapshotRolledOut struct {
+	ID       string
+	Previous swarm.ServiceSpec
+}
+
+// SnapshotDeployment - the state of the canary or blue/green deployment of
+// a service of a stack
+type SnapshotDeployment struct {
+	// Service is the name of the deployed service
+	Service string
+	Mode    types.StackDeployMode
+	Step    DeploymentStep
+	// ID is the ID of the canary or green service
+	ID string
+	// Spec is the spec being deployed, and Previous the spec of the
+	// service before the deployment
+	Spec     swarm.ServiceSpec
+	Previous swarm.ServiceSpec
+	// Started is when the current Step started, and Deadline when its
+	// analysis window ends
+	Started  time.Time
+	Deadline time.Time
 }
 
+// DeploymentStep is a step of a SnapshotDeployment
+type DeploymentStep string
+
+const (
+	// DeploymentAnalyzing - the canary or green service is monitored
+	DeploymentAnalyzing DeploymentStep = "analyzing"
+	// DeploymentPromoting - the service is updated to the deployed spec
+	DeploymentPromoting DeploymentStep = "promoting"
+	// DeploymentSwitched - the green service receives the traffic of the
+	// service while it is updated
+	DeploymentSwitched DeploymentStep = "switched"
+)
+
 // SnapshotResource - identifying information of a created Resource
 type SnapshotResource struct {
 	ID string
 	swarm.Meta
 	Name string
+	// SpecName is the name of the resource in the types.StackSpec, which
+	// differs from Name when the stack namespaces its resources
+	SpecName string `json:",omitempty"`
 }
 
 // StackLabelArg constructs the filters.KeyValuePair for API usage
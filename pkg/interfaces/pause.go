@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"github.com/docker/docker/errdefs"
+)
+
+// pauseStackAttempts bounds the number of times SetStackPaused retries after
+// a concurrent update of the stack
+const pauseStackAttempts = 5
+
+// SetStackPaused pauses or resumes the reconciliation of a stack in the
+// store. Pausing does not depend on the rest of the stack, so concurrent
+// updates of the stack are not reported as conflicts.
+func SetStackPaused(store StackStore, id string, paused bool) error {
+	var err error
+	for attempt := 0; attempt < pauseStackAttempts; attempt++ {
+		var snapshot SnapshotStack
+		snapshot, err = store.GetSnapshotStack(id)
+		if err != nil {
+			return err
+		}
+		if snapshot.Paused == paused {
+			return nil
+		}
+
+		snapshot.Paused = paused
+		_, err = store.UpdateSnapshotStack(id, snapshot, snapshot.Meta.Version.Index)
+		if !errdefs.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
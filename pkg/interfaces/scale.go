@@ -0,0 +1,80 @@
+package interfaces
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// scaleStackAttempts bounds the number of times ScaleStackServices retries
+// after a concurrent update of the stack
+const scaleStackAttempts = 5
+
+// StackUpdater reads stacks and updates their spec, provided they are still
+// at version. It is implemented by StackStores, and by the StacksBackends
+// which check the updated specs before storing them.
+type StackUpdater interface {
+	GetStack(id string) (types.Stack, error)
+	UpdateStack(id string, spec types.StackSpec, version uint64) error
+}
+
+// ScaleStackServices sets the number of replicas of services of a stack
+// through store. The services are named as in the types.StackSpec. A change
+// in the number of replicas does not depend on the rest of the StackSpec, so
+// concurrent updates of the stack are not reported as conflicts: the
+// replicas are set again on the updated StackSpec instead.
+func ScaleStackServices(store StackUpdater, id string, replicas map[string]uint64) error {
+	var err error
+	for attempt := 0; attempt < scaleStackAttempts; attempt++ {
+		var stack types.Stack
+		stack, err = store.GetStack(id)
+		if err != nil {
+			return err
+		}
+
+		var spec types.StackSpec
+		spec, err = ScaleServices(stack.Spec, replicas)
+		if err != nil {
+			return err
+		}
+
+		err = store.UpdateStack(id, spec, stack.Version.Index)
+		if !errdefs.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// ScaleServices returns a copy of spec with the number of replicas of the
+// services set as requested. The original spec is left untouched.
+func ScaleServices(spec types.StackSpec, replicas map[string]uint64) (types.StackSpec, error) {
+	services := make([]swarm.ServiceSpec, len(spec.Services))
+	copy(services, spec.Services)
+
+	for name, count := range replicas {
+		found := false
+		for i, service := range services {
+			if service.Annotations.Name != name {
+				continue
+			}
+			if service.Mode.Replicated == nil {
+				return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("service %s of stack %s is not replicated", name, spec.Annotations.Name))
+			}
+			replicated := *service.Mode.Replicated
+			count := count
+			replicated.Replicas = &count
+			services[i].Mode.Replicated = &replicated
+			found = true
+		}
+		if !found {
+			return types.StackSpec{}, errdefs.NotFound(fmt.Errorf("service %s not found in stack %s", name, spec.Annotations.Name))
+		}
+	}
+
+	spec.Services = services
+	return spec, nil
+}
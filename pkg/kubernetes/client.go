@@ -0,0 +1,500 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	composev1alpha3 "github.com/docker/compose-on-kubernetes/api/compose/v1alpha3"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/patch"
+	"github.com/docker/stacks/pkg/policy"
+	"github.com/docker/stacks/pkg/query"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+	"github.com/docker/stacks/pkg/validation"
+)
+
+// scaleAttempts bounds the number of times scaling a stack is retried when
+// it conflicts with another update of the stack
+const scaleAttempts = 3
+
+// Client implements the client.StackAPIClient interface on Kubernetes,
+// through compose-on-kubernetes.
+type Client struct {
+	clientset Clientset
+	admission *admission.Chain
+	policy    *policy.Engine
+}
+
+// ClientOptionFunc is the type used for functional arguments of the Client
+// during its creation.
+type ClientOptionFunc func(*Client)
+
+// WithAdmissionChain is a ClientOptionFunc which submits the specs of
+// created and updated stacks to the webhooks of chain.
+func WithAdmissionChain(chain *admission.Chain) ClientOptionFunc {
+	return func(c *Client) {
+		c.admission = chain
+	}
+}
+
+// WithPolicy is a ClientOptionFunc which evaluates the rules of engine over
+// the specs of created and updated stacks.
+func WithPolicy(engine *policy.Engine) ClientOptionFunc {
+	return func(c *Client) {
+		c.policy = engine
+	}
+}
+
+// NewClient creates a new Client using clientset.
+func NewClient(clientset Clientset, optsFunc ...ClientOptionFunc) *Client {
+	c := &Client{
+		clientset: clientset,
+	}
+	for _, f := range optsFunc {
+		f(c)
+	}
+	return c
+}
+
+// StackCreate creates a stack in the namespace of its collection.
+func (c *Client) StackCreate(_ context.Context, spec types.StackSpec, _ types.StackCreateOptions) (types.StackCreateResponse, error) {
+	spec, err := secrets.RestoreNew(spec)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	spec, err = c.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionCreate,
+		Spec:      spec,
+	})
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	if err := c.validator().Error(spec); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	namespace := Namespace(spec.Collection)
+	id := stackID(namespace, spec.Annotations.Name)
+	stack, err := composeStack(namespace, spec, time.Now())
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	_, err = c.clientset.Stacks(namespace).Get(stack.Name, metav1.GetOptions{})
+	if err == nil {
+		return types.StackCreateResponse{}, errdefs.Conflict(fmt.Errorf("stack %s already exists", id))
+	}
+	if !apierrors.IsNotFound(err) {
+		return types.StackCreateResponse{}, convertError(err)
+	}
+
+	if err := c.syncResources(namespace, id, spec); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	if _, err := c.clientset.Stacks(namespace).Create(stack); err != nil {
+		return types.StackCreateResponse{}, convertError(err)
+	}
+	if err := c.storeSpec(namespace, id, spec); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	return types.StackCreateResponse{ID: id}, nil
+}
+
+// StackValidate validates a StackSpec, including the parts of it which
+// cannot be deployed on Kubernetes.
+func (c *Client) StackValidate(_ context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	return c.validator().Validate(spec), nil
+}
+
+// StackInspect returns a stack by its ID.
+func (c *Client) StackInspect(_ context.Context, id string) (types.Stack, error) {
+	stack, err := c.getStack(id)
+	if err != nil {
+		return types.Stack{}, err
+	}
+	return c.inspect(stack)
+}
+
+// StackSpecWithSecrets returns the StackSpec of a stack along with the data
+// of its secrets, which StackInspect redacts, so that the stack can be
+// migrated to another orchestrator.
+func (c *Client) StackSpecWithSecrets(ctx context.Context, id string) (types.StackSpec, error) {
+	stack, err := c.StackInspect(ctx, id)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+	namespace, _, _ := parseStackID(id)
+	stored, err := c.storedSecrets(namespace, stack.Spec)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+	return secrets.Restore(stack.Spec, stored)
+}
+
+// StackList lists the stacks of every namespace selected by options.
+func (c *Client) StackList(_ context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	list, err := c.clientset.Stacks(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	specs, err := c.clientset.ConfigMaps(metav1.NamespaceAll).List(metav1.ListOptions{LabelSelector: specLabel})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	specsByID := map[string]*corev1.ConfigMap{}
+	for i, configMap := range specs.Items {
+		specsByID[configMap.Labels[specLabel]] = &specs.Items[i]
+	}
+	stacks := make([]types.Stack, 0, len(list.Items))
+	for i, stack := range list.Items {
+		stacks = append(stacks, fromComposeStack(&list.Items[i], specsByID[stackID(stack.Namespace, stack.Name)]))
+	}
+	return query.Stacks(stacks, options)
+}
+
+// StackUpdate updates a stack at version. The name and collection of a
+// stack cannot change, as they determine its ID.
+func (c *Client) StackUpdate(_ context.Context, id string, version types.Version, spec types.StackSpec, _ types.StackUpdateOptions) error {
+	current, err := c.getStack(id)
+	if err != nil {
+		return err
+	}
+	namespace := current.Namespace
+	if spec.Annotations.Name != current.Name || Namespace(spec.Collection) != namespace {
+		return errdefs.InvalidParameter(fmt.Errorf("the name and collection of stack %s cannot change", id))
+	}
+	old, err := c.inspect(current)
+	if err != nil {
+		return err
+	}
+	if version.Index != old.Version.Index {
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: old.Version.Index},
+		}
+	}
+
+	stored, err := c.storedSecrets(namespace, spec)
+	if err != nil {
+		return err
+	}
+	if spec, err = secrets.Restore(spec, stored); err != nil {
+		return err
+	}
+	spec, err = c.admit(types.StackAdmissionRequest{
+		Operation: types.StackAdmissionUpdate,
+		ID:        id,
+		Spec:      spec,
+		OldSpec:   &old.Spec,
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.validator().Error(spec); err != nil {
+		return err
+	}
+
+	stack, err := composeStack(namespace, spec, time.Now())
+	if err != nil {
+		return err
+	}
+	stack.ResourceVersion = current.ResourceVersion
+	stack.Status = current.Status
+	if _, err := c.clientset.Stacks(namespace).Update(stack); err != nil {
+		if apierrors.IsConflict(err) {
+			return c.conflict(id)
+		}
+		return convertError(err)
+	}
+	// The resources of the stack only change once the Stack did, which
+	// they are then synced with by the next update if this fails
+	if err := c.syncResources(namespace, id, spec); err != nil {
+		return err
+	}
+	return c.storeSpec(namespace, id, spec)
+}
+
+// StackScale sets the number of replicas of services of a stack.
+func (c *Client) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+	var err error
+	for attempt := 0; attempt < scaleAttempts; attempt++ {
+		var stack types.Stack
+		stack, err = c.StackInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		var spec types.StackSpec
+		spec, err = interfaces.ScaleServices(stack.Spec, replicas)
+		if err != nil {
+			return err
+		}
+
+		err = c.StackUpdate(ctx, id, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+		if !errdefs.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// StackPause is not supported on Kubernetes, where compose-on-kubernetes
+// reconciles stacks.
+func (c *Client) StackPause(_ context.Context, id string) error {
+	return errdefs.NotImplemented(fmt.Errorf("stack %s is deployed on kubernetes, which cannot pause its reconciliation", id))
+}
+
+// StackResume is not supported on Kubernetes, where compose-on-kubernetes
+// reconciles stacks.
+func (c *Client) StackResume(_ context.Context, id string) error {
+	return errdefs.NotImplemented(fmt.Errorf("stack %s is deployed on kubernetes, which cannot pause its reconciliation", id))
+}
+
+// StackPatch applies a patch to the spec of a stack, at version unless
+// version is the zero Version.
+func (c *Client) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, data []byte, options types.StackUpdateOptions) error {
+	stack, err := c.StackInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version.Index != 0 && version.Index != stack.Version.Index {
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: stack.Version.Index},
+		}
+	}
+
+	spec, err := patch.Apply(stack.Spec, patchType, data)
+	if err != nil {
+		return err
+	}
+	return c.StackUpdate(ctx, id, types.Version{Index: stack.Version.Index}, spec, options)
+}
+
+// StackDelete deletes a stack, along with the Secrets and ConfigMaps of its
+// secrets and configs. Deleting a stack which does not exist succeeds.
+func (c *Client) StackDelete(_ context.Context, id string) error {
+	namespace, name, ok := parseStackID(id)
+	if !ok {
+		return nil
+	}
+	err := c.clientset.Stacks(namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return convertError(err)
+	}
+	err = c.clientset.ConfigMaps(namespace).Delete(specConfigMapName(name), &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return convertError(err)
+	}
+	return c.syncResources(namespace, id, types.StackSpec{})
+}
+
+// StackMigrate is not implemented, see types.ErrStackNotMigratable.
+func (c *Client) StackMigrate(_ context.Context, id string, _ types.StackMigrateOptions) (types.StackMigration, error) {
+	return types.StackMigration{}, types.ErrStackNotMigratable(id)
+}
+
+// StackTasks returns the tasks of a stack, which are the pods of its
+// services.
+func (c *Client) StackTasks(_ context.Context, id string) ([]types.StackTask, error) {
+	stack, err := c.getStack(id)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.clientset.Pods(stack.Namespace).List(metav1.ListOptions{
+		LabelSelector: stackNamespaceLabel + "=" + stack.Name,
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	tasks := make([]types.StackTask, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		tasks = append(tasks, fromPod(pod))
+	}
+	return tasks, nil
+}
+
+// getStack returns the compose-on-kubernetes Stack of id
+func (c *Client) getStack(id string) (*composev1alpha3.Stack, error) {
+	namespace, name, ok := parseStackID(id)
+	if !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	stack, err := c.clientset.Stacks(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return stack, nil
+}
+
+// inspect returns the types.Stack of stack, with the spec stored along
+// with it.
+func (c *Client) inspect(stack *composev1alpha3.Stack) (types.Stack, error) {
+	configMap, err := c.clientset.ConfigMaps(stack.Namespace).Get(specConfigMapName(stack.Name), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return types.Stack{}, convertError(err)
+		}
+		configMap = nil
+	}
+	return fromComposeStack(stack, configMap), nil
+}
+
+// conflict returns the types.StackVersionConflict of an update of the stack
+// id which lost a race with another update
+func (c *Client) conflict(id string) error {
+	stack, err := c.StackInspect(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return types.StackVersionConflict{
+		ID:      id,
+		Current: types.Version{Index: stack.Version.Index},
+	}
+}
+
+// admit submits request to the admission webhooks of the client, if any.
+func (c *Client) admit(request types.StackAdmissionRequest) (types.StackSpec, error) {
+	if c.admission == nil {
+		return request.Spec, nil
+	}
+	return c.admission.Admit(request)
+}
+
+// validator checks StackSpecs against what Kubernetes can deploy and the
+// policy rules of the client, if any.
+func (c *Client) validator() *validation.Validator {
+	if c.policy == nil {
+		return validation.NewValidator(nil, validation.WithChecker(checker{}))
+	}
+	return validation.NewValidator(nil, validation.WithChecker(checker{}), validation.WithChecker(c.policy))
+}
+
+// storeSpec creates or updates the ConfigMap holding the redacted spec of
+// the stack id. It is written once the Stack is, so that the spec of a
+// stack is never one whose update failed.
+func (c *Client) storeSpec(namespace, id string, spec types.StackSpec) error {
+	object, err := specConfigMap(namespace, id, spec)
+	if err != nil {
+		return err
+	}
+	configMapsAPI := c.clientset.ConfigMaps(namespace)
+	_, err = configMapsAPI.Create(object)
+	if apierrors.IsAlreadyExists(err) {
+		_, err = configMapsAPI.Update(object)
+	}
+	return convertError(err)
+}
+
+// storedSecrets returns a StackSpec holding the stored data of the secrets
+// of spec redacted by the Stacks API
+func (c *Client) storedSecrets(namespace string, spec types.StackSpec) (types.StackSpec, error) {
+	stored := types.StackSpec{}
+	for _, secret := range spec.Secrets {
+		if _, ok := secret.Annotations.Labels[types.StackSecretHashLabel]; !ok || len(secret.Data) > 0 {
+			continue
+		}
+		name := secret.Annotations.Name
+		object, err := c.clientset.Secrets(namespace).Get(spec.ResourceName(name), metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return types.StackSpec{}, convertError(err)
+		}
+		stored.Secrets = append(stored.Secrets, swarm.SecretSpec{
+			Annotations: swarm.Annotations{Name: name},
+			Data:        object.Data[name],
+		})
+	}
+	return stored, nil
+}
+
+// syncResources creates or updates the Secrets and ConfigMaps of the
+// secrets and configs of spec, and deletes those of the stack id which are
+// no longer part of spec. Secrets without data are expected to exist
+// already, and are left alone.
+// nolint: gocyclo
+func (c *Client) syncResources(namespace, id string, spec types.StackSpec) error {
+	selector := metav1.ListOptions{LabelSelector: types.StackLabel + "=" + id}
+	secretsAPI := c.clientset.Secrets(namespace)
+	configMapsAPI := c.clientset.ConfigMaps(namespace)
+
+	wanted := map[string]bool{}
+	for _, secret := range spec.Secrets {
+		if len(secret.Data) == 0 {
+			continue
+		}
+		object := secretObject(namespace, id, spec, secret)
+		wanted[object.Name] = true
+		_, err := secretsAPI.Create(object)
+		if apierrors.IsAlreadyExists(err) {
+			_, err = secretsAPI.Update(object)
+		}
+		if err != nil {
+			return convertError(err)
+		}
+	}
+	existingSecrets, err := secretsAPI.List(selector)
+	if err != nil {
+		return convertError(err)
+	}
+	for _, object := range existingSecrets.Items {
+		if wanted[object.Name] {
+			continue
+		}
+		if err := secretsAPI.Delete(object.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return convertError(err)
+		}
+	}
+
+	wanted = map[string]bool{}
+	for _, config := range spec.Configs {
+		object := configMapObject(namespace, id, spec, config)
+		wanted[object.Name] = true
+		_, err := configMapsAPI.Create(object)
+		if apierrors.IsAlreadyExists(err) {
+			_, err = configMapsAPI.Update(object)
+		}
+		if err != nil {
+			return convertError(err)
+		}
+	}
+	existingConfigMaps, err := configMapsAPI.List(selector)
+	if err != nil {
+		return convertError(err)
+	}
+	for _, object := range existingConfigMaps.Items {
+		if wanted[object.Name] {
+			continue
+		}
+		if err := configMapsAPI.Delete(object.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return convertError(err)
+		}
+	}
+	return nil
+}
+
+// convertError turns the errors of the Kubernetes API into errdefs errors
+func convertError(err error) error {
+	switch {
+	case apierrors.IsNotFound(err):
+		return errdefs.NotFound(err)
+	case apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		return errdefs.Conflict(err)
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return errdefs.InvalidParameter(err)
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return errdefs.Forbidden(err)
+	}
+	return err
+}
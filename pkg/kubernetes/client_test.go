@@ -0,0 +1,322 @@
+package kubernetes_test
+
+import (
+	"context"
+	"testing"
+
+	composev1alpha3 "github.com/docker/compose-on-kubernetes/api/compose/v1alpha3"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/docker/stacks/pkg/kubernetes"
+	"github.com/docker/stacks/pkg/kubernetes/fake"
+	"github.com/docker/stacks/pkg/policy"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestSpec() types.StackSpec {
+	replicas := uint64(2)
+	return types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Collection:  "Team A",
+		Services: []swarm.ServiceSpec{{
+			Annotations: swarm.Annotations{Name: "web"},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image: "nginx:1.16",
+					Secrets: []*swarm.SecretReference{{
+						File:       &swarm.SecretReferenceFileTarget{Name: "password"},
+						SecretName: "password",
+					}},
+				},
+			},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		}},
+		Secrets: []swarm.SecretSpec{{
+			Annotations: swarm.Annotations{Name: "password"},
+			Data:        []byte("hunter2"),
+		}},
+		Configs: []swarm.ConfigSpec{{
+			Annotations: swarm.Annotations{Name: "nginx.conf"},
+			Data:        []byte("worker_processes 1;"),
+		}},
+	}
+}
+
+func TestStackCreateInspect(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	cli := kubernetes.NewClient(clientset)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	require.Equal("team-a.app", resp.ID)
+
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(resp.ID, stack.ID)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), stack.Orchestrator)
+	require.Equal(secrets.Redact(getTestSpec()), stack.Spec)
+	require.NotZero(stack.Version.Index)
+
+	composeStack, err := clientset.Stacks("team-a").Get("app", metav1.GetOptions{})
+	require.NoError(err)
+	require.Len(composeStack.Spec.Services, 1)
+	require.Equal("nginx:1.16", composeStack.Spec.Services[0].Image)
+	require.Equal(uint64(2), *composeStack.Spec.Services[0].Deploy.Replicas)
+	require.True(composeStack.Spec.Secrets["password"].External.External)
+
+	secret, err := clientset.Secrets("team-a").Get("password", metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal([]byte("hunter2"), secret.Data["password"])
+	configMap, err := clientset.ConfigMaps("team-a").Get("nginx.conf", metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal("worker_processes 1;", configMap.Data["nginx.conf"])
+
+	// The spec is kept in a ConfigMap rather than in the Stack
+	require.NotContains(composeStack.Annotations, "com.docker.stacks.spec")
+	specConfigMap, err := clientset.ConfigMaps("team-a").Get("app.stack-spec", metav1.GetOptions{})
+	require.NoError(err)
+	require.NotContains(specConfigMap.Data["spec.json"], "hunter2")
+
+	_, err = cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.True(errdefs.IsConflict(err))
+
+	_, err = cli.StackInspect(ctx, "swarmid")
+	require.True(errdefs.IsNotFound(err))
+	_, err = cli.StackInspect(ctx, "team-a.other")
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestStackUpdate(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	cli := kubernetes.NewClient(clientset)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+
+	// The redacted spec keeps the data of the secrets
+	spec := stack.Spec
+	spec.Configs = nil
+	require.NoError(cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{}))
+	updated, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Nil(updated.Spec.Configs)
+	require.Equal(stack.CreatedAt, updated.CreatedAt)
+	require.True(updated.UpdatedAt.After(stack.UpdatedAt))
+	secret, err := clientset.Secrets("team-a").Get("password", metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal([]byte("hunter2"), secret.Data["password"])
+	_, err = clientset.ConfigMaps("team-a").Get("nginx.conf", metav1.GetOptions{})
+	require.Error(err)
+
+	err = cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+	require.IsType(types.StackVersionConflict{}, err)
+
+	stack, err = cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	spec.Collection = "other"
+	err = cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	require.NoError(cli.StackScale(ctx, resp.ID, map[string]uint64{"web": 5}))
+	composeStack, err := clientset.Stacks("team-a").Get("app", metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal(uint64(5), *composeStack.Spec.Services[0].Deploy.Replicas)
+
+	patch := []byte(`{"Services": {"web": {"TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.17"}}}}}`)
+	require.NoError(cli.StackPatch(ctx, resp.ID, types.Version{}, types.StackPatchMerge, patch, types.StackUpdateOptions{}))
+	composeStack, err = clientset.Stacks("team-a").Get("app", metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal("nginx:1.17", composeStack.Spec.Services[0].Image)
+
+	require.True(errdefs.IsNotImplemented(cli.StackPause(ctx, resp.ID)))
+}
+
+// conflictingClientset is a Clientset whose Stacks conflict with another
+// update whenever they are updated
+type conflictingClientset struct {
+	*fake.Clientset
+}
+
+func (c conflictingClientset) Stacks(namespace string) kubernetes.StackInterface {
+	return conflictingStacks{c.Clientset.Stacks(namespace)}
+}
+
+type conflictingStacks struct {
+	kubernetes.StackInterface
+}
+
+func (s conflictingStacks) Update(stack *composev1alpha3.Stack) (*composev1alpha3.Stack, error) {
+	return nil, apierrors.NewConflict(composev1alpha3.SchemeGroupVersion.WithResource("stacks").GroupResource(), stack.Name, nil)
+}
+
+func TestStackUpdateConflict(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	resp, err := kubernetes.NewClient(clientset).StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+
+	// The resources of a stack are left alone when the Stack fails to
+	// update
+	cli := kubernetes.NewClient(conflictingClientset{clientset})
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	spec := getTestSpec()
+	spec.Secrets[0].Data = []byte("hunter3")
+	err = cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+	require.IsType(types.StackVersionConflict{}, err)
+
+	secret, err := clientset.Secrets("team-a").Get(getTestSpec().ResourceName("password"), metav1.GetOptions{})
+	require.NoError(err)
+	require.Equal([]byte("hunter2"), secret.Data["password"])
+}
+
+func TestStackValidate(t *testing.T) {
+	require := require.New(t)
+	cli := kubernetes.NewClient(fake.NewClientset())
+
+	spec := getTestSpec()
+	spec.Services[0].TaskTemplate.ContainerSpec.User = "nginx"
+	result, err := cli.StackValidate(context.Background(), spec)
+	require.NoError(err)
+	require.False(result.Valid)
+	require.Equal("Services[0].TaskTemplate.ContainerSpec.User", result.Errors[0].Field)
+
+	_, err = cli.StackCreate(context.Background(), spec, types.StackCreateOptions{})
+	require.IsType(types.StackValidationError{}, err)
+
+	// The ConfigMap of the spec of the stack cannot be a config
+	spec = getTestSpec()
+	spec.Configs[0].Annotations.Name = "app.stack-spec"
+	result, err = cli.StackValidate(context.Background(), spec)
+	require.NoError(err)
+	require.False(result.Valid)
+	require.Equal("Configs[0].Name", result.Errors[0].Field)
+}
+
+func TestStackPolicy(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	engine, err := policy.NewEngine([]policy.Rule{{
+		Name:     "team-label",
+		Path:     "Annotations.Labels[team]",
+		Required: true,
+	}})
+	require.NoError(err)
+	cli := kubernetes.NewClient(fake.NewClientset(), kubernetes.WithPolicy(engine))
+
+	_, err = cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.IsType(types.StackValidationError{}, err)
+
+	spec := getTestSpec()
+	spec.Annotations.Labels = map[string]string{"team": "platform"}
+	resp, err := cli.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.NoError(err)
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+
+	// Updates, and so patches and scales, are checked as well
+	spec.Annotations.Labels = nil
+	err = cli.StackUpdate(ctx, resp.ID, types.Version{Index: stack.Version.Index}, spec, types.StackUpdateOptions{})
+	require.IsType(types.StackValidationError{}, err)
+}
+
+func TestStackListDelete(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	cli := kubernetes.NewClient(clientset)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	other := getTestSpec()
+	other.Annotations.Name = "other"
+	other.Collection = ""
+	other.Secrets, other.Configs = nil, nil
+	other.Services[0].TaskTemplate.ContainerSpec.Secrets = nil
+	otherResp, err := cli.StackCreate(ctx, other, types.StackCreateOptions{})
+	require.NoError(err)
+	require.Equal("default.other", otherResp.ID)
+
+	stacks, err := cli.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 2)
+
+	stacks, err = cli.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", "other")),
+	})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal(otherResp.ID, stacks[0].ID)
+
+	require.NoError(cli.StackDelete(ctx, resp.ID))
+	_, err = cli.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+	secretList, err := clientset.Secrets("team-a").List(metav1.ListOptions{})
+	require.NoError(err)
+	require.Empty(secretList.Items)
+	configMapList, err := clientset.ConfigMaps("team-a").List(metav1.ListOptions{})
+	require.NoError(err)
+	require.Empty(configMapList.Items)
+
+	// Deleting is idempotent, and ignores the stacks of other orchestrators
+	require.NoError(cli.StackDelete(ctx, resp.ID))
+	require.NoError(cli.StackDelete(ctx, "swarmid"))
+}
+
+func TestStackStatusTasks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	clientset := fake.NewClientset()
+	cli := kubernetes.NewClient(clientset)
+
+	resp, err := cli.StackCreate(ctx, getTestSpec(), types.StackCreateOptions{})
+	require.NoError(err)
+	require.NoError(clientset.SetStackStatus("team-a", "app", composev1alpha3.StackStatus{
+		Phase:   composev1alpha3.StackAvailable,
+		Message: "Stack is started",
+	}))
+	require.NoError(clientset.AddPod(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-1234",
+			Namespace: "team-a",
+			Labels: map[string]string{
+				"com.docker.stack.namespace": "app",
+				"com.docker.service.name":    "web",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Image: "nginx:1.16"}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}))
+
+	stack, err := cli.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.StackPhase("Available"), stack.Status.Phase)
+	require.Equal("Stack is started", stack.Status.Message)
+
+	tasks, err := cli.StackTasks(ctx, resp.ID)
+	require.NoError(err)
+	require.Len(tasks, 1)
+	require.Equal("web-1234", tasks[0].Name)
+	require.Equal("nginx:1.16", tasks[0].Image)
+	require.Equal("node-1", tasks[0].NodeID)
+	require.Equal("running", tasks[0].CurrentState)
+}
@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	composeclientset "github.com/docker/compose-on-kubernetes/api/client/clientset"
+	composev1alpha3 "github.com/docker/compose-on-kubernetes/api/compose/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Clientset is the subset of the Kubernetes and compose-on-kubernetes APIs
+// used by a Client.
+type Clientset interface {
+	Stacks(namespace string) StackInterface
+	Secrets(namespace string) SecretInterface
+	ConfigMaps(namespace string) ConfigMapInterface
+	Pods(namespace string) PodInterface
+}
+
+// StackInterface manages the compose-on-kubernetes Stacks of a namespace.
+type StackInterface interface {
+	Create(*composev1alpha3.Stack) (*composev1alpha3.Stack, error)
+	Update(*composev1alpha3.Stack) (*composev1alpha3.Stack, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*composev1alpha3.Stack, error)
+	List(options metav1.ListOptions) (*composev1alpha3.StackList, error)
+}
+
+// SecretInterface manages the Secrets of a namespace.
+type SecretInterface interface {
+	Create(*corev1.Secret) (*corev1.Secret, error)
+	Update(*corev1.Secret) (*corev1.Secret, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*corev1.Secret, error)
+	List(options metav1.ListOptions) (*corev1.SecretList, error)
+}
+
+// ConfigMapInterface manages the ConfigMaps of a namespace.
+type ConfigMapInterface interface {
+	Create(*corev1.ConfigMap) (*corev1.ConfigMap, error)
+	Update(*corev1.ConfigMap) (*corev1.ConfigMap, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Get(name string, options metav1.GetOptions) (*corev1.ConfigMap, error)
+	List(options metav1.ListOptions) (*corev1.ConfigMapList, error)
+}
+
+// PodInterface lists the Pods of a namespace.
+type PodInterface interface {
+	List(options metav1.ListOptions) (*corev1.PodList, error)
+}
+
+// clientset implements Clientset with the clients of a live cluster.
+type clientset struct {
+	compose composeclientset.Interface
+	core    corev1client.CoreV1Interface
+}
+
+// NewClientset creates a Clientset for the cluster configured by config.
+func NewClientset(config *rest.Config) (Clientset, error) {
+	compose, err := composeclientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	core, err := corev1client.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &clientset{
+		compose: compose,
+		core:    core,
+	}, nil
+}
+
+func (c *clientset) Stacks(namespace string) StackInterface {
+	return c.compose.ComposeV1alpha3().Stacks(namespace)
+}
+
+func (c *clientset) Secrets(namespace string) SecretInterface {
+	return c.core.Secrets(namespace)
+}
+
+func (c *clientset) ConfigMaps(namespace string) ConfigMapInterface {
+	return c.core.ConfigMaps(namespace)
+}
+
+func (c *clientset) Pods(namespace string) PodInterface {
+	return c.core.Pods(namespace)
+}
@@ -0,0 +1,482 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	composev1alpha3 "github.com/docker/compose-on-kubernetes/api/compose/v1alpha3"
+	"github.com/docker/docker/api/types/swarm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// specLabel labels the ConfigMap holding the redacted types.StackSpec
+	// of a compose-on-kubernetes Stack with the ID of the stack. It is not
+	// labelled with types.StackLabel, which selects the ConfigMaps of the
+	// configs of the stack.
+	specLabel = "com.docker.stacks.spec"
+	// specConfigMapSuffix follows the name of a stack in the name of the
+	// ConfigMap of its spec, and specKey holds the JSON encoded spec.
+	specConfigMapSuffix = ".stack-spec"
+	specKey             = "spec.json"
+
+	// updatedAtAnnotation is the annotation of a compose-on-kubernetes
+	// Stack holding the time the Stacks API last updated it
+	updatedAtAnnotation = "com.docker.stacks.updated-at"
+
+	// stackNamespaceLabel and serviceNameLabel are the labels given by
+	// compose-on-kubernetes to the pods of the services of a Stack
+	stackNamespaceLabel = "com.docker.stack.namespace"
+	serviceNameLabel    = "com.docker.service.name"
+
+	// defaultNamespace holds the stacks without a collection
+	defaultNamespace = "default"
+
+	// maxNamespaceLength is the length limit of the DNS labels Kubernetes
+	// namespaces are named with
+	maxNamespaceLength = 63
+)
+
+var invalidNamespaceChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// Namespace returns the Kubernetes namespace of the stacks of collection.
+func Namespace(collection string) string {
+	namespace := invalidNamespaceChars.ReplaceAllString(strings.ToLower(collection), "-")
+	if len(namespace) > maxNamespaceLength {
+		namespace = namespace[:maxNamespaceLength]
+	}
+	namespace = strings.Trim(namespace, "-")
+	if namespace == "" {
+		return defaultNamespace
+	}
+	return namespace
+}
+
+// stackID returns the ID of the stack name in namespace. Namespaces cannot
+// contain dots, which separates them from the name.
+func stackID(namespace, name string) string {
+	return namespace + "." + name
+}
+
+// parseStackID returns the namespace and name of the stack id, if id is the
+// ID of a Kubernetes stack.
+func parseStackID(id string) (string, string, bool) {
+	parts := strings.SplitN(id, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// checker reports the parts of StackSpecs which cannot be converted for
+// compose-on-kubernetes. It implements validation.Checker.
+type checker struct{}
+
+// Check returns the issues converting spec.
+func (checker) Check(spec types.StackSpec) types.StackValidationResult {
+	c := &converter{}
+	c.stackSpec(spec)
+	return types.StackValidationResult{
+		Valid:  len(c.issues) == 0,
+		Errors: c.issues,
+	}
+}
+
+// converter converts StackSpecs into compose-on-kubernetes StackSpecs, and
+// collects the issues found on the way.
+type converter struct {
+	issues []types.StackValidationIssue
+}
+
+func (c *converter) errorf(field, format string, args ...interface{}) {
+	c.issues = append(c.issues, types.StackValidationIssue{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// stackSpec converts spec. Its secrets and configs are referenced as
+// external resources, named as by spec.ResourceName.
+func (c *converter) stackSpec(spec types.StackSpec) *composev1alpha3.StackSpec {
+	stackSpec := &composev1alpha3.StackSpec{
+		Secrets: map[string]composev1alpha3.SecretConfig{},
+		Configs: map[string]composev1alpha3.ConfigObjConfig{},
+	}
+	for i, secret := range spec.Secrets {
+		if secret.Templating != nil {
+			c.errorf(fmt.Sprintf("Secrets[%d].Templating", i), "templating is not supported on kubernetes")
+		}
+		stackSpec.Secrets[secret.Annotations.Name] = composev1alpha3.SecretConfig{
+			Name:     spec.ResourceName(secret.Annotations.Name),
+			External: composev1alpha3.External{External: true},
+			Labels:   secret.Annotations.Labels,
+		}
+	}
+	for i, config := range spec.Configs {
+		if config.Templating != nil {
+			c.errorf(fmt.Sprintf("Configs[%d].Templating", i), "templating is not supported on kubernetes")
+		}
+		if name := spec.ResourceName(config.Annotations.Name); name == specConfigMapName(spec.Annotations.Name) {
+			c.errorf(fmt.Sprintf("Configs[%d].Name", i), "the name %s is reserved for the spec of the stack", name)
+		}
+		stackSpec.Configs[config.Annotations.Name] = composev1alpha3.ConfigObjConfig{
+			Name:     spec.ResourceName(config.Annotations.Name),
+			External: composev1alpha3.External{External: true},
+			Labels:   config.Annotations.Labels,
+		}
+	}
+	for i, service := range spec.Services {
+		stackSpec.Services = append(stackSpec.Services, c.service(fmt.Sprintf("Services[%d]", i), service))
+	}
+	return stackSpec
+}
+
+// service converts the spec of a service
+// nolint: gocyclo
+func (c *converter) service(field string, spec swarm.ServiceSpec) composev1alpha3.ServiceConfig {
+	service := composev1alpha3.ServiceConfig{
+		Name: spec.Annotations.Name,
+		Deploy: composev1alpha3.DeployConfig{
+			Mode:   "replicated",
+			Labels: spec.Annotations.Labels,
+		},
+	}
+
+	switch {
+	case spec.Mode.Global != nil:
+		service.Deploy.Mode = "global"
+	case spec.Mode.Replicated != nil:
+		service.Deploy.Replicas = spec.Mode.Replicated.Replicas
+	}
+	if spec.UpdateConfig != nil {
+		parallelism := spec.UpdateConfig.Parallelism
+		service.Deploy.UpdateConfig = &composev1alpha3.UpdateConfig{Parallelism: &parallelism}
+	}
+	if policy := spec.TaskTemplate.RestartPolicy; policy != nil && policy.Condition != "" {
+		service.Deploy.RestartPolicy = &composev1alpha3.RestartPolicy{Condition: string(policy.Condition)}
+	}
+	if resources := spec.TaskTemplate.Resources; resources != nil {
+		service.Deploy.Resources = composev1alpha3.Resources{
+			Limits:       resource(resources.Limits),
+			Reservations: resource(resources.Reservations),
+		}
+	}
+	if placement := spec.TaskTemplate.Placement; placement != nil && len(placement.Constraints) > 0 {
+		service.Deploy.Placement.Constraints = c.constraints(field+".TaskTemplate.Placement.Constraints", placement.Constraints)
+	}
+
+	if endpoint := spec.EndpointSpec; endpoint != nil {
+		for _, port := range endpoint.Ports {
+			service.Ports = append(service.Ports, composev1alpha3.ServicePortConfig{
+				Mode:      string(port.PublishMode),
+				Target:    port.TargetPort,
+				Published: port.PublishedPort,
+				Protocol:  string(port.Protocol),
+			})
+		}
+	}
+
+	container := spec.TaskTemplate.ContainerSpec
+	if container == nil {
+		c.errorf(field+".TaskTemplate.ContainerSpec", "only container services are supported on kubernetes")
+		return service
+	}
+	field += ".TaskTemplate.ContainerSpec"
+	service.Image = container.Image
+	service.Entrypoint = container.Command
+	service.Command = container.Args
+	service.Labels = container.Labels
+	service.Hostname = container.Hostname
+	service.WorkingDir = container.Dir
+	service.Tty = container.TTY
+	service.StdinOpen = container.OpenStdin
+	service.ReadOnly = container.ReadOnly
+	service.StopGracePeriod = container.StopGracePeriod
+
+	if len(container.Env) > 0 {
+		service.Environment = map[string]*string{}
+		for _, variable := range container.Env {
+			parts := strings.SplitN(variable, "=", 2)
+			if len(parts) == 1 {
+				service.Environment[parts[0]] = nil
+			} else {
+				value := parts[1]
+				service.Environment[parts[0]] = &value
+			}
+		}
+	}
+	if container.User != "" {
+		// Only numeric users can be set on pods
+		user, err := strconv.ParseInt(strings.SplitN(container.User, ":", 2)[0], 10, 64)
+		if err != nil {
+			c.errorf(field+".User", "user '%s' is not numeric, which kubernetes requires", container.User)
+		} else {
+			service.User = &user
+		}
+	}
+	for i, host := range container.Hosts {
+		// Swarm hosts are "IP hostname", compose ones "hostname:IP"
+		parts := strings.Fields(host)
+		if len(parts) < 2 {
+			c.errorf(fmt.Sprintf("%s.Hosts[%d]", field, i), "invalid host entry '%s'", host)
+			continue
+		}
+		service.ExtraHosts = append(service.ExtraHosts, parts[1]+":"+parts[0])
+	}
+	if health := container.Healthcheck; health != nil {
+		service.HealthCheck = &composev1alpha3.HealthCheckConfig{
+			Test:     health.Test,
+			Timeout:  duration(health.Timeout),
+			Interval: duration(health.Interval),
+		}
+		if health.Retries > 0 {
+			retries := uint64(health.Retries)
+			service.HealthCheck.Retries = &retries
+		}
+	}
+	for _, m := range container.Mounts {
+		service.Volumes = append(service.Volumes, composev1alpha3.ServiceVolumeConfig{
+			Type:     string(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	for i, reference := range container.Secrets {
+		if reference.File == nil {
+			c.errorf(fmt.Sprintf("%s.Secrets[%d].File", field, i), "secret is not mounted as a file")
+			continue
+		}
+		service.Secrets = append(service.Secrets, composev1alpha3.ServiceSecretConfig(
+			fileReference(reference.SecretName, reference.File.Name, reference.File.UID, reference.File.GID, uint32(reference.File.Mode))))
+	}
+	for i, reference := range container.Configs {
+		if reference.File == nil {
+			c.errorf(fmt.Sprintf("%s.Configs[%d].File", field, i), "config is not mounted as a file")
+			continue
+		}
+		service.Configs = append(service.Configs, composev1alpha3.ServiceConfigObjConfig(
+			fileReference(reference.ConfigName, reference.File.Name, reference.File.UID, reference.File.GID, uint32(reference.File.Mode))))
+	}
+	return service
+}
+
+// constraints converts the placement constraints of a service, of which
+// compose-on-kubernetes supports those on the hostname, platform and labels
+// of nodes
+func (c *converter) constraints(field string, constraints []string) *composev1alpha3.Constraints {
+	result := &composev1alpha3.Constraints{}
+	for i, constraint := range constraints {
+		key, operator, value, ok := parseConstraint(constraint)
+		if !ok {
+			c.errorf(fmt.Sprintf("%s[%d]", field, i), "invalid constraint '%s'", constraint)
+			continue
+		}
+		converted := &composev1alpha3.Constraint{Value: value, Operator: operator}
+		switch {
+		case key == "node.hostname":
+			result.Hostname = converted
+		case key == "node.platform.os":
+			result.OperatingSystem = converted
+		case key == "node.platform.arch":
+			result.Architecture = converted
+		case strings.HasPrefix(key, "node.labels."):
+			if result.MatchLabels == nil {
+				result.MatchLabels = map[string]composev1alpha3.Constraint{}
+			}
+			result.MatchLabels[strings.TrimPrefix(key, "node.labels.")] = *converted
+		default:
+			c.errorf(fmt.Sprintf("%s[%d]", field, i), "constraint on '%s' is not supported on kubernetes", key)
+		}
+	}
+	return result
+}
+
+func parseConstraint(constraint string) (string, string, string, bool) {
+	for _, operator := range []string{"==", "!="} {
+		if parts := strings.SplitN(constraint, operator, 2); len(parts) == 2 {
+			key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if key == "" || value == "" {
+				return "", "", "", false
+			}
+			return key, operator, value, true
+		}
+	}
+	return "", "", "", false
+}
+
+func fileReference(source, target, uid, gid string, mode uint32) composev1alpha3.FileReferenceConfig {
+	reference := composev1alpha3.FileReferenceConfig{
+		Source: source,
+		Target: target,
+		UID:    uid,
+		GID:    gid,
+	}
+	if mode != 0 {
+		reference.Mode = &mode
+	}
+	return reference
+}
+
+func resource(resources *swarm.Resources) *composev1alpha3.Resource {
+	if resources == nil {
+		return nil
+	}
+	converted := &composev1alpha3.Resource{MemoryBytes: resources.MemoryBytes}
+	if resources.NanoCPUs != 0 {
+		converted.NanoCPUs = strconv.FormatFloat(float64(resources.NanoCPUs)/1e9, 'f', -1, 64)
+	}
+	return converted
+}
+
+func duration(d time.Duration) *time.Duration {
+	if d == 0 {
+		return nil
+	}
+	return &d
+}
+
+// resourceLabels returns the labels of the Kubernetes objects of the stack id
+func resourceLabels(id string) map[string]string {
+	return map[string]string{types.StackLabel: id}
+}
+
+// composeStack returns the compose-on-kubernetes Stack of spec, updated at
+// updatedAt.
+func composeStack(namespace string, spec types.StackSpec, updatedAt time.Time) (*composev1alpha3.Stack, error) {
+	c := &converter{}
+	stackSpec := c.stackSpec(spec)
+	if len(c.issues) > 0 {
+		return nil, types.StackValidationError{Result: types.StackValidationResult{Errors: c.issues}}
+	}
+	return &composev1alpha3.Stack{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.Annotations.Name,
+			Namespace:   namespace,
+			Labels:      resourceLabels(stackID(namespace, spec.Annotations.Name)),
+			Annotations: map[string]string{updatedAtAnnotation: updatedAt.UTC().Format(time.RFC3339Nano)},
+		},
+		Spec: stackSpec,
+	}, nil
+}
+
+// specConfigMapName returns the name of the ConfigMap of the spec of the
+// stack name
+func specConfigMapName(name string) string {
+	return name + specConfigMapSuffix
+}
+
+// specConfigMap returns the ConfigMap holding the redacted spec of the stack
+// id. ConfigMaps hold up to 1MiB, where the annotations of an object are
+// limited to 256KiB.
+func specConfigMap(namespace, id string, spec types.StackSpec) (*corev1.ConfigMap, error) {
+	encoded, err := json.Marshal(secrets.Redact(spec))
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      specConfigMapName(spec.Annotations.Name),
+			Namespace: namespace,
+			Labels:    map[string]string{specLabel: id},
+		},
+		Data: map[string]string{specKey: string(encoded)},
+	}, nil
+}
+
+// fromComposeStack returns the types.Stack of a compose-on-kubernetes Stack,
+// whose spec is held by specConfigMap. Stacks which were not created
+// through the Stacks API have no such ConfigMap, and only report their name
+// and labels.
+func fromComposeStack(stack *composev1alpha3.Stack, specConfigMap *corev1.ConfigMap) types.Stack {
+	result := types.Stack{
+		ID:           stackID(stack.Namespace, stack.Name),
+		Orchestrator: types.OrchestratorKubernetes,
+		Spec: types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name:   stack.Name,
+				Labels: stack.Labels,
+			},
+		},
+	}
+	if specConfigMap != nil {
+		var spec types.StackSpec
+		if err := json.Unmarshal([]byte(specConfigMap.Data[specKey]), &spec); err == nil {
+			result.Spec = spec
+		}
+	}
+	result.Version.Index, _ = strconv.ParseUint(stack.ResourceVersion, 10, 64)
+	result.CreatedAt = stack.CreationTimestamp.Time
+	result.UpdatedAt = stack.CreationTimestamp.Time
+	if updatedAt, err := time.Parse(time.RFC3339Nano, stack.Annotations[updatedAtAnnotation]); err == nil {
+		result.UpdatedAt = updatedAt
+	}
+	if stack.Status != nil {
+		result.Status = types.StackStatus{
+			Phase:   types.StackPhase(stack.Status.Phase),
+			Message: stack.Status.Message,
+		}
+	}
+	return result
+}
+
+// secretObject returns the Kubernetes Secret of a secret of spec. The data
+// is stored under the name of the secret.
+func secretObject(namespace, id string, spec types.StackSpec, secret swarm.SecretSpec) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.ResourceName(secret.Annotations.Name),
+			Namespace:   namespace,
+			Labels:      resourceLabels(id),
+			Annotations: secret.Annotations.Labels,
+		},
+		Data: map[string][]byte{secret.Annotations.Name: secret.Data},
+	}
+}
+
+// configMapObject returns the Kubernetes ConfigMap of a config of spec. The
+// data is stored under the name of the config.
+func configMapObject(namespace, id string, spec types.StackSpec, config swarm.ConfigSpec) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.ResourceName(config.Annotations.Name),
+			Namespace:   namespace,
+			Labels:      resourceLabels(id),
+			Annotations: config.Annotations.Labels,
+		},
+	}
+	if utf8.Valid(config.Data) {
+		configMap.Data = map[string]string{config.Annotations.Name: string(config.Data)}
+	} else {
+		configMap.BinaryData = map[string][]byte{config.Annotations.Name: config.Data}
+	}
+	return configMap
+}
+
+// fromPod returns the types.StackTask of a pod of a stack
+func fromPod(pod corev1.Pod) types.StackTask {
+	task := types.StackTask{
+		ID:           string(pod.UID),
+		Name:         pod.Name,
+		NodeID:       pod.Spec.NodeName,
+		DesiredState: "running",
+		CurrentState: strings.ToLower(string(pod.Status.Phase)),
+		Err:          pod.Status.Message,
+	}
+	if pod.DeletionTimestamp != nil {
+		task.DesiredState = "shutdown"
+	}
+	if len(pod.Spec.Containers) > 0 {
+		task.Image = pod.Spec.Containers[0].Image
+	}
+	return task
+}
@@ -0,0 +1,98 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace(t *testing.T) {
+	for collection, namespace := range map[string]string{
+		"":              "default",
+		"team-a":        "team-a",
+		"Team A":        "team-a",
+		"/prod/web/":    "prod-web",
+		"--":            "default",
+		"ÉQUIPE_étoile": "quipe-toile",
+	} {
+		require.Equal(t, namespace, Namespace(collection), collection)
+	}
+
+	id := stackID(Namespace("Team A"), "app.v2")
+	namespace, name, ok := parseStackID(id)
+	require.True(t, ok)
+	require.Equal(t, "team-a", namespace)
+	require.Equal(t, "app.v2", name)
+	_, _, ok = parseStackID("swarmid")
+	require.False(t, ok)
+}
+
+func TestConvertService(t *testing.T) {
+	require := require.New(t)
+	grace := 10 * time.Second
+	spec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{Name: "web", Labels: map[string]string{"tier": "front"}},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image:           "nginx:1.16",
+				Command:         []string{"nginx"},
+				Args:            []string{"-g", "daemon off;"},
+				Env:             []string{"A=1", "B"},
+				User:            "1000:1000",
+				Hosts:           []string{"10.0.0.1 db"},
+				StopGracePeriod: &grace,
+				Healthcheck:     &container.HealthConfig{Test: []string{"CMD", "true"}, Interval: time.Second, Retries: 3},
+				Mounts:          []mount.Mount{{Type: mount.TypeVolume, Source: "data", Target: "/data", ReadOnly: true}},
+				Configs: []*swarm.ConfigReference{{
+					File:       &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx/nginx.conf", Mode: 0444},
+					ConfigName: "nginx.conf",
+				}},
+			},
+			RestartPolicy: &swarm.RestartPolicy{Condition: swarm.RestartPolicyConditionOnFailure},
+			Resources: &swarm.ResourceRequirements{
+				Limits: &swarm.Resources{NanoCPUs: 1500000000, MemoryBytes: 1 << 20},
+			},
+			Placement: &swarm.Placement{Constraints: []string{"node.platform.os == linux", "node.labels.disk != hdd"}},
+		},
+		Mode: swarm.ServiceMode{Global: &swarm.GlobalService{}},
+		EndpointSpec: &swarm.EndpointSpec{
+			Ports: []swarm.PortConfig{{TargetPort: 80, PublishedPort: 8080, Protocol: swarm.PortConfigProtocolTCP}},
+		},
+	}
+
+	c := &converter{}
+	service := c.service("Services[0]", spec)
+	require.Empty(c.issues)
+	require.Equal("web", service.Name)
+	require.Equal("global", service.Deploy.Mode)
+	require.Equal(map[string]string{"tier": "front"}, service.Deploy.Labels)
+	require.Equal([]string{"nginx"}, service.Entrypoint)
+	require.Equal([]string{"-g", "daemon off;"}, service.Command)
+	require.Equal("1", *service.Environment["A"])
+	require.Nil(service.Environment["B"])
+	require.Equal(int64(1000), *service.User)
+	require.Equal([]string{"db:10.0.0.1"}, service.ExtraHosts)
+	require.Equal(grace, *service.StopGracePeriod)
+	require.Equal(uint64(3), *service.HealthCheck.Retries)
+	require.Equal("volume", service.Volumes[0].Type)
+	require.True(service.Volumes[0].ReadOnly)
+	require.Equal("nginx.conf", service.Configs[0].Source)
+	require.Equal(uint32(0444), *service.Configs[0].Mode)
+	require.Equal("on-failure", service.Deploy.RestartPolicy.Condition)
+	require.Equal("1.5", service.Deploy.Resources.Limits.NanoCPUs)
+	require.Equal("linux", service.Deploy.Placement.Constraints.OperatingSystem.Value)
+	require.Equal("!=", service.Deploy.Placement.Constraints.MatchLabels["disk"].Operator)
+	require.Equal(uint32(8080), service.Ports[0].Published)
+
+	spec.TaskTemplate.Placement.Constraints = []string{"node.role == manager"}
+	spec.TaskTemplate.ContainerSpec.Hosts = []string{"db"}
+	c = &converter{}
+	c.service("Services[0]", spec)
+	require.Len(c.issues, 2)
+	require.Equal("Services[0].TaskTemplate.Placement.Constraints[0]", c.issues[0].Field)
+	require.Equal("Services[0].TaskTemplate.ContainerSpec.Hosts[0]", c.issues[1].Field)
+}
@@ -0,0 +1,17 @@
+package kubernetes
+
+// The `kubernetes` package implements the Stacks API on Kubernetes, so that
+// a router.StacksRouter can serve stacks deployed on Swarm and on Kubernetes
+// through the same API.
+//
+// A Client converts a StackSpec into a compose-on-kubernetes Stack, in a
+// namespace derived from the Collection of the StackSpec. The data of its
+// secrets and configs is stored in Kubernetes Secrets and ConfigMaps, which
+// the Stack references as external resources. The StackSpec itself is kept
+// in a ConfigMap named <stack>.stack-spec, with the data of its secrets
+// redacted, so that inspecting the stack returns the StackSpec it was
+// created with. The Stack is annotated with the time it was last updated
+// through the Stacks API.
+//
+// The Client only depends on the Clientset interface, which NewClientset
+// implements for a live cluster and the `fake` package in memory.
@@ -0,0 +1,333 @@
+package fake
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	composev1alpha3 "github.com/docker/compose-on-kubernetes/api/compose/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/docker/stacks/pkg/kubernetes"
+)
+
+// Clientset is an in-memory kubernetes.Clientset. Objects are versioned
+// like in Kubernetes: updates of an object at a stale ResourceVersion fail
+// with a conflict.
+type Clientset struct {
+	mu      sync.Mutex
+	version uint64
+	objects map[schema.GroupResource]map[string]runtime.Object
+}
+
+var (
+	stacksResource     = schema.GroupResource{Group: "compose.docker.com", Resource: "stacks"}
+	secretsResource    = schema.GroupResource{Resource: "secrets"}
+	configMapsResource = schema.GroupResource{Resource: "configmaps"}
+	podsResource       = schema.GroupResource{Resource: "pods"}
+)
+
+// NewClientset creates a new, empty Clientset.
+func NewClientset() *Clientset {
+	return &Clientset{
+		objects: map[schema.GroupResource]map[string]runtime.Object{},
+	}
+}
+
+// Stacks returns the compose-on-kubernetes Stacks of a namespace.
+func (c *Clientset) Stacks(namespace string) kubernetes.StackInterface {
+	return &stacks{c, namespace}
+}
+
+// Secrets returns the Secrets of a namespace.
+func (c *Clientset) Secrets(namespace string) kubernetes.SecretInterface {
+	return &secrets{c, namespace}
+}
+
+// ConfigMaps returns the ConfigMaps of a namespace.
+func (c *Clientset) ConfigMaps(namespace string) kubernetes.ConfigMapInterface {
+	return &configMaps{c, namespace}
+}
+
+// Pods returns the Pods of a namespace.
+func (c *Clientset) Pods(namespace string) kubernetes.PodInterface {
+	return &pods{c, namespace}
+}
+
+// AddPod stores a pod, as compose-on-kubernetes creates them for the
+// services of stacks.
+func (c *Clientset) AddPod(pod *corev1.Pod) error {
+	_, err := c.create(podsResource, pod.Namespace, pod)
+	return err
+}
+
+// SetStackStatus sets the status of a stack, as compose-on-kubernetes
+// reports it.
+func (c *Clientset) SetStackStatus(namespace, name string, status composev1alpha3.StackStatus) error {
+	object, err := c.get(stacksResource, namespace, name)
+	if err != nil {
+		return err
+	}
+	stack := object.(*composev1alpha3.Stack)
+	stack.Status = &status
+	_, err = c.update(stacksResource, namespace, stack)
+	return err
+}
+
+func key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (c *Clientset) create(resource schema.GroupResource, namespace string, object runtime.Object) (runtime.Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	object = object.DeepCopyObject()
+	meta := object.(metav1.Object)
+	meta.SetNamespace(namespace)
+	if c.objects[resource] == nil {
+		c.objects[resource] = map[string]runtime.Object{}
+	}
+	if _, ok := c.objects[resource][key(namespace, meta.GetName())]; ok {
+		return nil, apierrors.NewAlreadyExists(resource, meta.GetName())
+	}
+	c.version++
+	meta.SetResourceVersion(strconv.FormatUint(c.version, 10))
+	meta.SetUID(k8stypes.UID(strconv.FormatUint(c.version, 10)))
+	meta.SetCreationTimestamp(metav1.Now())
+	c.objects[resource][key(namespace, meta.GetName())] = object
+	return object.DeepCopyObject(), nil
+}
+
+func (c *Clientset) update(resource schema.GroupResource, namespace string, object runtime.Object) (runtime.Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	object = object.DeepCopyObject()
+	meta := object.(metav1.Object)
+	current, ok := c.objects[resource][key(namespace, meta.GetName())]
+	if !ok {
+		return nil, apierrors.NewNotFound(resource, meta.GetName())
+	}
+	currentMeta := current.(metav1.Object)
+	if meta.GetResourceVersion() != "" && meta.GetResourceVersion() != currentMeta.GetResourceVersion() {
+		return nil, apierrors.NewConflict(resource, meta.GetName(), nil)
+	}
+	c.version++
+	meta.SetNamespace(namespace)
+	meta.SetResourceVersion(strconv.FormatUint(c.version, 10))
+	meta.SetUID(currentMeta.GetUID())
+	meta.SetCreationTimestamp(currentMeta.GetCreationTimestamp())
+	c.objects[resource][key(namespace, meta.GetName())] = object
+	return object.DeepCopyObject(), nil
+}
+
+func (c *Clientset) delete(resource schema.GroupResource, namespace, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.objects[resource][key(namespace, name)]; !ok {
+		return apierrors.NewNotFound(resource, name)
+	}
+	delete(c.objects[resource], key(namespace, name))
+	return nil
+}
+
+func (c *Clientset) get(resource schema.GroupResource, namespace, name string) (runtime.Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	object, ok := c.objects[resource][key(namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(resource, name)
+	}
+	return object.DeepCopyObject(), nil
+}
+
+// list returns the objects of a namespace, or of all namespaces, selected
+// by the label selector of options, ordered by namespace and name
+func (c *Clientset) list(resource schema.GroupResource, namespace string, options metav1.ListOptions) ([]runtime.Object, error) {
+	selector, err := labels.Parse(options.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := []string{}
+	for k, object := range c.objects[resource] {
+		meta := object.(metav1.Object)
+		if namespace != metav1.NamespaceAll && meta.GetNamespace() != namespace {
+			continue
+		}
+		if selector.Matches(labels.Set(meta.GetLabels())) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	objects := make([]runtime.Object, 0, len(keys))
+	for _, k := range keys {
+		objects = append(objects, c.objects[resource][k].DeepCopyObject())
+	}
+	return objects, nil
+}
+
+type stacks struct {
+	c         *Clientset
+	namespace string
+}
+
+func (s *stacks) Create(stack *composev1alpha3.Stack) (*composev1alpha3.Stack, error) {
+	object, err := s.c.create(stacksResource, s.namespace, stack)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*composev1alpha3.Stack), nil
+}
+
+func (s *stacks) Update(stack *composev1alpha3.Stack) (*composev1alpha3.Stack, error) {
+	object, err := s.c.update(stacksResource, s.namespace, stack)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*composev1alpha3.Stack), nil
+}
+
+func (s *stacks) Delete(name string, _ *metav1.DeleteOptions) error {
+	return s.c.delete(stacksResource, s.namespace, name)
+}
+
+func (s *stacks) Get(name string, _ metav1.GetOptions) (*composev1alpha3.Stack, error) {
+	object, err := s.c.get(stacksResource, s.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*composev1alpha3.Stack), nil
+}
+
+func (s *stacks) List(options metav1.ListOptions) (*composev1alpha3.StackList, error) {
+	objects, err := s.c.list(stacksResource, s.namespace, options)
+	if err != nil {
+		return nil, err
+	}
+	list := &composev1alpha3.StackList{}
+	for _, object := range objects {
+		list.Items = append(list.Items, *object.(*composev1alpha3.Stack))
+	}
+	return list, nil
+}
+
+type secrets struct {
+	c         *Clientset
+	namespace string
+}
+
+func (s *secrets) Create(secret *corev1.Secret) (*corev1.Secret, error) {
+	object, err := s.c.create(secretsResource, s.namespace, secret)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.Secret), nil
+}
+
+func (s *secrets) Update(secret *corev1.Secret) (*corev1.Secret, error) {
+	object, err := s.c.update(secretsResource, s.namespace, secret)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.Secret), nil
+}
+
+func (s *secrets) Delete(name string, _ *metav1.DeleteOptions) error {
+	return s.c.delete(secretsResource, s.namespace, name)
+}
+
+func (s *secrets) Get(name string, _ metav1.GetOptions) (*corev1.Secret, error) {
+	object, err := s.c.get(secretsResource, s.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.Secret), nil
+}
+
+func (s *secrets) List(options metav1.ListOptions) (*corev1.SecretList, error) {
+	objects, err := s.c.list(secretsResource, s.namespace, options)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.SecretList{}
+	for _, object := range objects {
+		list.Items = append(list.Items, *object.(*corev1.Secret))
+	}
+	return list, nil
+}
+
+type configMaps struct {
+	c         *Clientset
+	namespace string
+}
+
+func (s *configMaps) Create(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	object, err := s.c.create(configMapsResource, s.namespace, configMap)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.ConfigMap), nil
+}
+
+func (s *configMaps) Update(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	object, err := s.c.update(configMapsResource, s.namespace, configMap)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.ConfigMap), nil
+}
+
+func (s *configMaps) Delete(name string, _ *metav1.DeleteOptions) error {
+	return s.c.delete(configMapsResource, s.namespace, name)
+}
+
+func (s *configMaps) Get(name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	object, err := s.c.get(configMapsResource, s.namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.ConfigMap), nil
+}
+
+func (s *configMaps) List(options metav1.ListOptions) (*corev1.ConfigMapList, error) {
+	objects, err := s.c.list(configMapsResource, s.namespace, options)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.ConfigMapList{}
+	for _, object := range objects {
+		list.Items = append(list.Items, *object.(*corev1.ConfigMap))
+	}
+	return list, nil
+}
+
+type pods struct {
+	c         *Clientset
+	namespace string
+}
+
+func (s *pods) List(options metav1.ListOptions) (*corev1.PodList, error) {
+	objects, err := s.c.list(podsResource, s.namespace, options)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PodList{}
+	for _, object := range objects {
+		list.Items = append(list.Items, *object.(*corev1.Pod))
+	}
+	return list, nil
+}
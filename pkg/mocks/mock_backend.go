@@ -310,16 +310,54 @@ func (_mr *MockBackendClientMockRecorder) Info() *gomock.Call {
 }
 
 // ListStacks mocks base method
-func (_m *MockBackendClient) ListStacks() ([]types0.Stack, error) {
-	ret := _m.ctrl.Call(_m, "ListStacks")
+func (_m *MockBackendClient) ListStacks(_param0 types0.StackListOptions) ([]types0.Stack, error) {
+	ret := _m.ctrl.Call(_m, "ListStacks", _param0)
 	ret0, _ := ret[0].([]types0.Stack)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListStacks indicates an expected call of ListStacks
-func (_mr *MockBackendClientMockRecorder) ListStacks() *gomock.Call {
-	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "ListStacks", reflect.TypeOf((*MockBackendClient)(nil).ListStacks))
+func (_mr *MockBackendClientMockRecorder) ListStacks(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "ListStacks", reflect.TypeOf((*MockBackendClient)(nil).ListStacks), arg0)
+}
+
+// Maintenance mocks base method
+func (_m *MockBackendClient) Maintenance() (types0.StackMaintenance, error) {
+	ret := _m.ctrl.Call(_m, "Maintenance")
+	ret0, _ := ret[0].(types0.StackMaintenance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Maintenance indicates an expected call of Maintenance
+func (_mr *MockBackendClientMockRecorder) Maintenance() *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "Maintenance", reflect.TypeOf((*MockBackendClient)(nil).Maintenance))
+}
+
+// PauseStack mocks base method
+func (_m *MockBackendClient) PauseStack(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "PauseStack", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseStack indicates an expected call of PauseStack
+func (_mr *MockBackendClientMockRecorder) PauseStack(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "PauseStack", reflect.TypeOf((*MockBackendClient)(nil).PauseStack), arg0)
+}
+
+// PolicyReport mocks base method
+func (_m *MockBackendClient) PolicyReport() (types0.StackPolicyReport, error) {
+	ret := _m.ctrl.Call(_m, "PolicyReport")
+	ret0, _ := ret[0].(types0.StackPolicyReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PolicyReport indicates an expected call of PolicyReport
+func (_mr *MockBackendClientMockRecorder) PolicyReport() *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "PolicyReport", reflect.TypeOf((*MockBackendClient)(nil).PolicyReport))
 }
 
 // RemoveConfig mocks base method
@@ -370,6 +408,42 @@ func (_mr *MockBackendClientMockRecorder) RemoveService(arg0 interface{}) *gomoc
 	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "RemoveService", reflect.TypeOf((*MockBackendClient)(nil).RemoveService), arg0)
 }
 
+// ResumeStack mocks base method
+func (_m *MockBackendClient) ResumeStack(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "ResumeStack", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeStack indicates an expected call of ResumeStack
+func (_mr *MockBackendClientMockRecorder) ResumeStack(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "ResumeStack", reflect.TypeOf((*MockBackendClient)(nil).ResumeStack), arg0)
+}
+
+// ScaleStack mocks base method
+func (_m *MockBackendClient) ScaleStack(_param0 string, _param1 map[string]uint64) error {
+	ret := _m.ctrl.Call(_m, "ScaleStack", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScaleStack indicates an expected call of ScaleStack
+func (_mr *MockBackendClientMockRecorder) ScaleStack(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "ScaleStack", reflect.TypeOf((*MockBackendClient)(nil).ScaleStack), arg0, arg1)
+}
+
+// SetMaintenance mocks base method
+func (_m *MockBackendClient) SetMaintenance(_param0 types0.StackMaintenance) error {
+	ret := _m.ctrl.Call(_m, "SetMaintenance", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMaintenance indicates an expected call of SetMaintenance
+func (_mr *MockBackendClientMockRecorder) SetMaintenance(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "SetMaintenance", reflect.TypeOf((*MockBackendClient)(nil).SetMaintenance), arg0)
+}
+
 // SubscribeToEvents mocks base method
 func (_m *MockBackendClient) SubscribeToEvents(_param0 time.Time, _param1 time.Time, _param2 filters.Args) ([]events.Message, chan interface{}) {
 	ret := _m.ctrl.Call(_m, "SubscribeToEvents", _param0, _param1, _param2)
@@ -454,3 +528,15 @@ func (_m *MockBackendClient) UpdateStack(_param0 string, _param1 types0.StackSpe
 func (_mr *MockBackendClientMockRecorder) UpdateStack(arg0, arg1, arg2 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "UpdateStack", reflect.TypeOf((*MockBackendClient)(nil).UpdateStack), arg0, arg1, arg2)
 }
+
+// ValidateStack mocks base method
+func (_m *MockBackendClient) ValidateStack(_param0 types0.StackSpec) types0.StackValidationResult {
+	ret := _m.ctrl.Call(_m, "ValidateStack", _param0)
+	ret0, _ := ret[0].(types0.StackValidationResult)
+	return ret0
+}
+
+// ValidateStack indicates an expected call of ValidateStack
+func (_mr *MockBackendClientMockRecorder) ValidateStack(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCallWithMethodType(_mr.mock, "ValidateStack", reflect.TypeOf((*MockBackendClient)(nil).ValidateStack), arg0)
+}
@@ -0,0 +1,11 @@
+package patch
+
+// The `patch` package applies JSON Merge Patches (RFC 7386) and JSON Patches
+// (RFC 6902) to a types.StackSpec. Both operate on the JSON representation
+// of the StackSpec, except that the Services, Secrets and Configs lists are
+// presented as objects keyed by name, so that a patch can address an entry
+// by name rather than by its position in the list:
+//
+//	{"Services": {"web": {"TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.17"}}}}}
+//
+//	[{"op": "replace", "path": "/Services/web/TaskTemplate/ContainerSpec/Image", "value": "nginx:1.17"}]
@@ -0,0 +1,257 @@
+package patch
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// operation is a single operation of a JSON Patch
+type operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+// applyJSONPatch applies the operations of a JSON Patch to document in
+// sequence, as described in section 4 of RFC 6902. The patch fails as a
+// whole if any of its operations fails.
+func applyJSONPatch(document interface{}, operations []operation) (interface{}, error) {
+	for i, op := range operations {
+		var err error
+		document, err = applyOperation(document, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %s", i, op.Op, op.Path, err)
+		}
+	}
+	return document, nil
+}
+
+func applyOperation(document interface{}, op operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return add(document, path, op.Value)
+	case "remove":
+		document, _, err = remove(document, path)
+		return document, err
+	case "replace":
+		document, _, err = remove(document, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, op.Value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if len(path) > len(from) && isPrefix(from, path) {
+				return nil, fmt.Errorf("cannot move a value into itself")
+			}
+			document, value, err = remove(document, from)
+		} else {
+			value, err = get(document, from)
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, value)
+	case "test":
+		value, err := get(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.Value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return document, nil
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+}
+
+// parsePointer splits a JSON Pointer, as described in RFC 6901, into its
+// unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses the reference token of an element of an array of
+// length size. The "-" token, past the last element, is only valid when
+// adding.
+func arrayIndex(token string, size int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return size, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > size || (index == size && !adding) || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	return index, nil
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			child, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("no such member '%s'", token)
+			}
+			node = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			node = container[index]
+		default:
+			return nil, fmt.Errorf("cannot descend into a value with '%s'", token)
+		}
+	}
+	return node, nil
+}
+
+// add returns node with value added at path. Arrays are copied, objects are
+// modified in place.
+func add(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+	switch container := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			container[token] = value
+			return container, nil
+		}
+		child, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("no such member '%s'", token)
+		}
+		child, err := add(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		container[token] = child
+		return container, nil
+	case []interface{}:
+		if len(path) == 1 {
+			index, err := arrayIndex(token, len(container), true)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]interface{}, 0, len(container)+1)
+			result = append(result, container[:index]...)
+			result = append(result, value)
+			return append(result, container[index:]...), nil
+		}
+		index, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := add(container[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		result := append([]interface{}{}, container...)
+		result[index] = child
+		return result, nil
+	default:
+		return nil, fmt.Errorf("cannot descend into a value with '%s'", token)
+	}
+}
+
+// remove returns node without the value at path, and that value.
+func remove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+
+	token := path[0]
+	switch container := node.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("no such member '%s'", token)
+		}
+		if len(path) == 1 {
+			delete(container, token)
+			return container, child, nil
+		}
+		child, removed, err := remove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		container[token] = child
+		return container, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			result := make([]interface{}, 0, len(container)-1)
+			result = append(result, container[:index]...)
+			return append(result, container[index+1:]...), container[index], nil
+		}
+		child, removed, err := remove(container[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		result := append([]interface{}{}, container...)
+		result[index] = child
+		return result, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("cannot descend into a value with '%s'", token)
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for name, child := range v {
+			result[name] = deepCopy(child)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = deepCopy(child)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,177 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// namedLists are the lists of a StackSpec whose entries are addressed by
+// name
+var namedLists = []string{"Services", "Secrets", "Configs"}
+
+// Apply returns a copy of spec with patch applied. Invalid patches, and
+// patches which cannot be applied to spec, are reported as
+// errdefs.InvalidParameter errors.
+func Apply(spec types.StackSpec, patchType types.StackPatchType, patch []byte) (types.StackSpec, error) {
+	document, order, err := toDocument(spec)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+
+	var patched interface{}
+	switch patchType {
+	case types.StackPatchMerge:
+		var mergePatch interface{}
+		if err := decode(patch, &mergePatch); err != nil {
+			return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("invalid merge patch: %s", err))
+		}
+		patched = applyMergePatch(document, mergePatch)
+	case types.StackPatchJSON:
+		var operations []operation
+		if err := decode(patch, &operations); err != nil {
+			return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("invalid JSON patch: %s", err))
+		}
+		patched, err = applyJSONPatch(document, operations)
+		if err != nil {
+			return types.StackSpec{}, errdefs.InvalidParameter(err)
+		}
+	default:
+		return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("unsupported patch type '%s'", patchType))
+	}
+
+	result, err := fromDocument(patched, order)
+	if err != nil {
+		return types.StackSpec{}, errdefs.InvalidParameter(err)
+	}
+	return result, nil
+}
+
+// decode unmarshals data, keeping numbers as json.Number so that integers
+// such as NanoCPUs survive the round trip exactly.
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// toDocument returns the JSON representation of spec, with its named lists
+// turned into objects, and the order of the names in each list. Entries
+// without a name, or sharing their name with another entry of their list,
+// cannot be addressed and are reported as errdefs.InvalidParameter errors.
+func toDocument(spec types.StackSpec) (map[string]interface{}, map[string][]string, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	document := map[string]interface{}{}
+	if err := decode(encoded, &document); err != nil {
+		return nil, nil, err
+	}
+
+	order := map[string][]string{}
+	for _, list := range namedLists {
+		entries, _ := document[list].([]interface{})
+		byName := map[string]interface{}{}
+		for _, entry := range entries {
+			name := entryName(entry)
+			if name == "" {
+				return nil, nil, errdefs.InvalidParameter(fmt.Errorf("cannot patch a stack whose %s include an entry without a name", list))
+			}
+			if _, ok := byName[name]; ok {
+				return nil, nil, errdefs.InvalidParameter(fmt.Errorf("cannot patch a stack whose %s include several entries named %s", list, name))
+			}
+			order[list] = append(order[list], name)
+			byName[name] = entry
+		}
+		document[list] = byName
+	}
+	return document, order, nil
+}
+
+// fromDocument is the inverse of toDocument. Entries keep their original
+// position in the named lists, and entries added by the patch follow them
+// in the order of their names. The name of an entry is its key, which
+// takes precedence over the name it may contain.
+func fromDocument(patched interface{}, order map[string][]string) (types.StackSpec, error) {
+	document, ok := patched.(map[string]interface{})
+	if !ok {
+		return types.StackSpec{}, fmt.Errorf("a stack specification must be an object")
+	}
+
+	for _, list := range namedLists {
+		value, ok := document[list]
+		if !ok || value == nil {
+			continue
+		}
+		byName, ok := value.(map[string]interface{})
+		if !ok {
+			return types.StackSpec{}, fmt.Errorf("%s must be an object keyed by name", list)
+		}
+
+		names := []string{}
+		for _, name := range order[list] {
+			if _, ok := byName[name]; ok {
+				names = append(names, name)
+			}
+		}
+		added := []string{}
+		for name := range byName {
+			if !contains(order[list], name) {
+				added = append(added, name)
+			}
+		}
+		sort.Strings(added)
+		names = append(names, added...)
+		if len(names) == 0 {
+			document[list] = nil
+			continue
+		}
+
+		entries := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			entry, ok := byName[name].(map[string]interface{})
+			if !ok {
+				return types.StackSpec{}, fmt.Errorf("%s/%s must be an object", list, name)
+			}
+			entry["Name"] = name
+			entries = append(entries, entry)
+		}
+		document[list] = entries
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return types.StackSpec{}, err
+	}
+	var spec types.StackSpec
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return types.StackSpec{}, fmt.Errorf("patched stack specification is invalid: %s", err)
+	}
+	return spec, nil
+}
+
+// entryName returns the name of an entry of a named list. The
+// swarm.Annotations of services, secrets and configs are embedded, so their
+// names are members of the entries themselves.
+func entryName(entry interface{}) string {
+	object, _ := entry.(map[string]interface{})
+	name, _ := object["Name"].(string)
+	return name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
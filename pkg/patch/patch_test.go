@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestSpec() types.StackSpec {
+	replicas := uint64(3)
+	service := func(name, image string) swarm.ServiceSpec {
+		return swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name: name,
+			},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image: image,
+					Env:   []string{"A=1"},
+				},
+				Resources: &swarm.ResourceRequirements{
+					Limits: &swarm.Resources{
+						NanoCPUs: 1234567890123456789,
+					},
+				},
+			},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{
+					Replicas: &replicas,
+				},
+			},
+		}
+	}
+	return types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "app",
+		},
+		Services: []swarm.ServiceSpec{
+			service("web", "nginx:1.16"),
+			service("db", "postgres:11"),
+		},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"backend": {Driver: "overlay"},
+		},
+		Secrets: []swarm.SecretSpec{
+			{Annotations: swarm.Annotations{Name: "password"}},
+		},
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	require := require.New(t)
+	spec := getTestSpec()
+
+	patched, err := Apply(spec, types.StackPatchMerge, []byte(`{
+		"Services": {
+			"web": {"TaskTemplate": {"ContainerSpec": {"Image": "nginx:1.17"}}},
+			"cache": {"TaskTemplate": {"ContainerSpec": {"Image": "redis"}}}
+		},
+		"Secrets": {"password": null},
+		"Networks": {"frontend": {"Driver": "overlay"}}
+	}`))
+	require.NoError(err)
+
+	require.Len(patched.Services, 3)
+	require.Equal("web", patched.Services[0].Annotations.Name)
+	require.Equal("nginx:1.17", patched.Services[0].TaskTemplate.ContainerSpec.Image)
+	require.Equal([]string{"A=1"}, patched.Services[0].TaskTemplate.ContainerSpec.Env)
+	require.Equal(int64(1234567890123456789), patched.Services[0].TaskTemplate.Resources.Limits.NanoCPUs)
+	require.Equal(spec.Services[1], patched.Services[1])
+	require.Equal("cache", patched.Services[2].Annotations.Name)
+	require.Equal("redis", patched.Services[2].TaskTemplate.ContainerSpec.Image)
+	require.Empty(patched.Secrets)
+	require.Len(patched.Networks, 2)
+
+	// the original spec is left untouched
+	require.Equal(getTestSpec(), spec)
+}
+
+func TestJSONPatch(t *testing.T) {
+	require := require.New(t)
+	spec := getTestSpec()
+
+	patched, err := Apply(spec, types.StackPatchJSON, []byte(`[
+		{"op": "test", "path": "/Services/db/TaskTemplate/ContainerSpec/Image", "value": "postgres:11"},
+		{"op": "replace", "path": "/Services/db/TaskTemplate/ContainerSpec/Image", "value": "postgres:12"},
+		{"op": "add", "path": "/Services/db/TaskTemplate/ContainerSpec/Env/-", "value": "B=2"},
+		{"op": "copy", "from": "/Services/web", "path": "/Services/web2"},
+		{"op": "remove", "path": "/Services/web"},
+		{"op": "move", "from": "/Networks/backend", "path": "/Networks/private"}
+	]`))
+	require.NoError(err)
+
+	require.Len(patched.Services, 2)
+	require.Equal("db", patched.Services[0].Annotations.Name)
+	require.Equal("postgres:12", patched.Services[0].TaskTemplate.ContainerSpec.Image)
+	require.Equal([]string{"A=1", "B=2"}, patched.Services[0].TaskTemplate.ContainerSpec.Env)
+	require.Equal("web2", patched.Services[1].Annotations.Name)
+	require.Equal("nginx:1.16", patched.Services[1].TaskTemplate.ContainerSpec.Image)
+	require.Contains(patched.Networks, "private")
+	require.NotContains(patched.Networks, "backend")
+
+	require.Equal(getTestSpec(), spec)
+}
+
+func TestJSONPatchFailures(t *testing.T) {
+	for _, patch := range []string{
+		`[{"op": "test", "path": "/Services/web/TaskTemplate/ContainerSpec/Image", "value": "httpd"}]`,
+		`[{"op": "replace", "path": "/Services/nosuchservice/TaskTemplate", "value": {}}]`,
+		`[{"op": "remove", "path": "/Services/web/TaskTemplate/ContainerSpec/Env/1"}]`,
+		`[{"op": "add", "path": "Services", "value": {}}]`,
+		`[{"op": "add", "path": "/Servises", "value": {}}]`,
+		`[{"op": "frobnicate", "path": "/Services"}]`,
+		`{"op": "remove", "path": "/Services"}`,
+	} {
+		_, err := Apply(getTestSpec(), types.StackPatchJSON, []byte(patch))
+		require.Error(t, err, patch)
+		require.True(t, errdefs.IsInvalidParameter(err), patch)
+	}
+
+	_, err := Apply(getTestSpec(), "application/json", []byte(`{}`))
+	require.Error(t, err)
+	require.True(t, errdefs.IsInvalidParameter(err))
+}
+
+func TestPatchUnaddressableEntries(t *testing.T) {
+	duplicate := getTestSpec()
+	duplicate.Services = append(duplicate.Services, duplicate.Services[0])
+	unnamed := getTestSpec()
+	unnamed.Secrets = append(unnamed.Secrets, swarm.SecretSpec{})
+
+	for _, spec := range []types.StackSpec{duplicate, unnamed} {
+		_, err := Apply(spec, types.StackPatchMerge, []byte(`{}`))
+		require.Error(t, err)
+		require.True(t, errdefs.IsInvalidParameter(err))
+	}
+}
@@ -0,0 +1,29 @@
+package policy
+
+// The `policy` package evaluates declarative rules over the fields of
+// StackSpecs, in process, so that organization policies can be enforced on
+// clusters which cannot reach an admission webhook.
+//
+// Rules are loaded from a YAML file such as:
+//
+//	rules:
+//	- name: resource-limits
+//	  description: services must set resource limits
+//	  path: Services[*].TaskTemplate.Resources.Limits
+//	  required: true
+//	- name: corporate-registry
+//	  path: Services[*].TaskTemplate.ContainerSpec.Image
+//	  patterns: ["registry.corp/*"]
+//	- name: no-privileges
+//	  mode: warn
+//	  path: Services[*].TaskTemplate.ContainerSpec.Privileges
+//	  forbidden: true
+//
+// Paths name the fields of the JSON representation of a StackSpec. A
+// bracketed selector picks elements of lists and maps: `*` selects all of
+// them, a number an element of a list by index, and any other value the
+// member of a map with that key, or the element of a list with that Name.
+//
+// Violations of rules in `enforce` mode, the default, are reported as
+// validation errors and reject the StackSpec, those of rules in `warn` mode
+// as validation warnings.
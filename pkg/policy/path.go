@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// step is a single step of a path: either a field, or a selector of the
+// elements of a list or map.
+type step struct {
+	field    string
+	selector string
+}
+
+func (s step) String() string {
+	if s.field != "" {
+		return "." + s.field
+	}
+	return "[" + s.selector + "]"
+}
+
+// parsePath splits a path such as "Services[*].TaskTemplate" into its
+// steps.
+func parsePath(path string) ([]step, error) {
+	steps := []step{}
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		if i := strings.Index(segment, "["); i >= 0 {
+			name = segment[:i]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+		steps = append(steps, step{field: name})
+
+		rest := segment[len(name):]
+		for rest != "" {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 2 {
+				return nil, fmt.Errorf("invalid path '%s'", path)
+			}
+			steps = append(steps, step{selector: rest[1:end]})
+			rest = rest[end+1:]
+		}
+	}
+	return steps, nil
+}
+
+// location is a field selected by a path. Fields missing from the
+// document are selected with found set to false.
+type location struct {
+	path  string
+	value interface{}
+	found bool
+}
+
+// render returns the path of steps, appended to prefix.
+func render(prefix string, steps []step) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, s := range steps {
+		b.WriteString(s.String())
+	}
+	return strings.TrimPrefix(b.String(), ".")
+}
+
+// walk returns the locations selected by steps in node, whose own path is
+// prefix.
+func walk(node interface{}, prefix string, steps []step) []location {
+	if len(steps) == 0 {
+		return []location{{path: strings.TrimPrefix(prefix, "."), value: node, found: node != nil}}
+	}
+
+	s := steps[0]
+	if s.field != "" {
+		object, _ := node.(map[string]interface{})
+		child, ok := object[s.field]
+		if !ok || child == nil {
+			return []location{{path: render(prefix, steps)}}
+		}
+		return walk(child, prefix+s.String(), steps[1:])
+	}
+
+	var locations []location
+	switch container := node.(type) {
+	case []interface{}:
+		for i, element := range container {
+			if s.selector == "*" || s.selector == strconv.Itoa(i) || s.selector == entryName(element) {
+				locations = append(locations, walk(element, fmt.Sprintf("%s[%d]", prefix, i), steps[1:])...)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(container))
+		for key := range container {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if s.selector == "*" || s.selector == key {
+				locations = append(locations, walk(container[key], fmt.Sprintf("%s[%s]", prefix, key), steps[1:])...)
+			}
+		}
+	}
+	if len(locations) == 0 && s.selector != "*" {
+		// a specific element which does not exist is missing, while a
+		// wildcard over no elements selects nothing
+		return []location{{path: render(prefix, steps)}}
+	}
+	return locations
+}
+
+// entryName returns the name of an element of a list of services, secrets
+// or configs, whose swarm.Annotations are embedded.
+func entryName(element interface{}) string {
+	object, _ := element.(map[string]interface{})
+	name, _ := object["Name"].(string)
+	return name
+}
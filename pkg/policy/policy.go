@@ -0,0 +1,234 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Mode decides how violations of a Rule are reported
+type Mode string
+
+const (
+	// ModeEnforce reports violations as errors, which reject the
+	// StackSpec. This is the default.
+	ModeEnforce Mode = "enforce"
+
+	// ModeWarn reports violations as warnings
+	ModeWarn Mode = "warn"
+)
+
+// Rule is a condition on the fields of a StackSpec selected by Path. A
+// Required field must be set, a Forbidden one must not, and a field with
+// Patterns must be a string matching one of them if set. In patterns, `*`
+// matches any sequence of characters.
+type Rule struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Mode        Mode     `yaml:"mode,omitempty"`
+	Path        string   `yaml:"path"`
+	Required    bool     `yaml:"required,omitempty"`
+	Forbidden   bool     `yaml:"forbidden,omitempty"`
+	Patterns    []string `yaml:"patterns,omitempty"`
+}
+
+// File is the content of a rule file
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// rule is a Rule ready to be evaluated
+type rule struct {
+	Rule
+	steps    []step
+	patterns []*regexp.Regexp
+}
+
+// Engine evaluates rules over StackSpecs.
+type Engine struct {
+	rules []rule
+}
+
+// Load creates a new Engine from a YAML rule file.
+func Load(path string) (*Engine, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rule file %s: %s", path, err)
+	}
+	return NewEngine(file.Rules)
+}
+
+// NewEngine creates a new Engine evaluating rules.
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	names := map[string]bool{}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("policy rule has no name")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate policy rule %s", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Mode {
+		case "":
+			r.Mode = ModeEnforce
+		case ModeEnforce, ModeWarn:
+		default:
+			return nil, fmt.Errorf("policy rule %s has unknown mode '%s'", r.Name, r.Mode)
+		}
+		if r.Forbidden && (r.Required || len(r.Patterns) > 0) {
+			return nil, fmt.Errorf("policy rule %s cannot forbid a field and constrain its value", r.Name)
+		}
+		if !r.Forbidden && !r.Required && len(r.Patterns) == 0 {
+			return nil, fmt.Errorf("policy rule %s has no condition", r.Name)
+		}
+
+		steps, err := parsePath(r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %s: %s", r.Name, err)
+		}
+		compiled := rule{Rule: r, steps: steps}
+		for _, pattern := range r.Patterns {
+			expression := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+			compiled.patterns = append(compiled.patterns, regexp.MustCompile(expression))
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// Check evaluates the rules of the engine over spec.
+func (e *Engine) Check(spec types.StackSpec) types.StackValidationResult {
+	result := types.StackValidationResult{Valid: true}
+	if len(e.rules) == 0 {
+		return result
+	}
+
+	document, err := toDocument(spec)
+	if err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, types.StackValidationIssue{
+			Message: fmt.Sprintf("unable to evaluate policy: %s", err),
+		})
+		return result
+	}
+
+	for _, r := range e.rules {
+		for _, l := range walk(document, "", r.steps) {
+			violation := r.check(l)
+			if violation == "" {
+				continue
+			}
+			if r.Description != "" {
+				violation = r.Description
+			}
+			issue := types.StackValidationIssue{
+				Field:   l.path,
+				Message: fmt.Sprintf("policy %s: %s", r.Name, violation),
+			}
+			if r.Mode == ModeWarn {
+				result.Warnings = append(result.Warnings, issue)
+			} else {
+				result.Valid = false
+				result.Errors = append(result.Errors, issue)
+			}
+		}
+	}
+	return result
+}
+
+// check returns the violation of the rule at l, if any.
+func (r rule) check(l location) string {
+	set := l.found && !isZero(l.value)
+	switch {
+	case r.Forbidden && set:
+		return "field must not be set"
+	case r.Required && !set:
+		return "field must be set"
+	case len(r.patterns) == 0 || !set:
+		return ""
+	}
+
+	value, ok := l.value.(string)
+	if !ok {
+		return "field must be a string"
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(value) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("'%s' does not match %s", value, strings.Join(r.Patterns, ", "))
+}
+
+// isZero returns whether a JSON value is the zero value of its type, which
+// the fields of a StackSpec use to mean that they are not set.
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// toDocument returns the JSON representation of spec.
+func toDocument(spec types.StackSpec) (interface{}, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Report evaluates the rules of engine over stacks. A nil engine has no
+// rules.
+func Report(engine *Engine, stacks []types.Stack) types.StackPolicyReport {
+	report := types.StackPolicyReport{
+		Stacks: []types.StackPolicyResult{},
+	}
+	for _, stack := range stacks {
+		result := types.StackValidationResult{Valid: true}
+		if engine != nil {
+			result = engine.Check(stack.Spec)
+		}
+		report.Stacks = append(report.Stacks, types.StackPolicyResult{
+			ID:        stack.ID,
+			Name:      stack.Spec.Annotations.Name,
+			Compliant: result.Valid,
+			Errors:    result.Errors,
+			Warnings:  result.Warnings,
+		})
+	}
+	return report
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const testRules = `
+rules:
+- name: resource-limits
+  description: services must set resource limits
+  path: Services[*].TaskTemplate.Resources.Limits
+  required: true
+- name: corporate-registry
+  path: Services[*].TaskTemplate.ContainerSpec.Image
+  patterns: ["registry.corp/*"]
+- name: no-privileges
+  mode: warn
+  path: Services[*].TaskTemplate.ContainerSpec.Privileges
+  forbidden: true
+- name: team-label
+  path: Annotations.Labels[team]
+  required: true
+`
+
+func loadTestRules(t *testing.T) *Engine {
+	dir, err := ioutil.TempDir("", "policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testRules), 0600))
+	engine, err := Load(path)
+	require.NoError(t, err)
+	return engine
+}
+
+func getTestSpec() types.StackSpec {
+	return types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name:   "app",
+			Labels: map[string]string{"team": "platform"},
+		},
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{Name: "web"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image: "registry.corp/web/nginx:1.17",
+					},
+					Resources: &swarm.ResourceRequirements{
+						Limits: &swarm.Resources{MemoryBytes: 1 << 30},
+					},
+				},
+			},
+		},
+	}
+}
+
+func fields(issues []types.StackValidationIssue) []string {
+	result := []string{}
+	for _, issue := range issues {
+		result = append(result, issue.Field)
+	}
+	return result
+}
+
+func TestCheck(t *testing.T) {
+	require := require.New(t)
+	engine := loadTestRules(t)
+
+	result := engine.Check(getTestSpec())
+	require.True(result.Valid)
+	require.Empty(result.Errors)
+	require.Empty(result.Warnings)
+
+	spec := getTestSpec()
+	spec.Annotations.Labels = nil
+	spec.Services = append(spec.Services, swarm.ServiceSpec{
+		Annotations: swarm.Annotations{Name: "db"},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image:      "postgres:11",
+				Privileges: &swarm.Privileges{SELinuxContext: &swarm.SELinuxContext{Disable: true}},
+			},
+		},
+	})
+
+	result = engine.Check(spec)
+	require.False(result.Valid)
+	require.Equal([]string{
+		"Services[1].TaskTemplate.Resources.Limits",
+		"Services[1].TaskTemplate.ContainerSpec.Image",
+		"Annotations.Labels[team]",
+	}, fields(result.Errors))
+	require.Equal("policy resource-limits: services must set resource limits", result.Errors[0].Message)
+	require.Contains(result.Errors[1].Message, "'postgres:11' does not match registry.corp/*")
+	require.Equal([]string{
+		"Services[1].TaskTemplate.ContainerSpec.Privileges",
+	}, fields(result.Warnings))
+}
+
+func TestSelectors(t *testing.T) {
+	require := require.New(t)
+	engine, err := NewEngine([]Rule{
+		{Name: "by-name", Path: "Services[web].TaskTemplate.ContainerSpec.Image", Patterns: []string{"*:1.17"}},
+		{Name: "by-index", Path: "Services[0].Mode.Global", Forbidden: true},
+		{Name: "missing", Path: "Services[cache].Name", Required: true},
+	})
+	require.NoError(err)
+
+	spec := getTestSpec()
+	spec.Services[0].Mode.Global = &swarm.GlobalService{}
+	result := engine.Check(spec)
+	require.Equal([]string{
+		"Services[cache].Name",
+	}, fields(result.Errors))
+
+	// An empty object is not set
+	spec.Services[0].Mode.Global = nil
+	spec.Services[0].TaskTemplate.ContainerSpec.Image = "registry.corp/web/nginx:1.16"
+	result = engine.Check(spec)
+	require.Equal([]string{
+		"Services[0].TaskTemplate.ContainerSpec.Image",
+		"Services[cache].Name",
+	}, fields(result.Errors))
+}
+
+func TestNewEngineInvalid(t *testing.T) {
+	for _, rule := range []Rule{
+		{Path: "Services", Required: true},
+		{Name: "a", Path: "Services"},
+		{Name: "a", Path: "Services", Required: true, Mode: "audit"},
+		{Name: "a", Path: "Services", Forbidden: true, Required: true},
+		{Name: "a", Path: "Services[", Required: true},
+		{Name: "a", Path: "Services..Name", Required: true},
+	} {
+		_, err := NewEngine([]Rule{rule})
+		require.Error(t, err, "%v", rule)
+	}
+}
+
+func TestReport(t *testing.T) {
+	require := require.New(t)
+	engine := loadTestRules(t)
+
+	compliant := types.Stack{ID: "1", Spec: getTestSpec()}
+	violating := types.Stack{ID: "2", Spec: getTestSpec()}
+	violating.Spec.Annotations.Name = "other"
+	violating.Spec.Annotations.Labels = nil
+
+	report := Report(engine, []types.Stack{compliant, violating})
+	require.Len(report.Stacks, 2)
+	require.True(report.Stacks[0].Compliant)
+	require.Equal("other", report.Stacks[1].Name)
+	require.False(report.Stacks[1].Compliant)
+	require.Equal([]string{"Annotations.Labels[team]"}, fields(report.Stacks[1].Errors))
+
+	report = Report(nil, []types.Stack{violating})
+	require.True(report.Stacks[0].Compliant)
+}
@@ -0,0 +1,7 @@
+package query
+
+// The `query` package filters, orders and paginates stack listings. It is
+// shared by every implementation of a stack listing so that the same
+// types.StackListOptions produce the same results regardless of whether the
+// stacks come from the swarmkit store, a fake, or several orchestrator
+// backends at once.
@@ -0,0 +1,179 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+var acceptedFilters = map[string]bool{
+	"name":         true,
+	"id":           true,
+	"label":        true,
+	"orchestrator": true,
+	"phase":        true,
+	"collection":   true,
+	"cluster":      true,
+}
+
+// position is the place of a stack in a listing. It is what a cursor
+// records, rather than an offset, so that a listing resumes at the right
+// place even when stacks are created or deleted between two pages.
+type position struct {
+	SortBy     types.StackSortField
+	Descending bool       `json:",omitempty"`
+	Name       string     `json:",omitempty"`
+	Time       *time.Time `json:",omitempty"`
+	ID         string
+}
+
+// Stacks returns the stacks matching the filters of options, ordered and
+// paginated as requested. The stacks slice is left untouched.
+func Stacks(stacks []types.Stack, options types.StackListOptions) ([]types.Stack, error) {
+	if err := options.Filters.Validate(acceptedFilters); err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	sortBy, err := sortField(options.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	if options.Limit < 0 {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid limit %d", options.Limit))
+	}
+
+	var after *position
+	if options.Cursor != "" {
+		after, err = decodeCursor(options.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.SortBy != sortBy || after.Descending != options.Descending {
+			return nil, errdefs.InvalidParameter(fmt.Errorf("cursor was not issued for this order"))
+		}
+	}
+
+	result := []types.Stack{}
+	for _, stack := range stacks {
+		if !Match(stack, options.Filters) {
+			continue
+		}
+		if after != nil && compare(positionOf(stack, sortBy, options.Descending), *after) <= 0 {
+			continue
+		}
+		result = append(result, stack)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return compare(positionOf(result[i], sortBy, options.Descending), positionOf(result[j], sortBy, options.Descending)) < 0
+	})
+
+	if options.Limit > 0 && len(result) > options.Limit {
+		result = result[:options.Limit]
+	}
+	return result, nil
+}
+
+// Match reports whether stack satisfies all of the filters.
+func Match(stack types.Stack, filter filters.Args) bool {
+	orchestrator := stack.Orchestrator
+	if orchestrator == "" {
+		orchestrator = types.OrchestratorSwarm
+	}
+	return filter.Match("name", stack.Spec.Annotations.Name) &&
+		filter.FuzzyMatch("id", stack.ID) &&
+		filter.MatchKVList("label", stack.Spec.Annotations.Labels) &&
+		filter.ExactMatch("orchestrator", string(orchestrator)) &&
+		filter.ExactMatch("phase", string(stack.Status.Phase)) &&
+		filter.ExactMatch("collection", stack.Spec.Collection) &&
+		filter.ExactMatch("cluster", stack.Cluster)
+}
+
+// NextCursor returns the cursor to list the page following page, which was
+// obtained with options, or an empty string if page is the last one.
+func NextCursor(page []types.Stack, options types.StackListOptions) string {
+	if options.Limit <= 0 || len(page) < options.Limit {
+		return ""
+	}
+	sortBy, err := sortField(options.SortBy)
+	if err != nil {
+		return ""
+	}
+	last := positionOf(page[len(page)-1], sortBy, options.Descending)
+	encoded, err := json.Marshal(last)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func sortField(sortBy types.StackSortField) (types.StackSortField, error) {
+	switch sortBy {
+	case "":
+		return types.StackSortName, nil
+	case types.StackSortName, types.StackSortCreated, types.StackSortUpdated:
+		return sortBy, nil
+	default:
+		return "", errdefs.InvalidParameter(fmt.Errorf("invalid sort field %q", sortBy))
+	}
+}
+
+func decodeCursor(cursor string) (*position, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid cursor %q", cursor))
+	}
+	var after position
+	if err := json.Unmarshal(decoded, &after); err != nil {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid cursor %q", cursor))
+	}
+	return &after, nil
+}
+
+func positionOf(stack types.Stack, sortBy types.StackSortField, descending bool) position {
+	p := position{
+		SortBy:     sortBy,
+		Descending: descending,
+		ID:         stack.ID,
+	}
+	switch sortBy {
+	case types.StackSortCreated:
+		created := stack.CreatedAt
+		p.Time = &created
+	case types.StackSortUpdated:
+		updated := stack.UpdatedAt
+		p.Time = &updated
+	default:
+		p.Name = stack.Spec.Annotations.Name
+	}
+	return p
+}
+
+// compare returns a negative number when a is listed before b, a positive
+// number when it is listed after b, and zero when both are the same stack.
+// Stacks with the same sort key are ordered by ID.
+func compare(a, b position) int {
+	result := 0
+	switch {
+	case a.Time != nil && b.Time != nil && a.Time.Before(*b.Time):
+		result = -1
+	case a.Time != nil && b.Time != nil && a.Time.After(*b.Time):
+		result = 1
+	default:
+		result = strings.Compare(a.Name, b.Name)
+	}
+	if result == 0 {
+		result = strings.Compare(a.ID, b.ID)
+	}
+	if a.Descending {
+		return -result
+	}
+	return result
+}
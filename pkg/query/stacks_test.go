@@ -0,0 +1,144 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestStacks() []types.Stack {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	stack := func(id, name string, age int, labels map[string]string) types.Stack {
+		return types.Stack{
+			ID: id,
+			Meta: swarm.Meta{
+				CreatedAt: created.Add(time.Duration(age) * time.Hour),
+				UpdatedAt: created.Add(time.Duration(10-age) * time.Hour),
+			},
+			Spec: types.StackSpec{
+				Annotations: swarm.Annotations{
+					Name:   name,
+					Labels: labels,
+				},
+			},
+		}
+	}
+	stacks := []types.Stack{
+		stack("id4", "web", 3, map[string]string{"tier": "front"}),
+		stack("id1", "db", 1, map[string]string{"tier": "back"}),
+		stack("id3", "cache", 2, nil),
+		stack("id2", "web", 0, nil),
+	}
+	stacks[2].Orchestrator = types.OrchestratorKubernetes
+	stacks[2].Spec.Collection = "shared"
+	stacks[3].Cluster = "east"
+	stacks[1].Status.Phase = "failed"
+	return stacks
+}
+
+func ids(stacks []types.Stack) []string {
+	result := []string{}
+	for _, stack := range stacks {
+		result = append(result, stack.ID)
+	}
+	return result
+}
+
+func TestStacksSort(t *testing.T) {
+	require := require.New(t)
+	stacks := getTestStacks()
+
+	result, err := Stacks(stacks, types.StackListOptions{})
+	require.NoError(err)
+	require.Equal([]string{"id3", "id1", "id2", "id4"}, ids(result))
+
+	result, err = Stacks(stacks, types.StackListOptions{Descending: true})
+	require.NoError(err)
+	require.Equal([]string{"id4", "id2", "id1", "id3"}, ids(result))
+
+	result, err = Stacks(stacks, types.StackListOptions{SortBy: types.StackSortCreated})
+	require.NoError(err)
+	require.Equal([]string{"id2", "id1", "id3", "id4"}, ids(result))
+
+	result, err = Stacks(stacks, types.StackListOptions{SortBy: types.StackSortUpdated})
+	require.NoError(err)
+	require.Equal([]string{"id4", "id3", "id1", "id2"}, ids(result))
+
+	// the original slice is left untouched
+	require.Equal(getTestStacks(), stacks)
+
+	_, err = Stacks(stacks, types.StackListOptions{SortBy: "size"})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
+func TestStacksFilters(t *testing.T) {
+	require := require.New(t)
+	stacks := getTestStacks()
+
+	for _, tc := range []struct {
+		key, value string
+		expected   []string
+	}{
+		{"name", "web", []string{"id2", "id4"}},
+		{"id", "id3", []string{"id3"}},
+		{"label", "tier", []string{"id1", "id4"}},
+		{"label", "tier=back", []string{"id1"}},
+		{"orchestrator", types.OrchestratorSwarm, []string{"id1", "id2", "id4"}},
+		{"orchestrator", types.OrchestratorKubernetes, []string{"id3"}},
+		{"phase", "failed", []string{"id1"}},
+		{"collection", "shared", []string{"id3"}},
+		{"cluster", "east", []string{"id2"}},
+	} {
+		result, err := Stacks(stacks, types.StackListOptions{
+			Filters: filters.NewArgs(filters.Arg(tc.key, tc.value)),
+		})
+		require.NoError(err)
+		require.Equal(tc.expected, ids(result), "%s=%s", tc.key, tc.value)
+	}
+
+	_, err := Stacks(stacks, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("size", "big")),
+	})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
+func TestStacksPagination(t *testing.T) {
+	require := require.New(t)
+	stacks := getTestStacks()
+	options := types.StackListOptions{
+		SortBy: types.StackSortCreated,
+		Limit:  3,
+	}
+
+	page, err := Stacks(stacks, options)
+	require.NoError(err)
+	require.Equal([]string{"id2", "id1", "id3"}, ids(page))
+
+	options.Cursor = NextCursor(page, options)
+	require.NotEmpty(options.Cursor)
+
+	// Stacks deleted or created before the cursor do not shift the next page
+	stacks = append(stacks[:1], stacks[2:]...)
+	page, err = Stacks(stacks, options)
+	require.NoError(err)
+	require.Equal([]string{"id4"}, ids(page))
+	require.Empty(NextCursor(page, options))
+
+	// A cursor only applies to the order it was issued for
+	options.Descending = true
+	_, err = Stacks(stacks, options)
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	_, err = Stacks(stacks, types.StackListOptions{Cursor: "garbage!"})
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+}
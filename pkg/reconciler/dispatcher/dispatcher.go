@@ -45,6 +45,11 @@ type dispatcher struct {
 	pendingSecrets  map[string]*interfaces.ReconcileResource
 	pendingConfigs  map[string]*interfaces.ReconcileResource
 	pendingServices map[string]*interfaces.ReconcileResource
+
+	// wake is signalled when an object is notified, so that the objects
+	// notified while waiting for events, such as the stacks reconciled
+	// again to go on with their rollouts, are reconciled
+	wake chan struct{}
 }
 
 // New creates and returns the default Dispatcher object, which will
@@ -63,6 +68,7 @@ func newDispatcher(r reconciler.Reconciler, register notifier.Register) *dispatc
 		pendingSecrets:  map[string]*interfaces.ReconcileResource{},
 		pendingConfigs:  map[string]*interfaces.ReconcileResource{},
 		pendingServices: map[string]*interfaces.ReconcileResource{},
+		wake:            make(chan struct{}, 1),
 	}
 	register.Register(m)
 	return m
@@ -102,6 +108,11 @@ func (d *dispatcher) Notify(request *interfaces.ReconcileResource) {
 	case interfaces.ReconcileService:
 		d.pendingServices[id] = request
 	}
+	// there is no need to signal wake again if it was not read yet
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
 }
 
 // HandleEvents takes a channel that issues events, and processes those events
@@ -141,15 +152,20 @@ func (d *dispatcher) HandleEvents(eventC chan interface{}) error {
 
 	// the whole thing  goes in a for loop
 	for {
-		// initial state: waiting for a channel read
-		ev, ok := <-eventC
-		if !ok {
-			// if the channel is closed, return
-			return nil
-		}
-		err := d.resolveMessage(ev)
-		if err != nil {
-			logrus.Error(err)
+		// initial state: waiting for a channel read, or for an object to
+		// be notified
+		var err error
+		select {
+		case ev, ok := <-eventC:
+			if !ok {
+				// if the channel is closed, return
+				return nil
+			}
+			err = d.resolveMessage(ev)
+			if err != nil {
+				logrus.Error(err)
+			}
+		case <-d.wake:
 		}
 		// next state: reading events
 	readingEvents:
@@ -156,6 +156,24 @@ var _ = Describe("Dispatcher", func() {
 			// the mock will error if we try to call it more than once
 		})
 
+		It("should reconcile objects notified while waiting for events", func() {
+			eventC := make(chan interface{})
+			request, _ := NewRequest(interfaces.ReconcileStack, "someID")
+			mockReconciler.EXPECT().Reconcile(
+				gomock.Eq(request),
+			).Do(func(*interfaces.ReconcileResource) {
+				close(eventC)
+			}).Return(nil)
+
+			done := make(chan error)
+			go func() {
+				done <- d.HandleEvents(eventC)
+			}()
+			d.Notify(request)
+
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
 		It("should process events in order of Stacks, Networks, Secrets, Configs, and Services", func() {
 			// first, create a channel
 			// TODO(dperny): 32 is just a random choice, pick something better
@@ -1,9 +1,12 @@
 package reconciler
 
 import (
+	"sort"
+
 	"github.com/docker/docker/api/types/filters"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
 )
 
 /**
@@ -141,95 +144,10 @@ func reconcileResource(current interfaces.SnapshotStack, plugin algorithmPlugin)
 	// Docker API call as the only reconciler calling.
 	// Failures imply a policy based retry of reconciliation
 
-	// MARK PHASE 1 - Initially mark as DELETE all previous resources
-	//                unless requested to SKIP a resource reconciliation
-	//
-	for _, resource := range plugin.getGoalResources() {
-		if resource.Mark != interfaces.ReconcileSkip {
-			resource.Mark = interfaces.ReconcileDelete
-		}
-	}
-
-	//
-	// MARK PHASE 2 - Mark COMPARE any DELETE Resources when
-	//                a current specification is found
-	//              - Mark new specifications for CREATE
-	//
-	for _, specName := range plugin.getSpecifiedResourceNames() {
-		resource := plugin.getGoalResource(specName)
-		if resource != nil {
-			if resource.Mark == interfaces.ReconcileDelete {
-				resource.Mark = interfaces.ReconcileCompare
-			}
-		} else {
-			added := plugin.addCreateResourceGoal(specName)
-			added.Mark = interfaces.ReconcileCreate
-
-		}
-	}
-
-	// At this point, the goal resources are marked one of the following:
-	// SKIP, DELETE, COMPARE, CREATE
-
-	//
-	// MARK PHASE 3 - Query for all active Resources labelled as belonging
-	//                to the Stack
-	//		- Commonly, active Resources will match COMPARE marks
-	//              - Update stale Resource ID's
-	//              - Perhaps, Active Resource matches an above CREATE
-	//              - DELETE Active Resource without specification and
-	//                not previously recorded
-	//              - Compare active specification to Stack specification
-	//                and mark SAME xor UPDATE
-	//
-	activeResources, err := plugin.getActiveResources()
-	if err != nil {
+	if err := markResources(plugin); err != nil {
 		return current, err
 	}
 
-	for _, activeResourceWrapper := range activeResources {
-		activeResource := activeResourceWrapper.getSnapshot()
-		resource := plugin.getGoalResource(activeResource.Name)
-		if resource != nil {
-
-			if resource.Mark == interfaces.ReconcileCreate {
-				// MATCHING CREATE -  Implies another MUTATOR
-				// created the to-be-created resource.
-				//
-				// The implementation in PHASE 4 will clean
-				// this up
-				resource.Mark = interfaces.ReconcileCompare
-				resource.ID = activeResource.ID
-				resource.Meta = activeResource.Meta
-
-			} else if resource.Mark == interfaces.ReconcileDelete {
-				// Name matches previous goal, spec missing
-				resource.ID = activeResource.ID
-				resource.Meta = activeResource.Meta
-
-			} else if resource.Mark == interfaces.ReconcileCompare {
-				if plugin.hasSameConfiguration(*resource, activeResourceWrapper) {
-					resource.Mark = interfaces.ReconcileSame
-				} else {
-					resource.Mark = interfaces.ReconcileUpdate
-				}
-				resource.ID = activeResource.ID
-				resource.Meta = activeResource.Meta
-			}
-
-		} else {
-
-			// MISMATCHING DELETE -  Implies another MUTATOR
-			// Name does not match previous goal, spec missing
-			//
-			// The resource is an orphan to be removed
-			// or it is improperly associated with the stack
-			added := plugin.addRemoveResourceGoal(activeResourceWrapper)
-			added.Mark = interfaces.ReconcileDelete
-
-		}
-	}
-
 	// At this point, the goal resources are marked one of the following:
 	// SKIP, DELETE, COMPARE, CREATE, UPDATE, SAME
 	//
@@ -285,7 +203,13 @@ func reconcileResource(current interfaces.SnapshotStack, plugin algorithmPlugin)
 		}
 	}
 
+	rolling, isRolling := plugin.(rollingPlugin)
 	if witnessedAllSame {
+		// A rollout in progress goes on once the resources are up to
+		// date, as their health is still to be checked
+		if isRolling && rolling.isRollingOut(current) {
+			return rolling.rollOut(current, nil)
+		}
 		return current, nil
 	}
 	// GOAL CONTRACT
@@ -305,6 +229,12 @@ func reconcileResource(current interfaces.SnapshotStack, plugin algorithmPlugin)
 	// At this point, the GOAL resources are marked one of the following:
 	// SKIP, DELETE, CREATE, UPDATE, SAME
 
+	// Plugins rolling out their updates progressively update their
+	// resources once the other mutations are done
+	isRollingOut := isRolling && rolling.isRollingOut(current)
+	isRolling = isRolling && rolling.isRolling()
+	updates := []*interfaces.ReconcileResource{}
+
 	var mutationError error
 	for _, resource := range plugin.getGoalResources() {
 		if resource.Mark == interfaces.ReconcileSkip {
@@ -321,6 +251,10 @@ func reconcileResource(current interfaces.SnapshotStack, plugin algorithmPlugin)
 		} else if resource.Mark == interfaces.ReconcileDelete {
 			mutationError = plugin.deleteResource(resource)
 		} else if resource.Mark == interfaces.ReconcileUpdate {
+			if isRolling {
+				updates = append(updates, resource)
+				continue
+			}
 			mutationError = plugin.updateResource(*resource)
 		}
 		if mutationError == nil {
@@ -334,5 +268,137 @@ func reconcileResource(current interfaces.SnapshotStack, plugin algorithmPlugin)
 		}
 	}
 
+	if len(updates) > 0 || isRollingOut {
+		return rolling.rollOut(current, updates)
+	}
 	return current, nil
 }
+
+// markResources implements the MARK phases of the QUERY of reconcileResource,
+// which only read the state of the resources
+// nolint: gocyclo
+func markResources(plugin algorithmPlugin) error {
+
+	// MARK PHASE 1 - Initially mark as DELETE all previous resources
+	//                unless requested to SKIP a resource reconciliation
+	//
+	for _, resource := range plugin.getGoalResources() {
+		if resource.Mark != interfaces.ReconcileSkip {
+			resource.Mark = interfaces.ReconcileDelete
+		}
+	}
+
+	//
+	// MARK PHASE 2 - Mark COMPARE any DELETE Resources when
+	//                a current specification is found
+	//              - Mark new specifications for CREATE
+	//
+	for _, specName := range plugin.getSpecifiedResourceNames() {
+		resource := plugin.getGoalResource(specName)
+		if resource != nil {
+			if resource.Mark == interfaces.ReconcileDelete {
+				resource.Mark = interfaces.ReconcileCompare
+			}
+		} else {
+			added := plugin.addCreateResourceGoal(specName)
+			added.Mark = interfaces.ReconcileCreate
+
+		}
+	}
+
+	// At this point, the goal resources are marked one of the following:
+	// SKIP, DELETE, COMPARE, CREATE
+
+	//
+	// MARK PHASE 3 - Query for all active Resources labelled as belonging
+	//                to the Stack
+	//		- Commonly, active Resources will match COMPARE marks
+	//              - Update stale Resource ID's
+	//              - Perhaps, Active Resource matches an above CREATE
+	//              - DELETE Active Resource without specification and
+	//                not previously recorded
+	//              - Compare active specification to Stack specification
+	//                and mark SAME xor UPDATE
+	//
+	activeResources, err := plugin.getActiveResources()
+	if err != nil {
+		return err
+	}
+
+	for _, activeResourceWrapper := range activeResources {
+		activeResource := activeResourceWrapper.getSnapshot()
+		resource := plugin.getGoalResource(activeResource.Name)
+		if resource != nil {
+
+			if resource.Mark == interfaces.ReconcileCreate {
+				// MATCHING CREATE -  Implies another MUTATOR
+				// created the to-be-created resource.
+				//
+				// The implementation in PHASE 4 will clean
+				// this up
+				resource.Mark = interfaces.ReconcileCompare
+				resource.ID = activeResource.ID
+				resource.Meta = activeResource.Meta
+
+			} else if resource.Mark == interfaces.ReconcileDelete {
+				// Name matches previous goal, spec missing
+				resource.ID = activeResource.ID
+				resource.Meta = activeResource.Meta
+
+			} else if resource.Mark == interfaces.ReconcileCompare {
+				if plugin.hasSameConfiguration(*resource, activeResourceWrapper) {
+					resource.Mark = interfaces.ReconcileSame
+				} else {
+					resource.Mark = interfaces.ReconcileUpdate
+				}
+				resource.ID = activeResource.ID
+				resource.Meta = activeResource.Meta
+			}
+
+		} else {
+
+			// MISMATCHING DELETE -  Implies another MUTATOR
+			// Name does not match previous goal, spec missing
+			//
+			// The resource is an orphan to be removed
+			// or it is improperly associated with the stack
+			added := plugin.addRemoveResourceGoal(activeResourceWrapper)
+			added.Mark = interfaces.ReconcileDelete
+
+		}
+	}
+	return nil
+}
+
+// driftResources returns the changes reconcileResource would make to the
+// resources of plugin, without making them. A resource marked for re-CREATE
+// is reported as created.
+func driftResources(plugin algorithmPlugin) ([]types.StackDrift, error) {
+	if err := markResources(plugin); err != nil {
+		return nil, err
+	}
+
+	drift := []types.StackDrift{}
+	for _, resource := range plugin.getGoalResources() {
+		var action types.StackDriftAction
+		switch resource.Mark {
+		case interfaces.ReconcileCreate, interfaces.ReconcileCompare:
+			action = types.StackDriftCreate
+		case interfaces.ReconcileUpdate:
+			action = types.StackDriftUpdate
+		case interfaces.ReconcileDelete:
+			action = types.StackDriftDelete
+		default:
+			continue
+		}
+		drift = append(drift, types.StackDrift{
+			Kind:   plugin.getKind(),
+			Name:   resource.Name,
+			Action: action,
+		})
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Name < drift[j].Name
+	})
+	return drift, nil
+}
@@ -22,7 +22,7 @@ var _ = Describe("Initial Algorithm Support - Service", func() {
 	BeforeEach(func() {
 		var resp1, resp2 *dockerTypes.ServiceCreateResponse
 		cli = fakes.NewFakeReconcilerClient()
-		serviceInit = newInitializationSupportService(cli)
+		serviceInit = newInitializationSupportService(cli, nil)
 		// testing two services one with a stacks label and
 		// one without skipping zero-th element
 		resources = fakes.GenerateServiceFixtures(3, "", "InitialService")
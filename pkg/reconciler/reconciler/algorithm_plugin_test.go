@@ -22,7 +22,7 @@ var _ = Describe("Algorithm Plugin for Service - Stack Request", func() {
 	)
 	BeforeEach(func() {
 		input.cli = fakes.NewFakeReconcilerClient()
-		serviceInit = newInitializationSupportService(input.cli)
+		serviceInit = newInitializationSupportService(input.cli, nil)
 		stack = fakes.GetTestStack("stack12")
 		input.stack = &stack
 		input.algorithmInit = serviceInit
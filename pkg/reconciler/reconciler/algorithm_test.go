@@ -87,17 +87,23 @@ func changeSpecifiedResource(init initializationSupport, snapshot interfaces.Sna
 	Expect(err).ToNot(HaveOccurred())
 	resource.ID = active.getSnapshot().ID
 	resource.Meta = active.getSnapshot().Meta
+	changeConfig(init, resource.Config)
+	err1 := plugin.updateResource(*resource)
+	return err1
+}
+
+// changeConfig changes the configuration of a resource, in a way which
+// requires it to be updated
+func changeConfig(init initializationSupport, config interface{}) {
 	if init.getKind() == interfaces.ReconcileService {
-		resource.Config.(*swarm.ServiceSpec).UpdateConfig = &swarm.UpdateConfig{}
+		config.(*swarm.ServiceSpec).UpdateConfig = &swarm.UpdateConfig{Parallelism: 2}
 	} else if init.getKind() == interfaces.ReconcileConfig {
-		resource.Config.(*swarm.ConfigSpec).Templating = &swarm.Driver{}
+		config.(*swarm.ConfigSpec).Templating = &swarm.Driver{Name: "golang"}
 	} else if init.getKind() == interfaces.ReconcileSecret {
-		resource.Config.(*swarm.SecretSpec).Driver = &swarm.Driver{}
+		config.(*swarm.SecretSpec).Driver = &swarm.Driver{Name: "vault"}
 	} else if init.getKind() == interfaces.ReconcileNetwork {
-		resource.Config.(*dockerTypes.NetworkCreateRequest).NetworkCreate.Driver = "driver"
+		config.(*dockerTypes.NetworkCreateRequest).NetworkCreate.Driver = "driver"
 	}
-	err1 := plugin.updateResource(*resource)
-	return err1
 }
 
 func mutateSnapshot(init initializationSupport, snapshot *interfaces.SnapshotStack) {
@@ -134,6 +140,19 @@ func mutateStackSpec(init initializationSupport, stackSpec *types.StackSpec) {
 	}
 }
 
+// changeStackSpec changes the spec of the first resource, in a way which
+// requires it to be updated. The labels of secrets and configs are changed,
+// as other changes of their spec create a new version of them.
+func changeStackSpec(init initializationSupport, stackSpec *types.StackSpec) {
+	if init.getKind() == interfaces.ReconcileService {
+		changeConfig(init, &stackSpec.Services[0])
+	} else if init.getKind() == interfaces.ReconcileConfig {
+		stackSpec.Configs[0].Annotations.Labels["changed"] = "true"
+	} else if init.getKind() == interfaces.ReconcileSecret {
+		stackSpec.Secrets[0].Annotations.Labels["changed"] = "true"
+	}
+}
+
 type Stuff struct {
 	cli                            *fakes.FakeReconcilerClient
 	pluginInit                     initializationSupport
@@ -308,6 +327,8 @@ func algorithmTest(stuff *Stuff) {
 			var altered interfaces.SnapshotStack
 			BeforeEach(func() {
 				mutateStackSpec(stuff.pluginInit, &stuff.plainStackSpecUpdateErr)
+				// The remaining resource is updated, which fails
+				changeStackSpec(stuff.pluginInit, &stuff.plainStackSpecUpdateErr)
 				err1 = stuff.cli.UpdateStack(stuff.stackID, stuff.plainStackSpecUpdateErr, current.Meta.Version.Index)
 				altered, _ = stuff.cli.FakeStackStore.GetSnapshotStack(stuff.stackID)
 
@@ -458,7 +479,7 @@ var _ = Describe("Algorithm Test - Services", func() {
 	)
 	BeforeEach(func() {
 		makeStuff(&stuff)
-		stuff.pluginInit = newInitializationSupportService(stuff.cli)
+		stuff.pluginInit = newInitializationSupportService(stuff.cli, nil)
 		stuff.request = &interfaces.ReconcileResource{
 			SnapshotResource: interfaces.SnapshotResource{
 				ID: stuff.stackID,
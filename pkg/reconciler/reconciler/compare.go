@@ -0,0 +1,231 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// The defaults which swarm fills in on the services it stores
+const (
+	defaultUpdateParallelism = 1
+	defaultUpdateMonitor     = 5 * time.Second
+	defaultRestartDelay      = 5 * time.Second
+	defaultIsolation         = "default"
+)
+
+// sameServiceConfiguration compares the desired spec of a service to the
+// spec swarm stores for it. Both are normalised first, so that the defaults
+// swarm fills in, nil and empty values, and the order of lists which swarm
+// does not depend on do not make them differ.
+func sameServiceConfiguration(desired, actual swarm.ServiceSpec) bool {
+	return compareMapsIgnoreStackLabel(desired.Annotations.Labels, actual.Annotations.Labels) &&
+		equivalent(normalizeServiceSpec(desired), normalizeServiceSpec(actual))
+}
+
+// sameSecretConfiguration compares the desired spec of a secret to the spec
+// swarm stores for it. Swarm does not return the data of secrets, which is
+// then not compared.
+func sameSecretConfiguration(desired, actual swarm.SecretSpec) bool {
+	same := compareMapsIgnoreStackLabel(desired.Annotations.Labels, actual.Annotations.Labels)
+	desired.Annotations.Labels, actual.Annotations.Labels = nil, nil
+	if len(actual.Data) == 0 {
+		desired.Data = nil
+	}
+	return same && equivalent(desired, actual)
+}
+
+// sameConfigConfiguration compares the desired spec of a config to the spec
+// swarm stores for it
+func sameConfigConfiguration(desired, actual swarm.ConfigSpec) bool {
+	same := compareMapsIgnoreStackLabel(desired.Annotations.Labels, actual.Annotations.Labels)
+	desired.Annotations.Labels, actual.Annotations.Labels = nil, nil
+	return same && equivalent(desired, actual)
+}
+
+// sameNetworkConfiguration compares the desired spec of a network to the
+// spec of the network docker reports. Only the fields which docker keeps as
+// requested are compared, as it fills in the driver, options and IPAM
+// configuration the request leaves out.
+func sameNetworkConfiguration(desired dockerTypes.NetworkCreate, actual dockerTypes.NetworkResource) bool {
+	return compareMapsIgnoreStackLabel(desired.Labels, actual.Labels) &&
+		(desired.Driver == "" || desired.Driver == actual.Driver) &&
+		desired.Internal == actual.Internal &&
+		desired.Attachable == actual.Attachable &&
+		desired.Ingress == actual.Ingress &&
+		desired.EnableIPv6 == actual.EnableIPv6
+}
+
+// normalizeServiceSpec returns a copy of spec with the defaults of swarm
+// filled in, its deprecated fields moved to their current place, and its
+// unordered lists sorted. Its labels are left out, as they are compared
+// separately.
+// nolint: gocyclo
+func normalizeServiceSpec(spec swarm.ServiceSpec) swarm.ServiceSpec {
+	if err := deepCopy(&spec, &spec); err != nil {
+		return spec
+	}
+	spec.Annotations.Labels = nil
+
+	if spec.Mode.Replicated == nil && spec.Mode.Global == nil {
+		spec.Mode.Replicated = &swarm.ReplicatedService{}
+	}
+	if spec.Mode.Replicated != nil && spec.Mode.Replicated.Replicas == nil {
+		replicas := uint64(1)
+		spec.Mode.Replicated.Replicas = &replicas
+	}
+
+	spec.UpdateConfig = normalizeUpdateConfig(spec.UpdateConfig)
+	spec.RollbackConfig = normalizeUpdateConfig(spec.RollbackConfig)
+
+	if spec.TaskTemplate.RestartPolicy == nil {
+		spec.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{}
+	}
+	if spec.TaskTemplate.RestartPolicy.Condition == "" {
+		spec.TaskTemplate.RestartPolicy.Condition = swarm.RestartPolicyConditionAny
+	}
+	if spec.TaskTemplate.RestartPolicy.Delay == nil {
+		delay := defaultRestartDelay
+		spec.TaskTemplate.RestartPolicy.Delay = &delay
+	}
+
+	// Swarm moves the networks of a service to its task template
+	if len(spec.TaskTemplate.Networks) == 0 {
+		spec.TaskTemplate.Networks = spec.Networks
+	}
+	spec.Networks = nil
+	sort.SliceStable(spec.TaskTemplate.Networks, func(i, j int) bool {
+		return spec.TaskTemplate.Networks[i].Target < spec.TaskTemplate.Networks[j].Target
+	})
+
+	if spec.EndpointSpec == nil {
+		spec.EndpointSpec = &swarm.EndpointSpec{}
+	}
+	if spec.EndpointSpec.Mode == "" {
+		spec.EndpointSpec.Mode = swarm.ResolutionModeVIP
+	}
+	for i := range spec.EndpointSpec.Ports {
+		port := &spec.EndpointSpec.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = swarm.PortConfigProtocolTCP
+		}
+		if port.PublishMode == "" {
+			port.PublishMode = swarm.PortConfigPublishModeIngress
+		}
+	}
+	sort.SliceStable(spec.EndpointSpec.Ports, func(i, j int) bool {
+		one, two := spec.EndpointSpec.Ports[i], spec.EndpointSpec.Ports[j]
+		if one.TargetPort != two.TargetPort {
+			return one.TargetPort < two.TargetPort
+		}
+		if one.Protocol != two.Protocol {
+			return one.Protocol < two.Protocol
+		}
+		return one.PublishedPort < two.PublishedPort
+	})
+
+	if containerSpec := spec.TaskTemplate.ContainerSpec; containerSpec != nil {
+		if containerSpec.Isolation == defaultIsolation {
+			containerSpec.Isolation = ""
+		}
+		sort.Strings(containerSpec.Env)
+		sort.SliceStable(containerSpec.Mounts, func(i, j int) bool {
+			return containerSpec.Mounts[i].Target < containerSpec.Mounts[j].Target
+		})
+		sort.SliceStable(containerSpec.Secrets, func(i, j int) bool {
+			return containerSpec.Secrets[i].SecretName < containerSpec.Secrets[j].SecretName
+		})
+		sort.SliceStable(containerSpec.Configs, func(i, j int) bool {
+			return containerSpec.Configs[i].ConfigName < containerSpec.Configs[j].ConfigName
+		})
+	}
+	return spec
+}
+
+// normalizeUpdateConfig returns config with the defaults of swarm filled in.
+// A missing config updates one task at a time.
+func normalizeUpdateConfig(config *swarm.UpdateConfig) *swarm.UpdateConfig {
+	if config == nil {
+		config = &swarm.UpdateConfig{Parallelism: defaultUpdateParallelism}
+	}
+	if config.FailureAction == "" {
+		config.FailureAction = swarm.UpdateFailureActionPause
+	}
+	if config.Monitor == 0 {
+		config.Monitor = defaultUpdateMonitor
+	}
+	if config.Order == "" {
+		config.Order = swarm.UpdateOrderStopFirst
+	}
+	return config
+}
+
+// equivalent compares one and two by their JSON representation, in which
+// nil, empty and zero values are all left out
+func equivalent(one, two interface{}) bool {
+	oneTree, oneErr := pruned(one)
+	twoTree, twoErr := pruned(two)
+	return oneErr == nil && twoErr == nil && reflect.DeepEqual(oneTree, twoTree)
+}
+
+func pruned(value interface{}) (interface{}, error) {
+	var tree interface{}
+	if err := deepCopy(value, &tree); err != nil {
+		return nil, err
+	}
+	return prune(tree), nil
+}
+
+// prune removes the empty and zero values from a decoded JSON tree, and
+// returns nil if nothing is left of tree
+func prune(tree interface{}) interface{} {
+	switch value := tree.(type) {
+	case map[string]interface{}:
+		for key, member := range value {
+			if member = prune(member); member == nil {
+				delete(value, key)
+			} else {
+				value[key] = member
+			}
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, member := range value {
+			value[i] = prune(member)
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	case string:
+		if value == "" {
+			return nil
+		}
+	case float64:
+		if value == 0 {
+			return nil
+		}
+	case bool:
+		if !value {
+			return nil
+		}
+	}
+	return tree
+}
+
+// deepCopy copies from into the value pointed to by to, through their JSON
+// representation
+func deepCopy(from, to interface{}) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	reset := reflect.ValueOf(to).Elem()
+	reset.Set(reflect.Zero(reset.Type()))
+	return json.Unmarshal(encoded, to)
+}
@@ -0,0 +1,226 @@
+package reconciler
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// comparison is a case of the conformance suite of the comparators: whether
+// the desired spec of a resource and the spec stored by swarm are the same
+type comparison struct {
+	description string
+	same        bool
+	change      func(desired, actual *swarm.ServiceSpec)
+}
+
+func baseServiceSpec() swarm.ServiceSpec {
+	replicas := uint64(2)
+	return swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name:   "web",
+			Labels: map[string]string{"tier": "front"},
+		},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: "nginx:1.16",
+				Env:   []string{"A=1", "B=2"},
+				Mounts: []mount.Mount{
+					{Type: mount.TypeVolume, Source: "data", Target: "/data"},
+					{Type: mount.TypeBind, Source: "/etc/app", Target: "/etc/app"},
+				},
+			},
+		},
+		Mode: swarm.ServiceMode{
+			Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+		},
+		EndpointSpec: &swarm.EndpointSpec{
+			Ports: []swarm.PortConfig{
+				{TargetPort: 80, PublishedPort: 8080},
+				{TargetPort: 443, PublishedPort: 8443},
+			},
+		},
+	}
+}
+
+// swarmDefaults fills in spec as swarm does when it stores a service
+func swarmDefaults(spec *swarm.ServiceSpec) {
+	monitor := 5 * time.Second
+	delay := 5 * time.Second
+	maxAttempts := uint64(0)
+	spec.Annotations.Labels[types.StackLabel] = "STK_1"
+	spec.UpdateConfig = &swarm.UpdateConfig{
+		Parallelism:   1,
+		FailureAction: swarm.UpdateFailureActionPause,
+		Monitor:       monitor,
+		Order:         swarm.UpdateOrderStopFirst,
+	}
+	spec.RollbackConfig = &swarm.UpdateConfig{
+		Parallelism:   1,
+		FailureAction: swarm.UpdateFailureActionPause,
+		Monitor:       monitor,
+		Order:         swarm.UpdateOrderStopFirst,
+	}
+	spec.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{
+		Condition:   swarm.RestartPolicyConditionAny,
+		Delay:       &delay,
+		MaxAttempts: &maxAttempts,
+	}
+	spec.TaskTemplate.Resources = &swarm.ResourceRequirements{}
+	spec.TaskTemplate.Placement = &swarm.Placement{}
+	spec.TaskTemplate.ContainerSpec.Isolation = "default"
+	spec.EndpointSpec.Mode = swarm.ResolutionModeVIP
+	for i := range spec.EndpointSpec.Ports {
+		spec.EndpointSpec.Ports[i].Protocol = swarm.PortConfigProtocolTCP
+		spec.EndpointSpec.Ports[i].PublishMode = swarm.PortConfigPublishModeIngress
+	}
+}
+
+var serviceComparisons = []comparison{
+	{"a spec is the same as itself", true,
+		func(desired, actual *swarm.ServiceSpec) {}},
+	{"the defaults of swarm are ignored", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			swarmDefaults(actual)
+		}},
+	{"a missing endpoint spec is the default one", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			desired.EndpointSpec, actual.EndpointSpec = nil, &swarm.EndpointSpec{Mode: swarm.ResolutionModeVIP}
+		}},
+	{"a missing mode is one replica", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			one := uint64(1)
+			desired.Mode = swarm.ServiceMode{}
+			actual.Mode.Replicated.Replicas = &one
+		}},
+	{"nil and empty values are equal", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			desired.Annotations.Labels = nil
+			actual.Annotations.Labels = map[string]string{}
+			actual.TaskTemplate.ContainerSpec.Args = []string{}
+			actual.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{}
+			actual.TaskTemplate.LogDriver = &swarm.Driver{}
+		}},
+	{"the order of env, mounts and ports is ignored", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			container := actual.TaskTemplate.ContainerSpec
+			container.Env = []string{container.Env[1], container.Env[0]}
+			container.Mounts = []mount.Mount{container.Mounts[1], container.Mounts[0]}
+			ports := actual.EndpointSpec.Ports
+			actual.EndpointSpec.Ports = []swarm.PortConfig{ports[1], ports[0]}
+		}},
+	{"networks are moved to the task template", true,
+		func(desired, actual *swarm.ServiceSpec) {
+			desired.Networks = []swarm.NetworkAttachmentConfig{{Target: "back"}, {Target: "front"}}
+			actual.TaskTemplate.Networks = []swarm.NetworkAttachmentConfig{{Target: "front"}, {Target: "back"}}
+		}},
+	{"a different image differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			swarmDefaults(actual)
+			actual.TaskTemplate.ContainerSpec.Image = "nginx:1.17"
+		}},
+	{"different replicas differ", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			replicas := uint64(3)
+			actual.Mode.Replicated.Replicas = &replicas
+		}},
+	{"a different env differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			actual.TaskTemplate.ContainerSpec.Env = []string{"A=1", "B=3"}
+		}},
+	{"a different published port differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			actual.EndpointSpec.Ports[0].PublishedPort = 9090
+		}},
+	{"an explicit unlimited update parallelism differs from the default", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			desired.UpdateConfig = &swarm.UpdateConfig{Parallelism: 0}
+			swarmDefaults(actual)
+		}},
+	{"a different endpoint mode differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			desired.EndpointSpec.Mode = swarm.ResolutionModeDNSRR
+		}},
+	{"a different label value differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			actual.Annotations.Labels = map[string]string{"tier": "back"}
+		}},
+	{"an extra label differs", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			actual.Annotations.Labels = map[string]string{"tier": "front", "extra": "label"}
+		}},
+	{"a global service differs from a replicated one", false,
+		func(desired, actual *swarm.ServiceSpec) {
+			actual.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+		}},
+}
+
+var _ = Describe("Comparing specs to the resources stored by swarm", func() {
+	Context("Services", func() {
+		for _, c := range serviceComparisons {
+			c := c
+			It(c.description, func() {
+				desired, actual := baseServiceSpec(), baseServiceSpec()
+				c.change(&desired, &actual)
+				var original swarm.ServiceSpec
+				Expect(deepCopy(&desired, &original)).To(Succeed())
+
+				Expect(sameServiceConfiguration(desired, actual)).To(Equal(c.same))
+				Expect(sameServiceConfiguration(actual, desired)).To(Equal(c.same))
+				// Comparing does not modify the specs
+				Expect(sameSpec(desired, original)).To(BeTrue())
+			})
+		}
+	})
+
+	Context("Secrets", func() {
+		secret := func(data string, driver *swarm.Driver) swarm.SecretSpec {
+			return swarm.SecretSpec{
+				Annotations: swarm.Annotations{Name: "password"},
+				Data:        []byte(data),
+				Driver:      driver,
+			}
+		}
+		It("ignores the data swarm does not return", func() {
+			Expect(sameSecretConfiguration(secret("secret", nil), secret("", nil))).To(BeTrue())
+		})
+		It("treats a missing driver as an empty one", func() {
+			Expect(sameSecretConfiguration(secret("secret", nil), secret("secret", &swarm.Driver{}))).To(BeTrue())
+		})
+		It("compares the data swarm returns", func() {
+			Expect(sameSecretConfiguration(secret("secret", nil), secret("other", nil))).To(BeFalse())
+		})
+		It("compares drivers", func() {
+			Expect(sameSecretConfiguration(secret("", &swarm.Driver{Name: "vault"}), secret("", nil))).To(BeFalse())
+		})
+	})
+
+	Context("Configs", func() {
+		config := func(data string, templating *swarm.Driver, labels map[string]string) swarm.ConfigSpec {
+			return swarm.ConfigSpec{
+				Annotations: swarm.Annotations{Name: "nginx.conf", Labels: labels},
+				Data:        []byte(data),
+				Templating:  templating,
+			}
+		}
+		It("ignores the stack label", func() {
+			Expect(sameConfigConfiguration(
+				config("conf", nil, nil),
+				config("conf", &swarm.Driver{}, map[string]string{types.StackLabel: "STK_1"}))).To(BeTrue())
+		})
+		It("compares data", func() {
+			Expect(sameConfigConfiguration(config("conf", nil, nil), config("other", nil, nil))).To(BeFalse())
+		})
+		It("compares labels", func() {
+			Expect(sameConfigConfiguration(
+				config("conf", nil, map[string]string{"a": "1"}),
+				config("conf", nil, map[string]string{"a": "2"}))).To(BeFalse())
+		})
+	})
+})
@@ -1,8 +1,6 @@
 package reconciler
 
 import (
-	"reflect"
-
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
@@ -28,6 +26,12 @@ type algorithmConfig struct {
 	stackID           string
 	stackSpec         types.StackSpec
 	goals             map[string]*interfaces.ReconcileResource
+	versions          []interfaces.SnapshotVersion
+
+	// inUse are the configs used by the services of the stack, and
+	// deferred is set once the deletion of one of them is deferred
+	inUse    map[string]bool
+	deferred bool
 }
 
 func (a activeConfig) getSnapshot() interfaces.SnapshotResource {
@@ -91,6 +95,7 @@ func newAlgorithmPluginConfig(initConfig initializationConfig, snapshot interfac
 		stackID:              snapshot.ID,
 		stackSpec:            snapshot.CurrentSpec,
 		goals:                map[string]*interfaces.ReconcileResource{},
+		versions:             snapshot.Versions,
 	}
 
 	for _, resource := range snapshot.Configs {
@@ -114,7 +119,8 @@ func (a *algorithmConfig) reconcile(stack interfaces.SnapshotStack) (interfaces.
 
 func (a *algorithmConfig) lookupConfigSpec(name string) *swarm.ConfigSpec {
 	for _, configSpec := range a.stackSpec.Configs {
-		if name == configSpec.Annotations.Name {
+		if name == a.configName(configSpec) {
+			configSpec.Annotations.Name = name
 			return &configSpec
 		}
 	}
@@ -132,7 +138,7 @@ func (a *algorithmConfig) getGoalResources() []*interfaces.ReconcileResource {
 func (a *algorithmConfig) getSpecifiedResourceNames() []string {
 	result := make([]string, 0, len(a.stackSpec.Configs))
 	for _, configSpec := range a.stackSpec.Configs {
-		result = append(result, configSpec.Annotations.Name)
+		result = append(result, a.configName(configSpec))
 	}
 	return result
 }
@@ -166,7 +172,8 @@ func (a *algorithmConfig) addCreateResourceGoal(specName string) *interfaces.Rec
 	configSpec := a.lookupConfigSpec(specName)
 	resource := &interfaces.ReconcileResource{
 		SnapshotResource: interfaces.SnapshotResource{
-			Name: configSpec.Annotations.Name,
+			Name:     configSpec.Annotations.Name,
+			SpecName: a.lookupSpecName(specName),
 		},
 		Config: configSpec,
 		Kind:   a.getKind(),
@@ -198,6 +205,11 @@ func (a *algorithmConfig) storeGoals(previous interfaces.SnapshotStack) (interfa
 	// Simple copy + override
 	updated := previous
 	updated.Configs = goalConfigs
+	hashes := map[string]string{}
+	for _, configSpec := range a.stackSpec.Configs {
+		hashes[resourceName(a.stackSpec, configSpec.Annotations.Name)] = configHash(configSpec)
+	}
+	updated.Versions = storeVersions(previous.Versions, a.getKind(), hashes, a)
 
 	current, err := a.cli.UpdateSnapshotStack(a.stackID,
 		updated,
@@ -212,10 +224,11 @@ func (a *algorithmConfig) storeGoals(previous interfaces.SnapshotStack) (interfa
 func (a *algorithmConfig) hasSameConfiguration(resource interfaces.ReconcileResource, actual activeResource) bool {
 	one := resource.Config.(*swarm.ConfigSpec)
 	two := actual.(activeConfig).config.Spec
-	return one.Annotations.Name == two.Annotations.Name &&
-		compareMapsIgnoreStackLabel(one.Annotations.Labels, two.Annotations.Labels) &&
-		reflect.DeepEqual(one.Data, two.Data) &&
-		reflect.DeepEqual(one.Templating, two.Templating)
+	if isCreateOnly(one.Annotations.Labels) {
+		return true
+	}
+	one = withActualFields(one, &two, ignoredFields(one.Annotations.Labels)).(*swarm.ConfigSpec)
+	return sameConfigConfiguration(*one, two)
 }
 
 func (a *algorithmConfig) createResource(resource *interfaces.ReconcileResource) error {
@@ -233,6 +246,19 @@ func (a *algorithmConfig) createResource(resource *interfaces.ReconcileResource)
 }
 
 func (a *algorithmConfig) deleteResource(resource *interfaces.ReconcileResource) error {
+	// A config still in use is removed once the services were reconciled
+	if a.inUse == nil {
+		inUse, err := resourcesInUse(a.cli, a.stackID, a.getKind())
+		if err != nil {
+			return err
+		}
+		a.inUse = inUse
+	}
+	if a.inUse[resource.ID] || a.inUse[resource.Name] {
+		a.deferred = true
+		return nil
+	}
+
 	err := a.cli.RemoveConfig(resource.ID)
 	// Ignore not found error
 	if err != nil && !errdefs.IsNotFound(err) {
@@ -243,13 +269,52 @@ func (a *algorithmConfig) deleteResource(resource *interfaces.ReconcileResource)
 }
 
 func (a *algorithmConfig) updateResource(resource interfaces.ReconcileResource) error {
+	configSpec := resource.Config.(*swarm.ConfigSpec)
+
+	// The fields managed by other systems keep their current values
+	if paths := ignoredFields(configSpec.Annotations.Labels); len(paths) > 0 {
+		config, err := a.cli.GetConfig(resource.ID)
+		if err != nil {
+			return err
+		}
+		configSpec = withActualFields(configSpec, &config.Spec, paths).(*swarm.ConfigSpec)
+	}
+
 	// the response from UpdateConfig is irrelevant
 	err := a.cli.UpdateConfig(
 		resource.ID,
 		resource.Meta.Version.Index,
-		*resource.Config.(*swarm.ConfigSpec))
+		*configSpec)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (a *algorithmConfig) hasDeferred() bool {
+	return a.deferred
+}
+
+// configName returns the name of the current version of configSpec, or of
+// the version to create if its data changed
+func (a *algorithmConfig) configName(configSpec swarm.ConfigSpec) string {
+	return versionedName(a.versions, a.getKind(),
+		resourceName(a.stackSpec, configSpec.Annotations.Name), configHash(configSpec))
+}
+
+// lookupSpecName returns the name in the types.StackSpec of the config
+// whose version is called name
+func (a *algorithmConfig) lookupSpecName(name string) string {
+	for _, configSpec := range a.stackSpec.Configs {
+		if name == a.configName(configSpec) {
+			return configSpec.Annotations.Name
+		}
+	}
+	return specifiedName(a.stackSpec, name)
+}
+
+// configHash returns the hash of the parts of configSpec which cannot be
+// updated
+func configHash(configSpec swarm.ConfigSpec) string {
+	return dataHash(configSpec.Data, configSpec.Templating)
+}
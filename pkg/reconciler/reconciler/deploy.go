@@ -0,0 +1,373 @@
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// deployStrategy returns the canary or blue/green deploy strategy of the
+// service of resource, if it has one
+func (a *algorithmService) deployStrategy(resource *interfaces.ReconcileResource) (types.StackDeployStrategy, bool) {
+	for _, serviceSpec := range a.stackSpec.Services {
+		if resourceName(a.stackSpec, serviceSpec.Annotations.Name) != resource.Name {
+			continue
+		}
+		strategy, ok := a.stackSpec.DeployStrategies[serviceSpec.Annotations.Name]
+		return strategy, ok && strategy.Mode != types.StackDeployInPlace
+	}
+	return types.StackDeployStrategy{}, false
+}
+
+// deploymentFailure is the error of a deployment which did not become
+// healthy and was cleaned up, for which the stack is rolled back. Other
+// errors of a deployment are retried.
+type deploymentFailure struct {
+	error
+}
+
+// deploy takes the next step of the deployment of the service of resource
+// according to strategy, resuming the deployment recorded in current if
+// there is one. deploy does not wait for the services it deploys to become
+// healthy: the step in progress is recorded in the deployment, along with
+// the end of its analysis window, and the stack is reconciled again to check
+// on it. The deployment is over once it is no longer recorded in the stack
+// returned. A deployment which does not become healthy is cleaned up,
+// leaving the service with its previous spec, and its cause is returned as
+// a deploymentFailure.
+// nolint: gocyclo
+func (a *algorithmService) deploy(current interfaces.SnapshotStack, resource *interfaces.ReconcileResource, strategy types.StackDeployStrategy) (interfaces.SnapshotStack, error) {
+	var err error
+	spec := a.labelServiceSpec(*resource.Config.(*swarm.ServiceSpec))
+	window := strategy.AnalysisWindow
+	if window == 0 {
+		window = types.DefaultStackUpdateMonitor
+	}
+
+	deployment := findDeployment(current, resource.Name)
+	if deployment != nil && (deployment.Mode != strategy.Mode || !sameSpec(deployment.Spec, spec)) {
+		// The deployment is out of date, and starts over from the spec
+		// the service had before it
+		if err = a.removeService(deployment.ID); err != nil {
+			return current, err
+		}
+		deployment = &interfaces.SnapshotDeployment{Previous: deployment.Previous}
+	}
+	if deployment == nil || deployment.ID == "" {
+		if deployment == nil {
+			service, err := a.cli.GetService(resource.ID, interfaces.DefaultGetServiceArg2)
+			if err != nil {
+				return current, err
+			}
+			deployment = &interfaces.SnapshotDeployment{Previous: service.Spec}
+		}
+
+		side := a.sideServiceSpec(spec, strategy)
+		if strategy.Mode == types.StackDeployCanary {
+			side = withoutPorts(side)
+		} else {
+			side = withoutTraffic(side)
+		}
+		// A side service left behind by an earlier deployment is replaced
+		if err = a.removeService(side.Annotations.Name); err != nil {
+			return current, err
+		}
+		started := time.Now()
+		resp, err := a.cli.CreateService(side,
+			interfaces.DefaultCreateServiceArg2,
+			interfaces.DefaultCreateServiceArg3)
+		if err != nil {
+			return current, err
+		}
+
+		deployment.Service = resource.Name
+		deployment.Mode = strategy.Mode
+		deployment.Step = interfaces.DeploymentAnalyzing
+		deployment.ID = resp.ID
+		deployment.Spec = spec
+		deployment.Started = started
+		deployment.Deadline = started.Add(window)
+		return a.waitDeployment(current, deployment)
+	}
+
+	abort := func(failure error) (interfaces.SnapshotStack, error) {
+		if err := a.removeService(deployment.ID); err != nil {
+			return current, err
+		}
+		current, err := a.setDeployment(current, resource.Name, nil)
+		if err != nil {
+			return current, err
+		}
+		return current, deploymentFailure{fmt.Errorf("%s deployment of service %s aborted: %s", deployment.Mode, resource.Name, failure)}
+	}
+
+	switch deployment.Step {
+	case interfaces.DeploymentAnalyzing:
+		healthy, failure := a.checkHealth([]string{deployment.ID}, deployment.Started, deployment.Deadline, strategy.MaxFailureRatio, true)
+		if failure != nil {
+			return abort(failure)
+		}
+		if !healthy {
+			return a.waitDeployment(current, deployment)
+		}
+
+		// The updates of the next step are taken before it is recorded,
+		// and are taken again if recording it fails
+		started := time.Now()
+		if deployment.Mode == types.StackDeployCanary {
+			if err = a.updateService(resource.ID, spec); err != nil {
+				return current, err
+			}
+			deployment.Step = interfaces.DeploymentPromoting
+		} else {
+			// The traffic of the service is switched over to the green
+			// service while the service is updated
+			if err = a.updateService(resource.ID, withoutTraffic(deployment.Previous)); err != nil {
+				return current, err
+			}
+			if err = a.updateService(deployment.ID, a.sideServiceSpec(spec, strategy)); err != nil {
+				return current, err
+			}
+			if err = a.updateService(resource.ID, withoutTraffic(spec)); err != nil {
+				return current, err
+			}
+			deployment.Step = interfaces.DeploymentSwitched
+		}
+		deployment.Started = started
+		deployment.Deadline = started.Add(window)
+		return a.waitDeployment(current, deployment)
+
+	case interfaces.DeploymentPromoting:
+		healthy, failure := a.checkHealth([]string{resource.ID}, deployment.Started, deployment.Deadline, strategy.MaxFailureRatio, false)
+		if failure != nil {
+			if err = a.updateService(resource.ID, deployment.Previous); err != nil {
+				return current, err
+			}
+			return abort(failure)
+		}
+		if !healthy {
+			return a.waitDeployment(current, deployment)
+		}
+		if err = a.removeService(deployment.ID); err != nil {
+			return current, err
+		}
+		current, err = a.setDeployment(current, resource.Name, nil)
+		return current, err
+
+	case interfaces.DeploymentSwitched:
+		healthy, failure := a.checkHealth([]string{resource.ID}, deployment.Started, deployment.Deadline, strategy.MaxFailureRatio, false)
+		if !healthy && failure == nil {
+			return a.waitDeployment(current, deployment)
+		}
+
+		// The traffic is switched back to the service, which is reverted
+		// to its previous spec if it did not become healthy
+		switchTo := spec
+		if failure != nil {
+			switchTo = deployment.Previous
+		}
+		if err = a.updateService(deployment.ID, withoutTraffic(a.sideServiceSpec(spec, strategy))); err != nil {
+			return current, err
+		}
+		if err = a.updateService(resource.ID, switchTo); err != nil {
+			return current, err
+		}
+		if failure != nil {
+			return abort(failure)
+		}
+		if err = a.removeService(deployment.ID); err != nil {
+			return current, err
+		}
+		current, err = a.setDeployment(current, resource.Name, nil)
+		return current, err
+
+	default:
+		return current, fmt.Errorf("unknown step '%s' of the deployment of service %s", deployment.Step, resource.Name)
+	}
+}
+
+// waitDeployment records deployment, and has the stack reconciled again to
+// check on it
+func (a *algorithmService) waitDeployment(current interfaces.SnapshotStack, deployment *interfaces.SnapshotDeployment) (interfaces.SnapshotStack, error) {
+	current, err := a.setDeployment(current, deployment.Service, deployment)
+	if err != nil {
+		return current, err
+	}
+	a.requeue(rolloutPollInterval)
+	return current, nil
+}
+
+// withDeploying returns resources along with the goals of the services
+// whose deployment is in progress in current, which are deployed until
+// their deployment is over even once they are up to date
+func (a *algorithmService) withDeploying(current interfaces.SnapshotStack, resources []*interfaces.ReconcileResource) []*interfaces.ReconcileResource {
+	result := resources
+	for _, deployment := range current.Deployments {
+		found := false
+		for _, resource := range resources {
+			found = found || resource.Name == deployment.Service
+		}
+		goal := a.getGoalResource(deployment.Service)
+		if found || goal == nil || goal.Mark == interfaces.ReconcileDelete {
+			continue
+		}
+		if spec, ok := goal.Config.(*swarm.ServiceSpec); !ok || spec == nil {
+			continue
+		}
+		if _, ok := a.deployStrategy(goal); ok {
+			result = append(result, goal)
+		}
+	}
+	return result
+}
+
+// clearDeployments removes the deployments of the services which no longer
+// have a deploy strategy, along with their side services, as the services
+// are updated by other means since.
+func (a *algorithmService) clearDeployments(current interfaces.SnapshotStack) (interfaces.SnapshotStack, error) {
+	if len(current.Deployments) == 0 || a.requestedResource.Kind != interfaces.ReconcileStack {
+		return current, nil
+	}
+	deployments := []interfaces.SnapshotDeployment{}
+	for _, deployment := range current.Deployments {
+		if goal := a.getGoalResource(deployment.Service); goal != nil && goal.Mark != interfaces.ReconcileDelete {
+			if _, ok := a.deployStrategy(goal); ok {
+				deployments = append(deployments, deployment)
+				continue
+			}
+		}
+		if err := a.removeService(deployment.ID); err != nil {
+			return current, err
+		}
+	}
+	if len(deployments) == len(current.Deployments) {
+		return current, nil
+	}
+	updated := current
+	updated.Deployments = deployments
+	stored, err := a.cli.UpdateSnapshotStack(a.stackID, updated, updated.Meta.Version.Index)
+	if err != nil {
+		return current, err
+	}
+	return stored, nil
+}
+
+// sideServiceSpec returns the spec of the canary or green service deployed
+// alongside the service of spec
+func (a *algorithmService) sideServiceSpec(spec swarm.ServiceSpec, strategy types.StackDeployStrategy) swarm.ServiceSpec {
+	side := a.labelServiceSpec(spec)
+	side.Annotations.Labels[types.StackDeploymentLabel] = string(strategy.Mode)
+	if strategy.Mode == types.StackDeployCanary {
+		side.Annotations.Name = spec.Annotations.Name + "-canary"
+		if side.Mode.Replicated != nil {
+			replicas := strategy.CanaryReplicas
+			if replicas == 0 {
+				replicas = 1
+			}
+			side.Mode = swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			}
+		}
+	} else {
+		side.Annotations.Name = spec.Annotations.Name + "-green"
+	}
+	return side
+}
+
+// updateService updates the service id to spec. The fields managed by
+// other systems keep their current values.
+func (a *algorithmService) updateService(id string, spec swarm.ServiceSpec) error {
+	service, err := a.cli.GetService(id, interfaces.DefaultGetServiceArg2)
+	if err != nil {
+		return err
+	}
+	spec = *withActualFields(&spec, &service.Spec, ignoredFields(spec.Annotations.Labels)).(*swarm.ServiceSpec)
+	_, err = a.cli.UpdateService(
+		id,
+		service.Meta.Version.Index,
+		spec,
+		interfaces.DefaultUpdateServiceArg4,
+		interfaces.DefaultUpdateServiceArg5)
+	return err
+}
+
+// removeService removes the service idOrName, if it exists
+func (a *algorithmService) removeService(idOrName string) error {
+	if idOrName == "" {
+		return nil
+	}
+	err := a.cli.RemoveService(idOrName)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setDeployment records deployment as the deployment of service, or
+// removes the deployment of service if deployment is nil
+func (a *algorithmService) setDeployment(current interfaces.SnapshotStack, service string, deployment *interfaces.SnapshotDeployment) (interfaces.SnapshotStack, error) {
+	deployments := []interfaces.SnapshotDeployment{}
+	for _, existing := range current.Deployments {
+		if existing.Service != service {
+			deployments = append(deployments, existing)
+		}
+	}
+	if deployment != nil {
+		deployments = append(deployments, *deployment)
+	}
+
+	updated := current
+	updated.Deployments = deployments
+	stored, err := a.cli.UpdateSnapshotStack(a.stackID, updated, updated.Meta.Version.Index)
+	if err != nil {
+		return current, err
+	}
+	return stored, nil
+}
+
+func findDeployment(current interfaces.SnapshotStack, service string) *interfaces.SnapshotDeployment {
+	for _, deployment := range current.Deployments {
+		if deployment.Service == service {
+			found := deployment
+			return &found
+		}
+	}
+	return nil
+}
+
+// withoutPorts returns a copy of spec which publishes no ports, as a port
+// can only be published by one service
+func withoutPorts(spec swarm.ServiceSpec) swarm.ServiceSpec {
+	if spec.EndpointSpec != nil {
+		endpointSpec := *spec.EndpointSpec
+		endpointSpec.Ports = nil
+		spec.EndpointSpec = &endpointSpec
+	}
+	return spec
+}
+
+// withoutTraffic returns a copy of spec which neither publishes ports nor
+// has network aliases, so that it receives no traffic
+func withoutTraffic(spec swarm.ServiceSpec) swarm.ServiceSpec {
+	spec = withoutPorts(spec)
+	spec.Networks = withoutAliases(spec.Networks)
+	spec.TaskTemplate.Networks = withoutAliases(spec.TaskTemplate.Networks)
+	return spec
+}
+
+func withoutAliases(attachments []swarm.NetworkAttachmentConfig) []swarm.NetworkAttachmentConfig {
+	if attachments == nil {
+		return nil
+	}
+	result := make([]swarm.NetworkAttachmentConfig, 0, len(attachments))
+	for _, attachment := range attachments {
+		attachment.Aliases = nil
+		result = append(result, attachment)
+	}
+	return result
+}
@@ -0,0 +1,208 @@
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+var _ = Describe("Deploying a service with a strategy", func() {
+	var (
+		cli     *fakes.FakeReconcilerClient
+		r       *reconciler
+		id      string
+		request *interfaces.ReconcileResource
+		webID   string
+	)
+
+	getWeb := func() swarm.Service {
+		service, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+
+	serviceNames := func() []string {
+		names := []string{}
+		for _, view := range cli.InternalQueryServices(func(service *swarm.Service) interface{} {
+			return service.Spec.Annotations.Name
+		}) {
+			names = append(names, view.(string))
+		}
+		return names
+	}
+
+	deploy := func(mode types.StackDeployMode, image string) {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.DeployStrategies = map[string]types.StackDeployStrategy{
+			"web": {
+				Mode:           mode,
+				CanaryReplicas: 1,
+				AnalysisWindow: 20 * time.Millisecond,
+			},
+		}
+		stack.Spec.Services[0].TaskTemplate.ContainerSpec.Image = image
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+	}
+
+	// settle reconciles the stack until the deployment is over, as the
+	// dispatcher would when notified
+	settle := func() {
+		Eventually(func() []interfaces.SnapshotDeployment {
+			Expect(r.Reconcile(request)).To(Succeed())
+			snapshot, err := cli.GetSnapshotStack(id)
+			Expect(err).ToNot(HaveOccurred())
+			return snapshot.Deployments
+		}).Should(BeEmpty())
+	}
+
+	BeforeEach(func() {
+		rolloutPollInterval = time.Millisecond
+		cli = fakes.NewFakeReconcilerClient()
+		r = newReconciler(notifier.NewNotificationForwarder(), cli)
+
+		web := getReplicatedServiceSpec("web", "nginx:1.16", 3)
+		web.EndpointSpec = &swarm.EndpointSpec{
+			Ports: []swarm.PortConfig{{TargetPort: 80, PublishedPort: 8080}},
+		}
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{web},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		request = &interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		}
+		Expect(r.Reconcile(request)).To(Succeed())
+		webID = getWeb().ID
+	})
+
+	It("promotes a healthy canary", func() {
+		deploy(types.StackDeployCanary, "nginx:1.17")
+		settle()
+
+		web := getWeb()
+		Expect(web.ID).To(Equal(webID))
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+		Expect(web.Spec.EndpointSpec.Ports).To(HaveLen(1))
+		Expect(serviceNames()).To(Equal([]string{"web"}))
+
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Deployments).To(BeEmpty())
+		Expect(snapshot.Status.Phase).To(Equal(types.StackPhaseUpdated))
+
+		// The stack is reconciled, and the service is left alone
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(getWeb().ID).To(Equal(webID))
+	})
+
+	It("keeps the ignored fields of a service promoted from a canary", func() {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Services[0].Annotations.Labels = map[string]string{
+			types.StackIgnoreFieldsLabel: "Mode.Replicated.Replicas",
+		}
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		// An autoscaler scales the service out
+		web := getWeb()
+		replicas := uint64(5)
+		web.Spec.Mode.Replicated.Replicas = &replicas
+		_, err = cli.UpdateService(web.ID, web.Meta.Version.Index, web.Spec,
+			interfaces.DefaultUpdateServiceArg4, interfaces.DefaultUpdateServiceArg5)
+		Expect(err).ToNot(HaveOccurred())
+
+		deploy(types.StackDeployCanary, "nginx:1.17")
+		settle()
+
+		web = getWeb()
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+		Expect(*web.Spec.Mode.Replicated.Replicas).To(Equal(uint64(5)))
+	})
+
+	It("analyzes the canary without waiting for its analysis window", func() {
+		deploy(types.StackDeployCanary, "nginx:1.17")
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.16"))
+		Expect(serviceNames()).To(ConsistOf("web", "web-canary"))
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Deployments).To(HaveLen(1))
+		deployment := snapshot.Deployments[0]
+		Expect(deployment.Step).To(Equal(interfaces.DeploymentAnalyzing))
+		Expect(deployment.Deadline).To(Equal(deployment.Started.Add(20 * time.Millisecond)))
+
+		settle()
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+	})
+
+	It("aborts an unhealthy canary", func() {
+		cli.SpecifyTaskState("nginx:broken", swarm.TaskStateFailed)
+		deploy(types.StackDeployCanary, "nginx:broken")
+		settle()
+
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.16"))
+		Expect(serviceNames()).To(Equal([]string{"web"}))
+
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stack.Status.Phase).To(Equal(types.StackPhaseRolledBack))
+		Expect(stack.Status.Message).To(ContainSubstring("canary deployment of service web aborted"))
+	})
+
+	It("switches traffic to the green service while the service is updated", func() {
+		deploy(types.StackDeployBlueGreen, "nginx:1.17")
+		settle()
+
+		web := getWeb()
+		Expect(web.ID).To(Equal(webID))
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+		Expect(web.Spec.EndpointSpec.Ports).To(HaveLen(1))
+		Expect(serviceNames()).To(Equal([]string{"web"}))
+	})
+
+	It("resumes an interrupted deployment", func() {
+		deploy(types.StackDeployBlueGreen, "nginx:1.17")
+
+		// Removing the green service fails once
+		errorKey := "interrupt"
+		cli.FakeServiceStore.SpecifyErrorTrigger(errorKey, fmt.Errorf("interrupted"))
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Services[0].Annotations.Labels = map[string]string{}
+		cli.MarkServiceSpecForError(errorKey, &stack.Spec.Services[0], "RemoveService")
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+
+		Eventually(func() error {
+			return r.Reconcile(request)
+		}).Should(HaveOccurred())
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Deployments).To(HaveLen(1))
+		Expect(snapshot.Deployments[0].Step).To(Equal(interfaces.DeploymentSwitched))
+		Expect(serviceNames()).To(ConsistOf("web", "web-green"))
+
+		cli.FakeServiceStore.SpecifyErrorTrigger(errorKey, nil)
+		settle()
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+		Expect(getWeb().Spec.EndpointSpec.Ports).To(HaveLen(1))
+		Expect(serviceNames()).To(Equal([]string{"web"}))
+	})
+})
@@ -0,0 +1,118 @@
+package reconciler
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+/**
+ *  Resource naming.
+ *
+ *  The algorithm works exclusively with the names of the resources as they
+ *  are known to Docker, because those are the names reported by the active
+ *  resources.  When a types.StackSpec uses types.ResourceNamingNamespaced,
+ *  the names in the specification are prefixed with the name of the stack
+ *  and the references held by the service specifications are rewritten to
+ *  the prefixed names.  The plugins translate at their boundaries so that
+ *  the algorithm never sees a specification name.
+ */
+
+// resourceName returns the Docker name of the resource called specName in
+// the types.StackSpec
+func resourceName(stackSpec types.StackSpec, specName string) string {
+	return stackSpec.ResourceName(specName)
+}
+
+// specifiedName returns the name in the types.StackSpec of the resource
+// known to Docker as name
+func specifiedName(stackSpec types.StackSpec, name string) string {
+	if stackSpec.ResourceNaming != types.ResourceNamingNamespaced {
+		return name
+	}
+	return strings.TrimPrefix(name, stackSpec.Annotations.Name+"_")
+}
+
+// namespaceServiceSpec returns a copy of serviceSpec with its own name and
+// its references to networks, secrets and configs defined in the stack
+// replaced by their Docker names.  References to resources outside of the
+// stack are left untouched.  The service remains reachable under its
+// specification name through a network alias, as with `docker stack deploy`.
+func namespaceServiceSpec(stackSpec types.StackSpec, serviceSpec swarm.ServiceSpec) swarm.ServiceSpec {
+	if stackSpec.ResourceNaming != types.ResourceNamingNamespaced {
+		return serviceSpec
+	}
+
+	networks := map[string]struct{}{}
+	for name := range stackSpec.Networks {
+		networks[name] = struct{}{}
+	}
+	secrets := map[string]struct{}{}
+	for _, secret := range stackSpec.Secrets {
+		secrets[secret.Annotations.Name] = struct{}{}
+	}
+	configs := map[string]struct{}{}
+	for _, config := range stackSpec.Configs {
+		configs[config.Annotations.Name] = struct{}{}
+	}
+
+	result := serviceSpec
+	result.Annotations.Name = resourceName(stackSpec, serviceSpec.Annotations.Name)
+	result.Networks = namespaceNetworkAttachments(stackSpec, networks, serviceSpec.Annotations.Name, serviceSpec.Networks)
+	result.TaskTemplate.Networks = namespaceNetworkAttachments(stackSpec, networks, serviceSpec.Annotations.Name, serviceSpec.TaskTemplate.Networks)
+
+	if serviceSpec.TaskTemplate.ContainerSpec != nil {
+		containerSpec := *serviceSpec.TaskTemplate.ContainerSpec
+		if containerSpec.Secrets != nil {
+			containerSpec.Secrets = make([]*swarm.SecretReference, 0, len(serviceSpec.TaskTemplate.ContainerSpec.Secrets))
+			for _, reference := range serviceSpec.TaskTemplate.ContainerSpec.Secrets {
+				copied := *reference
+				if _, ok := secrets[copied.SecretName]; ok {
+					copied.SecretName = resourceName(stackSpec, copied.SecretName)
+				}
+				containerSpec.Secrets = append(containerSpec.Secrets, &copied)
+			}
+		}
+		if containerSpec.Configs != nil {
+			containerSpec.Configs = make([]*swarm.ConfigReference, 0, len(serviceSpec.TaskTemplate.ContainerSpec.Configs))
+			for _, reference := range serviceSpec.TaskTemplate.ContainerSpec.Configs {
+				copied := *reference
+				if _, ok := configs[copied.ConfigName]; ok {
+					copied.ConfigName = resourceName(stackSpec, copied.ConfigName)
+				}
+				containerSpec.Configs = append(containerSpec.Configs, &copied)
+			}
+		}
+		result.TaskTemplate.ContainerSpec = &containerSpec
+	}
+
+	return result
+}
+
+func namespaceNetworkAttachments(stackSpec types.StackSpec, networks map[string]struct{}, alias string, attachments []swarm.NetworkAttachmentConfig) []swarm.NetworkAttachmentConfig {
+	if attachments == nil {
+		return nil
+	}
+	result := make([]swarm.NetworkAttachmentConfig, 0, len(attachments))
+	for _, attachment := range attachments {
+		if _, ok := networks[attachment.Target]; ok {
+			attachment.Target = resourceName(stackSpec, attachment.Target)
+			attachment.Aliases = appendMissing(attachment.Aliases, alias)
+		}
+		result = append(result, attachment)
+	}
+	return result
+}
+
+func appendMissing(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	result := make([]string, 0, len(values)+1)
+	result = append(result, values...)
+	return append(result, value)
+}
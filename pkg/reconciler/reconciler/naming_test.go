@@ -0,0 +1,165 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getNamespacedStackSpec(name string) types.StackSpec {
+	return types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: name,
+		},
+		ResourceNaming: types.ResourceNamingNamespaced,
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{
+					Name: "web",
+				},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image: "nginx",
+						Secrets: []*swarm.SecretReference{
+							{SecretName: "password"},
+							{SecretName: "external"},
+						},
+						Configs: []*swarm.ConfigReference{
+							{ConfigName: "settings"},
+						},
+					},
+					Networks: []swarm.NetworkAttachmentConfig{
+						{Target: "backend"},
+						{Target: "ingress"},
+					},
+				},
+			},
+		},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"backend": {},
+		},
+		Secrets: []swarm.SecretSpec{
+			{Annotations: swarm.Annotations{Name: "password"}},
+		},
+		Configs: []swarm.ConfigSpec{
+			{Annotations: swarm.Annotations{Name: "settings"}},
+		},
+	}
+}
+
+var _ = Describe("Resource naming", func() {
+	Context("Exact naming", func() {
+		It("leaves names untouched", func() {
+			spec := getNamespacedStackSpec("app")
+			spec.ResourceNaming = types.ResourceNamingExact
+			Expect(resourceName(spec, "web")).To(Equal("web"))
+			Expect(specifiedName(spec, "web")).To(Equal("web"))
+			Expect(namespaceServiceSpec(spec, spec.Services[0])).To(Equal(spec.Services[0]))
+		})
+	})
+
+	Context("Namespaced naming", func() {
+		var spec types.StackSpec
+		BeforeEach(func() {
+			spec = getNamespacedStackSpec("app")
+		})
+		It("prefixes names with the stack name", func() {
+			Expect(resourceName(spec, "web")).To(Equal("app_web"))
+			Expect(specifiedName(spec, "app_web")).To(Equal("web"))
+		})
+		It("rewrites references to stack resources only", func() {
+			namespaced := namespaceServiceSpec(spec, spec.Services[0])
+			Expect(namespaced.Annotations.Name).To(Equal("app_web"))
+			Expect(namespaced.TaskTemplate.ContainerSpec.Secrets[0].SecretName).To(Equal("app_password"))
+			Expect(namespaced.TaskTemplate.ContainerSpec.Secrets[1].SecretName).To(Equal("external"))
+			Expect(namespaced.TaskTemplate.ContainerSpec.Configs[0].ConfigName).To(Equal("app_settings"))
+			Expect(namespaced.TaskTemplate.Networks[0].Target).To(Equal("app_backend"))
+			Expect(namespaced.TaskTemplate.Networks[0].Aliases).To(ConsistOf("web"))
+			Expect(namespaced.TaskTemplate.Networks[1].Target).To(Equal("ingress"))
+			Expect(namespaced.TaskTemplate.Networks[1].Aliases).To(BeEmpty())
+		})
+		It("does not alter the original specification", func() {
+			_ = namespaceServiceSpec(spec, spec.Services[0])
+			Expect(spec).To(Equal(getNamespacedStackSpec("app")))
+		})
+	})
+
+	Context("Two stacks sharing resource names", func() {
+		var (
+			cli        *fakes.FakeReconcilerClient
+			ids        []string
+			err1, err2 error
+		)
+		BeforeEach(func() {
+			cli = fakes.NewFakeReconcilerClient()
+			r := newReconciler(notifier.NewNotificationForwarder(), cli)
+			ids = []string{}
+			for _, name := range []string{"blue", "green"} {
+				id, err := cli.AddStack(getNamespacedStackSpec(name))
+				Expect(err).ToNot(HaveOccurred())
+				ids = append(ids, id)
+			}
+			err1 = r.Reconcile(&interfaces.ReconcileResource{
+				SnapshotResource: interfaces.SnapshotResource{ID: ids[0]},
+				Kind:             interfaces.ReconcileStack,
+			})
+			err2 = r.Reconcile(&interfaces.ReconcileResource{
+				SnapshotResource: interfaces.SnapshotResource{ID: ids[1]},
+				Kind:             interfaces.ReconcileStack,
+			})
+		})
+		It("creates prefixed resources for both stacks", func() {
+			Expect(err1).ToNot(HaveOccurred())
+			Expect(err2).ToNot(HaveOccurred())
+
+			for _, stack := range []string{"blue", "green"} {
+				service, err := cli.GetService(stack+"_web", false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(service.Spec.TaskTemplate.Networks[0].Target).To(Equal(stack + "_backend"))
+			}
+			for _, name := range []string{"blue_password", "green_password"} {
+				_, err := cli.GetSecret(name)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			for _, name := range []string{"blue_settings", "green_settings"} {
+				_, err := cli.GetConfig(name)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			for _, name := range []string{"blue_backend", "green_backend"} {
+				_, err := cli.GetNetwork(name)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+		It("records the specification names in the snapshot", func() {
+			snapshot, err := cli.GetSnapshotStack(ids[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Services).To(HaveLen(1))
+			Expect(snapshot.Services[0].Name).To(Equal("blue_web"))
+			Expect(snapshot.Services[0].SpecName).To(Equal("web"))
+			Expect(snapshot.Networks[0].Name).To(Equal("blue_backend"))
+			Expect(snapshot.Networks[0].SpecName).To(Equal("backend"))
+			Expect(snapshot.Secrets[0].SpecName).To(Equal("password"))
+			Expect(snapshot.Configs[0].SpecName).To(Equal("settings"))
+		})
+		It("converges on a second reconciliation", func() {
+			r := newReconciler(notifier.NewNotificationForwarder(), cli)
+			before, err := cli.GetSnapshotStack(ids[0])
+			Expect(err).ToNot(HaveOccurred())
+			err = r.Reconcile(&interfaces.ReconcileResource{
+				SnapshotResource: interfaces.SnapshotResource{ID: ids[0]},
+				Kind:             interfaces.ReconcileStack,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			after, err := cli.GetSnapshotStack(ids[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(after.Meta.Version.Index).To(Equal(before.Meta.Version.Index))
+		})
+	})
+})
@@ -3,6 +3,7 @@ package reconciler
 import (
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/types"
@@ -110,7 +111,7 @@ func (a *algorithmNetwork) reconcile(stack interfaces.SnapshotStack) (interfaces
 
 func (a *algorithmNetwork) lookupNetworkSpec(name string) *dockerTypes.NetworkCreateRequest {
 	for networkName, networkSpec := range a.stackSpec.Networks {
-		if name == networkName {
+		if name == resourceName(a.stackSpec, networkName) {
 			return &dockerTypes.NetworkCreateRequest{
 				Name:          name,
 				NetworkCreate: networkSpec,
@@ -131,7 +132,7 @@ func (a *algorithmNetwork) getGoalResources() []*interfaces.ReconcileResource {
 func (a *algorithmNetwork) getSpecifiedResourceNames() []string {
 	result := make([]string, 0, len(a.stackSpec.Networks))
 	for networkName := range a.stackSpec.Networks {
-		result = append(result, networkName)
+		result = append(result, resourceName(a.stackSpec, networkName))
 	}
 	return result
 }
@@ -163,7 +164,8 @@ func (a *algorithmNetwork) addCreateResourceGoal(specName string) *interfaces.Re
 	networkCreateRequest := a.lookupNetworkSpec(specName)
 	resource := &interfaces.ReconcileResource{
 		SnapshotResource: interfaces.SnapshotResource{
-			Name: networkCreateRequest.Name,
+			Name:     networkCreateRequest.Name,
+			SpecName: specifiedName(a.stackSpec, specName),
 		},
 		Config: networkCreateRequest,
 		Kind:   a.getKind(),
@@ -207,13 +209,13 @@ func (a *algorithmNetwork) storeGoals(previous interfaces.SnapshotStack) (interf
 }
 
 func (a *algorithmNetwork) hasSameConfiguration(resource interfaces.ReconcileResource, actual activeResource) bool {
-	// FIXME: Since Networks cannot be updated, is it still useful to
-	// compare the original configuration and the current configuration
-	/*
-		createRequest := resource.Config.(*dockerTypes.NetworkCreateRequest)
-		networkResource := actual.(activeNetwork).network
-	*/
-	return true
+	one := resource.Config.(*dockerTypes.NetworkCreateRequest)
+	two := actual.(activeNetwork).network
+	if isCreateOnly(one.Labels) {
+		return true
+	}
+	desired := withActualFields(&one.NetworkCreate, actualNetworkCreate(two), ignoredFields(one.Labels)).(*dockerTypes.NetworkCreate)
+	return sameNetworkConfiguration(*desired, two)
 }
 
 func (a *algorithmNetwork) createResource(resource *interfaces.ReconcileResource) error {
@@ -240,6 +242,28 @@ func (a *algorithmNetwork) deleteResource(resource *interfaces.ReconcileResource
 	return nil
 }
 
+// updateResource cannot update the network, as docker does not update
+// networks in place. A network which differs from its spec is reported, and
+// is left as it is rather than removed from under the services using it.
 func (a *algorithmNetwork) updateResource(resource interfaces.ReconcileResource) error {
+	logrus.Warnf("network %s of stack %s differs from its spec, but networks cannot be updated", resource.Name, a.stackID)
 	return nil
 }
+
+// actualNetworkCreate returns the NetworkCreate network was created with,
+// as far as docker reports it
+func actualNetworkCreate(network dockerTypes.NetworkResource) *dockerTypes.NetworkCreate {
+	return &dockerTypes.NetworkCreate{
+		Driver:     network.Driver,
+		Scope:      network.Scope,
+		EnableIPv6: network.EnableIPv6,
+		IPAM:       &network.IPAM,
+		Internal:   network.Internal,
+		Attachable: network.Attachable,
+		Ingress:    network.Ingress,
+		ConfigOnly: network.ConfigOnly,
+		ConfigFrom: &network.ConfigFrom,
+		Options:    network.Options,
+		Labels:     network.Labels,
+	}
+}
@@ -0,0 +1,94 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+var _ = Describe("Reconciling a paused stack", func() {
+	var (
+		cli     *fakes.FakeReconcilerClient
+		r       *reconciler
+		id      string
+		request *interfaces.ReconcileResource
+	)
+
+	image := func() string {
+		service, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		return service.Spec.TaskTemplate.ContainerSpec.Image
+	}
+
+	hotfix := func() {
+		service, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		spec := service.Spec
+		spec.TaskTemplate.ContainerSpec.Image = "nginx:hotfix"
+		_, err = cli.UpdateService(service.ID, service.Meta.Version.Index, spec,
+			interfaces.DefaultUpdateServiceArg4, interfaces.DefaultUpdateServiceArg5)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	drift := func() []types.StackDrift {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		return stack.Status.Drift
+	}
+
+	BeforeEach(func() {
+		cli = fakes.NewFakeReconcilerClient()
+		r = newReconciler(notifier.NewNotificationForwarder(), cli)
+
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{getReplicatedServiceSpec("web", "nginx:1.16", 1)},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		request = &interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		}
+		Expect(r.Reconcile(request)).To(Succeed())
+	})
+
+	It("reports the drift of a paused stack without reverting it", func() {
+		Expect(cli.PauseStack(id)).To(Succeed())
+		hotfix()
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		Expect(image()).To(Equal("nginx:hotfix"))
+		Expect(drift()).To(ConsistOf(types.StackDrift{
+			Kind:   "service",
+			Name:   "web",
+			Action: types.StackDriftUpdate,
+		}))
+
+		Expect(cli.ResumeStack(id)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(image()).To(Equal("nginx:1.16"))
+		Expect(drift()).To(BeEmpty())
+	})
+
+	It("reconciles no stack during a maintenance", func() {
+		Expect(cli.SetMaintenance(types.StackMaintenance{Enabled: true})).To(Succeed())
+		hotfix()
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(image()).To(Equal("nginx:hotfix"))
+		Expect(drift()).To(HaveLen(1))
+
+		Expect(cli.SetMaintenance(types.StackMaintenance{})).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(image()).To(Equal("nginx:1.16"))
+	})
+})
@@ -44,7 +44,7 @@ func compareMapsIgnoreStackLabel(one, two map[string]string) bool {
 			continue
 		}
 		twoValue, ok := two[oneKey]
-		if !ok || oneValue == twoValue {
+		if !ok || oneValue != twoValue {
 			return false
 		}
 	}
@@ -0,0 +1,99 @@
+package reconciler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// isCreateOnly reports whether the resource with labels is marked with
+// types.StackCreateOnlyLabel, and is never updated once created
+func isCreateOnly(labels map[string]string) bool {
+	createOnly, _ := strconv.ParseBool(labels[types.StackCreateOnlyLabel])
+	return createOnly
+}
+
+// ignoredFields returns the field paths listed by the
+// types.StackIgnoreFieldsLabel of labels
+func ignoredFields(labels map[string]string) []string {
+	paths := []string{}
+	for _, path := range strings.Split(labels[types.StackIgnoreFieldsLabel], ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// withActualFields returns a copy of the spec pointed to by desired, in
+// which the fields of paths have the values they have in the spec pointed to
+// by actual. Paths which do not exist in both specs are left alone. desired
+// is returned as is when there are no paths.
+func withActualFields(desired, actual interface{}, paths []string) interface{} {
+	if len(paths) == 0 {
+		return desired
+	}
+	// The copy is a deep one, as the fields of paths may be reached
+	// through pointers and maps shared with desired
+	result := reflect.New(reflect.TypeOf(desired).Elem())
+	if err := deepCopy(desired, result.Interface()); err != nil {
+		return desired
+	}
+	for _, path := range paths {
+		copyField(result.Elem(), reflect.ValueOf(actual).Elem(), strings.Split(path, "."))
+	}
+	return result.Interface()
+}
+
+// copyField sets the field of dst at path to the value of the field of src
+// at path
+func copyField(dst, src reflect.Value, path []string) {
+	for dst.Kind() == reflect.Ptr {
+		if dst.IsNil() || src.IsNil() {
+			return
+		}
+		dst, src = dst.Elem(), src.Elem()
+	}
+	if len(path) == 0 {
+		dst.Set(src)
+		return
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		dstField := dst.FieldByName(path[0])
+		srcField := src.FieldByName(path[0])
+		if !dstField.IsValid() || !dstField.CanSet() {
+			return
+		}
+		copyField(dstField, srcField, path[1:])
+
+	case reflect.Map:
+		if dst.Type().Key().Kind() != reflect.String {
+			return
+		}
+		key := strings.Join(path, ".")
+		matches := func(candidate string) bool {
+			if strings.HasSuffix(key, "*") {
+				return strings.HasPrefix(candidate, strings.TrimSuffix(key, "*"))
+			}
+			return candidate == key
+		}
+		for _, k := range dst.MapKeys() {
+			if matches(k.String()) {
+				dst.SetMapIndex(k, reflect.Value{})
+			}
+		}
+		for _, k := range src.MapKeys() {
+			if !matches(k.String()) {
+				continue
+			}
+			if dst.IsNil() {
+				dst.Set(reflect.MakeMap(dst.Type()))
+			}
+			dst.SetMapIndex(k, src.MapIndex(k))
+		}
+	}
+}
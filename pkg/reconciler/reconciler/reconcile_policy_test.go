@@ -0,0 +1,132 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+var _ = Describe("Reconcile policies of resources", func() {
+	var (
+		cli     *fakes.FakeReconcilerClient
+		r       *reconciler
+		id      string
+		request *interfaces.ReconcileResource
+	)
+
+	getWeb := func() swarm.Service {
+		service, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+
+	// manage changes the web service as another system would
+	manage := func(change func(spec *swarm.ServiceSpec)) {
+		service := getWeb()
+		spec := service.Spec
+		change(&spec)
+		_, err := cli.UpdateService(service.ID, service.Meta.Version.Index, spec,
+			interfaces.DefaultUpdateServiceArg4, interfaces.DefaultUpdateServiceArg5)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	deploy := func(labels map[string]string) {
+		web := getReplicatedServiceSpec("web", "nginx:1.16", 2)
+		web.Annotations.Labels = labels
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{web},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		request = &interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		}
+		Expect(r.Reconcile(request)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		cli = fakes.NewFakeReconcilerClient()
+		r = newReconciler(notifier.NewNotificationForwarder(), cli)
+	})
+
+	It("preserves ignored fields when updating a service", func() {
+		deploy(map[string]string{
+			types.StackIgnoreFieldsLabel: "Mode.Replicated.Replicas, TaskTemplate.ForceUpdate, Labels.com.example.*",
+		})
+		manage(func(spec *swarm.ServiceSpec) {
+			replicas := uint64(5)
+			spec.Mode.Replicated.Replicas = &replicas
+			spec.TaskTemplate.ForceUpdate = 3
+			spec.Annotations.Labels["com.example.agent"] = "monitored"
+		})
+		version := getWeb().Meta.Version.Index
+
+		// The changes to ignored fields are left alone
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(getWeb().Meta.Version.Index).To(Equal(version))
+
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Services[0].TaskTemplate.ContainerSpec.Image = "nginx:1.17"
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		web := getWeb()
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.17"))
+		Expect(*web.Spec.Mode.Replicated.Replicas).To(Equal(uint64(5)))
+		Expect(web.Spec.TaskTemplate.ForceUpdate).To(Equal(uint64(3)))
+		Expect(web.Spec.Annotations.Labels).To(HaveKeyWithValue("com.example.agent", "monitored"))
+		Expect(web.Spec.Annotations.Labels).To(HaveKeyWithValue(types.StackLabel, id))
+	})
+
+	It("does not update a create-only service", func() {
+		deploy(map[string]string{types.StackCreateOnlyLabel: "true"})
+		manage(func(spec *swarm.ServiceSpec) {
+			spec.TaskTemplate.ContainerSpec.Image = "nginx:hotfix"
+		})
+		version := getWeb().Meta.Version.Index
+
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(getWeb().Meta.Version.Index).To(Equal(version))
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:hotfix"))
+
+		// but creates it when it is missing
+		Expect(cli.RemoveService(getWeb().ID)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(getWeb().Spec.TaskTemplate.ContainerSpec.Image).To(Equal("nginx:1.16"))
+	})
+
+	It("copies the values of ignored fields from the actual spec", func() {
+		one := uint64(1)
+		three := uint64(3)
+		desired := &swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Labels: map[string]string{"com.example.stale": "x", "keep": "y"}},
+			Mode:        swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &one}},
+		}
+		actual := &swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Labels: map[string]string{"com.example.agent": "z"}},
+			Mode:        swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: &three}},
+		}
+
+		result := withActualFields(desired, actual, []string{
+			"Mode.Replicated.Replicas", "Labels.com.example.*", "Mode.Global.Unknown", "Unknown",
+		}).(*swarm.ServiceSpec)
+		Expect(*result.Mode.Replicated.Replicas).To(Equal(uint64(3)))
+		Expect(result.Annotations.Labels).To(Equal(map[string]string{"com.example.agent": "z", "keep": "y"}))
+
+		// desired is left untouched
+		Expect(*desired.Mode.Replicated.Replicas).To(Equal(uint64(1)))
+		Expect(desired.Annotations.Labels).To(HaveKey("com.example.stale"))
+	})
+})
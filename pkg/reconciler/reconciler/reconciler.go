@@ -1,10 +1,13 @@
 package reconciler
 
 import (
+	"reflect"
+
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
 )
 
 // Reconciler is the interface implemented to do the actual work of computing
@@ -48,6 +51,11 @@ type reconcileStackRequest struct {
 	networks               algorithmPlugin
 	secrets                algorithmPlugin
 	configs                algorithmPlugin
+
+	// secretInit and configInit create the plugins which reconcile the
+	// secrets and configs again once the services were reconciled
+	secretInit initializationSupport
+	configInit initializationSupport
 }
 
 // New creates a new Reconciler object, which uses the provided
@@ -77,7 +85,7 @@ func (r *reconciler) Reconcile(request *interfaces.ReconcileResource) error {
 
 	r.stackRequest = nil
 
-	serviceInit := newInitializationSupportService(r.cli)
+	serviceInit := newInitializationSupportService(r.cli, r.notify)
 	secretInit := newInitializationSupportSecret(r.cli)
 	networkInit := newInitializationSupportNetwork(r.cli)
 	configInit := newInitializationSupportConfig(r.cli)
@@ -125,6 +133,12 @@ func (r *reconciler) Reconcile(request *interfaces.ReconcileResource) error {
 				return nil
 			}
 			request.StackID = resource.getStackID()
+			// Requests which originate from events only carry the ID of
+			// the resource, whereas the plugins select the requested
+			// resource by name.
+			if request.Name == "" {
+				request.Name = resource.getSnapshot().Name
+			}
 		}
 	}
 
@@ -139,6 +153,17 @@ func (r *reconciler) Reconcile(request *interfaces.ReconcileResource) error {
 		return err
 	}
 
+	// Paused stacks, and every stack during a maintenance, are not
+	// reconciled. The changes reconciling them would make are reported as
+	// their drift instead.
+	maintenance, err := r.cli.Maintenance()
+	if err != nil {
+		return err
+	}
+	if snapshot.Paused || maintenance.Enabled {
+		return r.reportDrift(snapshot, []initializationSupport{&secretInit, &configInit, &networkInit, &serviceInit})
+	}
+
 	// Currently, even if a reconcile request Kind was for a non-Stack, all
 	// of the types.Stack resources are processed and the original
 	// reconcile request is passed to all types.Stack resources.  This
@@ -150,10 +175,45 @@ func (r *reconciler) Reconcile(request *interfaces.ReconcileResource) error {
 		secrets:           secretInit.createPlugin(snapshot, request),
 		networks:          networkInit.createPlugin(snapshot, request),
 		configs:           configInit.createPlugin(snapshot, request),
+		secretInit:        &secretInit,
+		configInit:        &configInit,
 	}
 
-	_, err = r.reconcile(snapshot)
+	stack, err := r.reconcile(snapshot)
+	if err != nil {
+		return err
+	}
 
+	// The drift of a stack which was paused is reverted by now
+	if len(stack.Status.Drift) > 0 {
+		stack.Status.Drift = nil
+		_, err = r.cli.UpdateSnapshotStack(stack.ID, stack, stack.Meta.Version.Index)
+	}
+	return err
+}
+
+// reportDrift records the drift of the stack of snapshot, computed by the
+// plugins of inits for the whole stack, in its status
+func (r *reconciler) reportDrift(snapshot interfaces.SnapshotStack, inits []initializationSupport) error {
+	request := &interfaces.ReconcileResource{
+		SnapshotResource: interfaces.SnapshotResource{ID: snapshot.ID},
+		Kind:             interfaces.ReconcileStack,
+	}
+
+	drift := []types.StackDrift{}
+	for _, init := range inits {
+		resources, err := driftResources(init.createPlugin(snapshot, request))
+		if err != nil {
+			return err
+		}
+		drift = append(drift, resources...)
+	}
+
+	if len(drift) == 0 && len(snapshot.Status.Drift) == 0 || reflect.DeepEqual(drift, snapshot.Status.Drift) {
+		return nil
+	}
+	snapshot.Status.Drift = drift
+	_, err := r.cli.UpdateSnapshotStack(snapshot.ID, snapshot, snapshot.Meta.Version.Index)
 	return err
 }
 
@@ -196,5 +256,19 @@ func (r reconcileStackRequest) reconcile(stack interfaces.SnapshotStack) (interf
 	if err != nil {
 		return stack, err
 	}
+
+	// The secrets and configs which were in use, such as the previous
+	// versions of those whose data changed, may be unused by now
+	for _, pass := range []struct {
+		plugin algorithmPlugin
+		init   initializationSupport
+	}{{r.secrets, r.secretInit}, {r.configs, r.configInit}} {
+		if deferring, ok := pass.plugin.(deferringPlugin); ok && deferring.hasDeferred() {
+			stack, err = pass.init.createPlugin(stack, r.requestedResource).reconcile(stack)
+			if err != nil {
+				return stack, err
+			}
+		}
+	}
 	return stack, nil
 }
@@ -0,0 +1,385 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// rolloutPollInterval is how often the stacks whose services are being
+// rolled out are reconciled again, to check whether their tasks became
+// healthy.
+var rolloutPollInterval = time.Second
+
+// rollingPlugin is implemented by the algorithmPlugins whose updates may be
+// rolled out progressively instead of all at once. reconcileResource defers
+// the updates of a rolling plugin until its other mutations are done.
+type rollingPlugin interface {
+	isRolling() bool
+	// isRollingOut reports whether current has a rollout in progress,
+	// which goes on once the resources are up to date
+	isRollingOut(current interfaces.SnapshotStack) bool
+	rollOut(current interfaces.SnapshotStack, resources []*interfaces.ReconcileResource) (interfaces.SnapshotStack, error)
+}
+
+func (a *algorithmService) isRolling() bool {
+	return a.stackSpec.UpdateConfig != nil || len(a.stackSpec.DeployStrategies) > 0
+}
+
+func (a *algorithmService) isRollingOut(current interfaces.SnapshotStack) bool {
+	return current.Rollout != nil || len(current.Deployments) > 0
+}
+
+// rollOut takes the next steps of the rolling update of the services of
+// resources, which are updated in batches, in dependency order. rollOut
+// does not wait for the tasks of a batch to become healthy before updating
+// the next one: the batch is recorded in the rollout of the stack, which is
+// reconciled again to check on it. Services with a deploy strategy are
+// deployed on their own, one step of their deployment at a time. If a
+// batch does not become healthy, every service updated so far is reverted
+// to its previous spec, and the stack is marked as rolled back so that the
+// same spec is not rolled out again.
+//
+// Without an UpdateConfig, the services updated in place are updated at
+// once and are not monitored.
+// nolint: gocyclo
+func (a *algorithmService) rollOut(current interfaces.SnapshotStack, resources []*interfaces.ReconcileResource) (interfaces.SnapshotStack, error) {
+	if current.RolledBack != nil && sameSpec(*current.RolledBack, a.stackSpec) {
+		return current, nil
+	}
+
+	config := types.StackUpdateConfig{Parallelism: uint64(len(resources))}
+	if a.stackSpec.UpdateConfig != nil {
+		config = *a.stackSpec.UpdateConfig
+	}
+	parallelism := int(config.Parallelism)
+	if parallelism == 0 {
+		parallelism = 1
+	}
+	monitor := config.Monitor
+	if monitor == 0 {
+		monitor = types.DefaultStackUpdateMonitor
+	}
+
+	var err error
+	rollout := &interfaces.SnapshotRollout{}
+	if current.Rollout != nil {
+		found := *current.Rollout
+		rollout = &found
+	} else {
+		current.Status = types.StackStatus{Phase: types.StackPhaseUpdating}
+	}
+
+	if len(rollout.Monitored) > 0 {
+		healthy, failure := a.checkHealth(rollout.Monitored, rollout.Started, rollout.Deadline, config.MaxFailureRatio, false)
+		if failure != nil {
+			return a.rollBack(current, rollout.Updated, failure)
+		}
+		if !healthy {
+			a.requeue(rolloutPollInterval)
+			return current, nil
+		}
+		rollout.Batch++
+		rollout.Monitored = nil
+	}
+
+	resources = a.withDeploying(current, resources)
+	for _, batch := range a.batches(a.dependencyOrder(resources), parallelism) {
+		if strategy, ok := a.deployStrategy(batch[0]); ok {
+			current, err = a.deploy(current, batch[0], strategy)
+			if failure, ok := err.(deploymentFailure); ok {
+				return a.rollBack(current, rollout.Updated, failure)
+			}
+			if err != nil {
+				return current, err
+			}
+			if findDeployment(current, batch[0].Name) != nil {
+				// The deployment is in progress
+				return a.setRollout(current, rollout)
+			}
+			rollout.Batch++
+			continue
+		}
+
+		// The previous specs of the services are recorded before they
+		// are updated, so that they are reverted if the rollout is
+		// rolled back after a restart of the controller
+		services := make([]swarm.Service, 0, len(batch))
+		for _, resource := range batch {
+			service, err := a.cli.GetService(resource.ID, interfaces.DefaultGetServiceArg2)
+			if err != nil {
+				return current, err
+			}
+			services = append(services, service)
+			rollout.Updated = withRolledOut(rollout.Updated, service)
+		}
+		if current, err = a.setRollout(current, rollout); err != nil {
+			return current, err
+		}
+
+		since := time.Now()
+		ids := []string{}
+		for i, resource := range batch {
+			resource.Meta = services[i].Meta
+			if err := a.updateResource(*resource); err != nil {
+				return current, err
+			}
+			ids = append(ids, resource.ID)
+		}
+		if current, err = a.storeGoals(current); err != nil {
+			return current, err
+		}
+
+		if a.stackSpec.UpdateConfig == nil {
+			rollout.Batch++
+			continue
+		}
+		rollout.Monitored = ids
+		rollout.Started = since
+		rollout.Deadline = since.Add(monitor)
+		if current, err = a.setRollout(current, rollout); err != nil {
+			return current, err
+		}
+		a.requeue(rolloutPollInterval)
+		return current, nil
+	}
+
+	current.RolledBack = nil
+	current.Rollout = nil
+	return a.setStatus(current, types.StackStatus{Phase: types.StackPhaseUpdated})
+}
+
+// withRolledOut returns updated with service, unless service was already
+// updated by the rollout, in which case its first previous spec is kept
+func withRolledOut(updated []interfaces.SnapshotRolledOut, service swarm.Service) []interfaces.SnapshotRolledOut {
+	for _, rolledOut := range updated {
+		if rolledOut.ID == service.ID {
+			return updated
+		}
+	}
+	return append(updated, interfaces.SnapshotRolledOut{ID: service.ID, Previous: service.Spec})
+}
+
+// batches splits ordered into batches of at most parallelism services. A
+// service with a deploy strategy is a batch of its own.
+func (a *algorithmService) batches(ordered []*interfaces.ReconcileResource, parallelism int) [][]*interfaces.ReconcileResource {
+	result := [][]*interfaces.ReconcileResource{}
+	batch := []*interfaces.ReconcileResource{}
+	for _, resource := range ordered {
+		if _, ok := a.deployStrategy(resource); ok {
+			if len(batch) > 0 {
+				result = append(result, batch)
+				batch = []*interfaces.ReconcileResource{}
+			}
+			result = append(result, []*interfaces.ReconcileResource{resource})
+			continue
+		}
+		batch = append(batch, resource)
+		if len(batch) == parallelism {
+			result = append(result, batch)
+			batch = []*interfaces.ReconcileResource{}
+		}
+	}
+	if len(batch) > 0 {
+		result = append(result, batch)
+	}
+	return result
+}
+
+// rollBack reverts the services in updated to their previous spec, most
+// recently updated first.
+func (a *algorithmService) rollBack(current interfaces.SnapshotStack, updated []interfaces.SnapshotRolledOut, failure error) (interfaces.SnapshotStack, error) {
+	logrus.Warnf("rolling back stack %s: %s", a.stackID, failure)
+	for i := len(updated) - 1; i >= 0; i-- {
+		if err := a.updateService(updated[i].ID, updated[i].Previous); err != nil {
+			return current, err
+		}
+	}
+
+	rolledBack := a.stackSpec
+	current.RolledBack = &rolledBack
+	current.Rollout = nil
+	return a.setStatus(current, types.StackStatus{
+		Phase:   types.StackPhaseRolledBack,
+		Message: failure.Error(),
+	})
+}
+
+// checkHealth checks whether the tasks of the current spec of the services
+// ids, which were updated at since, are running. It returns a failure once
+// more than maxFailureRatio of them failed, or if they are not all running
+// by deadline. With soak, the tasks must also keep running until deadline.
+// nolint: gocyclo
+func (a *algorithmService) checkHealth(ids []string, since, deadline time.Time, maxFailureRatio float32, soak bool) (bool, error) {
+	var expected, running, failed int
+	for _, id := range ids {
+		service, err := a.cli.GetService(id, interfaces.DefaultGetServiceArg2)
+		if err != nil {
+			return false, err
+		}
+		tasks, err := a.cli.GetTasks(dockerTypes.TaskListOptions{
+			Filters: filters.NewArgs(filters.Arg("service", id)),
+		})
+		if err != nil {
+			return false, err
+		}
+
+		serviceRunning := 0
+		nodes := map[string]bool{}
+		for _, task := range tasks {
+			if task.DesiredState == swarm.TaskStateRunning && task.NodeID != "" {
+				nodes[task.NodeID] = true
+			}
+			if !currentTask(service, task) {
+				continue
+			}
+			switch {
+			case task.Status.State == swarm.TaskStateFailed || task.Status.State == swarm.TaskStateRejected:
+				failed++
+			case task.Status.State == swarm.TaskStateRunning && task.DesiredState == swarm.TaskStateRunning:
+				serviceRunning++
+			}
+		}
+
+		// Global services run a task on every node eligible for them,
+		// which are the nodes swarm runs their tasks on
+		serviceExpected := 1
+		if mode := service.Spec.Mode.Replicated; mode != nil && mode.Replicas != nil {
+			serviceExpected = int(*mode.Replicas)
+		} else if service.Spec.Mode.Global != nil && len(nodes) > serviceExpected {
+			serviceExpected = len(nodes)
+		}
+		expected += serviceExpected
+		running += serviceRunning
+	}
+
+	total := expected
+	if failed > total {
+		total = failed
+	}
+	if failed > 0 && float32(failed)/float32(total) > maxFailureRatio {
+		return false, fmt.Errorf("%d of %d tasks failed", failed, total)
+	}
+	over := time.Now().After(deadline)
+	if running >= expected && (!soak || over) {
+		return true, nil
+	}
+	if over {
+		return false, fmt.Errorf("%d of %d tasks running after %s", running, expected, deadline.Sub(since))
+	}
+	return false, nil
+}
+
+// currentTask returns whether task runs the current spec of service. A task
+// created before the last update of service started is left over from it,
+// even if the update rolled the service back to the spec of the task. Both
+// times are set by the swarm managers.
+func currentTask(service swarm.Service, task swarm.Task) bool {
+	if status := service.UpdateStatus; status != nil && status.StartedAt != nil && task.Meta.CreatedAt.Before(*status.StartedAt) {
+		return false
+	}
+	return sameSpec(task.Spec, service.Spec.TaskTemplate)
+}
+
+// requeue has the stack reconciled again after delay, to go on with its
+// rollout
+func (a *algorithmService) requeue(delay time.Duration) {
+	if a.notify == nil {
+		return
+	}
+	request := &interfaces.ReconcileResource{
+		SnapshotResource: interfaces.SnapshotResource{ID: a.stackID},
+		Kind:             interfaces.ReconcileStack,
+	}
+	time.AfterFunc(delay, func() {
+		a.notify.Notify(request)
+	})
+}
+
+// dependencyOrder sorts resources so that each service follows the services
+// it depends on according to its types.StackDependsOnLabel. Services are
+// otherwise kept in the order of the StackSpec, which also breaks
+// dependency cycles.
+func (a *algorithmService) dependencyOrder(resources []*interfaces.ReconcileResource) []*interfaces.ReconcileResource {
+	byName := map[string]*interfaces.ReconcileResource{}
+	for _, resource := range resources {
+		byName[resource.Name] = resource
+	}
+
+	names := []string{}
+	dependencies := map[string][]string{}
+	for _, serviceSpec := range a.stackSpec.Services {
+		name := resourceName(a.stackSpec, serviceSpec.Annotations.Name)
+		names = append(names, name)
+		for _, dependency := range strings.Split(serviceSpec.Annotations.Labels[types.StackDependsOnLabel], ",") {
+			if dependency = strings.TrimSpace(dependency); dependency != "" {
+				dependencies[name] = append(dependencies[name], resourceName(a.stackSpec, dependency))
+			}
+		}
+	}
+
+	ordered := []*interfaces.ReconcileResource{}
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dependency := range dependencies[name] {
+			visit(dependency)
+		}
+		if resource, ok := byName[name]; ok {
+			ordered = append(ordered, resource)
+		}
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	// resources without a spec are not expected, but are not dropped
+	for _, resource := range resources {
+		if !visited[resource.Name] {
+			ordered = append(ordered, resource)
+		}
+	}
+	return ordered
+}
+
+// setRollout stores rollout as the rollout in progress of the stack
+func (a *algorithmService) setRollout(current interfaces.SnapshotStack, rollout *interfaces.SnapshotRollout) (interfaces.SnapshotStack, error) {
+	updated := current
+	updated.Rollout = rollout
+	stored, err := a.cli.UpdateSnapshotStack(a.stackID, updated, updated.Meta.Version.Index)
+	if err != nil {
+		return current, err
+	}
+	return stored, nil
+}
+
+// setStatus stores status as the status of the stack
+func (a *algorithmService) setStatus(current interfaces.SnapshotStack, status types.StackStatus) (interfaces.SnapshotStack, error) {
+	updated := current
+	updated.Status = status
+	stored, err := a.cli.UpdateSnapshotStack(a.stackID, updated, updated.Meta.Version.Index)
+	if err != nil {
+		return current, err
+	}
+	return stored, nil
+}
+
+// sameSpec compares specs by their JSON representation, which is how they
+// are stored
+func sameSpec(one, two interface{}) bool {
+	oneJSON, oneErr := json.Marshal(one)
+	twoJSON, twoErr := json.Marshal(two)
+	return oneErr == nil && twoErr == nil && string(oneJSON) == string(twoJSON)
+}
@@ -0,0 +1,222 @@
+package reconciler
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// notifyFunc is a notifier.ObjectChangeNotifier calling itself
+type notifyFunc func(*interfaces.ReconcileResource)
+
+func (f notifyFunc) Notify(request *interfaces.ReconcileResource) {
+	f(request)
+}
+
+var _ = Describe("Rolling out a stack update", func() {
+	var (
+		cli      *fakes.FakeReconcilerClient
+		r        *reconciler
+		id       string
+		request  *interfaces.ReconcileResource
+		notified chan *interfaces.ReconcileResource
+	)
+
+	image := func(name string) string {
+		service, err := cli.GetService(name, false)
+		Expect(err).ToNot(HaveOccurred())
+		return service.Spec.TaskTemplate.ContainerSpec.Image
+	}
+
+	update := func(images map[string]string) {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		for i, service := range stack.Spec.Services {
+			if image, ok := images[service.Annotations.Name]; ok {
+				stack.Spec.Services[i].TaskTemplate.ContainerSpec.Image = image
+			}
+		}
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+	}
+
+	// settle reconciles the stack until its rollout is over, as the
+	// dispatcher would when notified
+	settle := func() {
+		Eventually(func() *interfaces.SnapshotRollout {
+			Expect(r.Reconcile(request)).To(Succeed())
+			snapshot, err := cli.GetSnapshotStack(id)
+			Expect(err).ToNot(HaveOccurred())
+			return snapshot.Rollout
+		}).Should(BeNil())
+	}
+
+	BeforeEach(func() {
+		rolloutPollInterval = time.Millisecond
+		cli = fakes.NewFakeReconcilerClient()
+		notified = make(chan *interfaces.ReconcileResource, 1)
+		forwarder := notifier.NewNotificationForwarder()
+		forwarder.Register(notifyFunc(func(request *interfaces.ReconcileResource) {
+			select {
+			case notified <- request:
+			default:
+			}
+		}))
+		r = newReconciler(forwarder, cli)
+
+		web := getReplicatedServiceSpec("web", "nginx:1.16", 2)
+		web.Annotations.Labels = map[string]string{types.StackDependsOnLabel: "db"}
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{
+				web,
+				getReplicatedServiceSpec("db", "postgres:11", 1),
+				getReplicatedServiceSpec("cache", "redis:5", 1),
+			},
+			UpdateConfig: &types.StackUpdateConfig{
+				Monitor: 50 * time.Millisecond,
+			},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		request = &interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		}
+		Expect(r.Reconcile(request)).To(Succeed())
+	})
+
+	It("updates every service once they become healthy", func() {
+		update(map[string]string{"web": "nginx:1.17", "db": "postgres:12", "cache": "redis:6"})
+		settle()
+
+		Expect(image("web")).To(Equal("nginx:1.17"))
+		Expect(image("db")).To(Equal("postgres:12"))
+		Expect(image("cache")).To(Equal("redis:6"))
+
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stack.Status.Phase).To(Equal(types.StackPhaseUpdated))
+	})
+
+	It("reconciles the stack again instead of waiting for a batch", func() {
+		update(map[string]string{"web": "nginx:1.17", "db": "postgres:12"})
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		// db is monitored, and web is not updated yet
+		Expect(image("db")).To(Equal("postgres:12"))
+		Expect(image("web")).To(Equal("nginx:1.16"))
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Status.Phase).To(Equal(types.StackPhaseUpdating))
+		Expect(snapshot.Rollout.Monitored).To(HaveLen(1))
+		Expect(snapshot.Rollout.Deadline).To(BeTemporally("~", snapshot.Rollout.Started.Add(50*time.Millisecond)))
+		var requeued *interfaces.ReconcileResource
+		Eventually(notified).Should(Receive(&requeued))
+		Expect(requeued.ID).To(Equal(id))
+		Expect(requeued.Kind).To(Equal(interfaces.ReconcileStack))
+
+		settle()
+		Expect(image("web")).To(Equal("nginx:1.17"))
+	})
+
+	It("updates services after the services they depend on", func() {
+		a := &algorithmService{stackSpec: types.StackSpec{
+			Services: []swarm.ServiceSpec{
+				{Annotations: swarm.Annotations{Name: "web", Labels: map[string]string{types.StackDependsOnLabel: "api, cache"}}},
+				{Annotations: swarm.Annotations{Name: "api", Labels: map[string]string{types.StackDependsOnLabel: "db"}}},
+				{Annotations: swarm.Annotations{Name: "db"}},
+				{Annotations: swarm.Annotations{Name: "cache"}},
+			},
+		}}
+		resources := []*interfaces.ReconcileResource{}
+		for _, name := range []string{"cache", "web", "db", "api"} {
+			resources = append(resources, &interfaces.ReconcileResource{
+				SnapshotResource: interfaces.SnapshotResource{Name: name},
+			})
+		}
+		names := []string{}
+		for _, resource := range a.dependencyOrder(resources) {
+			names = append(names, resource.Name)
+		}
+		Expect(names).To(Equal([]string{"db", "api", "cache", "web"}))
+	})
+
+	It("rolls back every updated service when a batch fails", func() {
+		cli.SpecifyTaskState("nginx:broken", swarm.TaskStateFailed)
+		update(map[string]string{"web": "nginx:broken", "db": "postgres:12", "cache": "redis:6"})
+		settle()
+
+		// db was updated before web, and cache was never reached
+		Expect(image("web")).To(Equal("nginx:1.16"))
+		Expect(image("db")).To(Equal("postgres:11"))
+		Expect(image("cache")).To(Equal("redis:5"))
+
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stack.Status.Phase).To(Equal(types.StackPhaseRolledBack))
+		Expect(stack.Status.Message).To(ContainSubstring("2 of 2 tasks failed"))
+
+		// The rolled back spec is not rolled out again
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(image("db")).To(Equal("postgres:11"))
+
+		// until the stack is updated
+		update(map[string]string{"web": "nginx:1.17"})
+		settle()
+		Expect(image("web")).To(Equal("nginx:1.17"))
+		Expect(image("db")).To(Equal("postgres:12"))
+
+		stack, err = cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stack.Status.Phase).To(Equal(types.StackPhaseUpdated))
+	})
+
+	It("rolls back when the tasks do not become healthy in time", func() {
+		cli.SpecifyTaskState("postgres:12", swarm.TaskStatePending)
+		update(map[string]string{"db": "postgres:12"})
+		settle()
+
+		Expect(image("db")).To(Equal("postgres:11"))
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stack.Status.Phase).To(Equal(types.StackPhaseRolledBack))
+		Expect(stack.Status.Message).To(ContainSubstring("0 of 1 tasks running"))
+	})
+})
+
+var _ = Describe("Checking the tasks of a rolled out service", func() {
+	updated := time.Now()
+	service := swarm.Service{
+		Spec: swarm.ServiceSpec{
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{Image: "nginx:1.17"},
+			},
+		},
+		UpdateStatus: &swarm.UpdateStatus{StartedAt: &updated},
+	}
+	task := func(image string, created time.Time) swarm.Task {
+		return swarm.Task{
+			Meta: swarm.Meta{CreatedAt: created},
+			Spec: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{Image: image},
+			},
+		}
+	}
+
+	It("only counts the tasks of the current spec created since the update", func() {
+		Expect(currentTask(service, task("nginx:1.17", updated.Add(time.Second)))).To(BeTrue())
+		Expect(currentTask(service, task("nginx:1.16", updated.Add(time.Second)))).To(BeFalse())
+		Expect(currentTask(service, task("nginx:1.17", updated.Add(-time.Second)))).To(BeFalse())
+	})
+})
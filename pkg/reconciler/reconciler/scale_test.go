@@ -0,0 +1,84 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getReplicatedServiceSpec(name, image string, replicas uint64) swarm.ServiceSpec {
+	return swarm.ServiceSpec{
+		Annotations: swarm.Annotations{
+			Name: name,
+		},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Image: image,
+			},
+		},
+		Mode: swarm.ServiceMode{
+			Replicated: &swarm.ReplicatedService{
+				Replicas: &replicas,
+			},
+		},
+	}
+}
+
+var _ = Describe("Scaling a service of a stack", func() {
+	var (
+		cli *fakes.FakeReconcilerClient
+		r   *reconciler
+		id  string
+	)
+	BeforeEach(func() {
+		cli = fakes.NewFakeReconcilerClient()
+		r = newReconciler(notifier.NewNotificationForwarder(), cli)
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{
+				getReplicatedServiceSpec("web", "nginx", 1),
+				getReplicatedServiceSpec("db", "postgres:11", 1),
+			},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Reconcile(&interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		})).To(Succeed())
+	})
+
+	It("reconciles only the scaled service", func() {
+		// An unrelated change to the stack is pending
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Services[1].TaskTemplate.ContainerSpec.Image = "postgres:12"
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+
+		Expect(cli.ScaleStack(id, map[string]uint64{"web": 4})).To(Succeed())
+
+		// The request is issued from a service event, which only carries
+		// the name of the service
+		Expect(r.Reconcile(&interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: "web"},
+			Kind:             interfaces.ReconcileService,
+		})).To(Succeed())
+
+		web, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*web.Spec.Mode.Replicated.Replicas).To(Equal(uint64(4)))
+
+		db, err := cli.GetService("db", false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(db.Spec.TaskTemplate.ContainerSpec.Image).To(Equal("postgres:11"))
+	})
+})
@@ -1,8 +1,6 @@
 package reconciler
 
 import (
-	"reflect"
-
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
@@ -27,6 +25,12 @@ type algorithmSecret struct {
 	stackID           string
 	stackSpec         types.StackSpec
 	goals             map[string]*interfaces.ReconcileResource
+	versions          []interfaces.SnapshotVersion
+
+	// inUse are the secrets used by the services of the stack, and
+	// deferred is set once the deletion of one of them is deferred
+	inUse    map[string]bool
+	deferred bool
 }
 
 func (a activeSecret) getSnapshot() interfaces.SnapshotResource {
@@ -90,6 +94,7 @@ func newAlgorithmPluginSecret(secretInit initializationSecret, snapshot interfac
 		stackID:              snapshot.ID,
 		stackSpec:            snapshot.CurrentSpec,
 		goals:                map[string]*interfaces.ReconcileResource{},
+		versions:             snapshot.Versions,
 	}
 
 	for _, resource := range snapshot.Secrets {
@@ -113,7 +118,8 @@ func (a *algorithmSecret) reconcile(stack interfaces.SnapshotStack) (interfaces.
 
 func (a *algorithmSecret) lookupSecretSpec(name string) *swarm.SecretSpec {
 	for _, secretSpec := range a.stackSpec.Secrets {
-		if name == secretSpec.Annotations.Name {
+		if name == a.secretName(secretSpec) {
+			secretSpec.Annotations.Name = name
 			return &secretSpec
 		}
 	}
@@ -131,7 +137,7 @@ func (a *algorithmSecret) getGoalResources() []*interfaces.ReconcileResource {
 func (a *algorithmSecret) getSpecifiedResourceNames() []string {
 	result := make([]string, 0, len(a.stackSpec.Secrets))
 	for _, secretSpec := range a.stackSpec.Secrets {
-		result = append(result, secretSpec.Annotations.Name)
+		result = append(result, a.secretName(secretSpec))
 	}
 	return result
 }
@@ -165,7 +171,8 @@ func (a *algorithmSecret) addCreateResourceGoal(specName string) *interfaces.Rec
 	secretSpec := a.lookupSecretSpec(specName)
 	resource := &interfaces.ReconcileResource{
 		SnapshotResource: interfaces.SnapshotResource{
-			Name: secretSpec.Annotations.Name,
+			Name:     secretSpec.Annotations.Name,
+			SpecName: a.lookupSpecName(specName),
 		},
 		Config: secretSpec,
 		Kind:   a.getKind(),
@@ -197,6 +204,11 @@ func (a *algorithmSecret) storeGoals(previous interfaces.SnapshotStack) (interfa
 	// Simple copy + override
 	updated := previous
 	updated.Secrets = goalSecrets
+	hashes := map[string]string{}
+	for _, secretSpec := range a.stackSpec.Secrets {
+		hashes[resourceName(a.stackSpec, secretSpec.Annotations.Name)] = secretHash(secretSpec)
+	}
+	updated.Versions = storeVersions(previous.Versions, a.getKind(), hashes, a)
 
 	current, err := a.cli.UpdateSnapshotStack(a.stackID,
 		updated,
@@ -211,11 +223,11 @@ func (a *algorithmSecret) storeGoals(previous interfaces.SnapshotStack) (interfa
 func (a *algorithmSecret) hasSameConfiguration(resource interfaces.ReconcileResource, actual activeResource) bool {
 	one := resource.Config.(*swarm.SecretSpec)
 	two := actual.(activeSecret).secret.Spec
-	return one.Annotations.Name == two.Annotations.Name &&
-		compareMapsIgnoreStackLabel(one.Annotations.Labels, two.Annotations.Labels) &&
-		reflect.DeepEqual(one.Data, two.Data) &&
-		reflect.DeepEqual(one.Driver, two.Driver) &&
-		reflect.DeepEqual(one.Templating, two.Templating)
+	if isCreateOnly(one.Annotations.Labels) {
+		return true
+	}
+	one = withActualFields(one, &two, ignoredFields(one.Annotations.Labels)).(*swarm.SecretSpec)
+	return sameSecretConfiguration(*one, two)
 }
 
 func (a *algorithmSecret) createResource(resource *interfaces.ReconcileResource) error {
@@ -233,6 +245,19 @@ func (a *algorithmSecret) createResource(resource *interfaces.ReconcileResource)
 }
 
 func (a *algorithmSecret) deleteResource(resource *interfaces.ReconcileResource) error {
+	// A secret still in use is removed once the services were reconciled
+	if a.inUse == nil {
+		inUse, err := resourcesInUse(a.cli, a.stackID, a.getKind())
+		if err != nil {
+			return err
+		}
+		a.inUse = inUse
+	}
+	if a.inUse[resource.ID] || a.inUse[resource.Name] {
+		a.deferred = true
+		return nil
+	}
+
 	err := a.cli.RemoveSecret(resource.ID)
 	// Ignore not found error
 	if err != nil && !errdefs.IsNotFound(err) {
@@ -243,13 +268,52 @@ func (a *algorithmSecret) deleteResource(resource *interfaces.ReconcileResource)
 }
 
 func (a *algorithmSecret) updateResource(resource interfaces.ReconcileResource) error {
+	secretSpec := resource.Config.(*swarm.SecretSpec)
+
+	// The fields managed by other systems keep their current values
+	if paths := ignoredFields(secretSpec.Annotations.Labels); len(paths) > 0 {
+		secret, err := a.cli.GetSecret(resource.ID)
+		if err != nil {
+			return err
+		}
+		secretSpec = withActualFields(secretSpec, &secret.Spec, paths).(*swarm.SecretSpec)
+	}
+
 	// the response from UpdateSecret is irrelevant
 	err := a.cli.UpdateSecret(
 		resource.ID,
 		resource.Meta.Version.Index,
-		*resource.Config.(*swarm.SecretSpec))
+		*secretSpec)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (a *algorithmSecret) hasDeferred() bool {
+	return a.deferred
+}
+
+// secretName returns the name of the current version of secretSpec, or of
+// the version to create if its data changed
+func (a *algorithmSecret) secretName(secretSpec swarm.SecretSpec) string {
+	return versionedName(a.versions, a.getKind(),
+		resourceName(a.stackSpec, secretSpec.Annotations.Name), secretHash(secretSpec))
+}
+
+// lookupSpecName returns the name in the types.StackSpec of the secret
+// whose version is called name
+func (a *algorithmSecret) lookupSpecName(name string) string {
+	for _, secretSpec := range a.stackSpec.Secrets {
+		if name == a.secretName(secretSpec) {
+			return secretSpec.Annotations.Name
+		}
+	}
+	return specifiedName(a.stackSpec, name)
+}
+
+// secretHash returns the hash of the parts of secretSpec which cannot be
+// updated
+func secretHash(secretSpec swarm.SecretSpec) string {
+	return dataHash(secretSpec.Data, secretSpec.Driver, secretSpec.Templating)
+}
@@ -1,13 +1,12 @@
 package reconciler
 
 import (
-	"reflect"
-
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -19,6 +18,9 @@ type activeService struct {
 
 type initializationService struct {
 	cli interfaces.BackendClient
+	// notify is notified of the stacks to reconcile again, to go on with
+	// their rollouts
+	notify notifier.ObjectChangeNotifier
 }
 
 type algorithmService struct {
@@ -27,6 +29,12 @@ type algorithmService struct {
 	stackID           string
 	stackSpec         types.StackSpec
 	goals             map[string]*interfaces.ReconcileResource
+
+	// versions, secrets and configs are those of the stack, which the
+	// references of the services are resolved with
+	versions []interfaces.SnapshotVersion
+	secrets  []interfaces.SnapshotResource
+	configs  []interfaces.SnapshotResource
 }
 
 func (a activeService) getSnapshot() interfaces.SnapshotResource {
@@ -77,9 +85,10 @@ func (i initializationService) createPlugin(snapshot interfaces.SnapshotStack, r
 	return newAlgorithmPluginService(i, snapshot, requestedResource)
 }
 
-func newInitializationSupportService(cli interfaces.BackendClient) initializationService {
+func newInitializationSupportService(cli interfaces.BackendClient, notify notifier.ObjectChangeNotifier) initializationService {
 	return initializationService{
-		cli: cli,
+		cli:    cli,
+		notify: notify,
 	}
 }
 
@@ -90,6 +99,9 @@ func newAlgorithmPluginService(initService initializationService, snapshot inter
 		stackID:               snapshot.ID,
 		stackSpec:             snapshot.CurrentSpec,
 		goals:                 map[string]*interfaces.ReconcileResource{},
+		versions:              snapshot.Versions,
+		secrets:               snapshot.Secrets,
+		configs:               snapshot.Configs,
 	}
 
 	for _, resource := range snapshot.Services {
@@ -108,13 +120,26 @@ func (a *algorithmService) getRequestedResource() *interfaces.ReconcileResource
 }
 
 func (a *algorithmService) reconcile(stack interfaces.SnapshotStack) (interfaces.SnapshotStack, error) {
-	return reconcileResource(stack, a)
+	// The secrets and configs were reconciled since the plugin was
+	// created, and may have new versions
+	a.versions, a.secrets, a.configs = stack.Versions, stack.Secrets, stack.Configs
+	for _, goal := range a.goals {
+		goal.Config = a.lookupSpecifiedResource(goal.Name)
+	}
+
+	stack, err := reconcileResource(stack, a)
+	if err != nil {
+		return stack, err
+	}
+	return a.clearDeployments(stack)
 }
 
 func (a *algorithmService) lookupServiceSpec(name string) *swarm.ServiceSpec {
 	for _, serviceSpec := range a.stackSpec.Services {
-		if name == serviceSpec.Annotations.Name {
-			return &serviceSpec
+		if name == resourceName(a.stackSpec, serviceSpec.Annotations.Name) {
+			namespaced := namespaceServiceSpec(a.stackSpec, serviceSpec)
+			versioned := versionServiceSpec(a.versions, a.secrets, a.configs, namespaced)
+			return &versioned
 		}
 	}
 	return nil
@@ -131,7 +156,7 @@ func (a *algorithmService) getGoalResources() []*interfaces.ReconcileResource {
 func (a *algorithmService) getSpecifiedResourceNames() []string {
 	result := make([]string, 0, len(a.stackSpec.Services))
 	for _, serviceSpec := range a.stackSpec.Services {
-		result = append(result, serviceSpec.Annotations.Name)
+		result = append(result, resourceName(a.stackSpec, serviceSpec.Annotations.Name))
 	}
 	return result
 }
@@ -145,6 +170,11 @@ func (a *algorithmService) getActiveResources() ([]activeResource, error) {
 	}
 	result := make([]activeResource, 0, len(services))
 	for _, service := range services {
+		// The canary and green services of deployments are not part of
+		// the stack's goals
+		if _, ok := service.Spec.Annotations.Labels[types.StackDeploymentLabel]; ok {
+			continue
+		}
 		result = append(result, a.wrapService(service))
 	}
 	return result, nil
@@ -165,7 +195,8 @@ func (a *algorithmService) addCreateResourceGoal(specName string) *interfaces.Re
 	serviceSpec := a.lookupServiceSpec(specName)
 	resource := &interfaces.ReconcileResource{
 		SnapshotResource: interfaces.SnapshotResource{
-			Name: serviceSpec.Annotations.Name,
+			Name:     serviceSpec.Annotations.Name,
+			SpecName: specifiedName(a.stackSpec, specName),
 		},
 		Config: serviceSpec,
 		Kind:   a.getKind(),
@@ -211,14 +242,11 @@ func (a *algorithmService) storeGoals(previous interfaces.SnapshotStack) (interf
 func (a *algorithmService) hasSameConfiguration(resource interfaces.ReconcileResource, actual activeResource) bool {
 	one := resource.Config.(*swarm.ServiceSpec)
 	two := actual.(activeService).service.Spec
-	return one.Annotations.Name == two.Annotations.Name &&
-		compareMapsIgnoreStackLabel(one.Annotations.Labels, two.Annotations.Labels) &&
-		reflect.DeepEqual(one.TaskTemplate, two.TaskTemplate) &&
-		reflect.DeepEqual(one.Mode, two.Mode) &&
-		reflect.DeepEqual(one.UpdateConfig, two.UpdateConfig) &&
-		reflect.DeepEqual(one.RollbackConfig, two.RollbackConfig) &&
-		reflect.DeepEqual(one.Networks, two.Networks) &&
-		reflect.DeepEqual(one.EndpointSpec, two.EndpointSpec)
+	if isCreateOnly(one.Annotations.Labels) {
+		return true
+	}
+	one = withActualFields(one, &two, ignoredFields(one.Annotations.Labels)).(*swarm.ServiceSpec)
+	return sameServiceConfiguration(*one, two)
 }
 
 func (a *algorithmService) createResource(resource *interfaces.ReconcileResource) error {
@@ -248,11 +276,22 @@ func (a *algorithmService) deleteResource(resource *interfaces.ReconcileResource
 }
 
 func (a *algorithmService) updateResource(resource interfaces.ReconcileResource) error {
+	serviceSpec := resource.Config.(*swarm.ServiceSpec)
+
+	// The fields managed by other systems keep their current values
+	if paths := ignoredFields(serviceSpec.Annotations.Labels); len(paths) > 0 {
+		service, err := a.cli.GetService(resource.ID, interfaces.DefaultGetServiceArg2)
+		if err != nil {
+			return err
+		}
+		serviceSpec = withActualFields(serviceSpec, &service.Spec, paths).(*swarm.ServiceSpec)
+	}
+
 	// the response from UpdateService is irrelevant
 	_, err := a.cli.UpdateService(
 		resource.ID,
 		resource.Meta.Version.Index,
-		*resource.Config.(*swarm.ServiceSpec),
+		a.labelServiceSpec(*serviceSpec),
 		interfaces.DefaultUpdateServiceArg4,
 		interfaces.DefaultUpdateServiceArg5)
 	if err != nil {
@@ -260,3 +299,15 @@ func (a *algorithmService) updateResource(resource interfaces.ReconcileResource)
 	}
 	return nil
 }
+
+// labelServiceSpec returns a copy of serviceSpec labelled as belonging to
+// the stack, so that an update does not remove the service from the stack
+func (a *algorithmService) labelServiceSpec(serviceSpec swarm.ServiceSpec) swarm.ServiceSpec {
+	labels := make(map[string]string, len(serviceSpec.Annotations.Labels)+1)
+	for key, value := range serviceSpec.Annotations.Labels {
+		labels[key] = value
+	}
+	labels[types.StackLabel] = a.stackID
+	serviceSpec.Annotations.Labels = labels
+	return serviceSpec
+}
@@ -0,0 +1,215 @@
+package reconciler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/interfaces"
+)
+
+/**
+ *  Secret and config versions.
+ *
+ *  Secrets and configs cannot be updated apart from their labels.  The
+ *  first version of a secret or config keeps its name, and a change of its
+ *  data is rolled out as a new version called <name>-<hash>, where the hash
+ *  is that of the data.  The algorithm sees the new version as a resource to
+ *  create, and the previous version as a resource to delete.  The services
+ *  reference the current versions, so that they are rolled out with the new
+ *  data.  A version which is still in use is only removed once the services
+ *  were reconciled, or by a later reconciliation if tasks still use it.
+ */
+
+// versionHashLength is the number of hexadecimal digits of the hash of a
+// version in its name
+const versionHashLength = 10
+
+// deferringPlugin is implemented by the algorithmPlugins which defer the
+// deletion of resources still in use by the services of the stack. The
+// resources of such plugins are reconciled again after the services.
+type deferringPlugin interface {
+	hasDeferred() bool
+}
+
+// dataHash returns the hash of the immutable parts of a secret or config
+func dataHash(parts ...interface{}) string {
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])[:versionHashLength]
+}
+
+func findVersion(versions []interfaces.SnapshotVersion, kind interfaces.ReconcileKind, name string) *interfaces.SnapshotVersion {
+	for i := range versions {
+		if versions[i].Kind == kind && versions[i].Name == name {
+			return &versions[i]
+		}
+	}
+	return nil
+}
+
+// versionedName returns the name of the version of the resource name whose
+// data has hash
+func versionedName(versions []interfaces.SnapshotVersion, kind interfaces.ReconcileKind, name, hash string) string {
+	version := findVersion(versions, kind, name)
+	switch {
+	case version == nil:
+		return name
+	case version.Hash == hash:
+		return version.Current
+	default:
+		return name + "-" + hash
+	}
+}
+
+// storeVersions returns versions with the versions of the resources of kind
+// replaced by current, which maps the name of each specified resource to
+// the hash of its data. The previous versions of a resource are kept as
+// long as they are still among the goals of plugin.
+func storeVersions(versions []interfaces.SnapshotVersion, kind interfaces.ReconcileKind, current map[string]string, plugin algorithmPlugin) []interfaces.SnapshotVersion {
+	alive := func(name string) bool {
+		goal := plugin.getGoalResource(name)
+		return goal != nil && goal.ID != ""
+	}
+
+	result := []interfaces.SnapshotVersion{}
+	for _, version := range versions {
+		if version.Kind != kind {
+			result = append(result, version)
+		}
+	}
+	for _, name := range sortedKeys(current) {
+		hash := current[name]
+		stored := interfaces.SnapshotVersion{
+			Kind:    kind,
+			Name:    name,
+			Hash:    hash,
+			Current: versionedName(versions, kind, name, hash),
+		}
+		if previous := findVersion(versions, kind, name); previous != nil {
+			for _, candidate := range append([]string{previous.Current}, previous.Previous...) {
+				if candidate != stored.Current && alive(candidate) {
+					stored.Previous = appendMissing(stored.Previous, candidate)
+				}
+			}
+		}
+		result = append(result, stored)
+	}
+	return result
+}
+
+// resourcesInUse returns the names and IDs of the secrets or configs, as
+// of kind, referenced by the services of the stack stackID or by their tasks
+// which did not terminate yet
+func resourcesInUse(cli interfaces.BackendClient, stackID string, kind interfaces.ReconcileKind) (map[string]bool, error) {
+	inUse := map[string]bool{}
+	addReferences := func(containerSpec *swarm.ContainerSpec) {
+		if containerSpec == nil {
+			return
+		}
+		if kind == interfaces.ReconcileSecret {
+			for _, reference := range containerSpec.Secrets {
+				inUse[reference.SecretName], inUse[reference.SecretID] = true, true
+			}
+		} else {
+			for _, reference := range containerSpec.Configs {
+				inUse[reference.ConfigName], inUse[reference.ConfigID] = true, true
+			}
+		}
+	}
+
+	services, err := cli.GetServices(dockerTypes.ServiceListOptions{
+		Filters: stackLabelFilter(stackID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		addReferences(service.Spec.TaskTemplate.ContainerSpec)
+		tasks, err := cli.GetTasks(dockerTypes.TaskListOptions{
+			Filters: filters.NewArgs(filters.Arg("service", service.ID)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			if !terminated(task.Status.State) {
+				addReferences(task.Spec.ContainerSpec)
+			}
+		}
+	}
+	delete(inUse, "")
+	return inUse, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func terminated(state swarm.TaskState) bool {
+	switch state {
+	case swarm.TaskStateComplete, swarm.TaskStateShutdown, swarm.TaskStateFailed,
+		swarm.TaskStateRejected, swarm.TaskStateRemove, swarm.TaskStateOrphaned:
+		return true
+	}
+	return false
+}
+
+// versionServiceSpec returns a copy of serviceSpec whose references to the
+// secrets and configs of the stack are replaced by references to their
+// current versions. serviceSpec references the resources by the names they
+// have in Docker, and resources are the secrets and configs of the stack.
+func versionServiceSpec(versions []interfaces.SnapshotVersion, secrets, configs []interfaces.SnapshotResource, serviceSpec swarm.ServiceSpec) swarm.ServiceSpec {
+	if len(versions) == 0 || serviceSpec.TaskTemplate.ContainerSpec == nil {
+		return serviceSpec
+	}
+	resourceID := func(resources []interfaces.SnapshotResource, name string) string {
+		for _, resource := range resources {
+			if resource.Name == name {
+				return resource.ID
+			}
+		}
+		return ""
+	}
+
+	containerSpec := *serviceSpec.TaskTemplate.ContainerSpec
+	if containerSpec.Secrets != nil {
+		containerSpec.Secrets = make([]*swarm.SecretReference, 0, len(serviceSpec.TaskTemplate.ContainerSpec.Secrets))
+		for _, reference := range serviceSpec.TaskTemplate.ContainerSpec.Secrets {
+			copied := *reference
+			version := findVersion(versions, interfaces.ReconcileSecret, copied.SecretName)
+			if version != nil && version.Current != copied.SecretName {
+				copied.SecretName = version.Current
+				copied.SecretID = resourceID(secrets, version.Current)
+			}
+			containerSpec.Secrets = append(containerSpec.Secrets, &copied)
+		}
+	}
+	if containerSpec.Configs != nil {
+		containerSpec.Configs = make([]*swarm.ConfigReference, 0, len(serviceSpec.TaskTemplate.ContainerSpec.Configs))
+		for _, reference := range serviceSpec.TaskTemplate.ContainerSpec.Configs {
+			copied := *reference
+			version := findVersion(versions, interfaces.ReconcileConfig, copied.ConfigName)
+			if version != nil && version.Current != copied.ConfigName {
+				copied.ConfigName = version.Current
+				copied.ConfigID = resourceID(configs, version.Current)
+			}
+			containerSpec.Configs = append(containerSpec.Configs, &copied)
+		}
+	}
+	serviceSpec.TaskTemplate.ContainerSpec = &containerSpec
+	return serviceSpec
+}
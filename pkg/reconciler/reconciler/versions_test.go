@@ -0,0 +1,158 @@
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
+)
+
+var _ = Describe("Rotating secrets and configs", func() {
+	var (
+		cli     *fakes.FakeReconcilerClient
+		r       *reconciler
+		id      string
+		request *interfaces.ReconcileResource
+	)
+
+	serviceUsing := func(name string) swarm.ServiceSpec {
+		spec := getReplicatedServiceSpec(name, "nginx:1.16", 1)
+		spec.TaskTemplate.ContainerSpec.Secrets = []*swarm.SecretReference{{
+			File:       &swarm.SecretReferenceFileTarget{Name: "password"},
+			SecretName: "password",
+		}}
+		spec.TaskTemplate.ContainerSpec.Configs = []*swarm.ConfigReference{{
+			File:       &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx/nginx.conf"},
+			ConfigName: "nginx.conf",
+		}}
+		return spec
+	}
+
+	rotate := func(password, conf string) {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Secrets[0].Data = []byte(password)
+		stack.Spec.Configs[0].Data = []byte(conf)
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+	}
+
+	secretExists := func(name string) bool {
+		_, err := cli.GetSecret(name)
+		if errdefs.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		cli = fakes.NewFakeReconcilerClient()
+		r = newReconciler(notifier.NewNotificationForwarder(), cli)
+
+		spec := types.StackSpec{
+			Annotations: swarm.Annotations{
+				Name: "app",
+			},
+			Services: []swarm.ServiceSpec{serviceUsing("web")},
+			Secrets: []swarm.SecretSpec{{
+				Annotations: swarm.Annotations{Name: "password"},
+				Data:        []byte("one"),
+			}},
+			Configs: []swarm.ConfigSpec{{
+				Annotations: swarm.Annotations{Name: "nginx.conf"},
+				Data:        []byte("worker_processes 1;"),
+			}},
+		}
+		var err error
+		id, err = cli.AddStack(spec)
+		Expect(err).ToNot(HaveOccurred())
+		request = &interfaces.ReconcileResource{
+			SnapshotResource: interfaces.SnapshotResource{ID: id},
+			Kind:             interfaces.ReconcileStack,
+		}
+		Expect(r.Reconcile(request)).To(Succeed())
+	})
+
+	It("keeps the name of the first version", func() {
+		Expect(secretExists("password")).To(BeTrue())
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot.Versions).To(HaveLen(2))
+		for _, version := range snapshot.Versions {
+			Expect(version.Current).To(Equal(version.Name))
+		}
+	})
+
+	It("rolls the services out with new versions", func() {
+		rotate("two", "worker_processes 2;")
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		secretName := "password-" + secretHash(swarm.SecretSpec{Data: []byte("two")})
+		configName := "nginx.conf-" + configHash(swarm.ConfigSpec{Data: []byte("worker_processes 2;")})
+		secret, err := cli.GetSecret(secretName)
+		Expect(err).ToNot(HaveOccurred())
+		config, err := cli.GetConfig(configName)
+		Expect(err).ToNot(HaveOccurred())
+
+		web, err := cli.GetService("web", false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Secrets[0].SecretName).To(Equal(secretName))
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Secrets[0].SecretID).To(Equal(secret.ID))
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Configs[0].ConfigName).To(Equal(configName))
+		Expect(web.Spec.TaskTemplate.ContainerSpec.Configs[0].ConfigID).To(Equal(config.ID))
+
+		// The previous versions are unused, and removed
+		Expect(secretExists("password")).To(BeFalse())
+		_, err = cli.GetConfig("nginx.conf")
+		Expect(errdefs.IsNotFound(err)).To(BeTrue())
+
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		version := findVersion(snapshot.Versions, interfaces.ReconcileSecret, "password")
+		Expect(version).ToNot(BeNil())
+		Expect(version.Current).To(Equal(secretName))
+		Expect(version.Previous).To(BeEmpty())
+
+		// Reconciling again changes nothing
+		Expect(r.Reconcile(request)).To(Succeed())
+		Expect(secretExists(secretName)).To(BeTrue())
+	})
+
+	It("keeps previous versions while they are in use", func() {
+		stack, err := cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		worker := serviceUsing("worker")
+		worker.Annotations.Labels = map[string]string{types.StackCreateOnlyLabel: "true"}
+		stack.Spec.Services = append(stack.Spec.Services, worker)
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		rotate("two", "worker_processes 2;")
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		// The create-only worker still uses the first version
+		Expect(secretExists("password")).To(BeTrue())
+		snapshot, err := cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		version := findVersion(snapshot.Versions, interfaces.ReconcileSecret, "password")
+		Expect(version.Previous).To(Equal([]string{"password"}))
+
+		stack, err = cli.GetStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		stack.Spec.Services[1].Annotations.Labels = nil
+		Expect(cli.UpdateStack(id, stack.Spec, stack.Version.Index)).To(Succeed())
+		Expect(r.Reconcile(request)).To(Succeed())
+
+		Expect(secretExists("password")).To(BeFalse())
+		snapshot, err = cli.GetSnapshotStack(id)
+		Expect(err).ToNot(HaveOccurred())
+		version = findVersion(snapshot.Versions, interfaces.ReconcileSecret, "password")
+		Expect(version.Previous).To(BeEmpty())
+	})
+})
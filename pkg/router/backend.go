@@ -0,0 +1,270 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// DefaultBackendTimeout is how long each call to a backend may take,
+	// unless the backend is registered with another timeout.
+	DefaultBackendTimeout = 30 * time.Second
+
+	// DefaultBreakerThreshold is the number of consecutive failures of a
+	// backend after which it is no longer called, until
+	// DefaultBreakerCooldown elapsed.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long a failing backend is no longer
+	// called for.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// BackendOptionFunc is the type used for functional arguments of the
+// backends of a StacksRouter during their registration.
+type BackendOptionFunc func(*backend)
+
+// WithBackendTimeout is a BackendOptionFunc which bounds the duration of
+// each call to the backend by timeout, instead of DefaultBackendTimeout.
+// Zero does not bound it.
+func WithBackendTimeout(timeout time.Duration) BackendOptionFunc {
+	return func(b *backend) {
+		b.timeout = timeout
+	}
+}
+
+// WithCircuitBreaker is a BackendOptionFunc which stops calling the backend
+// for cooldown after threshold consecutive failures, instead of
+// DefaultBreakerThreshold and DefaultBreakerCooldown. Calls to a backend
+// which is not called fail with an errdefs.Unavailable error.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) BackendOptionFunc {
+	return func(b *backend) {
+		b.threshold = threshold
+		b.cooldown = cooldown
+	}
+}
+
+// backend is a registered backend of a StacksRouter. It bounds the duration
+// of the calls to the StackAPIClient of the backend, and stops calling it
+// while it keeps failing. Errors of requests, such as stacks which are not
+// found or invalid specs, are not failures of the backend.
+type backend struct {
+	client       client.StackAPIClient
+	orchestrator types.OrchestratorChoice
+	timeout      time.Duration
+	threshold    int
+	cooldown     time.Duration
+	now          func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newBackend(orchestrator types.OrchestratorChoice, stackClient client.StackAPIClient, optsFunc ...BackendOptionFunc) *backend {
+	b := &backend{
+		client:       stackClient,
+		orchestrator: orchestrator,
+		timeout:      DefaultBackendTimeout,
+		threshold:    DefaultBreakerThreshold,
+		cooldown:     DefaultBreakerCooldown,
+		now:          time.Now,
+	}
+
+	for _, f := range optsFunc {
+		f(b)
+	}
+
+	return b
+}
+
+// call calls f with a context bounded by the timeout of the backend, unless
+// the circuit breaker of the backend is open
+func (b *backend) call(ctx context.Context, f func(context.Context) error) error {
+	b.mu.Lock()
+	if b.failures >= b.threshold && b.now().Before(b.openUntil) {
+		b.mu.Unlock()
+		return errdefs.Unavailable(fmt.Errorf("backend %s is unavailable after %d consecutive failures", b.orchestrator, b.failures))
+	}
+	b.mu.Unlock()
+
+	callCtx := ctx
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+	err := f(callCtx)
+	if err != nil && callCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = errdefs.Deadline(fmt.Errorf("backend %s timed out after %s: %s", b.orchestrator, b.timeout, err))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Calls cancelled by the caller tell nothing of the backend
+	if ctx.Err() != nil {
+		return err
+	}
+	if !isBackendFailure(err) {
+		b.failures = 0
+		return err
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+	return err
+}
+
+// isBackendFailure returns whether err is a failure of a backend, rather
+// than an error of the request
+func isBackendFailure(err error) bool {
+	switch {
+	case err == nil,
+		errdefs.IsNotFound(err),
+		errdefs.IsInvalidParameter(err),
+		errdefs.IsConflict(err),
+		errdefs.IsAlreadyExists(err),
+		errdefs.IsUnauthorized(err),
+		errdefs.IsForbidden(err),
+		errdefs.IsNotModified(err),
+		errdefs.IsNotImplemented(err):
+		return false
+	}
+	return true
+}
+
+func (b *backend) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (resp types.StackCreateResponse, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		resp, err = b.client.StackCreate(ctx, spec, options)
+		return err
+	})
+	return resp, err
+}
+
+func (b *backend) StackValidate(ctx context.Context, spec types.StackSpec) (result types.StackValidationResult, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		result, err = b.client.StackValidate(ctx, spec)
+		return err
+	})
+	return result, err
+}
+
+func (b *backend) StackInspect(ctx context.Context, id string) (stack types.Stack, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		stack, err = b.client.StackInspect(ctx, id)
+		return err
+	})
+	return stack, err
+}
+
+func (b *backend) StackList(ctx context.Context, options types.StackListOptions) (stacks []types.Stack, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		stacks, err = b.client.StackList(ctx, options)
+		return err
+	})
+	return stacks, err
+}
+
+func (b *backend) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackUpdate(ctx, id, version, spec, options)
+	})
+}
+
+func (b *backend) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackScale(ctx, id, replicas)
+	})
+}
+
+func (b *backend) StackPause(ctx context.Context, id string) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackPause(ctx, id)
+	})
+}
+
+func (b *backend) StackResume(ctx context.Context, id string) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackResume(ctx, id)
+	})
+}
+
+func (b *backend) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, patch []byte, options types.StackUpdateOptions) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackPatch(ctx, id, version, patchType, patch, options)
+	})
+}
+
+func (b *backend) StackDelete(ctx context.Context, id string) error {
+	return b.call(ctx, func(ctx context.Context) error {
+		return b.client.StackDelete(ctx, id)
+	})
+}
+
+func (b *backend) StackMigrate(ctx context.Context, id string, options types.StackMigrateOptions) (migration types.StackMigration, err error) {
+	err = b.call(ctx, func(ctx context.Context) error {
+		migration, err = b.client.StackMigrate(ctx, id, options)
+		return err
+	})
+	return migration, err
+}
+
+// StackTasks returns the tasks of a stack, if the StackAPIClient of the
+// backend reports them
+func (b *backend) StackTasks(ctx context.Context, id string) (tasks []types.StackTask, err error) {
+	lister, ok := b.client.(taskLister)
+	if !ok {
+		return nil, errdefs.NotImplemented(fmt.Errorf("backend %s does not report the tasks of stacks", b.orchestrator))
+	}
+	err = b.call(ctx, func(ctx context.Context) error {
+		tasks, err = lister.StackTasks(ctx, id)
+		return err
+	})
+	return tasks, err
+}
+
+// StackSpecWithSecrets returns the StackSpec of a stack with the data of
+// its secrets, if the StackAPIClient of the backend discloses it
+func (b *backend) StackSpecWithSecrets(ctx context.Context, id string) (spec types.StackSpec, err error) {
+	reader, ok := b.client.(secretsReader)
+	if !ok {
+		return types.StackSpec{}, errdefs.NotImplemented(fmt.Errorf("backend %s does not disclose the data of secrets", b.orchestrator))
+	}
+	err = b.call(ctx, func(ctx context.Context) error {
+		spec, err = reader.StackSpecWithSecrets(ctx, id)
+		return err
+	})
+	return spec, err
+}
+
+// BackendErrors aggregates the errors of the backends of a StacksRouter
+// when an operation failed on several of them, by orchestrator.
+type BackendErrors map[types.OrchestratorChoice]error
+
+func (e BackendErrors) Error() string {
+	orchestrators := make([]string, 0, len(e))
+	for orchestrator := range e {
+		orchestrators = append(orchestrators, string(orchestrator))
+	}
+	sort.Strings(orchestrators)
+
+	msg := "encountered 1 backend error"
+	if len(e) > 1 {
+		msg = fmt.Sprintf("encountered %d backend errors", len(e))
+	}
+	errs := make([]string, 0, len(e))
+	for _, orchestrator := range orchestrators {
+		errs = append(errs, fmt.Sprintf("backend %s: %s", orchestrator, e[types.OrchestratorChoice(orchestrator)]))
+	}
+	return msg + ": " + strings.Join(errs, "; ")
+}
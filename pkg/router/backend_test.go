@@ -0,0 +1,198 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/client/fake"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// flakyBackend is a fake backend whose inspects, listings and deletes fail
+// with err while it is set, or wait for their context to be done while
+// hang is set
+type flakyBackend struct {
+	*fake.StackClient
+
+	mu    sync.Mutex
+	err   error
+	hang  bool
+	calls int
+}
+
+func (b *flakyBackend) set(err error, hang bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+	b.hang = hang
+}
+
+func (b *flakyBackend) called() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+func (b *flakyBackend) fail(ctx context.Context) error {
+	b.mu.Lock()
+	b.calls++
+	err, hang := b.err, b.hang
+	b.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
+
+func (b *flakyBackend) StackInspect(ctx context.Context, id string) (types.Stack, error) {
+	if err := b.fail(ctx); err != nil {
+		return types.Stack{}, err
+	}
+	return b.StackClient.StackInspect(ctx, id)
+}
+
+func (b *flakyBackend) StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	if err := b.fail(ctx); err != nil {
+		return nil, err
+	}
+	return b.StackClient.StackList(ctx, options)
+}
+
+func (b *flakyBackend) StackDelete(ctx context.Context, id string) error {
+	if err := b.fail(ctx); err != nil {
+		return err
+	}
+	return b.StackClient.StackDelete(ctx, id)
+}
+
+func newFlakyRouter(optsFunc ...BackendOptionFunc) (*StacksRouter, *fake.StackClient, *flakyBackend) {
+	router := NewStacksRouter()
+	swarmBackend := fake.NewStackClient()
+	kubeBackend := &flakyBackend{StackClient: fake.NewStackClient(fake.WithStartingID(5000))}
+	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
+	router.RegisterBackend(types.OrchestratorKubernetes, kubeBackend, optsFunc...)
+	return router, swarmBackend, kubeBackend
+}
+
+func TestListPartial(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, swarmBackend, kubeBackend := newFlakyRouter()
+
+	_, err := swarmBackend.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = kubeBackend.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	// The stacks of a failing backend are left out of the listing
+	kubeBackend.set(errors.New("connection refused"), false)
+	list, err := router.StackListPartial(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(list.Items, 1)
+	require.Equal("swarm-stack", list.Items[0].Spec.Annotations.Name)
+	require.Len(list.Warnings, 1)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), list.Warnings[0].Orchestrator)
+	require.Contains(list.Warnings[0].Message, "connection refused")
+
+	stacks, err := router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+
+	// A listing fails if all its backends fail
+	_, err = router.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("orchestrator", types.OrchestratorKubernetes)),
+	})
+	require.Error(err)
+	backendErrs, ok := err.(BackendErrors)
+	require.True(ok)
+	require.Contains(backendErrs, types.OrchestratorChoice(types.OrchestratorKubernetes))
+}
+
+func TestBackendTimeout(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, swarmBackend, kubeBackend := newFlakyRouter(WithBackendTimeout(10 * time.Millisecond))
+
+	resp, err := swarmBackend.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	// A backend which hangs does not prevent finding stacks in the others
+	kubeBackend.set(nil, true)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+
+	// but a stack which is not found may be deployed on it
+	_, err = router.StackInspect(ctx, "nosuchid")
+	require.Error(err)
+	require.False(errdefs.IsNotFound(err))
+	require.Contains(err.Error(), "timed out")
+
+	list, err := router.StackListPartial(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(list.Items, 1)
+	require.Len(list.Warnings, 1)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, _, kubeBackend := newFlakyRouter(WithCircuitBreaker(2, time.Minute))
+	now := time.Now()
+	router.backends[types.OrchestratorKubernetes].(*backend).now = func() time.Time {
+		return now
+	}
+
+	kubeBackend.set(errors.New("connection refused"), false)
+	for i := 0; i < 2; i++ {
+		_, err := router.StackListPartial(ctx, types.StackListOptions{})
+		require.NoError(err)
+	}
+	require.Equal(2, kubeBackend.called())
+
+	// The backend is no longer called after consecutive failures
+	list, err := router.StackListPartial(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Equal(2, kubeBackend.called())
+	require.Contains(list.Warnings[0].Message, "unavailable")
+
+	// Stacks which are not found are not failures of the backend
+	kubeBackend.set(errdefs.NotFound(errors.New("stack not found")), false)
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		_, err = router.StackInspect(ctx, "nosuchid")
+		require.True(errdefs.IsNotFound(err))
+	}
+	require.Equal(5, kubeBackend.called())
+}
+
+func TestDeleteAllBackends(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, swarmBackend, kubeBackend := newFlakyRouter()
+
+	resp, err := swarmBackend.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	// The stack is deleted from every backend, despite the failing ones
+	kubeBackend.set(errors.New("connection refused"), false)
+	err = router.StackDelete(ctx, resp.ID)
+	require.Error(err)
+	backendErrs, ok := err.(BackendErrors)
+	require.True(ok)
+	require.Len(backendErrs, 1)
+	require.Contains(backendErrs[types.OrchestratorKubernetes].Error(), "connection refused")
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+
+	kubeBackend.set(nil, false)
+	require.NoError(router.StackDelete(ctx, resp.ID))
+}
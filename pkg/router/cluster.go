@@ -0,0 +1,236 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// ClusterSeparator separates the name of a cluster from the ID of a stack
+// in this cluster, in the IDs of the stacks of a federated StacksRouter.
+const ClusterSeparator = ":"
+
+// JoinClusterID returns the ID of the stack id of cluster in a federated
+// StacksRouter.
+func JoinClusterID(cluster, id string) string {
+	return cluster + ClusterSeparator + id
+}
+
+// SplitClusterID returns the cluster and the ID in this cluster of the
+// stack id of a federated StacksRouter, or false if id is not the ID of a
+// stack of a federated StacksRouter.
+func SplitClusterID(id string) (string, string, bool) {
+	parts := strings.SplitN(id, ClusterSeparator, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// WithDefaultCluster is a StacksRouterOptionFunc which creates and
+// validates the stacks which do not name a cluster on cluster. Without it,
+// they are only created on the single cluster of a federated router.
+func WithDefaultCluster(cluster string) StacksRouterOptionFunc {
+	return func(s *StacksRouter) {
+		s.defaultCluster = cluster
+	}
+}
+
+// RegisterCluster registers the client of the stacks controller of the
+// cluster name as a backend of the StacksRouter, which then federates
+// clusters rather than routing stacks to the backends of orchestrators.
+// The IDs of the stacks of the cluster are prefixed with its name, and the
+// calls to its controller are bounded and stopped as those to any other
+// backend.
+func (s *StacksRouter) RegisterCluster(name string, stackClient client.StackAPIClient, optsFunc ...BackendOptionFunc) {
+	s.federated = true
+	s.RegisterBackend(types.OrchestratorChoice(name), &clusterClient{
+		name:   name,
+		client: stackClient,
+	}, optsFunc...)
+}
+
+// Clusters returns the names of the clusters of a federated router, sorted.
+func (s *StacksRouter) Clusters() []string {
+	if !s.federated {
+		return nil
+	}
+	names := make([]string, 0, len(s.backends))
+	for name := range s.backends {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getCluster returns the backend of cluster, or of the default cluster if
+// cluster is empty
+func (s *StacksRouter) getCluster(cluster string) (types.OrchestratorChoice, client.StackAPIClient, error) {
+	if cluster == "" {
+		cluster = s.defaultCluster
+	}
+	if cluster == "" && len(s.backends) == 1 {
+		cluster = s.Clusters()[0]
+	}
+	if cluster == "" {
+		return "", nil, errdefs.InvalidParameter(fmt.Errorf("no cluster given, among %s", strings.Join(s.Clusters(), ", ")))
+	}
+	backend, ok := s.backends[types.OrchestratorChoice(cluster)]
+	if !ok {
+		return "", nil, errdefs.InvalidParameter(fmt.Errorf("unknown cluster %s", cluster))
+	}
+	return types.OrchestratorChoice(cluster), backend, nil
+}
+
+// clusterClient is the client of the stacks controller of a cluster, in
+// the IDs of a federated StacksRouter. The stacks of other clusters are
+// not found without calling the controller.
+type clusterClient struct {
+	name   string
+	client client.StackAPIClient
+}
+
+// stackID returns the ID in the cluster of the stack id
+func (c *clusterClient) stackID(id string) (string, error) {
+	cluster, stackID, ok := SplitClusterID(id)
+	if !ok || cluster != c.name {
+		return "", errdefs.NotFound(fmt.Errorf("stack %s not found", id))
+	}
+	return stackID, nil
+}
+
+// federateStack rewrites the IDs of a stack of the cluster into IDs of the
+// router
+func (c *clusterClient) federateStack(stack types.Stack) types.Stack {
+	stack.ID = JoinClusterID(c.name, stack.ID)
+	stack.Cluster = c.name
+	if stack.Migration != nil {
+		migration := c.federateMigration(*stack.Migration)
+		stack.Migration = &migration
+	}
+	return stack
+}
+
+func (c *clusterClient) federateMigration(migration types.StackMigration) types.StackMigration {
+	migration.SourceID = JoinClusterID(c.name, migration.SourceID)
+	if migration.TargetID != "" {
+		migration.TargetID = JoinClusterID(c.name, migration.TargetID)
+	}
+	return migration
+}
+
+func (c *clusterClient) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	options.Cluster = ""
+	resp, err := c.client.StackCreate(ctx, spec, options)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	resp.ID = JoinClusterID(c.name, resp.ID)
+	return resp, nil
+}
+
+func (c *clusterClient) StackValidate(ctx context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	return c.client.StackValidate(ctx, spec)
+}
+
+func (c *clusterClient) StackInspect(ctx context.Context, id string) (types.Stack, error) {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return types.Stack{}, err
+	}
+	stack, err := c.client.StackInspect(ctx, stackID)
+	if err != nil {
+		return types.Stack{}, err
+	}
+	return c.federateStack(stack), nil
+}
+
+// StackList lists the stacks of the cluster. The controller knows neither
+// the name of its cluster nor the IDs of its stacks in the router, so that
+// these filters are left to the router.
+func (c *clusterClient) StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error) {
+	clusterOptions := types.StackListOptions{
+		Filters: options.Filters.Clone(),
+	}
+	for _, value := range options.Filters.Get("id") {
+		clusterOptions.Filters.Del("id", value)
+	}
+
+	stacks, err := c.client.StackList(ctx, clusterOptions)
+	if err != nil {
+		return nil, err
+	}
+	federated := make([]types.Stack, len(stacks))
+	for i, stack := range stacks {
+		federated[i] = c.federateStack(stack)
+	}
+	return federated, nil
+}
+
+func (c *clusterClient) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackUpdate(ctx, stackID, version, spec, options)
+}
+
+func (c *clusterClient) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackScale(ctx, stackID, replicas)
+}
+
+func (c *clusterClient) StackPause(ctx context.Context, id string) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackPause(ctx, stackID)
+}
+
+func (c *clusterClient) StackResume(ctx context.Context, id string) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackResume(ctx, stackID)
+}
+
+func (c *clusterClient) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, patch []byte, options types.StackUpdateOptions) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackPatch(ctx, stackID, version, patchType, patch, options)
+}
+
+func (c *clusterClient) StackDelete(ctx context.Context, id string) error {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return err
+	}
+	return c.client.StackDelete(ctx, stackID)
+}
+
+// StackMigrate migrates a stack to another orchestrator of the cluster.
+// Stacks are not migrated between clusters.
+func (c *clusterClient) StackMigrate(ctx context.Context, id string, options types.StackMigrateOptions) (types.StackMigration, error) {
+	stackID, err := c.stackID(id)
+	if err != nil {
+		return types.StackMigration{}, err
+	}
+	migration, err := c.client.StackMigrate(ctx, stackID, options)
+	if err != nil {
+		return types.StackMigration{}, err
+	}
+	return c.federateMigration(migration), nil
+}
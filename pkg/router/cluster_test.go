@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/client/fake"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func clusterSpec(name string) types.StackSpec {
+	return types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: name,
+		},
+	}
+}
+
+// unreachableCluster is a fake cluster whose controller cannot be reached
+type unreachableCluster struct {
+	*fake.StackClient
+}
+
+func (c unreachableCluster) StackList(context.Context, types.StackListOptions) ([]types.Stack, error) {
+	return nil, errors.New("connection refused")
+}
+
+func newTestFederation(optsFunc ...StacksRouterOptionFunc) (*StacksRouter, *fake.StackClient, *fake.StackClient) {
+	f := NewStacksRouter(optsFunc...)
+	east := fake.NewStackClient()
+	west := fake.NewStackClient()
+	f.RegisterCluster("east", east)
+	f.RegisterCluster("west", west)
+	return f, east, west
+}
+
+func TestFederationCreate(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	f, east, west := newTestFederation()
+
+	// Stacks are created on the cluster they name, and identified by it
+	resp, err := f.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{Cluster: "west"})
+	require.NoError(err)
+	require.Equal("west:1", resp.ID)
+	_, err = west.StackInspect(ctx, "1")
+	require.NoError(err)
+	_, err = east.StackInspect(ctx, "1")
+	require.True(errdefs.IsNotFound(err))
+
+	stack, err := f.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal("west:1", stack.ID)
+	require.Equal("west", stack.Cluster)
+	require.Equal("web", stack.Spec.Annotations.Name)
+	require.Empty(stack.Orchestrator)
+
+	// Stacks which do not name a cluster need a default one
+	_, err = f.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = f.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{Cluster: "north"})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	f, east, _ = newTestFederation(WithDefaultCluster("east"))
+	resp, err = f.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{})
+	require.NoError(err)
+	require.Equal("east:1", resp.ID)
+	_, err = east.StackInspect(ctx, "1")
+	require.NoError(err)
+}
+
+func TestFederationRouting(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	f, east, west := newTestFederation()
+
+	_, err := east.StackCreate(ctx, clusterSpec("db"), types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = west.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{})
+	require.NoError(err)
+
+	// Stacks with the same ID in their clusters are told apart
+	require.NoError(f.StackPause(ctx, "west:1"))
+	stack, err := west.StackInspect(ctx, "1")
+	require.NoError(err)
+	require.True(stack.Paused)
+	stack, err = east.StackInspect(ctx, "1")
+	require.NoError(err)
+	require.False(stack.Paused)
+
+	for _, id := range []string{"1", "north:1", "east:", "east:2"} {
+		_, err = f.StackInspect(ctx, id)
+		require.True(errdefs.IsNotFound(err), id)
+	}
+
+	require.NoError(f.StackDelete(ctx, "east:1"))
+	_, err = east.StackInspect(ctx, "1")
+	require.True(errdefs.IsNotFound(err))
+	require.NoError(f.StackDelete(ctx, "north:1"))
+
+	// Stacks are not migrated by the fake clusters, which serve a single
+	// orchestrator
+	_, err = f.StackMigrate(ctx, "west:1", types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.True(errdefs.IsNotImplemented(err))
+}
+
+func TestFederationList(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	f, east, west := newTestFederation()
+
+	_, err := east.StackCreate(ctx, clusterSpec("db"), types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = west.StackCreate(ctx, clusterSpec("web"), types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = west.StackCreate(ctx, clusterSpec("cache"), types.StackCreateOptions{})
+	require.NoError(err)
+
+	stacks, err := f.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 3)
+	require.Equal("west:2", stacks[0].ID)
+	require.Equal("east:1", stacks[1].ID)
+	require.Equal("west:1", stacks[2].ID)
+
+	stacks, err = f.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("cluster", "west")),
+	})
+	require.NoError(err)
+	require.Len(stacks, 2)
+	for _, stack := range stacks {
+		require.Equal("west", stack.Cluster)
+	}
+
+	stacks, err = f.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("id", "east:")),
+	})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal("db", stacks[0].Spec.Annotations.Name)
+
+	// Pages span clusters
+	stacks, err = f.StackList(ctx, types.StackListOptions{Limit: 2})
+	require.NoError(err)
+	require.Len(stacks, 2)
+	require.Equal("east:1", stacks[1].ID)
+
+	// The stacks of clusters which cannot be reached are left out
+	f.RegisterCluster("north", unreachableCluster{fake.NewStackClient()})
+	list, err := f.StackListPartial(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(list.Items, 3)
+	require.Len(list.Warnings, 1)
+	require.Equal("north", list.Warnings[0].Cluster)
+	require.Contains(list.Warnings[0].Message, "connection refused")
+
+	_, err = f.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("cluster", "north")),
+	})
+	require.Error(err)
+	backendErrs, ok := err.(BackendErrors)
+	require.True(ok)
+	require.Contains(backendErrs, types.OrchestratorChoice("north"))
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"sync"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// stackIndex indexes the orchestrator of the backend of stacks by their ID.
+// It is filled as stacks are created, listed and looked for, and entries
+// are only hints: they are checked against the backends before being
+// trusted.
+type stackIndex struct {
+	mu     sync.Mutex
+	stacks map[string]types.OrchestratorChoice
+	stats  types.StackIndexStats
+}
+
+func newStackIndex() *stackIndex {
+	return &stackIndex{
+		stacks: make(map[string]types.OrchestratorChoice),
+	}
+}
+
+func (i *stackIndex) get(id string) (types.OrchestratorChoice, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	orchestrator, ok := i.stacks[id]
+	return orchestrator, ok
+}
+
+func (i *stackIndex) set(id string, orchestrator types.OrchestratorChoice) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stacks[id] = orchestrator
+}
+
+func (i *stackIndex) invalidate(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.stacks[id]; ok {
+		delete(i.stacks, id)
+		i.stats.Invalidations++
+	}
+}
+
+func (i *stackIndex) hit() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.Hits++
+}
+
+func (i *stackIndex) miss() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.Misses++
+}
+
+func (i *stackIndex) statistics() types.StackIndexStats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	stats := i.stats
+	stats.Size = len(i.stacks)
+	return stats
+}
+
+// IndexStats returns the statistics of the index of the router, from the
+// IDs of stacks to the orchestrator of the backend holding them.
+func (s *StacksRouter) IndexStats() types.StackIndexStats {
+	return s.index.statistics()
+}
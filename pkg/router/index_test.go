@@ -0,0 +1,52 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestIndex(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, swarmBackend, kubeBackend := newFlakyRouter()
+
+	// Created stacks are only looked for in their backend
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+	require.NoError(router.StackPause(ctx, resp.ID))
+	require.Equal(0, kubeBackend.called())
+	require.Equal(types.StackIndexStats{Hits: 2, Size: 1}, router.IndexStats())
+
+	// and so are listed stacks
+	kubeResp, err := kubeBackend.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Equal(1, kubeBackend.called())
+	_, err = router.StackInspect(ctx, kubeResp.ID)
+	require.NoError(err)
+	require.Equal(2, kubeBackend.called())
+	require.Equal(types.StackIndexStats{Hits: 3, Size: 2}, router.IndexStats())
+
+	// Stacks which are no longer in their backend are looked for in all
+	// backends
+	require.NoError(swarmBackend.StackDelete(ctx, resp.ID))
+	_, err = router.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+	require.Equal(types.StackIndexStats{Hits: 3, Misses: 1, Invalidations: 1, Size: 1}, router.IndexStats())
+
+	// Deleted stacks are removed from the index
+	require.NoError(router.StackDelete(ctx, kubeResp.ID))
+	stats := router.IndexStats()
+	require.Equal(0, stats.Size)
+	require.Equal(uint64(2), stats.Invalidations)
+	require.Equal(0.75, stats.HitRate())
+}
@@ -0,0 +1,697 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// defaultPollInterval is how often the convergence of migrated stacks is
+// checked
+const defaultPollInterval = 2 * time.Second
+
+// migrationRetention is how long the migrations which completed or were
+// rolled back are reported, before they are forgotten
+const migrationRetention = 24 * time.Hour
+
+const (
+	// kubernetesPhaseProgressing and kubernetesPhaseFailure are phases of
+	// the stacks of compose-on-kubernetes
+	kubernetesPhaseProgressing types.StackPhase = "Progressing"
+	kubernetesPhaseFailure     types.StackPhase = "Failure"
+)
+
+// ConvergenceFunc returns whether the stack id of backend converged, that
+// is whether it runs as specified. An error stops waiting for it.
+type ConvergenceFunc func(ctx context.Context, backend client.StackAPIClient, id string) (bool, error)
+
+// WithConvergenceCheck is a StacksRouterOptionFunc which replaces how the
+// router decides that a migrated stack converged on its target
+// orchestrator.
+func WithConvergenceCheck(converged ConvergenceFunc) StacksRouterOptionFunc {
+	return func(s *StacksRouter) {
+		s.converged = converged
+	}
+}
+
+// taskLister is implemented by the backends which report the tasks of
+// stacks
+type taskLister interface {
+	StackTasks(ctx context.Context, id string) ([]types.StackTask, error)
+}
+
+// secretsReader is implemented by the backends whose StackInspect redacts
+// the data of secrets, but which disclose it to migrate stacks
+type secretsReader interface {
+	StackSpecWithSecrets(ctx context.Context, id string) (types.StackSpec, error)
+}
+
+// stackConverged is the default ConvergenceFunc. A stack converged once its
+// status reports neither an update in progress nor an error and, if its
+// backend reports the tasks of stacks, all its tasks are running.
+func stackConverged(ctx context.Context, backend client.StackAPIClient, id string) (bool, error) {
+	stack, err := backend.StackInspect(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	switch stack.Status.Phase {
+	case types.StackPhaseRolledBack, kubernetesPhaseFailure:
+		return false, fmt.Errorf("the stack failed to deploy: %s", stack.Status.Message)
+	case types.StackPhaseUpdating, kubernetesPhaseProgressing:
+		return false, nil
+	}
+	// Errors are reported until the stack is reconciled successfully
+	if stack.Status.Message != "" {
+		return false, nil
+	}
+
+	lister, ok := backend.(taskLister)
+	if !ok {
+		return true, nil
+	}
+	tasks, err := lister.StackTasks(ctx, id)
+	if errdefs.IsNotImplemented(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, task := range tasks {
+		if task.DesiredState == "running" && task.CurrentState != "running" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// migration is a migration of a stack between two backends, along with
+// the channels driving it
+type migration struct {
+	types.StackMigration
+	options types.StackMigrateOptions
+
+	// name is that of the migrated stack, by which its copy is hidden
+	// while it is created, until its ID is known
+	name string
+
+	// cutOver is set once the stack is served by the target backend
+	cutOver bool
+
+	// proceed is closed once the migration may be cut over, and abort
+	// once it must be rolled back. done is closed once the migration stops
+	// running.
+	proceed chan struct{}
+	abort   chan struct{}
+	aborted bool
+	done    chan struct{}
+}
+
+// hides returns whether the stack id of the backend of orchestrator is a
+// copy of the migrated stack which must not be seen
+func (m *migration) hides(orchestrator types.OrchestratorChoice, id string) bool {
+	if m.cutOver {
+		return m.From == orchestrator && m.SourceID == id
+	}
+	return m.Phase != types.StackMigrationRolledBack && m.TargetID != "" && m.To == orchestrator && m.TargetID == id
+}
+
+// hidden returns whether the stack id of the backend of orchestrator is
+// the hidden copy of a migrated stack. s.mu must be held.
+func (s *StacksRouter) hidden(orchestrator types.OrchestratorChoice, id string) bool {
+	for _, m := range s.migrations {
+		if m.hides(orchestrator, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrating returns whether the stack id is the source or the copy of a
+// migration which neither completed nor was rolled back. s.mu must be
+// held.
+func (s *StacksRouter) migrating(id string) bool {
+	for _, m := range s.migrations {
+		if m.Phase != types.StackMigrationCompleted && m.Phase != types.StackMigrationRolledBack &&
+			(m.SourceID == id || m.TargetID == id) {
+			return true
+		}
+	}
+	return false
+}
+
+// creating returns whether stack of the backend of orchestrator may be the
+// copy of the migrated stack being created, whose ID is not known yet
+func (m *migration) creating(orchestrator types.OrchestratorChoice, stack types.Stack) bool {
+	return m.Phase == types.StackMigrationCreating && m.From != "" && m.TargetID == "" &&
+		m.To == orchestrator && stack.Spec.Annotations.Name == m.name
+}
+
+// hiddenCopy returns whether stack of the backend of orchestrator is the
+// hidden copy of a migrated stack, including the copies being created.
+// s.mu must be held.
+func (s *StacksRouter) hiddenCopy(orchestrator types.OrchestratorChoice, stack types.Stack) bool {
+	if s.hidden(orchestrator, stack.ID) {
+		return true
+	}
+	for _, m := range s.migrations {
+		if m.creating(orchestrator, stack) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationOf returns the last migration of the stack id of the backend of
+// orchestrator, if any. s.mu must be held.
+func (s *StacksRouter) migrationOf(orchestrator types.OrchestratorChoice, id string) *types.StackMigration {
+	for _, m := range s.migrations {
+		if (m.cutOver && m.To == orchestrator && m.TargetID == id) ||
+			(!m.cutOver && m.From == orchestrator && m.SourceID == id) {
+			migration := m.StackMigration
+			return &migration
+		}
+	}
+	return nil
+}
+
+// setPhase records the progress of a migration, which is saved once s.mu
+// is released with unlockMigrations. s.mu must be held.
+func (s *StacksRouter) setPhase(m *migration, phase types.StackMigrationPhase, message string) {
+	logrus.Debugf("Migration of stack %s from %s to %s: %s %s", m.SourceID, m.From, m.To, phase, message)
+	m.Phase = phase
+	m.Message = message
+	m.UpdatedAt = time.Now()
+	s.migrationsChanged = true
+}
+
+// unlockMigrations forgets the migrations which ended more than
+// migrationRetention ago and releases s.mu. The migrations are then saved
+// to the MigrationStore of the router, if any, if they changed while s.mu
+// was held.
+func (s *StacksRouter) unlockMigrations() {
+	s.pruneMigrations()
+	if s.migrationStore == nil || !s.migrationsChanged {
+		s.migrationsChanged = false
+		s.mu.Unlock()
+		return
+	}
+	s.migrationsChanged = false
+	s.migrationsVersion++
+	version, records := s.migrationsVersion, s.migrationRecords()
+	s.mu.Unlock()
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	// A later version was saved first
+	if version <= s.savedVersion {
+		return
+	}
+	if err := s.migrationStore.Save(records); err != nil {
+		logrus.Errorf("Unable to save the migrations of stacks: %s", err)
+		return
+	}
+	s.savedVersion = version
+}
+
+// pruneMigrations forgets the migrations which completed or were rolled
+// back more than migrationRetention ago. s.mu must be held.
+func (s *StacksRouter) pruneMigrations() {
+	for id, m := range s.migrations {
+		if (m.Phase == types.StackMigrationCompleted || m.Phase == types.StackMigrationRolledBack) &&
+			time.Since(m.UpdatedAt) > migrationRetention {
+			delete(s.migrations, id)
+			s.migrationsChanged = true
+		}
+	}
+}
+
+// migrationRecords returns the MigrationRecords of the migrations which
+// started. s.mu must be held.
+func (s *StacksRouter) migrationRecords() []MigrationRecord {
+	records := []MigrationRecord{}
+	for _, m := range s.migrations {
+		if m.From == "" {
+			continue
+		}
+		records = append(records, MigrationRecord{
+			StackMigration: m.StackMigration,
+			Options:        m.options,
+			Name:           m.name,
+			CutOver:        m.cutOver,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].SourceID < records[j].SourceID
+	})
+	return records
+}
+
+// ResumeMigrations loads the migrations of the MigrationStore of the
+// router, and resumes those which a restart of the router interrupted. It
+// is called once, after the backends are registered. A migration
+// interrupted while the copy of its stack was created is rolled back, and
+// the stack left on the source backend of a migration which was cut over
+// is deleted again if deleting it failed.
+func (s *StacksRouter) ResumeMigrations() error {
+	if s.migrationStore == nil {
+		return nil
+	}
+	records, err := s.migrationStore.Load()
+	if err != nil {
+		return fmt.Errorf("unable to load the migrations of stacks: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.unlockMigrations()
+	for _, record := range records {
+		m := &migration{
+			StackMigration: record.StackMigration,
+			options:        record.Options,
+			name:           record.Name,
+			cutOver:        record.CutOver,
+			proceed:        make(chan struct{}),
+			abort:          make(chan struct{}),
+			done:           make(chan struct{}),
+		}
+		if m.options.CutOver {
+			close(m.proceed)
+		}
+		s.migrations[m.SourceID] = m
+
+		source, sourceOK := s.backends[m.From]
+		target, targetOK := s.backends[m.To]
+		switch {
+		case m.cutOver && m.Phase == types.StackMigrationFailed && sourceOK:
+			logrus.Infof("Deleting stack %s, migrated to %s, from %s again", m.SourceID, m.To, m.From)
+			s.setPhase(m, types.StackMigrationCuttingOver, "")
+			go s.resume(m, source, target)
+		case m.Done():
+			close(m.done)
+		case !sourceOK || !targetOK:
+			s.setPhase(m, types.StackMigrationFailed, fmt.Sprintf("the migration was interrupted, and backend %s or %s is no longer registered", m.From, m.To))
+			close(m.done)
+		case m.TargetID == "":
+			logrus.Infof("Rolling back the migration of stack %s from %s to %s", m.SourceID, m.From, m.To)
+			go s.rollbackCreation(m, target)
+		default:
+			logrus.Infof("Resuming the migration of stack %s from %s to %s", m.SourceID, m.From, m.To)
+			go s.resume(m, source, target)
+		}
+	}
+	return nil
+}
+
+// StackMigrate starts the migration of a stack to the backend of the
+// orchestrator of options. The stack is created on that backend and, once
+// it converged there, deleted from its current backend. If it does not
+// converge within the timeout of options, its copy is deleted and the
+// migration is rolled back.
+//
+// Until the migration is cut over, the stack is only served by its current
+// backend, and cannot be changed. A migration which is not cut over as
+// soon as the stack converges stops in the ready phase, until StackMigrate
+// is called again with options.CutOver, or the stack is deleted.
+func (s *StacksRouter) StackMigrate(ctx context.Context, id string, options types.StackMigrateOptions) (types.StackMigration, error) {
+	if options.To == "" {
+		return types.StackMigration{}, errdefs.InvalidParameter(errors.New("the orchestrator to migrate the stack to is missing"))
+	}
+	// Stacks are migrated by the controllers of their clusters
+	if s.federated {
+		backend, err := s.getMutableBackend(ctx, id)
+		if err != nil {
+			return types.StackMigration{}, err
+		}
+		return backend.StackMigrate(ctx, id, options)
+	}
+	to, target, err := s.getBackend(options.To)
+	if err != nil {
+		return types.StackMigration{}, err
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = types.DefaultStackMigrationTimeout
+	}
+	if err := s.retryCutOver(ctx, id); err != nil {
+		return types.StackMigration{}, err
+	}
+
+	s.mu.Lock()
+	if m, ok := s.migrations[id]; ok && !m.restartable() {
+		defer s.unlockMigrations()
+		return s.continueMigration(m, to, options)
+	}
+	for _, m := range s.migrations {
+		if m.TargetID == id && !m.Done() {
+			s.mu.Unlock()
+			return types.StackMigration{}, errdefs.Conflict(fmt.Errorf("stack %s is being migrated from %s", id, m.From))
+		}
+	}
+	now := time.Now()
+	m := &migration{
+		StackMigration: types.StackMigration{
+			SourceID:  id,
+			To:        to,
+			Phase:     types.StackMigrationCreating,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+		options: options,
+		proceed: make(chan struct{}),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if options.CutOver {
+		close(m.proceed)
+	}
+	s.migrations[id] = m
+	s.mu.Unlock()
+
+	var spec types.StackSpec
+	stackPair, err := s.getStack(ctx, id)
+	if err == nil && stackPair.fromBackend == to {
+		err = errdefs.InvalidParameter(fmt.Errorf("stack %s is already deployed on %s", id, to))
+	}
+	if err == nil {
+		spec, err = s.migrationSpec(ctx, stackPair)
+	}
+	if err == nil {
+		err = validateMigration(ctx, target, to, spec)
+	}
+	if err != nil {
+		s.mu.Lock()
+		delete(s.migrations, id)
+		s.mu.Unlock()
+		close(m.done)
+		return types.StackMigration{}, err
+	}
+
+	s.mu.Lock()
+	m.From = stackPair.fromBackend
+	m.name = spec.Annotations.Name
+	s.migrationsChanged = true
+	migration := m.StackMigration
+	s.unlockMigrations()
+
+	go s.migrate(m, spec, s.backends[stackPair.fromBackend], target)
+	return migration, nil
+}
+
+// restartable returns whether the stack of a migration can be migrated
+// again, that is whether the migration left no copy of it behind
+func (m *migration) restartable() bool {
+	return m.Phase == types.StackMigrationRolledBack ||
+		(m.Phase == types.StackMigrationFailed && m.TargetID == "")
+}
+
+// continueMigration handles a request to migrate a stack whose migration
+// already started, which cuts the migration over if options ask to. s.mu
+// must be held.
+func (s *StacksRouter) continueMigration(m *migration, to types.OrchestratorChoice, options types.StackMigrateOptions) (types.StackMigration, error) {
+	switch {
+	case m.Phase == types.StackMigrationCompleted:
+		return types.StackMigration{}, errdefs.NotFound(fmt.Errorf("stack %s was migrated to %s as %s", m.SourceID, m.To, m.TargetID))
+	case m.Phase == types.StackMigrationFailed:
+		return types.StackMigration{}, errdefs.Conflict(fmt.Errorf("the migration of stack %s to %s failed: %s", m.SourceID, m.To, m.Message))
+	case m.To != to:
+		return types.StackMigration{}, errdefs.Conflict(fmt.Errorf("stack %s is being migrated to %s", m.SourceID, m.To))
+	}
+	if options.CutOver && !m.options.CutOver {
+		m.options.CutOver = true
+		close(m.proceed)
+		s.migrationsChanged = true
+	}
+	return m.StackMigration, nil
+}
+
+// migrationSpec returns the StackSpec of the stack of stackPair with the
+// data of its secrets, which the copy of the stack is created with. Stacks
+// whose backend does not disclose the data of their secrets, such as the
+// remote Stacks APIs, cannot be migrated.
+func (s *StacksRouter) migrationSpec(ctx context.Context, stackPair stackPair) (types.StackSpec, error) {
+	spec := stackPair.stack.Spec
+	if len(secrets.Redacted(spec)) == 0 {
+		return spec, nil
+	}
+	if reader, ok := s.backends[stackPair.fromBackend].(secretsReader); ok {
+		withSecrets, err := reader.StackSpecWithSecrets(ctx, stackPair.stack.ID)
+		switch {
+		case err == nil:
+			spec = withSecrets
+		case !errdefs.IsNotImplemented(err):
+			return types.StackSpec{}, fmt.Errorf("unable to read the secrets of stack %s: %s", stackPair.stack.ID, err)
+		}
+	}
+	if redacted := secrets.Redacted(spec); len(redacted) > 0 {
+		return types.StackSpec{}, errdefs.InvalidParameter(fmt.Errorf("stack %s cannot be migrated: backend %s does not disclose the data of its secrets %s",
+			stackPair.stack.ID, stackPair.fromBackend, strings.Join(redacted, ", ")))
+	}
+	return spec, nil
+}
+
+// validateMigration validates spec with the backend of orchestrator, which
+// the stack is migrated to
+func validateMigration(ctx context.Context, backend client.StackAPIClient, orchestrator types.OrchestratorChoice, spec types.StackSpec) error {
+	result, err := backend.StackValidate(ctx, spec)
+	if err != nil {
+		return fmt.Errorf("unable to validate stack with backend %s: %s", orchestrator, err)
+	}
+	if !result.Valid {
+		return types.StackValidationError{Result: result}
+	}
+	return nil
+}
+
+// migrate runs a migration, from the creation of the copy of the stack on
+// the target backend to the deletion of the stack from the source backend
+func (s *StacksRouter) migrate(m *migration, spec types.StackSpec, source, target client.StackAPIClient) {
+	defer close(m.done)
+	ctx := context.Background()
+
+	// The copy of the stack is hidden by its name until its ID is known,
+	// so that it is not listed while it is created.
+	resp, err := target.StackCreate(ctx, spec, types.StackCreateOptions{Orchestrator: m.To})
+	s.mu.Lock()
+	if err != nil {
+		s.setPhase(m, types.StackMigrationFailed, fmt.Sprintf("unable to create the stack on %s: %s", m.To, err))
+		s.unlockMigrations()
+		return
+	}
+	m.TargetID = resp.ID
+	s.setPhase(m, types.StackMigrationConverging, "")
+	s.unlockMigrations()
+
+	s.converge(ctx, m, source, target)
+}
+
+// resume resumes a migration interrupted by a restart of the router, from
+// its last recorded phase
+func (s *StacksRouter) resume(m *migration, source, target client.StackAPIClient) {
+	defer close(m.done)
+	ctx := context.Background()
+
+	if m.cutOver {
+		s.deleteSource(ctx, m, source)
+		return
+	}
+	s.converge(ctx, m, source, target)
+}
+
+// converge waits for the copy of the stack of a migration to converge on
+// the target backend, and cuts the migration over once it may
+func (s *StacksRouter) converge(ctx context.Context, m *migration, source, target client.StackAPIClient) {
+	if err := s.waitConverged(ctx, m, target); err != nil {
+		s.rollback(ctx, m, target, fmt.Sprintf("the stack did not converge on %s: %s", m.To, err))
+		return
+	}
+
+	s.mu.Lock()
+	if !m.options.CutOver {
+		s.setPhase(m, types.StackMigrationReady, "")
+	}
+	s.unlockMigrations()
+	select {
+	case <-m.proceed:
+	case <-m.abort:
+	}
+	select {
+	case <-m.abort:
+		s.rollback(ctx, m, target, "the migration was aborted")
+		return
+	default:
+	}
+
+	s.mu.Lock()
+	m.cutOver = true
+	s.setPhase(m, types.StackMigrationCuttingOver, "")
+	s.index.invalidate(m.SourceID)
+	s.index.set(m.TargetID, m.To)
+	s.unlockMigrations()
+
+	s.deleteSource(ctx, m, source)
+}
+
+// deleteSource deletes the migrated stack from the source backend, which
+// completes a migration which was cut over. A migration failing to delete
+// it is retried by retryCutOver.
+func (s *StacksRouter) deleteSource(ctx context.Context, m *migration, source client.StackAPIClient) {
+	err := source.StackDelete(ctx, m.SourceID)
+	s.mu.Lock()
+	defer s.unlockMigrations()
+	if err != nil {
+		s.setPhase(m, types.StackMigrationFailed, fmt.Sprintf("unable to delete the stack from %s: %s", m.From, err))
+		return
+	}
+	s.setPhase(m, types.StackMigrationCompleted, "")
+}
+
+// waitConverged waits for the copy of the stack of a migration to converge
+// on the target backend, until the timeout of the migration
+func (s *StacksRouter) waitConverged(ctx context.Context, m *migration, target client.StackAPIClient) error {
+	timeout := time.NewTimer(m.options.Timeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		converged, err := s.converged(ctx, target, m.TargetID)
+		if err != nil {
+			return err
+		}
+		if converged {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			return fmt.Errorf("timed out after %s", m.options.Timeout)
+		case <-m.abort:
+			return errors.New("the migration was aborted")
+		}
+	}
+}
+
+// rollback deletes the copy of the stack of a migration from the target
+// backend
+func (s *StacksRouter) rollback(ctx context.Context, m *migration, target client.StackAPIClient, reason string) {
+	err := target.StackDelete(ctx, m.TargetID)
+	s.mu.Lock()
+	defer s.unlockMigrations()
+	if err != nil {
+		s.setPhase(m, types.StackMigrationFailed, fmt.Sprintf("%s, and unable to delete it from %s: %s", reason, m.To, err))
+		return
+	}
+	s.setPhase(m, types.StackMigrationRolledBack, reason)
+}
+
+// rollbackCreation rolls back a migration interrupted by a restart of the
+// router while the copy of its stack was created on the target backend.
+// The copy, if it was created, is found by the name of the stack, as its
+// ID is not known, and is hidden as such until it is deleted.
+func (s *StacksRouter) rollbackCreation(m *migration, target client.StackAPIClient) {
+	defer close(m.done)
+	ctx := context.Background()
+	reason := fmt.Sprintf("the migration was interrupted while the stack was created on %s", m.To)
+
+	stacks, err := target.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", m.name)),
+	})
+	s.mu.Lock()
+	if err != nil {
+		s.setPhase(m, types.StackMigrationFailed, fmt.Sprintf("%s, and unable to look for it there: %s", reason, err))
+		s.unlockMigrations()
+		return
+	}
+	for _, stack := range stacks {
+		if m.creating(m.To, stack) {
+			m.TargetID = stack.ID
+			break
+		}
+	}
+	if m.TargetID == "" {
+		s.setPhase(m, types.StackMigrationRolledBack, reason)
+		s.unlockMigrations()
+		return
+	}
+	s.migrationsChanged = true
+	s.unlockMigrations()
+
+	s.rollback(ctx, m, target, reason)
+}
+
+// retryCutOver deletes the stack left on its source backend by a migration
+// to the stack id which was cut over, if deleting it failed, before the
+// stack is migrated again or deleted.
+func (s *StacksRouter) retryCutOver(ctx context.Context, id string) error {
+	s.mu.Lock()
+	var m *migration
+	for _, candidate := range s.migrations {
+		if candidate.cutOver && candidate.Phase == types.StackMigrationFailed && (candidate.SourceID == id || candidate.TargetID == id) {
+			m = candidate
+			break
+		}
+	}
+	if m == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	source, ok := s.backends[m.From]
+	if !ok {
+		s.mu.Unlock()
+		return errdefs.Unavailable(fmt.Errorf("stack %s left on backend %s by its migration cannot be deleted, as the backend is no longer registered", m.SourceID, m.From))
+	}
+	s.setPhase(m, types.StackMigrationCuttingOver, "")
+	done := make(chan struct{})
+	m.done = done
+	s.unlockMigrations()
+
+	s.deleteSource(ctx, m, source)
+	close(done)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if m.Phase == types.StackMigrationFailed {
+		return fmt.Errorf("the migration of stack %s to %s failed: %s", m.SourceID, m.To, m.Message)
+	}
+	return nil
+}
+
+// abortMigration rolls back the migration of the stack id, if it was not
+// cut over, before the stack is deleted. The copy of the stack left by a
+// failed rollback is deleted as well.
+func (s *StacksRouter) abortMigration(ctx context.Context, id string) error {
+	s.mu.Lock()
+	m, ok := s.migrations[id]
+	if !ok || m.cutOver {
+		s.mu.Unlock()
+		return nil
+	}
+	if !m.Done() {
+		if !m.aborted {
+			m.aborted = true
+			close(m.abort)
+		}
+		s.mu.Unlock()
+		<-m.done
+		s.mu.Lock()
+	}
+	defer s.unlockMigrations()
+
+	if m.Phase != types.StackMigrationFailed || m.TargetID == "" {
+		return nil
+	}
+	if err := s.backends[m.To].StackDelete(ctx, m.TargetID); err != nil {
+		return fmt.Errorf("unable to delete the copy of stack %s from backend %s: %s", id, m.To, err)
+	}
+	s.setPhase(m, types.StackMigrationRolledBack, m.Message)
+	return nil
+}
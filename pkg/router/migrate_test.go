@@ -0,0 +1,442 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/client/fake"
+	"github.com/docker/stacks/pkg/kubernetes"
+	kubernetesFake "github.com/docker/stacks/pkg/kubernetes/fake"
+	"github.com/docker/stacks/pkg/secrets"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// newMigrationRouter returns a router serving a fake swarm backend and a
+// Kubernetes backend, whose stacks converge once converged is closed
+func newMigrationRouter(converged chan struct{}, optsFunc ...StacksRouterOptionFunc) (*StacksRouter, *fake.StackClient, *kubernetes.Client) {
+	swarmBackend := fake.NewStackClient()
+	kubeBackend := kubernetes.NewClient(kubernetesFake.NewClientset())
+	return newMigrationRouterWith(converged, swarmBackend, kubeBackend, optsFunc...), swarmBackend, kubeBackend
+}
+
+// newMigrationRouterWith returns a router serving swarmBackend and
+// kubeBackend, whose stacks converge once converged is closed
+func newMigrationRouterWith(converged chan struct{}, swarmBackend, kubeBackend client.StackAPIClient, optsFunc ...StacksRouterOptionFunc) *StacksRouter {
+	optsFunc = append([]StacksRouterOptionFunc{WithConvergenceCheck(func(context.Context, client.StackAPIClient, string) (bool, error) {
+		select {
+		case <-converged:
+			return true, nil
+		default:
+			return false, nil
+		}
+	})}, optsFunc...)
+	router := NewStacksRouter(optsFunc...)
+	router.pollInterval = time.Millisecond
+	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
+	router.RegisterBackend(types.OrchestratorKubernetes, kubeBackend)
+	return router
+}
+
+// waitMigration waits for the migration of the stack id to reach phase,
+// and returns it
+func waitMigration(t *testing.T, router *StacksRouter, id string, phase types.StackMigrationPhase) types.StackMigration {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		router.mu.RLock()
+		migration := router.migrations[id].StackMigration
+		router.mu.RUnlock()
+		if migration.Phase == phase {
+			return migration
+		}
+	}
+	t.Fatalf("the migration of stack %s did not reach phase %s", id, phase)
+	return types.StackMigration{}
+}
+
+func TestMigrateCutOver(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	converged := make(chan struct{})
+	router, swarmBackend, kubeBackend := newMigrationRouter(converged)
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	migration, err := router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.NoError(err)
+	require.Equal(types.StackMigrationCreating, migration.Phase)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), migration.From)
+
+	// Until it is cut over, the stack is only seen in the source backend,
+	// and cannot be changed
+	migration = waitMigration(t, router, resp.ID, types.StackMigrationConverging)
+	_, err = kubeBackend.StackInspect(ctx, migration.TargetID)
+	require.NoError(err)
+	_, err = router.StackInspect(ctx, migration.TargetID)
+	require.True(errdefs.IsNotFound(err))
+	stacks, err := router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal(resp.ID, stacks[0].ID)
+	require.Equal(types.StackMigrationConverging, stacks[0].Migration.Phase)
+	err = router.StackPause(ctx, resp.ID)
+	require.True(errdefs.IsConflict(err))
+
+	// A migration to another orchestrator conflicts with this one
+	router.RegisterBackend(types.OrchestratorNone, fake.NewStackClient(fake.WithStartingID(5000)))
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorNone})
+	require.True(errdefs.IsConflict(err))
+
+	close(converged)
+	waitMigration(t, router, resp.ID, types.StackMigrationReady)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+	require.Equal(types.StackMigrationReady, stack.Migration.Phase)
+
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes, CutOver: true})
+	require.NoError(err)
+	waitMigration(t, router, resp.ID, types.StackMigrationCompleted)
+
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+	_, err = router.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+	require.Contains(err.Error(), migration.TargetID)
+
+	stack, err = router.StackInspect(ctx, migration.TargetID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), stack.Orchestrator)
+	require.Equal(types.StackMigrationCompleted, stack.Migration.Phase)
+	require.Equal("swarm-stack", stack.Spec.Annotations.Name)
+	require.NoError(router.StackUpdate(ctx, migration.TargetID, types.Version{Index: stack.Version.Index}, stack.Spec, types.StackUpdateOptions{}))
+}
+
+func TestMigrateRollback(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, _, kubeBackend := newMigrationRouter(make(chan struct{}))
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{
+		To:      types.OrchestratorKubernetes,
+		CutOver: true,
+		Timeout: 10 * time.Millisecond,
+	})
+	require.NoError(err)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationRolledBack)
+	require.Contains(migration.Message, "timed out")
+
+	_, err = kubeBackend.StackInspect(ctx, migration.TargetID)
+	require.True(errdefs.IsNotFound(err))
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+	require.Equal(types.StackMigrationRolledBack, stack.Migration.Phase)
+	require.NoError(router.StackPause(ctx, resp.ID))
+}
+
+func TestMigrateAbort(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	converged := make(chan struct{})
+	close(converged)
+	router, swarmBackend, kubeBackend := newMigrationRouter(converged)
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.NoError(err)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationReady)
+
+	// Deleting a stack rolls back its migration
+	require.NoError(router.StackDelete(ctx, resp.ID))
+	migration = waitMigration(t, router, resp.ID, types.StackMigrationRolledBack)
+	_, err = kubeBackend.StackInspect(ctx, migration.TargetID)
+	require.True(errdefs.IsNotFound(err))
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router, _, _ := newMigrationRouter(make(chan struct{}))
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorNone})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorSwarm})
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = router.StackMigrate(ctx, "nosuchid", types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.True(errdefs.IsNotFound(err))
+
+	// Stacks using features the target orchestrator does not support are
+	// not migrated
+	spec := swarmStackCreate
+	spec.Annotations.Name = "named-user"
+	spec.Services = []swarm.ServiceSpec{{
+		Annotations: swarm.Annotations{Name: "testservice"},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "testimage", User: "nobody"},
+		},
+	}}
+	resp, err = router.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	_, ok := err.(types.StackValidationError)
+	require.True(ok)
+
+	// Failed attempts leave the stack unchanged
+	require.NoError(router.StackPause(ctx, resp.ID))
+}
+
+// redactingClient is a stacks client redacting the data of secrets, as the
+// remote Stacks APIs do
+type redactingClient struct {
+	*fake.StackClient
+}
+
+func (c redactingClient) StackInspect(ctx context.Context, id string) (types.Stack, error) {
+	stack, err := c.StackClient.StackInspect(ctx, id)
+	return secrets.RedactStack(stack), err
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	converged := make(chan struct{})
+	close(converged)
+	router, swarmBackend, _ := newMigrationRouter(converged)
+
+	spec := kubeStackCreate
+	spec.Secrets = []swarm.SecretSpec{{
+		Annotations: swarm.Annotations{Name: "password"},
+		Data:        []byte("hunter2"),
+	}}
+	resp, err := router.StackCreate(ctx, spec, types.StackCreateOptions{Orchestrator: types.OrchestratorKubernetes})
+	require.NoError(err)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal([]string{"password"}, secrets.Redacted(stack.Spec))
+
+	// The copy of the stack is created with the data of its secrets
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorSwarm, CutOver: true})
+	require.NoError(err)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationCompleted)
+	stack, err = swarmBackend.StackInspect(ctx, migration.TargetID)
+	require.NoError(err)
+	require.Equal([]byte("hunter2"), stack.Spec.Secrets[0].Data)
+
+	// Stacks whose backend does not disclose the data of their secrets are
+	// rejected before a copy is created
+	remote := redactingClient{fake.NewStackClient(fake.WithStartingID(100))}
+	router.RegisterBackend(types.OrchestratorNone, remote)
+	spec.Annotations.Name = "remote-stack"
+	resp, err = remote.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "password")
+	stacks, err := router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 2)
+}
+
+// blockingClient is a stacks client whose creations block once the stack
+// exists, until release is closed
+type blockingClient struct {
+	*fake.StackClient
+	created chan struct{}
+	release chan struct{}
+}
+
+func (c blockingClient) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	resp, err := c.StackClient.StackCreate(ctx, spec, options)
+	close(c.created)
+	<-c.release
+	return resp, err
+}
+
+func TestMigrateCreating(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	router := NewStacksRouter(WithConvergenceCheck(func(context.Context, client.StackAPIClient, string) (bool, error) {
+		return true, nil
+	}))
+	router.pollInterval = time.Millisecond
+	target := blockingClient{
+		StackClient: fake.NewStackClient(fake.WithStartingID(100)),
+		created:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	router.RegisterBackend(types.OrchestratorSwarm, fake.NewStackClient())
+	router.RegisterBackend(types.OrchestratorKubernetes, target)
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes, CutOver: true})
+	require.NoError(err)
+
+	// The router is not locked while the copy is created, and the copy is
+	// hidden before its ID is known
+	<-target.created
+	stacks, err := router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal(resp.ID, stacks[0].ID)
+	_, err = router.StackInspect(ctx, "100")
+	require.True(errdefs.IsNotFound(err))
+	// even by a stale entry of the index
+	router.index.set("100", types.OrchestratorKubernetes)
+	_, err = router.StackInspect(ctx, "100")
+	require.True(errdefs.IsNotFound(err))
+
+	close(target.release)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationCompleted)
+	require.Equal("100", migration.TargetID)
+	stack, err := router.StackInspect(ctx, "100")
+	require.NoError(err)
+	require.Equal(swarmStackCreate.Annotations.Name, stack.Spec.Annotations.Name)
+}
+
+func TestMigrateResume(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "migrations")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	store := NewFileMigrationStore(filepath.Join(dir, "migrations.json"))
+	converged := make(chan struct{})
+	close(converged)
+	router, swarmBackend, kubeBackend := newMigrationRouter(converged, WithMigrationStore(store))
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes})
+	require.NoError(err)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationReady)
+
+	// A restarted router resumes the migrations it saved
+	router = newMigrationRouterWith(converged, swarmBackend, kubeBackend, WithMigrationStore(store))
+	require.NoError(router.ResumeMigrations())
+	waitMigration(t, router, resp.ID, types.StackMigrationReady)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(migration.TargetID, stack.Migration.TargetID)
+	_, err = router.StackInspect(ctx, migration.TargetID)
+	require.True(errdefs.IsNotFound(err))
+
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes, CutOver: true})
+	require.NoError(err)
+	waitMigration(t, router, resp.ID, types.StackMigrationCompleted)
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+
+	records, err := store.Load()
+	require.NoError(err)
+	require.Len(records, 1)
+	require.Equal(types.StackMigrationCompleted, records[0].Phase)
+	require.True(records[0].CutOver)
+
+	// Migrations interrupted while the copy of their stack was created are
+	// rolled back, along with the copy, and can be started again.
+	// Migrations which ended long ago are forgotten.
+	copyResp, err := kubeBackend.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	require.NoError(store.Save([]MigrationRecord{{
+		StackMigration: types.StackMigration{
+			SourceID: "interrupted",
+			From:     types.OrchestratorSwarm,
+			To:       types.OrchestratorKubernetes,
+			Phase:    types.StackMigrationCreating,
+		},
+		Name: kubeStackCreate.Annotations.Name,
+	}, {
+		StackMigration: types.StackMigration{
+			SourceID:  "old",
+			TargetID:  "default.old",
+			From:      types.OrchestratorSwarm,
+			To:        types.OrchestratorKubernetes,
+			Phase:     types.StackMigrationCompleted,
+			UpdatedAt: time.Now().Add(-2 * migrationRetention),
+		},
+		CutOver: true,
+	}}))
+	router = newMigrationRouterWith(converged, swarmBackend, kubeBackend, WithMigrationStore(store))
+	require.NoError(router.ResumeMigrations())
+	migration = waitMigration(t, router, "interrupted", types.StackMigrationRolledBack)
+	require.Contains(migration.Message, "interrupted")
+	require.Equal(copyResp.ID, migration.TargetID)
+	require.True(router.migrations["interrupted"].restartable())
+	_, err = kubeBackend.StackInspect(ctx, copyResp.ID)
+	require.True(errdefs.IsNotFound(err))
+
+	records, err = store.Load()
+	require.NoError(err)
+	require.Len(records, 1)
+	require.Equal("interrupted", records[0].SourceID)
+}
+
+// deleteFailingClient is a stacks client failing to delete stacks while
+// failing is set
+type deleteFailingClient struct {
+	client.StackAPIClient
+	failing *int32
+}
+
+func (c deleteFailingClient) StackDelete(ctx context.Context, id string) error {
+	if atomic.LoadInt32(c.failing) != 0 {
+		return errors.New("unable to reach the backend")
+	}
+	return c.StackAPIClient.StackDelete(ctx, id)
+}
+
+func TestMigrateRetryCutOver(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	converged := make(chan struct{})
+	close(converged)
+	swarmBackend := fake.NewStackClient()
+	failing := int32(1)
+	router := newMigrationRouterWith(converged, deleteFailingClient{swarmBackend, &failing}, kubernetes.NewClient(kubernetesFake.NewClientset()))
+
+	resp, err := router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = router.StackMigrate(ctx, resp.ID, types.StackMigrateOptions{To: types.OrchestratorKubernetes, CutOver: true})
+	require.NoError(err)
+	migration := waitMigration(t, router, resp.ID, types.StackMigrationFailed)
+	require.Contains(migration.Message, "unable to delete the stack from swarm")
+
+	// The stack left on its source backend is hidden, and deleted again
+	// before the stack is migrated or deleted
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	_, err = router.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+	_, err = router.StackMigrate(ctx, migration.TargetID, types.StackMigrateOptions{To: types.OrchestratorSwarm})
+	require.Error(err)
+	require.Equal(types.StackMigrationFailed, router.migrations[resp.ID].Phase)
+
+	atomic.StoreInt32(&failing, 0)
+	require.NoError(router.StackDelete(ctx, migration.TargetID))
+	waitMigration(t, router, resp.ID, types.StackMigrationCompleted)
+	_, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.True(errdefs.IsNotFound(err))
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// MigrationRecord is the state of a migration kept by a MigrationStore.
+type MigrationRecord struct {
+	types.StackMigration
+	Options types.StackMigrateOptions
+
+	// Name is the name of the migrated stack, and CutOver is set once the
+	// stack is served by the target backend.
+	Name    string `json:",omitempty"`
+	CutOver bool   `json:",omitempty"`
+}
+
+// MigrationStore keeps the state of the migrations of a StacksRouter, so
+// that they survive a restart of the router.
+type MigrationStore interface {
+	// Load returns the migrations saved last, if any.
+	Load() ([]MigrationRecord, error)
+	// Save replaces the saved migrations with records.
+	Save(records []MigrationRecord) error
+}
+
+// WithMigrationStore is a StacksRouterOptionFunc which saves the state of
+// the migrations of the router to store whenever it changes, and resumes
+// them from it in ResumeMigrations. Without it, the migrations running
+// when the router stops are lost.
+func WithMigrationStore(store MigrationStore) StacksRouterOptionFunc {
+	return func(s *StacksRouter) {
+		s.migrationStore = store
+	}
+}
+
+// FileMigrationStore is a MigrationStore keeping migrations in a JSON file.
+type FileMigrationStore struct {
+	path string
+}
+
+var _ MigrationStore = &FileMigrationStore{}
+
+// NewFileMigrationStore creates a FileMigrationStore keeping migrations in
+// the file at path, which is created on the first save.
+func NewFileMigrationStore(path string) *FileMigrationStore {
+	return &FileMigrationStore{
+		path: path,
+	}
+}
+
+// Load reads the migrations of the file, if it exists.
+func (f *FileMigrationStore) Load() ([]MigrationRecord, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []MigrationRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Save writes the migrations to a temporary file, which then replaces the
+// file, so that it is never left half written.
+func (f *FileMigrationStore) Save(records []MigrationRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -3,20 +3,71 @@ package router
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/query"
 	"github.com/docker/stacks/pkg/types"
 )
 
 // StacksRouter is a router for the Stacks API, responsible for routing
 // requests to specific orchestrator backends. It implements the
 // StackAPIClient interface.
+//
+// A router federating clusters, see RegisterCluster, routes requests to the
+// stacks controllers of these clusters instead, which are keyed by their
+// name in place of an orchestrator.
 type StacksRouter struct {
 	backends map[types.OrchestratorChoice]client.StackAPIClient
+
+	// defaultOrchestrator is the orchestrator of the stacks created
+	// without one
+	defaultOrchestrator types.OrchestratorChoice
+
+	// federated is set once clusters are registered, and defaultCluster
+	// is the cluster of the stacks created without one
+	federated      bool
+	defaultCluster string
+
+	// converged checks whether migrated stacks converged on their target
+	// orchestrator, every pollInterval
+	converged    ConvergenceFunc
+	pollInterval time.Duration
+
+	// mu guards the migrations, by the ID of their source stack. It is
+	// held for reading while the backends are looked up, so that a
+	// migrated stack is only ever seen in one of them.
+	mu         sync.RWMutex
+	migrations map[string]*migration
+
+	// migrationStore keeps the migrations across restarts, if any. The
+	// migrations are saved once s.mu is released, in the order of
+	// migrationsVersion, which counts their changes.
+	migrationStore    MigrationStore
+	migrationsChanged bool
+	migrationsVersion uint64
+	saveMu            sync.Mutex
+	savedVersion      uint64
+
+	// index spares looking for stacks in every backend
+	index *stackIndex
+}
+
+// StacksRouterOptionFunc is the type used for functional arguments of the
+// StacksRouter during its creation.
+type StacksRouterOptionFunc func(*StacksRouter)
+
+// WithDefaultOrchestrator is a StacksRouterOptionFunc which deploys the
+// stacks created without an orchestrator on orchestrator, instead of Swarm.
+func WithDefaultOrchestrator(orchestrator types.OrchestratorChoice) StacksRouterOptionFunc {
+	return func(s *StacksRouter) {
+		s.defaultOrchestrator = orchestrator
+	}
 }
 
 // stackPair is used internally by the router to share implementations
@@ -27,22 +78,77 @@ type stackPair struct {
 }
 
 // NewStacksRouter creates a new StacksRouter
-func NewStacksRouter() *StacksRouter {
-	return &StacksRouter{
-		backends: make(map[types.OrchestratorChoice]client.StackAPIClient),
+func NewStacksRouter(optsFunc ...StacksRouterOptionFunc) *StacksRouter {
+	s := &StacksRouter{
+		backends:            make(map[types.OrchestratorChoice]client.StackAPIClient),
+		defaultOrchestrator: types.OrchestratorSwarm,
+		converged:           stackConverged,
+		pollInterval:        defaultPollInterval,
+		migrations:          make(map[string]*migration),
+		index:               newStackIndex(),
+	}
+
+	for _, f := range optsFunc {
+		f(s)
 	}
+
+	return s
 }
 
 // RegisterBackend registers a new orchestration backend for the
 // StacksRouter. If a backend already exists for the specified
-// orchestrator type, it will be overridden.
-func (s *StacksRouter) RegisterBackend(orch types.OrchestratorChoice, stackClient client.StackAPIClient) {
-	s.backends[orch] = stackClient
+// orchestrator type, it will be overridden. The calls to the backend are
+// bounded by DefaultBackendTimeout, and stop while it keeps failing, unless
+// optsFunc set other limits.
+func (s *StacksRouter) RegisterBackend(orch types.OrchestratorChoice, stackClient client.StackAPIClient, optsFunc ...BackendOptionFunc) {
+	s.backends[orch] = newBackend(orch, stackClient, optsFunc...)
+}
+
+// backendError is the error of a backend of the router
+type backendError struct {
+	orchestrator types.OrchestratorChoice
+	err          error
 }
 
+// getStack looks for the stack id in the backend it is indexed with, or
+// else in all backends. The copy of a stack being migrated is only found
+// in the backend serving it.
 func (s *StacksRouter) getStack(ctx context.Context, id string) (stackPair, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The stacks of the migrations in progress are looked for in every
+	// backend, as they may move between backends at any time
+	if orchestrator, ok := s.index.get(id); ok {
+		if backend, ok := s.backends[orchestrator]; ok && !s.migrating(id) {
+			stack, err := backend.StackInspect(ctx, id)
+			if err == nil && !s.hiddenCopy(orchestrator, stack) {
+				s.index.hit()
+				return stackPair{
+					stack:       stack,
+					fromBackend: orchestrator,
+				}, nil
+			}
+			if err != nil && !errdefs.IsNotFound(err) {
+				return stackPair{}, BackendErrors{orchestrator: fmt.Errorf("unable to look for stacks: %s", err)}
+			}
+		}
+		s.index.invalidate(id)
+	}
+	s.index.miss()
+
+	stackPair, err := s.findStack(ctx, id)
+	if err == nil {
+		s.index.set(id, stackPair.fromBackend)
+	}
+	return stackPair, err
+}
+
+// findStack looks for the stack id in all backends. The backends which fail
+// are ignored if the stack is found in another one. s.mu must be held.
+func (s *StacksRouter) findStack(ctx context.Context, id string) (stackPair, error) {
 	stackChan := make(chan stackPair)
-	errChan := make(chan error)
+	errChan := make(chan backendError)
 	spreadWG := sync.WaitGroup{}
 	collectWG := sync.WaitGroup{}
 	for backendType, backend := range s.backends {
@@ -52,10 +158,16 @@ func (s *StacksRouter) getStack(ctx context.Context, id string) (stackPair, erro
 			stack, err := backend.StackInspect(ctx, id)
 			if err != nil {
 				if !errdefs.IsNotFound(err) {
-					errChan <- fmt.Errorf("unable to look for stacks in backend %s: %s", backendType, err)
+					errChan <- backendError{
+						orchestrator: backendType,
+						err:          fmt.Errorf("unable to look for stacks: %s", err),
+					}
 				}
 				return
 			}
+			if s.hiddenCopy(backendType, stack) {
+				return
+			}
 			stackChan <- stackPair{
 				stack:       stack,
 				fromBackend: backendType,
@@ -64,7 +176,7 @@ func (s *StacksRouter) getStack(ctx context.Context, id string) (stackPair, erro
 	}
 
 	var stackPairs []stackPair
-	var errs []error
+	errs := BackendErrors{}
 
 	// The following two goroutines collect results and errors from the
 	// respective channels. A separate WaitGroup is used to avoid a race
@@ -73,8 +185,8 @@ func (s *StacksRouter) getStack(ctx context.Context, id string) (stackPair, erro
 	collectWG.Add(2)
 	go func() {
 		defer collectWG.Done()
-		for err := range errChan {
-			errs = append(errs, err)
+		for backendErr := range errChan {
+			errs[backendErr.orchestrator] = backendErr.err
 		}
 	}()
 
@@ -94,91 +206,330 @@ func (s *StacksRouter) getStack(ctx context.Context, id string) (stackPair, erro
 	// results from their channels.
 	collectWG.Wait()
 
-	if len(errs) > 0 {
-		errMsg := fmt.Sprintf("encountered 1 backend error")
-		if len(errs) > 1 {
-			errMsg = fmt.Sprintf("encountered %d backend errors", len(errs))
-		}
-		for i, err := range errs {
-			errMsg = fmt.Sprintf("%s (error %d): %s", errMsg, i, err)
-		}
-
-		return stackPair{}, fmt.Errorf(errMsg)
-	}
-
 	switch len(stackPairs) {
 	case 0:
+		// The stack may be deployed on a backend which failed
+		if len(errs) > 0 {
+			return stackPair{}, errs
+		}
+		if m, ok := s.migrations[id]; ok && m.Phase == types.StackMigrationCompleted {
+			return stackPair{}, errdefs.NotFound(fmt.Errorf("stack %s was migrated to %s as %s", id, m.To, m.TargetID))
+		}
 		return stackPair{}, errdefs.NotFound(fmt.Errorf("stack not found"))
 	case 1:
+		if len(errs) > 0 {
+			logrus.Warnf("Stack %s found in backend %s despite other backends failing: %s", id, stackPairs[0].fromBackend, errs)
+		}
 		return stackPairs[0], nil
 	default:
 		return stackPair{}, fmt.Errorf("multiple instances of the requested stack detected across backends")
 	}
 }
 
-// StackCreate creates a new stack.
-func (s *StacksRouter) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
-	var orchestrator types.OrchestratorChoice = types.OrchestratorSwarm
+// getBackend returns the backend of orchestrator, or of the default
+// orchestrator if orchestrator is empty
+func (s *StacksRouter) getBackend(orchestrator types.OrchestratorChoice) (types.OrchestratorChoice, client.StackAPIClient, error) {
+	if orchestrator == "" {
+		orchestrator = s.defaultOrchestrator
+	}
 	backend, ok := s.backends[orchestrator]
 	if !ok {
-		return types.StackCreateResponse{}, fmt.Errorf("invalid orchestrator choice %s", orchestrator)
+		return "", nil, errdefs.InvalidParameter(fmt.Errorf("invalid orchestrator choice %s", orchestrator))
+	}
+	return orchestrator, backend, nil
+}
+
+// StackCreate creates a new stack on the orchestrator of options, or on the
+// default orchestrator. The spec is first validated by the backend of the
+// orchestrator, so that stacks using features the orchestrator does not
+// support are rejected.
+func (s *StacksRouter) StackCreate(ctx context.Context, spec types.StackSpec, options types.StackCreateOptions) (types.StackCreateResponse, error) {
+	orchestrator, backend, err := s.getCreationBackend(options)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	result, err := backend.StackValidate(ctx, spec)
+	if err != nil {
+		return types.StackCreateResponse{}, fmt.Errorf("unable to validate stack with backend %s: %s", orchestrator, err)
+	}
+	if !result.Valid {
+		return types.StackCreateResponse{}, types.StackValidationError{Result: result}
 	}
 
-	return backend.StackCreate(ctx, spec, options)
+	if !s.federated {
+		options.Orchestrator = orchestrator
+	}
+	resp, err := backend.StackCreate(ctx, spec, options)
+	if err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	s.index.set(resp.ID, orchestrator)
+	return resp, nil
+}
+
+// getCreationBackend returns the backend to create a stack on, that of the
+// orchestrator of options or, when the router is federated, that of the
+// cluster of options.
+func (s *StacksRouter) getCreationBackend(options types.StackCreateOptions) (types.OrchestratorChoice, client.StackAPIClient, error) {
+	if s.federated {
+		return s.getCluster(options.Cluster)
+	}
+	return s.getBackend(options.Orchestrator)
+}
+
+// StackValidate validates a StackSpec with the backend of the default
+// orchestrator or cluster, which would create it.
+func (s *StacksRouter) StackValidate(ctx context.Context, spec types.StackSpec) (types.StackValidationResult, error) {
+	_, backend, err := s.getCreationBackend(types.StackCreateOptions{})
+	if err != nil {
+		return types.StackValidationResult{}, err
+	}
+
+	return backend.StackValidate(ctx, spec)
 }
 
 // StackInspect attempts to inspect a stack across all backends in parallel,
-// and returns the first response.
+// and returns the first response. The stack reports the orchestrator of
+// the backend it was found in, and its last migration.
 func (s *StacksRouter) StackInspect(ctx context.Context, id string) (types.Stack, error) {
 	stackPair, err := s.getStack(ctx, id)
-	return stackPair.stack, err
+	if err != nil {
+		return types.Stack{}, err
+	}
+	// The controllers of clusters report the orchestrator and the
+	// migration of their stacks themselves
+	if s.federated {
+		return stackPair.stack, nil
+	}
+	if stackPair.stack.Orchestrator == "" {
+		stackPair.stack.Orchestrator = stackPair.fromBackend
+	}
+	s.mu.RLock()
+	stackPair.stack.Migration = s.migrationOf(stackPair.fromBackend, id)
+	s.mu.RUnlock()
+	return stackPair.stack, nil
 }
 
-// StackList lists all stacks across all backends.
+// StackList lists the stacks selected by options across all backends. The
+// backends are asked for every stack matching the filters, which are then
+// ordered and paginated as a single listing. The stacks of the backends
+// which fail are left out, see StackListPartial.
 func (s *StacksRouter) StackList(ctx context.Context, options types.StackListOptions) ([]types.Stack, error) {
-	allStacks := []types.Stack{}
+	list, err := s.StackListPartial(ctx, options)
+	if err != nil {
+		return []types.Stack{}, err
+	}
+	for _, warning := range list.Warnings {
+		if warning.Cluster != "" {
+			logrus.Warnf("Stacks of cluster %s are not listed: %s", warning.Cluster, warning.Message)
+			continue
+		}
+		logrus.Warnf("Stacks of backend %s are not listed: %s", warning.Orchestrator, warning.Message)
+	}
+	return list.Items, nil
+}
+
+// StackListPartial lists the stacks selected by options across all
+// backends, in parallel. The stacks of the backends which fail are left
+// out of the listing, which reports a warning for each of these backends.
+// It only fails if every backend fails.
+func (s *StacksRouter) StackListPartial(ctx context.Context, options types.StackListOptions) (types.StackList, error) {
+	// Each backend only holds stacks of its own orchestrator or cluster,
+	// which it may not record on the stacks themselves, so that filter is
+	// applied here.
+	backendFilter := "orchestrator"
+	if s.federated {
+		backendFilter = "cluster"
+	}
+	backendOptions := types.StackListOptions{
+		Filters: options.Filters.Clone(),
+	}
+	for _, value := range options.Filters.Get(backendFilter) {
+		backendOptions.Filters.Del(backendFilter, value)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		allStacks = []types.Stack{}
+		errs      = BackendErrors{}
+		listed    = 0
+	)
 	for backendType, backend := range s.backends {
-		stacks, err := backend.StackList(ctx, options)
-		if err != nil {
-			return []types.Stack{}, fmt.Errorf("unable to list stacks from backend %s: %s", backendType, err)
+		if !options.Filters.ExactMatch(backendFilter, string(backendType)) {
+			continue
 		}
-		allStacks = append(allStacks, stacks...)
+		listed++
+		wg.Add(1)
+		go func(backendType types.OrchestratorChoice, backend client.StackAPIClient) {
+			defer wg.Done()
+			stacks, err := backend.StackList(ctx, backendOptions)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[backendType] = fmt.Errorf("unable to list stacks: %s", err)
+				return
+			}
+			for _, stack := range stacks {
+				if s.hiddenCopy(backendType, stack) {
+					continue
+				}
+				if !s.federated {
+					if stack.Orchestrator == "" {
+						stack.Orchestrator = backendType
+					}
+					stack.Migration = s.migrationOf(backendType, stack.ID)
+				}
+				allStacks = append(allStacks, stack)
+				s.index.set(stack.ID, backendType)
+			}
+		}(backendType, backend)
 	}
+	wg.Wait()
 
-	// TODO: sort/order stacks by some criteria
+	if len(errs) > 0 && len(errs) == listed {
+		return types.StackList{}, errs
+	}
 
-	return allStacks, nil
+	items, err := query.Stacks(allStacks, options)
+	if err != nil {
+		return types.StackList{}, err
+	}
+	list := types.StackList{Items: items}
+	backendTypes := make([]string, 0, len(errs))
+	for backendType := range errs {
+		backendTypes = append(backendTypes, string(backendType))
+	}
+	sort.Strings(backendTypes)
+	for _, backendType := range backendTypes {
+		warning := types.StackListWarning{
+			Orchestrator: types.OrchestratorChoice(backendType),
+			Message:      errs[types.OrchestratorChoice(backendType)].Error(),
+		}
+		if s.federated {
+			warning.Cluster, warning.Orchestrator = backendType, ""
+		}
+		list.Warnings = append(list.Warnings, warning)
+	}
+	return list, nil
 }
 
 // StackUpdate identifies which backend an existing stack is located at, and
 // calls the update operation of that backend.
 func (s *StacksRouter) StackUpdate(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions) error {
+	backend, err := s.getMutableBackend(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return backend.StackUpdate(ctx, id, version, spec, options)
+}
+
+// StackScale identifies which backend an existing stack is located at, and
+// calls the scale operation of that backend.
+func (s *StacksRouter) StackScale(ctx context.Context, id string, replicas map[string]uint64) error {
+	backend, err := s.getMutableBackend(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return backend.StackScale(ctx, id, replicas)
+}
+
+// StackPause identifies which backend an existing stack is located at, and
+// calls the pause operation of that backend.
+func (s *StacksRouter) StackPause(ctx context.Context, id string) error {
+	backend, err := s.getMutableBackend(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return backend.StackPause(ctx, id)
+}
+
+// StackResume identifies which backend an existing stack is located at, and
+// calls the resume operation of that backend.
+func (s *StacksRouter) StackResume(ctx context.Context, id string) error {
+	backend, err := s.getMutableBackend(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return backend.StackResume(ctx, id)
+}
+
+// StackPatch identifies which backend an existing stack is located at, and
+// calls the patch operation of that backend.
+func (s *StacksRouter) StackPatch(ctx context.Context, id string, version types.Version, patchType types.StackPatchType, patch []byte, options types.StackUpdateOptions) error {
+	backend, err := s.getMutableBackend(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return backend.StackPatch(ctx, id, version, patchType, patch, options)
+}
+
+// getMutableBackend returns the backend of the stack id, for an operation
+// changing it. Stacks cannot be changed while they are being migrated.
+func (s *StacksRouter) getMutableBackend(ctx context.Context, id string) (client.StackAPIClient, error) {
 	stackPair, err := s.getStack(ctx, id)
 	if err != nil {
 		if errdefs.IsNotFound(err) {
-			return err
+			return nil, err
 		}
-		return fmt.Errorf("unable to look for stack: %s", err)
+		return nil, fmt.Errorf("unable to look for stack: %s", err)
+	}
+
+	s.mu.RLock()
+	if m, ok := s.migrations[id]; ok && !m.Done() && !m.cutOver {
+		s.mu.RUnlock()
+		return nil, errdefs.Conflict(fmt.Errorf("stack %s is being migrated to %s", id, m.To))
 	}
+	s.mu.RUnlock()
 
 	backend, ok := s.backends[stackPair.fromBackend]
 	if !ok {
-		return fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
+		return nil, fmt.Errorf("internal error: no such backend %s", stackPair.fromBackend)
 	}
-
-	return backend.StackUpdate(ctx, id, version, spec, options)
+	return backend, nil
 }
 
 // StackDelete deletes a stack from all backends. StackDelete should be
-// idempotent so any errors need to be reported back.
+// idempotent so any errors need to be reported back: the stack is deleted
+// from every backend, and the errors of the backends which failed are
+// returned together as BackendErrors. A migration of the stack which was
+// not cut over yet is rolled back first, and the stack left on its source
+// backend by a migration which was cut over is deleted.
 func (s *StacksRouter) StackDelete(ctx context.Context, id string) error {
+	if err := s.retryCutOver(ctx, id); err != nil {
+		return err
+	}
+	if err := s.abortMigration(ctx, id); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	defer s.index.invalidate(id)
+	errs := BackendErrors{}
 	for backendType, backend := range s.backends {
+		if s.hidden(backendType, id) {
+			continue
+		}
 		logrus.Debugf("Deleting stack %s from backend %s", id, backendType)
 		err := backend.StackDelete(ctx, id)
-		if err != nil {
-			return fmt.Errorf("unable to delete stack from backend %s: %s", backendType, err)
+		// Remote backends report the stacks they do not hold as not found
+		if err != nil && !errdefs.IsNotFound(err) {
+			errs[backendType] = fmt.Errorf("unable to delete stack: %s", err)
 		}
 	}
 
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
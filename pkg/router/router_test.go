@@ -4,12 +4,14 @@ import (
 	"context"
 	"testing"
 
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/errdefs"
 	"github.com/stretchr/testify/require"
 
 	"github.com/docker/stacks/pkg/client/fake"
-
+	"github.com/docker/stacks/pkg/kubernetes"
+	kubernetesFake "github.com/docker/stacks/pkg/kubernetes/fake"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -76,13 +78,17 @@ func TestRouterMultipleBackendsUpdate(t *testing.T) {
 	require.NotEmpty(swarmResp.ID)
 
 	// Create a kube stack.
-	kubeResp, err := router.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	kubeResp, err := router.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{Orchestrator: types.OrchestratorKubernetes})
 	require.NoError(err)
 	require.NotEmpty(kubeResp.ID)
+	kubeStack, err := router.StackInspect(ctx, kubeResp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), kubeStack.Orchestrator)
 
 	// Update the swarm stack.
 	stack, err := router.StackInspect(ctx, swarmResp.ID)
 	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
 	newSpec := stack.Spec
 	newSpec.Services[0].TaskTemplate.ContainerSpec.Image = "newimage"
 
@@ -94,3 +100,85 @@ func TestRouterMultipleBackendsUpdate(t *testing.T) {
 	require.Contains(err.Error(), "update out of sequence")
 
 }
+
+func TestRouterMultipleBackendsList(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	router := NewStacksRouter()
+	swarmBackend := fake.NewStackClient()
+	kubeBackend := fake.NewStackClient(fake.WithStartingID(5000))
+
+	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
+	router.RegisterBackend(types.OrchestratorKubernetes, kubeBackend)
+
+	_, err := swarmBackend.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	_, err = kubeBackend.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+
+	// Stacks of all backends are listed as a single, ordered listing.
+	stacks, err := router.StackList(ctx, types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 2)
+	require.Equal("kube-stack", stacks[0].Spec.Annotations.Name)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), stacks[0].Orchestrator)
+	require.Equal("swarm-stack", stacks[1].Spec.Annotations.Name)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stacks[1].Orchestrator)
+
+	// The orchestrator filter selects the backends.
+	stacks, err = router.StackList(ctx, types.StackListOptions{
+		Filters: filters.NewArgs(filters.Arg("orchestrator", types.OrchestratorSwarm)),
+	})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal("swarm-stack", stacks[0].Spec.Annotations.Name)
+
+	// Pagination applies across backends.
+	stacks, err = router.StackList(ctx, types.StackListOptions{Descending: true, Limit: 1})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal("swarm-stack", stacks[0].Spec.Annotations.Name)
+}
+
+func TestRouterCreateOrchestrator(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	router := NewStacksRouter(WithDefaultOrchestrator(types.OrchestratorKubernetes))
+	swarmBackend := fake.NewStackClient()
+	router.RegisterBackend(types.OrchestratorSwarm, swarmBackend)
+	router.RegisterBackend(types.OrchestratorKubernetes, kubernetes.NewClient(kubernetesFake.NewClientset()))
+
+	// Stacks without an orchestrator are created on the default one
+	resp, err := router.StackCreate(ctx, kubeStackCreate, types.StackCreateOptions{})
+	require.NoError(err)
+	stack, err := router.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), stack.Orchestrator)
+
+	resp, err = router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{Orchestrator: types.OrchestratorSwarm})
+	require.NoError(err)
+	stack, err = swarmBackend.StackInspect(ctx, resp.ID)
+	require.NoError(err)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stack.Orchestrator)
+
+	_, err = router.StackCreate(ctx, swarmStackCreate, types.StackCreateOptions{Orchestrator: types.OrchestratorNone})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// Features which Kubernetes does not support are rejected before the
+	// stack is created
+	spec := swarmStackCreate
+	spec.Annotations.Name = "named-user"
+	spec.Services = []swarm.ServiceSpec{{
+		Annotations: swarm.Annotations{Name: "testservice"},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "testimage", User: "nobody"},
+		},
+	}}
+	_, err = router.StackCreate(ctx, spec, types.StackCreateOptions{})
+	require.True(errdefs.IsInvalidParameter(err))
+	invalid, ok := err.(types.StackValidationError)
+	require.True(ok)
+	require.Equal("Services[0].TaskTemplate.ContainerSpec.User", invalid.Result.Errors[0].Field)
+}
@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// encryptedPrefix marks the data of secrets encrypted by a Cipher
+var encryptedPrefix = []byte("stacks:aes-gcm:")
+
+// Cipher encrypts and decrypts the data of the secrets of StackSpecs.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from an AES key of 16, 24 or 32 bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadKey reads a base64 encoded key from the file at path.
+func LoadKey(path string) ([]byte, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %s", path, err)
+	}
+	return key, nil
+}
+
+// Encrypt returns a copy of spec with the data of its secrets encrypted.
+func (c *Cipher) Encrypt(spec types.StackSpec) (types.StackSpec, error) {
+	return c.transform(spec, c.encrypt)
+}
+
+// Decrypt returns a copy of spec with the data of its secrets decrypted.
+// Data which is not encrypted fails, as spec is expected to be encrypted.
+func (c *Cipher) Decrypt(spec types.StackSpec) (types.StackSpec, error) {
+	return c.transform(spec, func(data []byte) ([]byte, error) {
+		if !bytes.HasPrefix(data, encryptedPrefix) {
+			return nil, fmt.Errorf("data is not encrypted")
+		}
+		return c.decrypt(data)
+	})
+}
+
+// decryptStored is Decrypt for the specs of a store, in which the data of
+// the secrets stored before encryption was enabled is left as is.
+func (c *Cipher) decryptStored(spec types.StackSpec) (types.StackSpec, error) {
+	return c.transform(spec, c.decrypt)
+}
+
+func (c *Cipher) transform(spec types.StackSpec, f func([]byte) ([]byte, error)) (types.StackSpec, error) {
+	if len(spec.Secrets) == 0 {
+		return spec, nil
+	}
+	secrets := make([]swarm.SecretSpec, len(spec.Secrets))
+	for i, secret := range spec.Secrets {
+		if len(secret.Data) > 0 {
+			data, err := f(secret.Data)
+			if err != nil {
+				return types.StackSpec{}, fmt.Errorf("secret %s: %s", secret.Annotations.Name, err)
+			}
+			secret.Data = data
+		}
+		secrets[i] = secret
+	}
+	spec.Secrets = secrets
+	return spec, nil
+}
+
+func (c *Cipher) encrypt(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, encryptedPrefix) {
+		return data, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	encrypted := append([]byte{}, encryptedPrefix...)
+	encrypted = append(encrypted, nonce...)
+	return c.aead.Seal(encrypted, nonce, data, nil), nil
+}
+
+func (c *Cipher) decrypt(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedPrefix) {
+		return data, nil
+	}
+	data = data[len(encryptedPrefix):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, nil)
+}
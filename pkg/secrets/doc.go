@@ -0,0 +1,12 @@
+package secrets
+
+// The `secrets` package keeps the data of the secrets of stacks private.
+//
+// The StackSpecs returned by the Stacks API, and sent to admission webhooks,
+// are redacted: the data of their secrets is replaced by a
+// types.StackSecretHashLabel holding a hash of the data. Restore puts the
+// data back into a redacted StackSpec sent back by a client.
+//
+// The data of the secrets of stored StackSpecs is encrypted with AES-GCM by
+// a StackStore returned by NewEncryptedStackStore, with a key supplied to the
+// controller. Data stored before the key was supplied is read as is.
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Hash returns the value of the types.StackSecretHashLabel of a secret with
+// data.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Redact returns a copy of spec without the data of its secrets, which is
+// replaced by their types.StackSecretHashLabel.
+func Redact(spec types.StackSpec) types.StackSpec {
+	if len(spec.Secrets) == 0 {
+		return spec
+	}
+	secrets := make([]swarm.SecretSpec, len(spec.Secrets))
+	for i, secret := range spec.Secrets {
+		if len(secret.Data) > 0 {
+			secret.Annotations.Labels = withLabel(secret.Annotations.Labels, types.StackSecretHashLabel, Hash(secret.Data))
+			secret.Data = nil
+		}
+		secrets[i] = secret
+	}
+	spec.Secrets = secrets
+	return spec
+}
+
+// RedactStack returns a copy of stack whose StackSpec is redacted.
+func RedactStack(stack types.Stack) types.Stack {
+	stack.Spec = Redact(stack.Spec)
+	return stack
+}
+
+// RedactStacks returns copies of stacks whose StackSpecs are redacted.
+func RedactStacks(stacks []types.Stack) []types.Stack {
+	redacted := make([]types.Stack, len(stacks))
+	for i, stack := range stacks {
+		redacted[i] = RedactStack(stack)
+	}
+	return redacted
+}
+
+// Redacted returns the names of the secrets of spec whose data is redacted,
+// and only known from their types.StackSecretHashLabel.
+func Redacted(spec types.StackSpec) []string {
+	var names []string
+	for _, secret := range spec.Secrets {
+		if _, ok := secret.Annotations.Labels[types.StackSecretHashLabel]; ok && len(secret.Data) == 0 {
+			names = append(names, secret.Annotations.Name)
+		}
+	}
+	return names
+}
+
+// Restore returns a copy of spec in which the secrets redacted by Redact
+// have the data of the secrets of the same name in stored. A redacted secret
+// whose hash does not match the data of the stored secret fails with an
+// errdefs.InvalidParameter error, as its data is unknown.
+func Restore(spec, stored types.StackSpec) (types.StackSpec, error) {
+	if len(spec.Secrets) == 0 {
+		return spec, nil
+	}
+	storedData := map[string][]byte{}
+	for _, secret := range stored.Secrets {
+		storedData[secret.Annotations.Name] = secret.Data
+	}
+
+	secrets := make([]swarm.SecretSpec, len(spec.Secrets))
+	for i, secret := range spec.Secrets {
+		hash, ok := secret.Annotations.Labels[types.StackSecretHashLabel]
+		if ok {
+			secret.Annotations.Labels = withoutLabel(secret.Annotations.Labels, types.StackSecretHashLabel)
+			if len(secret.Data) == 0 {
+				data, found := storedData[secret.Annotations.Name]
+				if !found || Hash(data) != hash {
+					return types.StackSpec{}, errdefs.InvalidParameter(
+						fmt.Errorf("the data of secret %s is redacted and does not match a stored secret", secret.Annotations.Name))
+				}
+				secret.Data = data
+			}
+		}
+		secrets[i] = secret
+	}
+	spec.Secrets = secrets
+	return spec, nil
+}
+
+// RestoreNew returns a copy of the spec of a new stack, in which the
+// secrets are not redacted. A new stack has no stored secrets to restore the
+// data of redacted ones from, so that these fail as in Restore.
+func RestoreNew(spec types.StackSpec) (types.StackSpec, error) {
+	return Restore(spec, types.StackSpec{})
+}
+
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+func withoutLabel(labels map[string]string, key string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != key {
+			copied[k] = v
+		}
+	}
+	if len(copied) == 0 {
+		return nil
+	}
+	return copied
+}
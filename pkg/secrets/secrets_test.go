@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/fakes"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestSpec() types.StackSpec {
+	return types.StackSpec{
+		Annotations: swarm.Annotations{Name: "app"},
+		Secrets: []swarm.SecretSpec{
+			{
+				Annotations: swarm.Annotations{Name: "password"},
+				Data:        []byte("hunter2"),
+			},
+			{
+				Annotations: swarm.Annotations{Name: "external"},
+				Driver:      &swarm.Driver{Name: "vault"},
+			},
+		},
+	}
+}
+
+func TestRedact(t *testing.T) {
+	require := require.New(t)
+	spec := getTestSpec()
+
+	redacted := Redact(spec)
+	require.Nil(redacted.Secrets[0].Data)
+	require.Equal(Hash([]byte("hunter2")), redacted.Secrets[0].Annotations.Labels[types.StackSecretHashLabel])
+	require.Nil(redacted.Secrets[1].Annotations.Labels)
+
+	// The original is left untouched
+	require.Equal([]byte("hunter2"), spec.Secrets[0].Data)
+	require.Nil(spec.Secrets[0].Annotations.Labels)
+}
+
+func TestRestore(t *testing.T) {
+	require := require.New(t)
+	stored := getTestSpec()
+
+	restored, err := Restore(Redact(stored), stored)
+	require.NoError(err)
+	require.Equal(stored, restored)
+
+	// New data replaces the stored data
+	changed := Redact(stored)
+	changed.Secrets[0].Data = []byte("correct horse")
+	restored, err = Restore(changed, stored)
+	require.NoError(err)
+	require.Equal([]byte("correct horse"), restored.Secrets[0].Data)
+	require.Nil(restored.Secrets[0].Annotations.Labels)
+
+	// Redacted data can only be restored from the same data
+	other := getTestSpec()
+	other.Secrets[0].Data = []byte("other")
+	_, err = Restore(Redact(stored), other)
+	require.True(errdefs.IsInvalidParameter(err))
+	_, err = Restore(Redact(stored), types.StackSpec{})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// A new stack can only have secrets with their data
+	restored, err = RestoreNew(stored)
+	require.NoError(err)
+	require.Equal(stored, restored)
+	_, err = RestoreNew(Redact(stored))
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
+func getTestCipher(t *testing.T) *Cipher {
+	cipher, err := NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	return cipher
+}
+
+func TestCipher(t *testing.T) {
+	require := require.New(t)
+	cipher := getTestCipher(t)
+	spec := getTestSpec()
+
+	encrypted, err := cipher.Encrypt(spec)
+	require.NoError(err)
+	require.NotContains(string(encrypted.Secrets[0].Data), "hunter2")
+	require.Equal([]byte("hunter2"), spec.Secrets[0].Data)
+
+	// Encrypting twice does not encrypt the encrypted data
+	again, err := cipher.Encrypt(encrypted)
+	require.NoError(err)
+	require.Equal(encrypted, again)
+
+	decrypted, err := cipher.Decrypt(encrypted)
+	require.NoError(err)
+	require.Equal(spec, decrypted)
+
+	// Data which is not encrypted is rejected, unless it was stored
+	// before encryption was enabled
+	_, err = cipher.Decrypt(spec)
+	require.Error(err)
+	decrypted, err = cipher.decryptStored(spec)
+	require.NoError(err)
+	require.Equal(spec, decrypted)
+
+	_, err = NewCipher([]byte("short"))
+	require.Error(err)
+	other, err := NewCipher([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(err)
+	_, err = other.Decrypt(encrypted)
+	require.Error(err)
+}
+
+func TestEncryptedStackStore(t *testing.T) {
+	require := require.New(t)
+	underlying := fakes.NewFakeStackStore()
+	store := NewEncryptedStackStore(underlying, getTestCipher(t))
+	spec := getTestSpec()
+
+	id, err := store.AddStack(spec)
+	require.NoError(err)
+
+	raw, err := underlying.GetStack(id)
+	require.NoError(err)
+	require.NotEqual(spec.Secrets[0].Data, raw.Spec.Secrets[0].Data)
+
+	stack, err := store.GetStack(id)
+	require.NoError(err)
+	require.Equal(spec, stack.Spec)
+
+	stacks, err := store.ListStacks(types.StackListOptions{})
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Equal(spec, stacks[0].Spec)
+
+	spec.Secrets[0].Data = []byte("correct horse")
+	require.NoError(store.UpdateStack(id, spec, stack.Version.Index))
+
+	snapshot, err := store.GetSnapshotStack(id)
+	require.NoError(err)
+	require.Equal(spec, snapshot.CurrentSpec)
+
+	snapshot.RolledBack = &spec
+	updated, err := store.UpdateSnapshotStack(id, snapshot, snapshot.Version.Index)
+	require.NoError(err)
+	require.Equal(spec, *updated.RolledBack)
+
+	rawSnapshot, err := underlying.GetSnapshotStack(id)
+	require.NoError(err)
+	require.NotEqual(spec.Secrets[0].Data, rawSnapshot.RolledBack.Secrets[0].Data)
+}
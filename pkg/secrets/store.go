@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// encryptedStackStore encrypts the data of the secrets of the stacks it
+// stores in an underlying interfaces.StackStore.
+type encryptedStackStore struct {
+	interfaces.StackStore
+	cipher *Cipher
+}
+
+// NewEncryptedStackStore creates an interfaces.StackStore which stores stacks
+// in store with the data of their secrets encrypted by cipher.
+func NewEncryptedStackStore(store interfaces.StackStore, cipher *Cipher) interfaces.StackStore {
+	return &encryptedStackStore{
+		StackStore: store,
+		cipher:     cipher,
+	}
+}
+
+// AddStack stores a new stack
+func (s *encryptedStackStore) AddStack(spec types.StackSpec) (string, error) {
+	encrypted, err := s.cipher.Encrypt(spec)
+	if err != nil {
+		return "", err
+	}
+	return s.StackStore.AddStack(encrypted)
+}
+
+// AddStackWithOrchestrator stores a new stack deployed on orchestrator
+func (s *encryptedStackStore) AddStackWithOrchestrator(spec types.StackSpec, orchestrator types.OrchestratorChoice) (string, error) {
+	encrypted, err := s.cipher.Encrypt(spec)
+	if err != nil {
+		return "", err
+	}
+	return s.StackStore.AddStackWithOrchestrator(encrypted, orchestrator)
+}
+
+// UpdateStack updates the spec of a stack
+func (s *encryptedStackStore) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	encrypted, err := s.cipher.Encrypt(spec)
+	if err != nil {
+		return err
+	}
+	return s.StackStore.UpdateStack(id, encrypted, version)
+}
+
+// UpdateSnapshotStack updates the snapshot of a stack
+func (s *encryptedStackStore) UpdateSnapshotStack(id string, snapshot interfaces.SnapshotStack, version uint64) (interfaces.SnapshotStack, error) {
+	encrypted, err := s.encryptSnapshot(snapshot)
+	if err != nil {
+		return interfaces.SnapshotStack{}, err
+	}
+	updated, err := s.StackStore.UpdateSnapshotStack(id, encrypted, version)
+	if err != nil {
+		return updated, err
+	}
+	return s.decryptSnapshot(updated)
+}
+
+// GetStack retrieves a stack by its ID
+func (s *encryptedStackStore) GetStack(id string) (types.Stack, error) {
+	stack, err := s.StackStore.GetStack(id)
+	if err != nil {
+		return stack, err
+	}
+	stack.Spec, err = s.cipher.decryptStored(stack.Spec)
+	return stack, err
+}
+
+// GetSnapshotStack retrieves the snapshot of a stack by its ID
+func (s *encryptedStackStore) GetSnapshotStack(id string) (interfaces.SnapshotStack, error) {
+	snapshot, err := s.StackStore.GetSnapshotStack(id)
+	if err != nil {
+		return snapshot, err
+	}
+	return s.decryptSnapshot(snapshot)
+}
+
+// ListStacks lists the stacks selected by options
+func (s *encryptedStackStore) ListStacks(options types.StackListOptions) ([]types.Stack, error) {
+	stacks, err := s.StackStore.ListStacks(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stacks {
+		if stacks[i].Spec, err = s.cipher.decryptStored(stacks[i].Spec); err != nil {
+			return nil, err
+		}
+	}
+	return stacks, nil
+}
+
+func (s *encryptedStackStore) encryptSnapshot(snapshot interfaces.SnapshotStack) (interfaces.SnapshotStack, error) {
+	return s.transformSnapshot(snapshot, s.cipher.Encrypt)
+}
+
+func (s *encryptedStackStore) decryptSnapshot(snapshot interfaces.SnapshotStack) (interfaces.SnapshotStack, error) {
+	return s.transformSnapshot(snapshot, s.cipher.decryptStored)
+}
+
+// transformSnapshot applies f to the StackSpecs held by snapshot
+func (s *encryptedStackStore) transformSnapshot(snapshot interfaces.SnapshotStack, f func(types.StackSpec) (types.StackSpec, error)) (interfaces.SnapshotStack, error) {
+	var err error
+	if snapshot.CurrentSpec, err = f(snapshot.CurrentSpec); err != nil {
+		return interfaces.SnapshotStack{}, err
+	}
+	if snapshot.RolledBack != nil {
+		rolledBack, err := f(*snapshot.RolledBack)
+		if err != nil {
+			return interfaces.SnapshotStack{}, err
+		}
+		snapshot.RolledBack = &rolledBack
+	}
+	return snapshot, nil
+}
@@ -15,6 +15,26 @@ import (
 
 func init() {
 	typeurl.Register(&interfaces.SnapshotStack{}, "github.com/docker/interfaces/SnapshotStack")
+	typeurl.Register(&types.StackMaintenance{}, "github.com/docker/types/StackMaintenance")
+}
+
+// MarshalMaintenance marshals the cluster-wide maintenance switch into a
+// protocol buffer Any message.
+func MarshalMaintenance(maintenance *types.StackMaintenance) (*gogotypes.Any, error) {
+	return typeurl.MarshalAny(maintenance)
+}
+
+// UnmarshalMaintenance does the MarshalMaintenance operation in reverse.
+func UnmarshalMaintenance(payload *gogotypes.Any) (*types.StackMaintenance, error) {
+	iface, err := typeurl.UnmarshalAny(payload)
+	if err != nil {
+		return nil, err
+	}
+	maintenance, ok := iface.(*types.StackMaintenance)
+	if !ok {
+		return nil, errors.New("got back something other than a maintenance switch")
+	}
+	return maintenance, nil
 }
 
 // MarshalSnapshotStackSpec takes a interfaces.SnapshotStack and a
@@ -38,6 +58,12 @@ func MarshalSnapshotStackSnapshot(existingSnapshot *interfaces.SnapshotStack, sn
 	existingSnapshot.Configs = snapshot.Configs
 	existingSnapshot.Secrets = snapshot.Secrets
 	existingSnapshot.Networks = snapshot.Networks
+	existingSnapshot.Status = snapshot.Status
+	existingSnapshot.RolledBack = snapshot.RolledBack
+	existingSnapshot.Rollout = snapshot.Rollout
+	existingSnapshot.Deployments = snapshot.Deployments
+	existingSnapshot.Paused = snapshot.Paused
+	existingSnapshot.Versions = snapshot.Versions
 
 	return typeurl.MarshalAny(existingSnapshot)
 }
@@ -53,6 +79,16 @@ func MarshalStackSpec(stackSpec *types.StackSpec) (*gogotypes.Any, error) {
 
 }
 
+// MarshalStackSpecWithOrchestrator is MarshalStackSpec for a stack deployed
+// on orchestrator, which the SnapshotStack records.
+func MarshalStackSpecWithOrchestrator(stackSpec *types.StackSpec, orchestrator types.OrchestratorChoice) (*gogotypes.Any, error) {
+	snapshotStack := interfaces.SnapshotStack{
+		CurrentSpec:  *stackSpec,
+		Orchestrator: orchestrator,
+	}
+	return typeurl.MarshalAny(&snapshotStack)
+}
+
 // UnmarshalStackSpec does the MarshalStackSpec operation in reverse --
 // takes a proto message, and returns the StackSpec contained in it.
 func UnmarshalStackSpec(payload *gogotypes.Any) (*types.StackSpec, error) {
@@ -79,17 +115,17 @@ func UnmarshalSnapshotStack(payload *gogotypes.Any) (*interfaces.SnapshotStack,
 	return snapshotStack, nil
 }
 
-// ConstructStack takes a Swarmkit Resource object, calls UnmarshalStackSpec and
-// constructs a fresh types.Stack and populates its fields (Meta, Version, and ID)
-// contained in the Resource
+// ConstructStack takes a Swarmkit Resource object, calls UnmarshalSnapshotStack
+// and constructs a fresh types.Stack and populates its fields (Meta, Version,
+// ID and Status) contained in the Resource
 func ConstructStack(resource *api.Resource) (*types.Stack, error) {
 
 	// now, we have to get the stack out of the resource object
-	stackSpec, err := UnmarshalStackSpec(resource.Payload)
+	snapshotStack, err := UnmarshalSnapshotStack(resource.Payload)
 	if err != nil {
 		return &types.Stack{}, err
 	}
-	if stackSpec == nil {
+	if snapshotStack == nil {
 		return &types.Stack{}, errors.New("got back an empty stack")
 	}
 
@@ -112,7 +148,10 @@ func ConstructStack(resource *api.Resource) (*types.Stack, error) {
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
 		},
-		Spec: *stackSpec,
+		Spec:         snapshotStack.CurrentSpec,
+		Orchestrator: snapshotStack.Orchestrator,
+		Status:       snapshotStack.Status,
+		Paused:       snapshotStack.Paused,
 	}
 	return &stack, nil
 }
@@ -27,6 +27,12 @@ func (s *StackStore) AddStack(stackSpec types.StackSpec) (string, error) {
 	return AddStack(context.TODO(), s.client, stackSpec)
 }
 
+// AddStackWithOrchestrator creates a new Stack object deployed on
+// orchestrator in the swarmkit data store.
+func (s *StackStore) AddStackWithOrchestrator(stackSpec types.StackSpec, orchestrator types.OrchestratorChoice) (string, error) {
+	return AddStackWithOrchestrator(context.TODO(), s.client, stackSpec, orchestrator)
+}
+
 // UpdateStack updates an existing Stack object
 func (s *StackStore) UpdateStack(id string, stackSpec types.StackSpec, version uint64) error {
 	return UpdateStack(context.TODO(), s.client, id, stackSpec, version)
@@ -52,7 +58,17 @@ func (s *StackStore) GetSnapshotStack(id string) (interfaces.SnapshotStack, erro
 	return GetSnapshotStack(context.TODO(), s.client, id)
 }
 
-// ListStacks lists all available stack objects
-func (s *StackStore) ListStacks() ([]types.Stack, error) {
-	return ListStacks(context.TODO(), s.client)
+// ListStacks lists the stack objects selected by options
+func (s *StackStore) ListStacks(options types.StackListOptions) ([]types.Stack, error) {
+	return ListStacks(context.TODO(), s.client, options)
+}
+
+// GetMaintenance returns the cluster-wide maintenance switch
+func (s *StackStore) GetMaintenance() (types.StackMaintenance, error) {
+	return GetMaintenance(context.TODO(), s.client)
+}
+
+// UpdateMaintenance sets the cluster-wide maintenance switch
+func (s *StackStore) UpdateMaintenance(maintenance types.StackMaintenance) error {
+	return UpdateMaintenance(context.TODO(), s.client, maintenance)
 }
@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/query"
 	"github.com/docker/stacks/pkg/types"
 )
 
@@ -24,16 +25,36 @@ const (
 	// StackResourcesDescription is the description string of the stack
 	// extension kind.
 	StackResourcesDescription = "Docker server-side stacks"
+
+	// MaintenanceResourceKind defines the Kind of the swarmkit Resource
+	// holding the cluster-wide maintenance switch of stacks.
+	MaintenanceResourceKind = "github.com/docker/stacks/Maintenance"
+	// MaintenanceResourceDescription is the description string of the
+	// maintenance extension kind.
+	MaintenanceResourceDescription = "Docker server-side stacks maintenance switch"
+	// maintenanceResourceName is the name of the single resource of the
+	// maintenance extension kind.
+	maintenanceResourceName = "maintenance"
 )
 
-// InitExtension initializes the stack resource extension object
+// InitExtension initializes the stack resource extension object, and the
+// extension of the resource holding the maintenance switch
 func InitExtension(ctx context.Context, rc ResourcesClient) error {
+	if err := initExtension(ctx, rc, StackResourceKind, StackResourcesDescription); err != nil {
+		return err
+	}
+	return initExtension(ctx, rc, MaintenanceResourceKind, MaintenanceResourceDescription)
+}
+
+// initExtension creates the extension of the resources of kind, unless it
+// already exists
+func initExtension(ctx context.Context, rc ResourcesClient, kind, description string) error {
 	// try creating the extension
 	req := &swarmapi.CreateExtensionRequest{
 		Annotations: &swarmapi.Annotations{
-			Name: StackResourceKind,
+			Name: kind,
 		},
-		Description: StackResourcesDescription,
+		Description: description,
 	}
 	// we don't actually care about the response -- the only important thing
 	// is the error.
@@ -61,8 +82,13 @@ func InitExtension(ctx context.Context, rc ResourcesClient) error {
 
 // AddStack adds a stack
 func AddStack(ctx context.Context, rc ResourcesClient, stackSpec types.StackSpec) (string, error) {
+	return AddStackWithOrchestrator(ctx, rc, stackSpec, "")
+}
+
+// AddStackWithOrchestrator adds a stack deployed on orchestrator
+func AddStackWithOrchestrator(ctx context.Context, rc ResourcesClient, stackSpec types.StackSpec, orchestrator types.OrchestratorChoice) (string, error) {
 	// first, marshal the stackSpec and runtimeStack to a proto message
-	any, err := MarshalStackSpec(&stackSpec)
+	any, err := MarshalStackSpecWithOrchestrator(&stackSpec, orchestrator)
 	if err != nil {
 		return "", err
 	}
@@ -105,7 +131,10 @@ func UpdateStack(ctx context.Context, rc ResourcesClient, id string, stackSpec t
 	}
 
 	if version != resource.Meta.Version.Index {
-		return errors.New("Stack Version stale")
+		return types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: resource.Meta.Version.Index},
+		}
 	}
 
 	snapshotStackResource, err := UnmarshalSnapshotStack(resource.Payload)
@@ -149,7 +178,10 @@ func UpdateSnapshotStack(ctx context.Context, rc ResourcesClient, id string, sna
 	}
 
 	if version != resource.Meta.Version.Index {
-		return interfaces.SnapshotStack{}, errors.New("Stack Version stale")
+		return interfaces.SnapshotStack{}, types.StackVersionConflict{
+			ID:      id,
+			Current: types.Version{Index: resource.Meta.Version.Index},
+		}
 	}
 
 	existingSnapshot, err := UnmarshalSnapshotStack(resource.Payload)
@@ -227,8 +259,8 @@ func GetSnapshotStack(ctx context.Context, rc ResourcesClient, id string) (inter
 	return *snapshot, nil
 }
 
-// ListStacks returns all stacks
-func ListStacks(ctx context.Context, rc ResourcesClient) ([]types.Stack, error) {
+// ListStacks returns the stacks selected by options
+func ListStacks(ctx context.Context, rc ResourcesClient, options types.StackListOptions) ([]types.Stack, error) {
 	resp, err := rc.ListResources(ctx,
 		&swarmapi.ListResourcesRequest{
 			Filters: &swarmapi.ListResourcesRequest_Filters{
@@ -250,5 +282,69 @@ func ListStacks(ctx context.Context, rc ResourcesClient) ([]types.Stack, error)
 		}
 		stacks = append(stacks, *stack)
 	}
-	return stacks, nil
+	return query.Stacks(stacks, options)
+}
+
+// getMaintenanceResource returns the resource holding the maintenance switch,
+// or nil if it was never set
+func getMaintenanceResource(ctx context.Context, rc ResourcesClient) (*swarmapi.Resource, error) {
+	resp, err := rc.ListResources(ctx,
+		&swarmapi.ListResourcesRequest{
+			Filters: &swarmapi.ListResourcesRequest_Filters{
+				Kind: MaintenanceResourceKind,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Resources) == 0 {
+		return nil, nil
+	}
+	return resp.Resources[0], nil
+}
+
+// GetMaintenance returns the cluster-wide maintenance switch. It is
+// disabled until it is first set.
+func GetMaintenance(ctx context.Context, rc ResourcesClient) (types.StackMaintenance, error) {
+	resource, err := getMaintenanceResource(ctx, rc)
+	if err != nil || resource == nil {
+		return types.StackMaintenance{}, err
+	}
+	maintenance, err := UnmarshalMaintenance(resource.Payload)
+	if err != nil {
+		return types.StackMaintenance{}, err
+	}
+	return *maintenance, nil
+}
+
+// UpdateMaintenance sets the cluster-wide maintenance switch.
+func UpdateMaintenance(ctx context.Context, rc ResourcesClient, maintenance types.StackMaintenance) error {
+	any, err := MarshalMaintenance(&maintenance)
+	if err != nil {
+		return err
+	}
+
+	resource, err := getMaintenanceResource(ctx, rc)
+	if err != nil {
+		return err
+	}
+	annotations := &swarmapi.Annotations{
+		Name: maintenanceResourceName,
+	}
+	if resource == nil {
+		_, err = rc.CreateResource(ctx, &swarmapi.CreateResourceRequest{
+			Annotations: annotations,
+			Kind:        MaintenanceResourceKind,
+			Payload:     any,
+		})
+		return err
+	}
+	_, err = rc.UpdateResource(ctx, &swarmapi.UpdateResourceRequest{
+		ResourceID:      resource.ID,
+		ResourceVersion: &resource.Meta.Version,
+		Annotations:     annotations,
+		Payload:         any,
+	})
+	return err
 }
@@ -31,7 +31,7 @@ var _ = Describe("Store functions", func() {
 			ctrl.Finish()
 		})
 
-		It("should ensure the extension exists", func() {
+		It("should ensure the extensions exist", func() {
 			mockResourcesClient.EXPECT().CreateExtension(
 				ctx, &swarmapi.CreateExtensionRequest{
 					Annotations: &swarmapi.Annotations{
@@ -40,12 +40,20 @@ var _ = Describe("Store functions", func() {
 					Description: StackResourcesDescription,
 				},
 			).Return(nil, nil)
+			mockResourcesClient.EXPECT().CreateExtension(
+				ctx, &swarmapi.CreateExtensionRequest{
+					Annotations: &swarmapi.Annotations{
+						Name: MaintenanceResourceKind,
+					},
+					Description: MaintenanceResourceDescription,
+				},
+			).Return(nil, nil)
 
 			err := InitExtension(ctx, mockResourcesClient)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
-		It("should return no error if the extension already exists", func() {
+		It("should return no error if the extensions already exist", func() {
 			mockResourcesClient.EXPECT().CreateExtension(
 				ctx, &swarmapi.CreateExtensionRequest{
 					Annotations: &swarmapi.Annotations{
@@ -54,6 +62,14 @@ var _ = Describe("Store functions", func() {
 					Description: StackResourcesDescription,
 				},
 			).Return(nil, status.Errorf(codes.AlreadyExists, "already exists"))
+			mockResourcesClient.EXPECT().CreateExtension(
+				ctx, &swarmapi.CreateExtensionRequest{
+					Annotations: &swarmapi.Annotations{
+						Name: MaintenanceResourceKind,
+					},
+					Description: MaintenanceResourceDescription,
+				},
+			).Return(nil, status.Errorf(codes.AlreadyExists, "already exists"))
 
 			err := InitExtension(ctx, mockResourcesClient)
 			Expect(err).ToNot(HaveOccurred())
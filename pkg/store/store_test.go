@@ -336,12 +336,100 @@ var _ = Describe("StackStore", func() {
 			})
 
 			Specify("ListStacks", func() {
-				stacks, err := s.ListStacks()
+				stacks, err := s.ListStacks(types.StackListOptions{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(stacks).To(ConsistOf(allStacks...))
 			})
 		})
 
+		Describe("Maintenance", func() {
+			var maintenanceResource *swarmapi.Resource
+
+			BeforeEach(func() {
+				any, err := MarshalMaintenance(&types.StackMaintenance{Enabled: true})
+				Expect(err).ToNot(HaveOccurred())
+				maintenanceResource = &swarmapi.Resource{
+					ID: "maintenanceID",
+					Meta: swarmapi.Meta{
+						Version: swarmapi.Version{
+							Index: 3,
+						},
+					},
+					Annotations: swarmapi.Annotations{
+						Name: maintenanceResourceName,
+					},
+					Kind:    MaintenanceResourceKind,
+					Payload: any,
+				}
+			})
+
+			listMaintenance := func(resources ...*swarmapi.Resource) {
+				mockClient.EXPECT().ListResources(
+					context.TODO(),
+					&swarmapi.ListResourcesRequest{
+						Filters: &swarmapi.ListResourcesRequest_Filters{
+							Kind: MaintenanceResourceKind,
+						},
+					},
+				).Return(
+					&swarmapi.ListResourcesResponse{
+						Resources: resources,
+					}, nil,
+				)
+			}
+
+			Specify("GetMaintenance before it is set", func() {
+				listMaintenance()
+				maintenance, err := s.GetMaintenance()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(maintenance.Enabled).To(BeFalse())
+			})
+
+			Specify("GetMaintenance", func() {
+				listMaintenance(maintenanceResource)
+				maintenance, err := s.GetMaintenance()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(maintenance.Enabled).To(BeTrue())
+			})
+
+			Specify("UpdateMaintenance before it is set", func() {
+				listMaintenance()
+				mockClient.EXPECT().CreateResource(
+					context.TODO(),
+					&swarmapi.CreateResourceRequest{
+						Annotations: &maintenanceResource.Annotations,
+						Kind:        MaintenanceResourceKind,
+						Payload:     maintenanceResource.Payload,
+					},
+				).Return(
+					&swarmapi.CreateResourceResponse{
+						Resource: maintenanceResource,
+					}, nil,
+				)
+				Expect(s.UpdateMaintenance(types.StackMaintenance{Enabled: true})).To(Succeed())
+			})
+
+			Specify("UpdateMaintenance", func() {
+				listMaintenance(maintenanceResource)
+				disabled, err := MarshalMaintenance(&types.StackMaintenance{})
+				Expect(err).ToNot(HaveOccurred())
+				mockClient.EXPECT().UpdateResource(
+					context.TODO(),
+					&swarmapi.UpdateResourceRequest{
+						ResourceID:      maintenanceResource.ID,
+						ResourceVersion: &maintenanceResource.Meta.Version,
+						Annotations:     &maintenanceResource.Annotations,
+						Payload:         disabled,
+					},
+				).Return(
+					&swarmapi.UpdateResourceResponse{
+						Resource: maintenanceResource,
+					}, nil,
+				)
+				Expect(s.UpdateMaintenance(types.StackMaintenance{})).To(Succeed())
+			})
+		})
+
 		AfterEach(func() {
 			mockController.Finish()
 		})
@@ -0,0 +1,35 @@
+package types
+
+import "encoding/json"
+
+// StackAdmissionOperation is the operation submitted to admission webhooks
+type StackAdmissionOperation string
+
+const (
+	// StackAdmissionCreate is the admission of a new Stack
+	StackAdmissionCreate StackAdmissionOperation = "create"
+
+	// StackAdmissionUpdate is the admission of a new StackSpec for an
+	// existing Stack
+	StackAdmissionUpdate StackAdmissionOperation = "update"
+)
+
+// StackAdmissionRequest is the body of the request sent to an admission
+// webhook. ID and OldSpec are only set on updates.
+type StackAdmissionRequest struct {
+	Operation StackAdmissionOperation
+	ID        string `json:",omitempty"`
+	Spec      StackSpec
+	OldSpec   *StackSpec `json:",omitempty"`
+}
+
+// StackAdmissionResponse is the body of the response of an admission
+// webhook. A mutating webhook which allows a request may return a Patch of
+// the submitted StackSpec, of type PatchType, which defaults to
+// StackPatchJSON.
+type StackAdmissionResponse struct {
+	Allowed   bool
+	Reason    string          `json:",omitempty"`
+	PatchType StackPatchType  `json:",omitempty"`
+	Patch     json.RawMessage `json:",omitempty"`
+}
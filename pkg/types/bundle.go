@@ -0,0 +1,101 @@
+package types
+
+import (
+	"time"
+)
+
+// StackBundleVersion is the version of the format of the StackBundles
+// exported by the Stacks API. It only changes when the format changes in a
+// way earlier versions of the Stacks API cannot import.
+const StackBundleVersion = "1"
+
+// StackBundleKeyHeader is the HTTP header carrying the base64 encoded AES
+// key the secrets of a StackBundle are encrypted with, on export and import.
+const StackBundleKeyHeader = "X-Stacks-Bundle-Key"
+
+// StackBundleSecrets tells how the data of the secrets of a StackBundle is
+// carried.
+type StackBundleSecrets string
+
+const (
+	// StackBundleSecretsExcluded - the data of secrets is left out of the
+	// bundle, and replaced by a StackSecretHashLabel, as in the StackSpecs
+	// returned by the Stacks API. This is the default.
+	StackBundleSecretsExcluded StackBundleSecrets = "excluded"
+
+	// StackBundleSecretsEncrypted - the data of secrets is in the bundle,
+	// encrypted with an AES key which is not in the bundle
+	StackBundleSecretsEncrypted StackBundleSecrets = "encrypted"
+)
+
+// StackBundle is a self-contained copy of a Stack, exported from a server so
+// that the Stack can be recreated by an import, on the same server or on
+// another one.
+type StackBundle struct {
+	// BundleVersion is the StackBundleVersion of the format of the bundle.
+	BundleVersion string
+	ExportedAt    time.Time
+
+	// Source records the Stack the bundle was exported from.
+	Source StackBundleSource
+
+	// Orchestrator is the orchestrator the Stack was deployed on, and is
+	// recreated on unless the import chooses another one.
+	Orchestrator OrchestratorChoice `json:",omitempty"`
+
+	// Secrets tells how the data of the secrets of Spec is carried.
+	Secrets StackBundleSecrets
+	Spec    StackSpec
+}
+
+// StackBundleSource records the Stack a StackBundle was exported from. It is
+// informative only: imported stacks get their own ID and version.
+type StackBundleSource struct {
+	ID        string
+	Cluster   string `json:",omitempty"`
+	Version   uint64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// StackExportOptions holds the parameters to export a Stack.
+type StackExportOptions struct {
+	// Secrets tells how the data of secrets is carried in the bundle,
+	// StackBundleSecretsExcluded by default.
+	Secrets StackBundleSecrets
+
+	// Key is the AES key of 16, 24 or 32 bytes the data of secrets is
+	// encrypted with, when Secrets is StackBundleSecretsEncrypted.
+	Key []byte
+}
+
+// StackImportOptions holds the parameters to recreate a Stack from a
+// StackBundle.
+type StackImportOptions struct {
+	// Name renames the imported Stack, if set.
+	Name string
+
+	// Orchestrator and Cluster choose where the Stack is recreated, in
+	// place of the orchestrator of the bundle and the default cluster.
+	Orchestrator OrchestratorChoice
+	Cluster      string
+
+	// Secrets holds the data of secrets by name. It is required for the
+	// secrets excluded from the bundle, and replaces the data of the
+	// others.
+	Secrets map[string][]byte
+
+	// Key is the AES key the data of the secrets of the bundle is
+	// encrypted with, when they are StackBundleSecretsEncrypted.
+	Key []byte
+}
+
+// StackImportRequest is the body of a request to import a StackBundle, with
+// the StackImportOptions other than the Key.
+type StackImportRequest struct {
+	Bundle       StackBundle
+	Name         string             `json:",omitempty"`
+	Orchestrator OrchestratorChoice `json:",omitempty"`
+	Cluster      string             `json:",omitempty"`
+	Secrets      map[string][]byte  `json:",omitempty"`
+}
@@ -0,0 +1,12 @@
+package types
+
+// StackComposeMediaType is the media type of the Compose files the Stacks
+// API returns, and creates and updates stacks from. A request body of this
+// type may hold several Compose files, as a stream of YAML documents.
+const StackComposeMediaType = "application/x-yaml"
+
+// StackComposeFilesLabel is a label on the StackSpecs loaded from several
+// Compose files, listing the names of the files merged into the StackSpec
+// in order, separated by commas. Files without a name, as the documents of
+// a YAML stream, are listed as "-".
+const StackComposeFilesLabel = "com.docker.stacks.compose_files"
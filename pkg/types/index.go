@@ -0,0 +1,27 @@
+package types
+
+// StackIndexStats reports how well the index of a stacks router, from the
+// IDs of stacks to the orchestrator of the backend holding them, spares
+// looking for stacks in every backend.
+type StackIndexStats struct {
+	// Hits is the number of stacks found in the backend they were
+	// indexed with.
+	Hits uint64
+	// Misses is the number of stacks looked for in every backend, as they
+	// were not indexed or no longer in the backend they were indexed with.
+	Misses uint64
+	// Invalidations is the number of stacks removed from the index, as
+	// they were deleted or no longer in the backend they were indexed with.
+	Invalidations uint64
+	// Size is the number of stacks indexed.
+	Size int
+}
+
+// HitRate returns the fraction of the lookups of stacks which were answered
+// by the index.
+func (s StackIndexStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
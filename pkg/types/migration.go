@@ -0,0 +1,98 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// DefaultStackMigrationTimeout is how long the copy of a migrated Stack is
+// given to converge on its new orchestrator when StackMigrateOptions do not
+// set a Timeout.
+const DefaultStackMigrationTimeout = 5 * time.Minute
+
+// StackMigrationPhase is a short, machine readable description of the
+// progress of a StackMigration.
+type StackMigrationPhase string
+
+const (
+	// StackMigrationCreating - the Stack is being created on the target
+	// orchestrator
+	StackMigrationCreating StackMigrationPhase = "creating"
+
+	// StackMigrationConverging - the Stack was created on the target
+	// orchestrator, whose tasks are not all running yet
+	StackMigrationConverging StackMigrationPhase = "converging"
+
+	// StackMigrationReady - the Stack converged on the target orchestrator,
+	// and waits to be cut over
+	StackMigrationReady StackMigrationPhase = "ready"
+
+	// StackMigrationCuttingOver - the Stack is served by the target
+	// orchestrator, and is being deleted from the source orchestrator
+	StackMigrationCuttingOver StackMigrationPhase = "cutting_over"
+
+	// StackMigrationCompleted - the Stack was moved to the target
+	// orchestrator
+	StackMigrationCompleted StackMigrationPhase = "completed"
+
+	// StackMigrationRolledBack - the Stack did not converge on the target
+	// orchestrator, and was deleted from it. It is still served by the
+	// source orchestrator.
+	StackMigrationRolledBack StackMigrationPhase = "rolled_back"
+
+	// StackMigrationFailed - the migration stopped on an error, reported in
+	// the Message of the StackMigration
+	StackMigrationFailed StackMigrationPhase = "failed"
+)
+
+// StackMigrateOptions holds the parameters to migrate a Stack to another
+// orchestrator.
+type StackMigrateOptions struct {
+	// To is the orchestrator the Stack is migrated to.
+	To OrchestratorChoice
+
+	// CutOver deletes the Stack from its current orchestrator as soon as
+	// it converged on the target one. Without it, the migration stops in
+	// the ready phase until it is requested again with CutOver.
+	CutOver bool
+
+	// Timeout is how long the Stack is given to converge on the target
+	// orchestrator before the migration is rolled back. Zero waits for
+	// DefaultStackMigrationTimeout.
+	Timeout time.Duration
+}
+
+// StackMigration reports the progress of the migration of a Stack from one
+// orchestrator to another. Until it is cut over, the Stack is only served
+// by the source orchestrator under SourceID, and from then on only by the
+// target orchestrator under TargetID.
+type StackMigration struct {
+	SourceID string
+	From     OrchestratorChoice
+	TargetID string `json:",omitempty"`
+	To       OrchestratorChoice
+	Phase    StackMigrationPhase
+	Message  string `json:",omitempty"`
+
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Done returns whether the migration is over, successfully or not.
+func (m StackMigration) Done() bool {
+	switch m.Phase {
+	case StackMigrationCompleted, StackMigrationRolledBack, StackMigrationFailed:
+		return true
+	}
+	return false
+}
+
+// ErrStackNotMigratable returns the errdefs.NotImplemented error of the
+// StackMigrate of the backends serving a single orchestrator, which cannot
+// migrate the Stack id. Stacks are migrated between orchestrators by a
+// router.StacksRouter serving both.
+func ErrStackNotMigratable(id string) error {
+	return errdefs.NotImplemented(fmt.Errorf("stack %s cannot be migrated to another orchestrator", id))
+}
@@ -0,0 +1,31 @@
+package types
+
+// StackDriftAction is the kind of change reconciling a Stack would make to
+// one of its resources.
+type StackDriftAction string
+
+const (
+	// StackDriftCreate - the resource is missing and would be created
+	StackDriftCreate StackDriftAction = "create"
+	// StackDriftUpdate - the resource differs from the StackSpec and would
+	// be updated
+	StackDriftUpdate StackDriftAction = "update"
+	// StackDriftDelete - the resource is not part of the StackSpec and
+	// would be deleted
+	StackDriftDelete StackDriftAction = "delete"
+)
+
+// StackDrift is a change which reconciling a paused Stack would make to one
+// of its resources, such as reverting a manual hotfix of a service.
+type StackDrift struct {
+	// Kind is the kind of the resource, as in the Type of its events
+	Kind   string
+	Name   string
+	Action StackDriftAction
+}
+
+// StackMaintenance is the cluster-wide maintenance switch. While it is
+// enabled, no Stack is reconciled, as if they were all paused.
+type StackMaintenance struct {
+	Enabled bool
+}
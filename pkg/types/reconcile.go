@@ -0,0 +1,18 @@
+package types
+
+// StackIgnoreFieldsLabel is a label on the services, secrets and configs of
+// a StackSpec listing paths, separated by commas, of fields that other
+// systems manage, such as replicas set by an autoscaler. The values of these
+// fields are ignored when the resource is compared to its spec, and are
+// preserved when the resource is updated.
+//
+// A path names the fields of the spec from its root, separated by dots, as
+// in "Mode.Replicated.Replicas" or "TaskTemplate.ForceUpdate". Past a map,
+// the rest of the path is a key of the map, as in "Labels.com.example.agent",
+// and a trailing "*" matches every key with the preceding prefix.
+const StackIgnoreFieldsLabel = "com.docker.stacks.ignore_fields"
+
+// StackCreateOnlyLabel is a label on the resources of a StackSpec which,
+// when "true", marks them as managed by hand once created. Such resources
+// are created if they are missing, but are never updated to their spec.
+const StackCreateOnlyLabel = "com.docker.stacks.create_only"
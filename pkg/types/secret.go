@@ -0,0 +1,10 @@
+package types
+
+// StackSecretHashLabel is a label on the secrets of the StackSpecs returned
+// by the Stacks API. The data of secrets is never returned; it is replaced by
+// this label, whose value is the hash of the data, as in "sha256:<hex>".
+//
+// A secret without data whose label matches the stored secret of the same
+// name keeps its stored data when the StackSpec is updated, so that a
+// StackSpec read from the API can be sent back unchanged.
+const StackSecretHashLabel = "com.docker.stacks.secret_hash"
@@ -18,8 +18,32 @@ type Stack struct {
 	ID string
 	swarm.Meta
 	Spec StackSpec
+	// Orchestrator is the orchestrator the stack is deployed on. Stacks
+	// which do not record one are deployed on Swarm.
+	Orchestrator OrchestratorChoice `json:",omitempty"`
+	// Cluster is the name of the cluster the stack is deployed on, for
+	// stacks served by a federation of stacks controllers.
+	Cluster string `json:",omitempty"`
+	Status  StackStatus
+	// Paused stacks are not reconciled. The changes reconciling them would
+	// make are reported as the Drift of their Status instead.
+	Paused bool `json:",omitempty"`
+	// Migration reports the last migration of the stack to another
+	// orchestrator, if any.
+	Migration *StackMigration `json:",omitempty"`
 }
 
+// StackStatus reports the state of a Stack.
+type StackStatus struct {
+	Phase   StackPhase   `json:",omitempty"`
+	Message string       `json:",omitempty"`
+	Drift   []StackDrift `json:",omitempty"`
+}
+
+// StackPhase is a short, machine readable description of the state of a
+// Stack.
+type StackPhase string
+
 // StackSpec represents a StackSpec with Engine API types.
 type StackSpec struct {
 	Annotations swarm.Annotations
@@ -32,11 +56,68 @@ type StackSpec struct {
 	Configs  []swarm.ConfigSpec
 	// There are no "Volumes" in a StackSpec -- Swarm has no concept of
 	// volumes
+
+	// Collection is the collection the stack is grouped into.
+	Collection string `json:",omitempty"`
+
+	// ResourceNaming selects how the names of the created services,
+	// networks, secrets and configs are derived from the names used in
+	// this StackSpec.
+	ResourceNaming ResourceNamingMode `json:",omitempty"`
+
+	// UpdateConfig configures rolling updates of the services of the
+	// stack. Without one, all the services are updated at once.
+	UpdateConfig *StackUpdateConfig `json:",omitempty"`
+
+	// DeployStrategies selects how the services named by its keys are
+	// deployed when they are updated. Services without a strategy are
+	// updated in place.
+	DeployStrategies map[string]StackDeployStrategy `json:",omitempty"`
+}
+
+// ResourceName returns the name given to the resource called name in the
+// StackSpec, according to its ResourceNaming.
+func (s StackSpec) ResourceName(name string) string {
+	if s.ResourceNaming != ResourceNamingNamespaced {
+		return name
+	}
+	return s.Annotations.Name + "_" + name
 }
 
+// ResourceNamingMode determines the names given to the resources of a Stack.
+type ResourceNamingMode string
+
+const (
+	// ResourceNamingExact creates resources with exactly the names given in
+	// the StackSpec. This is the default.
+	ResourceNamingExact ResourceNamingMode = ""
+
+	// ResourceNamingNamespaced creates resources named <stack>_<name>, the
+	// same way `docker stack deploy` does, so that several stacks can
+	// define resources with the same name.
+	ResourceNamingNamespaced ResourceNamingMode = "namespaced"
+)
+
 // StackCreateOptions is input to the Create operation for a Stack
 type StackCreateOptions struct {
 	EncodedRegistryAuth string
+
+	// Orchestrator is the orchestrator the stack is deployed on. Stacks
+	// without one are deployed on the default orchestrator of the server.
+	Orchestrator OrchestratorChoice
+
+	// Cluster is the name of the cluster the stack is deployed on, for
+	// servers federating several clusters. Stacks without one are deployed
+	// on the default cluster of the federation.
+	Cluster string
+}
+
+// StackCreateRequest is the body of a request to create a Stack: a
+// StackSpec, along with the orchestrator and the cluster to deploy it on.
+type StackCreateRequest struct {
+	StackSpec
+	Orchestrator OrchestratorChoice `json:",omitempty"`
+	Cluster      string             `json:",omitempty"`
 }
 
 // StackUpdateOptions is input to the Update operation for a Stack
@@ -44,11 +125,60 @@ type StackUpdateOptions struct {
 	EncodedRegistryAuth string
 }
 
+// StackPatchType is the media type of a patch to a StackSpec
+type StackPatchType string
+
+const (
+	// StackPatchMerge is a JSON Merge Patch, as defined by RFC 7386
+	StackPatchMerge StackPatchType = "application/merge-patch+json"
+
+	// StackPatchJSON is a JSON Patch, as defined by RFC 6902
+	StackPatchJSON StackPatchType = "application/json-patch+json"
+)
+
+// StackScaleRequest is the body of a request to scale services of a Stack.
+type StackScaleRequest struct {
+	// Services maps the names of services in the StackSpec to their
+	// number of replicas.
+	Services map[string]uint64
+}
+
+// StackServiceScaleRequest is the body of a request to scale a single
+// service of a Stack.
+type StackServiceScaleRequest struct {
+	Replicas *uint64
+}
+
 // StackListOptions is input to the List operation for a Stack
 type StackListOptions struct {
+	// Filters restricts the listed stacks. The accepted filters are
+	// "name", "id", "label", "orchestrator", "phase" and "collection".
 	Filters filters.Args
+	// SortBy is the field by which stacks are ordered, by name if empty.
+	SortBy StackSortField
+	// Descending reverses the order of the stacks.
+	Descending bool
+	// Limit is the maximum number of stacks returned, or 0 for all of them.
+	Limit int
+	// Cursor resumes a listing after the last stack of a previous page.
+	// It is an opaque value obtained from query.NextCursor.
+	Cursor string
 }
 
+// StackSortField is a field by which stacks can be ordered when listed.
+type StackSortField string
+
+const (
+	// StackSortName orders stacks by name
+	StackSortName StackSortField = "name"
+
+	// StackSortCreated orders stacks by creation time
+	StackSortCreated StackSortField = "created"
+
+	// StackSortUpdated orders stacks by time of last update
+	StackSortUpdated StackSortField = "updated"
+)
+
 // Version represents the internal object version.
 type Version struct {
 	Index uint64 `json:",omitempty"`
@@ -57,6 +187,17 @@ type Version struct {
 // StackList is the output for Stack listing
 type StackList struct {
 	Items []Stack `json:"items"`
+	// Warnings reports the backends whose stacks could not be listed, and
+	// are missing from Items.
+	Warnings []StackListWarning `json:"warnings,omitempty"`
+}
+
+// StackListWarning reports a backend whose stacks could not be listed,
+// either the backend of an orchestrator or a cluster of a federation.
+type StackListWarning struct {
+	Orchestrator OrchestratorChoice `json:"orchestrator,omitempty"`
+	Cluster      string             `json:"cluster,omitempty"`
+	Message      string             `json:"message"`
 }
 
 // OrchestratorChoice This field specifies which orchestrator the stack is deployed on.
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"time"
+)
+
+// StackDependsOnLabel is a label on the services of a StackSpec listing the
+// names, separated by commas, of the services of the stack that they depend
+// on. Rolling updates update a service after the services it depends on.
+const StackDependsOnLabel = "com.docker.stacks.depends_on"
+
+const (
+	// StackPhaseUpdating is the phase of a Stack whose services are being
+	// rolled out.
+	StackPhaseUpdating StackPhase = "updating"
+
+	// StackPhaseUpdated is the phase of a Stack whose services were all
+	// rolled out and became healthy.
+	StackPhaseUpdated StackPhase = "updated"
+
+	// StackPhaseRolledBack is the phase of a Stack whose rollout failed and
+	// whose updated services were reverted to their previous spec.
+	StackPhaseRolledBack StackPhase = "rolled_back"
+)
+
+// StackUpdateConfig configures the rolling update of the services of a
+// Stack. Services are updated in batches, in dependency order, and the tasks
+// of each batch must become healthy before the next batch is updated. If
+// they do not, every service updated so far is reverted to its previous
+// spec.
+type StackUpdateConfig struct {
+	// Parallelism is the number of services updated at once. Zero updates
+	// one service at a time.
+	Parallelism uint64 `json:",omitempty"`
+
+	// Monitor is how long the tasks of a batch are given to become
+	// healthy. Zero waits for DefaultStackUpdateMonitor.
+	Monitor time.Duration `json:",omitempty"`
+
+	// MaxFailureRatio is the fraction of the tasks of a batch which may
+	// fail without rolling back the update.
+	MaxFailureRatio float32 `json:",omitempty"`
+}
+
+// DefaultStackUpdateMonitor is the Monitor of a StackUpdateConfig which does
+// not set one.
+const DefaultStackUpdateMonitor = 30 * time.Second
+
+// StackDeploymentLabel is a label on the services created alongside a
+// service of a Stack while it is deployed with a canary or blue/green
+// StackDeployStrategy. Its value is the StackDeployMode.
+const StackDeploymentLabel = "com.docker.stacks.deployment"
+
+// StackDeployMode selects how a service of a Stack is deployed.
+type StackDeployMode string
+
+const (
+	// StackDeployInPlace updates the service itself. This is the default.
+	StackDeployInPlace StackDeployMode = ""
+
+	// StackDeployCanary runs a <name>-canary service with the new spec
+	// alongside the service, sharing its network aliases but not its
+	// published ports. The service is updated once the canary stayed
+	// healthy for the analysis window, and the canary is removed.
+	StackDeployCanary StackDeployMode = "canary"
+
+	// StackDeployBlueGreen brings up a <name>-green service with the new
+	// spec on the same networks, without published ports or network
+	// aliases. Once it stayed healthy for the analysis window, the published
+	// ports and aliases are switched over to it while the service itself is
+	// updated, and switched back once the service is healthy.
+	StackDeployBlueGreen StackDeployMode = "blue-green"
+)
+
+// StackDeployStrategy configures how a service of a Stack is deployed.
+type StackDeployStrategy struct {
+	Mode StackDeployMode `json:",omitempty"`
+
+	// CanaryReplicas is the number of replicas of the canary service. Zero
+	// runs a single replica.
+	CanaryReplicas uint64 `json:",omitempty"`
+
+	// AnalysisWindow is how long the canary or green service must stay
+	// healthy. Zero waits for DefaultStackUpdateMonitor.
+	AnalysisWindow time.Duration `json:",omitempty"`
+
+	// MaxFailureRatio is the fraction of the tasks of the canary or green
+	// service which may fail without aborting the deployment.
+	MaxFailureRatio float32 `json:",omitempty"`
+}
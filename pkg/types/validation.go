@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StackValidationIssue is a problem found in a StackSpec. Field is the path
+// of the offending field, such as
+// "Services[0].TaskTemplate.ContainerSpec.Secrets[1].SecretName", and is
+// empty for problems concerning the StackSpec as a whole.
+type StackValidationIssue struct {
+	Field   string
+	Message string
+}
+
+// String returns a string representation of a StackValidationIssue
+func (i StackValidationIssue) String() string {
+	if i.Field == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.Field, i.Message)
+}
+
+// StackValidationResult is the outcome of the validation of a StackSpec. A
+// StackSpec with Errors is rejected, Warnings are informational.
+type StackValidationResult struct {
+	Valid    bool
+	Errors   []StackValidationIssue `json:",omitempty"`
+	Warnings []StackValidationIssue `json:",omitempty"`
+}
+
+// StackValidationError is the error returned when a Stack is created or
+// updated with a StackSpec which failed validation.
+type StackValidationError struct {
+	Result StackValidationResult
+}
+
+// Error returns a string representation of a StackValidationError
+func (e StackValidationError) Error() string {
+	issues := make([]string, 0, len(e.Result.Errors))
+	for _, issue := range e.Result.Errors {
+		issues = append(issues, issue.String())
+	}
+	return fmt.Sprintf("invalid stack specification: %s", strings.Join(issues, "; "))
+}
+
+// InvalidParameter makes StackValidationError an errdefs.ErrInvalidParameter
+func (e StackValidationError) InvalidParameter() {}
+
+// StackValidationErrorResponse is the body of the response to a request
+// which failed with a StackValidationError.
+type StackValidationErrorResponse struct {
+	Message string `json:"message"`
+	StackValidationResult
+}
+
+// StackPolicyReport is the evaluation of the policy rules of a cluster over
+// its existing Stacks, which were possibly created before the rules.
+type StackPolicyReport struct {
+	Stacks []StackPolicyResult
+}
+
+// StackPolicyResult is the evaluation of the policy rules over a Stack. A
+// Stack is compliant when it violates no enforced rule.
+type StackPolicyResult struct {
+	ID        string
+	Name      string
+	Compliant bool
+	Errors    []StackValidationIssue `json:",omitempty"`
+	Warnings  []StackValidationIssue `json:",omitempty"`
+}
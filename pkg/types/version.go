@@ -0,0 +1,56 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StackVersionConflict is the error returned when a Stack is updated or
+// deleted at a version other than its current one, which means that it was
+// changed since the caller last read it.
+type StackVersionConflict struct {
+	ID      string
+	Current Version
+}
+
+// Error returns a string representation of a StackVersionConflict
+func (e StackVersionConflict) Error() string {
+	return fmt.Sprintf("update out of sequence: stack %s is at version %d", e.ID, e.Current.Index)
+}
+
+// Conflict makes StackVersionConflict an errdefs.ErrConflict
+func (e StackVersionConflict) Conflict() {}
+
+// StackConflictResponse is the body of the response to a request which
+// failed with a StackVersionConflict. The request may be retried against
+// CurrentVersion once the changes it conflicted with have been reviewed.
+type StackConflictResponse struct {
+	Message        string `json:"message"`
+	ID             string
+	CurrentVersion Version
+}
+
+// StackETag returns the HTTP entity tag of a Stack at version.
+func StackETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// ParseStackETag returns the version of a Stack from its strong HTTP entity
+// tag. Weak entity tags are rejected, as If-Match compares entity tags with
+// the strong comparison function of RFC 7232.
+func ParseStackETag(etag string) (uint64, error) {
+	trimmed := strings.TrimSpace(etag)
+	if strings.HasPrefix(trimmed, "W/") {
+		return 0, fmt.Errorf("weak stack entity tag '%s' cannot be matched", etag)
+	}
+	unquoted, err := strconv.Unquote(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stack entity tag '%s'", etag)
+	}
+	version, err := strconv.ParseUint(unquoted, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stack entity tag '%s'", etag)
+	}
+	return version, nil
+}
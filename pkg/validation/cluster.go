@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// checkReferences reports the networks, secrets and configs used by the
+// services of spec which are neither part of the stack nor present in the
+// swarm.
+func (v *Validator) checkReferences(r *report, spec types.StackSpec) {
+	secrets := map[string]bool{}
+	for _, secret := range spec.Secrets {
+		secrets[secret.Annotations.Name] = true
+	}
+	configs := map[string]bool{}
+	for _, config := range spec.Configs {
+		configs[config.Annotations.Name] = true
+	}
+
+	for i, service := range spec.Services {
+		field := fmt.Sprintf("Services[%d]", i)
+		for j, attachment := range service.TaskTemplate.Networks {
+			if _, ok := spec.Networks[attachment.Target]; !ok {
+				v.checkExternal(r, fmt.Sprintf("%s.TaskTemplate.Networks[%d].Target", field, j), "network", attachment.Target)
+			}
+		}
+		for j, attachment := range service.Networks {
+			if _, ok := spec.Networks[attachment.Target]; !ok {
+				v.checkExternal(r, fmt.Sprintf("%s.Networks[%d].Target", field, j), "network", attachment.Target)
+			}
+		}
+
+		containerSpec := service.TaskTemplate.ContainerSpec
+		if containerSpec == nil {
+			continue
+		}
+		for j, secret := range containerSpec.Secrets {
+			if !secrets[secret.SecretName] {
+				v.checkExternal(r, fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Secrets[%d].SecretName", field, j), "secret", secret.SecretName)
+			}
+		}
+		for j, config := range containerSpec.Configs {
+			if !configs[config.ConfigName] {
+				v.checkExternal(r, fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Configs[%d].ConfigName", field, j), "config", config.ConfigName)
+			}
+		}
+	}
+}
+
+// checkExternal looks up a resource which a service uses but the stack
+// does not define. Resources found in the swarm are only reported as
+// warnings, as their lifecycle is not managed by the stack.
+func (v *Validator) checkExternal(r *report, field, kind, name string) {
+	found, err := v.lookup(kind, name)
+	switch {
+	case err != nil && errdefs.IsNotFound(err):
+		r.errorf(field, "%s '%s' is neither defined in the stack nor present in the swarm", kind, name)
+	case err != nil:
+		r.warnf(field, "%s '%s' is not defined in the stack, and could not be looked up in the swarm: %s", kind, name, err)
+	case !found:
+		r.errorf(field, "%s '%s' is not defined in the stack", kind, name)
+	default:
+		r.warnf(field, "%s '%s' is not defined in the stack, the existing one will be used", kind, name)
+	}
+}
+
+// lookup returns whether a resource exists in the swarm. It returns false
+// without an error when the backend cannot look up that kind of resource.
+func (v *Validator) lookup(kind, name string) (bool, error) {
+	var err error
+	switch kind {
+	case "network":
+		backend, ok := v.backend.(interfaces.SwarmNetworkBackend)
+		if !ok {
+			return false, nil
+		}
+		_, err = backend.GetNetwork(name)
+	case "secret":
+		backend, ok := v.backend.(interfaces.SwarmSecretBackend)
+		if !ok {
+			return false, nil
+		}
+		_, err = backend.GetSecret(name)
+	case "config":
+		backend, ok := v.backend.(interfaces.SwarmConfigBackend)
+		if !ok {
+			return false, nil
+		}
+		_, err = backend.GetConfig(name)
+	default:
+		return false, fmt.Errorf("unknown resource kind %s", kind)
+	}
+	return err == nil, err
+}
+
+// checkConstraints warns about placement constraints requiring nodes which
+// are not part of the swarm, as the tasks of the service would never be
+// scheduled.
+func (v *Validator) checkConstraints(r *report, spec types.StackSpec) {
+	if v.backend == nil {
+		return
+	}
+	for i, service := range spec.Services {
+		placement := service.TaskTemplate.Placement
+		if placement == nil {
+			continue
+		}
+		for j, constraint := range placement.Constraints {
+			key, operator, value, ok := parseConstraint(constraint)
+			if !ok || key != "node.id" || operator != "==" {
+				continue
+			}
+			if _, err := v.backend.GetNode(value); err != nil && errdefs.IsNotFound(err) {
+				r.warnf(fmt.Sprintf("Services[%d].TaskTemplate.Placement.Constraints[%d]", i, j), "node '%s' is not part of the swarm, tasks will not be scheduled", value)
+			}
+		}
+	}
+}
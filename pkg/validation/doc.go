@@ -0,0 +1,12 @@
+package validation
+
+// The `validation` package checks StackSpecs before they are stored, so
+// that problems are reported to the user when a stack is created or
+// updated, rather than when swarmkit rejects one of its resources in the
+// middle of a reconciliation. Each problem is addressed by the path of the
+// field it concerns.
+//
+// References to networks, secrets and configs which are not part of a
+// stack are resolved against the live swarm, as are placement constraints
+// naming specific nodes. Those checks are skipped when the Validator has no
+// SwarmResourceBackend.
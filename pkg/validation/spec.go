@@ -0,0 +1,276 @@
+package validation
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// maxPort is the highest valid TCP, UDP or SCTP port
+const maxPort = 65535
+
+// checkSpec performs the checks which only depend on the StackSpec itself.
+func checkSpec(r *report, spec types.StackSpec) {
+	if spec.Annotations.Name == "" {
+		r.errorf("Annotations.Name", "StackSpec contains no name")
+	}
+
+	switch spec.ResourceNaming {
+	case types.ResourceNamingExact, types.ResourceNamingNamespaced:
+	default:
+		r.errorf("ResourceNaming", "unknown resource naming mode '%s'", spec.ResourceNaming)
+	}
+
+	names := map[string]int{}
+	for i, service := range spec.Services {
+		checkName(r, names, fmt.Sprintf("Services[%d].Name", i), "service", service.Annotations.Name)
+		checkService(r, fmt.Sprintf("Services[%d]", i), service)
+	}
+	names = map[string]int{}
+	for i, secret := range spec.Secrets {
+		checkName(r, names, fmt.Sprintf("Secrets[%d].Name", i), "secret", secret.Annotations.Name)
+		if len(secret.Data) == 0 && secret.Driver == nil {
+			r.errorf(fmt.Sprintf("Secrets[%d].Data", i), "secret has neither data nor a driver")
+		}
+	}
+	names = map[string]int{}
+	for i, config := range spec.Configs {
+		checkName(r, names, fmt.Sprintf("Configs[%d].Name", i), "config", config.Annotations.Name)
+	}
+
+	checkPublishedPorts(r, spec.Services)
+	checkDependencies(r, spec.Services)
+	checkUnused(r, spec)
+}
+
+// checkDependencies reports services which depend, through their
+// types.StackDependsOnLabel, on services which are not part of the stack.
+func checkDependencies(r *report, services []swarm.ServiceSpec) {
+	names := map[string]bool{}
+	for _, service := range services {
+		names[service.Annotations.Name] = true
+	}
+	for i, service := range services {
+		for _, dependency := range strings.Split(service.Annotations.Labels[types.StackDependsOnLabel], ",") {
+			dependency = strings.TrimSpace(dependency)
+			if dependency != "" && !names[dependency] {
+				r.errorf(fmt.Sprintf("Services[%d].Labels[%s]", i, types.StackDependsOnLabel), "service depends on unknown service '%s'", dependency)
+			}
+		}
+	}
+}
+
+// checkName reports empty names, and names already present in names.
+func checkName(r *report, names map[string]int, field, kind, name string) {
+	if name == "" {
+		r.errorf(field, "%s has no name", kind)
+		return
+	}
+	if count := names[name]; count > 0 {
+		r.errorf(field, "duplicate %s name '%s'", kind, name)
+	}
+	names[name]++
+}
+
+func checkService(r *report, field string, service swarm.ServiceSpec) {
+	if service.Mode.Replicated != nil && service.Mode.Global != nil {
+		r.errorf(field+".Mode", "service cannot be both replicated and global")
+	}
+
+	if containerSpec := service.TaskTemplate.ContainerSpec; containerSpec != nil {
+		checkImage(r, field+".TaskTemplate.ContainerSpec.Image", containerSpec.Image)
+		targets := map[string]int{}
+		for i, m := range containerSpec.Mounts {
+			mountField := fmt.Sprintf("%s.TaskTemplate.ContainerSpec.Mounts[%d]", field, i)
+			checkMount(r, mountField, m)
+			if m.Target != "" {
+				if count := targets[path.Clean(m.Target)]; count > 0 {
+					r.errorf(mountField+".Target", "duplicate mount point '%s'", m.Target)
+				}
+				targets[path.Clean(m.Target)]++
+			}
+		}
+	}
+
+	if placement := service.TaskTemplate.Placement; placement != nil {
+		for i, constraint := range placement.Constraints {
+			if _, _, _, ok := parseConstraint(constraint); !ok {
+				r.errorf(fmt.Sprintf("%s.TaskTemplate.Placement.Constraints[%d]", field, i), "invalid constraint '%s'", constraint)
+			}
+		}
+	}
+
+	if service.EndpointSpec != nil {
+		for i, port := range service.EndpointSpec.Ports {
+			checkPort(r, fmt.Sprintf("%s.EndpointSpec.Ports[%d]", field, i), port)
+		}
+	}
+}
+
+func checkImage(r *report, field, image string) {
+	if image == "" {
+		r.errorf(field, "service has no image")
+		return
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		r.errorf(field, "invalid image reference '%s': %s", image, err)
+		return
+	}
+	if reference.IsNameOnly(named) {
+		r.warnf(field, "image '%s' has no tag, 'latest' will be used", image)
+	}
+}
+
+func checkMount(r *report, field string, m mount.Mount) {
+	if m.Target == "" {
+		r.errorf(field+".Target", "mount has no target")
+	} else if m.Type != mount.TypeNamedPipe && !path.IsAbs(m.Target) {
+		r.errorf(field+".Target", "mount target '%s' is not an absolute path", m.Target)
+	}
+
+	switch m.Type {
+	case mount.TypeBind:
+		if m.Source == "" {
+			r.errorf(field+".Source", "bind mount has no source")
+		} else if !path.IsAbs(m.Source) {
+			r.errorf(field+".Source", "bind mount source '%s' is not an absolute path", m.Source)
+		}
+	case mount.TypeVolume:
+	case mount.TypeTmpfs:
+		if m.Source != "" {
+			r.errorf(field+".Source", "tmpfs mount cannot have a source")
+		}
+	case mount.TypeNamedPipe:
+		if m.Source == "" {
+			r.errorf(field+".Source", "named pipe mount has no source")
+		}
+	default:
+		r.errorf(field+".Type", "unknown mount type '%s'", m.Type)
+		return
+	}
+
+	if m.BindOptions != nil && m.Type != mount.TypeBind {
+		r.errorf(field+".BindOptions", "bind options cannot be set on a %s mount", m.Type)
+	}
+	if m.VolumeOptions != nil && m.Type != mount.TypeVolume {
+		r.errorf(field+".VolumeOptions", "volume options cannot be set on a %s mount", m.Type)
+	}
+	if m.TmpfsOptions != nil && m.Type != mount.TypeTmpfs {
+		r.errorf(field+".TmpfsOptions", "tmpfs options cannot be set on a %s mount", m.Type)
+	}
+}
+
+func checkPort(r *report, field string, port swarm.PortConfig) {
+	switch port.Protocol {
+	case "", swarm.PortConfigProtocolTCP, swarm.PortConfigProtocolUDP, swarm.PortConfigProtocolSCTP:
+	default:
+		r.errorf(field+".Protocol", "unknown protocol '%s'", port.Protocol)
+	}
+	switch port.PublishMode {
+	case "", swarm.PortConfigPublishModeIngress, swarm.PortConfigPublishModeHost:
+	default:
+		r.errorf(field+".PublishMode", "unknown publish mode '%s'", port.PublishMode)
+	}
+	if port.TargetPort == 0 || port.TargetPort > maxPort {
+		r.errorf(field+".TargetPort", "target port %d is out of range 1-%d", port.TargetPort, maxPort)
+	}
+	if port.PublishedPort > maxPort {
+		r.errorf(field+".PublishedPort", "published port %d is out of range 1-%d", port.PublishedPort, maxPort)
+	}
+}
+
+// checkPublishedPorts reports ports published by more than one service of
+// the stack, which swarmkit would refuse to allocate.
+func checkPublishedPorts(r *report, services []swarm.ServiceSpec) {
+	published := map[string]string{}
+	for i, service := range services {
+		if service.EndpointSpec == nil {
+			continue
+		}
+		for j, port := range service.EndpointSpec.Ports {
+			if port.PublishedPort == 0 || port.PublishMode == swarm.PortConfigPublishModeHost {
+				continue
+			}
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = swarm.PortConfigProtocolTCP
+			}
+			key := fmt.Sprintf("%d/%s", port.PublishedPort, protocol)
+			if other, ok := published[key]; ok {
+				r.errorf(fmt.Sprintf("Services[%d].EndpointSpec.Ports[%d].PublishedPort", i, j), "port %s is already published by service '%s'", key, other)
+				continue
+			}
+			published[key] = service.Annotations.Name
+		}
+	}
+}
+
+// checkUnused warns about networks, secrets and configs of the stack which
+// none of its services use.
+func checkUnused(r *report, spec types.StackSpec) {
+	used := map[string]bool{}
+	for _, service := range spec.Services {
+		for _, target := range networkTargets(service) {
+			used["network/"+target] = true
+		}
+		if containerSpec := service.TaskTemplate.ContainerSpec; containerSpec != nil {
+			for _, secret := range containerSpec.Secrets {
+				used["secret/"+secret.SecretName] = true
+			}
+			for _, config := range containerSpec.Configs {
+				used["config/"+config.ConfigName] = true
+			}
+		}
+	}
+
+	for name := range spec.Networks {
+		if !used["network/"+name] {
+			r.warnf(fmt.Sprintf("Networks[%s]", name), "network '%s' is not used by any service", name)
+		}
+	}
+	for i, secret := range spec.Secrets {
+		if !used["secret/"+secret.Annotations.Name] {
+			r.warnf(fmt.Sprintf("Secrets[%d]", i), "secret '%s' is not used by any service", secret.Annotations.Name)
+		}
+	}
+	for i, config := range spec.Configs {
+		if !used["config/"+config.Annotations.Name] {
+			r.warnf(fmt.Sprintf("Configs[%d]", i), "config '%s' is not used by any service", config.Annotations.Name)
+		}
+	}
+}
+
+// networkTargets returns the networks a service is attached to, through
+// either of the fields of a ServiceSpec which attach it.
+func networkTargets(service swarm.ServiceSpec) []string {
+	targets := []string{}
+	for _, attachment := range service.TaskTemplate.Networks {
+		targets = append(targets, attachment.Target)
+	}
+	for _, attachment := range service.Networks {
+		targets = append(targets, attachment.Target)
+	}
+	return targets
+}
+
+// parseConstraint splits a placement constraint, such as
+// "node.role==manager", into its key, operator and value.
+func parseConstraint(constraint string) (string, string, string, bool) {
+	for _, operator := range []string{"==", "!="} {
+		if parts := strings.SplitN(constraint, operator, 2); len(parts) == 2 {
+			key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			if key == "" || value == "" {
+				return "", "", "", false
+			}
+			return key, operator, value, true
+		}
+	}
+	return "", "", "", false
+}
@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Validator validates StackSpecs.
+type Validator struct {
+	backend  interfaces.SwarmResourceBackend
+	checkers []Checker
+}
+
+// Checker performs additional checks of StackSpecs, such as the rules of a
+// policy.Engine.
+type Checker interface {
+	Check(spec types.StackSpec) types.StackValidationResult
+}
+
+// ValidatorOptionFunc is the type used for functional arguments of the
+// Validator during its creation.
+type ValidatorOptionFunc func(*Validator)
+
+// WithChecker is a ValidatorOptionFunc which adds the issues found by
+// checker to those of the Validator.
+func WithChecker(checker Checker) ValidatorOptionFunc {
+	return func(v *Validator) {
+		v.checkers = append(v.checkers, checker)
+	}
+}
+
+// NewValidator creates a new Validator. The backend is used to look up
+// resources in the live swarm, and may be nil.
+func NewValidator(backend interfaces.SwarmResourceBackend, optsFunc ...ValidatorOptionFunc) *Validator {
+	v := &Validator{
+		backend: backend,
+	}
+
+	for _, f := range optsFunc {
+		f(v)
+	}
+
+	return v
+}
+
+// Validate returns the errors and warnings found in spec.
+func (v *Validator) Validate(spec types.StackSpec) types.StackValidationResult {
+	r := &report{}
+	checkSpec(r, spec)
+	v.checkReferences(r, spec)
+	v.checkConstraints(r, spec)
+	for _, checker := range v.checkers {
+		checked := checker.Check(spec)
+		r.result.Errors = append(r.result.Errors, checked.Errors...)
+		r.result.Warnings = append(r.result.Warnings, checked.Warnings...)
+	}
+
+	r.result.Valid = len(r.result.Errors) == 0
+	return r.result
+}
+
+// Error returns a types.StackValidationError if spec is invalid, and nil
+// otherwise.
+func (v *Validator) Error(spec types.StackSpec) error {
+	result := v.Validate(spec)
+	if !result.Valid {
+		return types.StackValidationError{Result: result}
+	}
+	return nil
+}
+
+// report accumulates the issues found in a StackSpec
+type report struct {
+	result types.StackValidationResult
+}
+
+func (r *report) errorf(field, format string, args ...interface{}) {
+	r.result.Errors = append(r.result.Errors, types.StackValidationIssue{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *report) warnf(field, format string, args ...interface{}) {
+	r.result.Warnings = append(r.result.Warnings, types.StackValidationIssue{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func getTestSpec() types.StackSpec {
+	return types.StackSpec{
+		Annotations: swarm.Annotations{
+			Name: "app",
+		},
+		Services: []swarm.ServiceSpec{
+			{
+				Annotations: swarm.Annotations{Name: "web"},
+				TaskTemplate: swarm.TaskSpec{
+					ContainerSpec: &swarm.ContainerSpec{
+						Image: "nginx:1.17",
+						Secrets: []*swarm.SecretReference{
+							{SecretName: "password"},
+						},
+						Mounts: []mount.Mount{
+							{Type: mount.TypeVolume, Source: "data", Target: "/data"},
+						},
+					},
+					Networks: []swarm.NetworkAttachmentConfig{
+						{Target: "backend"},
+					},
+				},
+				EndpointSpec: &swarm.EndpointSpec{
+					Ports: []swarm.PortConfig{
+						{TargetPort: 80, PublishedPort: 8080},
+					},
+				},
+			},
+		},
+		Networks: map[string]dockerTypes.NetworkCreate{
+			"backend": {Driver: "overlay"},
+		},
+		Secrets: []swarm.SecretSpec{
+			{Annotations: swarm.Annotations{Name: "password"}, Data: []byte("secret")},
+		},
+	}
+}
+
+func fields(issues []types.StackValidationIssue) []string {
+	result := []string{}
+	for _, issue := range issues {
+		result = append(result, issue.Field)
+	}
+	return result
+}
+
+func TestValidSpec(t *testing.T) {
+	result := NewValidator(nil).Validate(getTestSpec())
+	require.True(t, result.Valid)
+	require.Empty(t, result.Errors)
+	require.Empty(t, result.Warnings)
+	require.NoError(t, NewValidator(nil).Error(getTestSpec()))
+}
+
+func TestInvalidSpec(t *testing.T) {
+	require := require.New(t)
+
+	spec := getTestSpec()
+	spec.Annotations.Name = ""
+	web := spec.Services[0]
+	containerSpec := *web.TaskTemplate.ContainerSpec
+	containerSpec.Image = "nginx"
+	containerSpec.Mounts = []mount.Mount{
+		{Type: mount.TypeBind, Source: "relative", Target: "/data"},
+		{Type: mount.TypeTmpfs, Target: "/data"},
+	}
+	web.TaskTemplate.ContainerSpec = &containerSpec
+	web.EndpointSpec = &swarm.EndpointSpec{
+		Ports: []swarm.PortConfig{
+			{TargetPort: 80, PublishedPort: 70000},
+		},
+	}
+	web.TaskTemplate.Placement = &swarm.Placement{
+		Constraints: []string{"node.role"},
+	}
+	api := getTestSpec().Services[0]
+	api.Annotations.Name = "api"
+	api.Annotations.Labels = map[string]string{types.StackDependsOnLabel: "web, queue"}
+	spec.Services = []swarm.ServiceSpec{web, api, api}
+
+	result := NewValidator(nil).Validate(spec)
+	require.False(result.Valid)
+	require.Equal([]string{
+		"Annotations.Name",
+		"Services[0].TaskTemplate.ContainerSpec.Mounts[0].Source",
+		"Services[0].TaskTemplate.ContainerSpec.Mounts[1].Target",
+		"Services[0].TaskTemplate.Placement.Constraints[0]",
+		"Services[0].EndpointSpec.Ports[0].PublishedPort",
+		"Services[2].Name",
+		"Services[2].EndpointSpec.Ports[0].PublishedPort",
+		"Services[1].Labels[com.docker.stacks.depends_on]",
+		"Services[2].Labels[com.docker.stacks.depends_on]",
+	}, fields(result.Errors))
+	require.Equal([]string{
+		"Services[0].TaskTemplate.ContainerSpec.Image",
+	}, fields(result.Warnings))
+
+	err := NewValidator(nil).Error(spec)
+	require.Error(err)
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "StackSpec contains no name")
+}
+
+func TestExternalReferences(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+
+	spec := getTestSpec()
+	spec.Secrets = nil
+	spec.Networks = nil
+	spec.Services[0].TaskTemplate.ContainerSpec.Configs = []*swarm.ConfigReference{
+		{ConfigName: "settings"},
+	}
+	spec.Services[0].TaskTemplate.Placement = &swarm.Placement{
+		Constraints: []string{"node.id==gone", "node.role==manager"},
+	}
+
+	// Without a swarm, the references cannot be resolved
+	result := NewValidator(nil).Validate(spec)
+	require.Equal([]string{
+		"Services[0].TaskTemplate.Networks[0].Target",
+		"Services[0].TaskTemplate.ContainerSpec.Secrets[0].SecretName",
+		"Services[0].TaskTemplate.ContainerSpec.Configs[0].ConfigName",
+	}, fields(result.Errors))
+
+	backend.EXPECT().GetNetwork("backend").Return(dockerTypes.NetworkResource{Name: "backend"}, nil)
+	backend.EXPECT().GetSecret("password").Return(swarm.Secret{}, errdefs.NotFound(errors.New("not found")))
+	backend.EXPECT().GetConfig("settings").Return(swarm.Config{}, errors.New("connection refused"))
+	backend.EXPECT().GetNode("gone").Return(swarm.Node{}, errdefs.NotFound(errors.New("not found")))
+
+	result = NewValidator(backend).Validate(spec)
+	require.False(result.Valid)
+	require.Equal([]string{
+		"Services[0].TaskTemplate.ContainerSpec.Secrets[0].SecretName",
+	}, fields(result.Errors))
+	require.Equal([]string{
+		"Services[0].TaskTemplate.Networks[0].Target",
+		"Services[0].TaskTemplate.ContainerSpec.Configs[0].ConfigName",
+		"Services[0].TaskTemplate.Placement.Constraints[0]",
+	}, fields(result.Warnings))
+}